@@ -0,0 +1,158 @@
+package id3
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Title returns the song title stored in the tag's TIT2 frame, or the empty
+// string if there is none.
+func (t *Tag) Title() string {
+	return firstText(t, FrameTypeTextSongTitle)
+}
+
+// SetTitle sets the song title stored in the tag's TIT2 frame, adding the
+// frame if necessary.
+func (t *Tag) SetTitle(title string) {
+	t.setTextValues(FrameTypeTextSongTitle, title)
+}
+
+// Artist returns the first lead artist stored in the tag's TPE1 frame, or
+// the empty string if there is none.
+func (t *Tag) Artist() string {
+	if a := t.Artists(); len(a) > 0 {
+		return a[0]
+	}
+	return ""
+}
+
+// Artists returns all lead artists stored in the tag's TPE1 frame, one per
+// text value. The slash-separated lists stored by earlier versions aren't
+// split, since slashes may be part of an artist's name, as in "AC/DC".
+func (t *Tag) Artists() []string {
+	return Summarize(t).Artists
+}
+
+// SetArtist sets the lead artists stored in the tag's TPE1 frame, adding the
+// frame if necessary. Multiple artists are stored as separate strings in
+// v2.4 tags and as a slash-separated list in earlier versions.
+func (t *Tag) SetArtist(artists ...string) {
+	t.setTextValues(FrameTypeTextArtist, artists...)
+}
+
+// Album returns the album name stored in the tag's TALB frame, or the empty
+// string if there is none.
+func (t *Tag) Album() string {
+	return firstText(t, FrameTypeTextAlbumName)
+}
+
+// SetAlbum sets the album name stored in the tag's TALB frame, adding the
+// frame if necessary.
+func (t *Tag) SetAlbum(album string) {
+	t.setTextValues(FrameTypeTextAlbumName, album)
+}
+
+// Year returns the recording year stored in the tag's TDRC (v2.4) or TYER
+// (v2.3) frame, or 0 if there is none.
+func (t *Tag) Year() int {
+	return Summarize(t).Year
+}
+
+// SetYear sets the recording year stored in the tag's TDRC (v2.4) or TYER
+// (v2.3) frame, adding the frame if necessary. Any month, day or time
+// already stored in a TDRC frame is preserved.
+func (t *Tag) SetYear(year int) {
+	y := strconv.Itoa(year)
+	for len(y) < 4 {
+		y = "0" + y
+	}
+	if s := firstText(t, FrameTypeTextRecordingTime); len(s) > 4 && t.Version >= Version2_4 {
+		y += s[4:]
+	}
+	t.setTextValues(FrameTypeTextRecordingTime, y)
+}
+
+// Track returns the track number stored in the tag's TRCK frame. Values
+// that can't be parsed are returned as 0.
+func (t *Tag) Track() TrackNumber {
+	tn, _ := ParseTrackNumber(firstText(t, FrameTypeTextTrackNumber))
+	return tn
+}
+
+// SetTrack sets the track number stored in the tag's TRCK frame, adding
+// the frame if necessary.
+func (t *Tag) SetTrack(tn TrackNumber) {
+	t.setTextValues(FrameTypeTextTrackNumber, tn.String())
+}
+
+// Disc returns the disc number stored in the tag's TPOS (part of set)
+// frame. Values that can't be parsed are returned as 0.
+func (t *Tag) Disc() TrackNumber {
+	tn, _ := ParseTrackNumber(firstText(t, FrameTypeTextPartOfSet))
+	return tn
+}
+
+// SetDisc sets the disc number stored in the tag's TPOS (part of set)
+// frame, adding the frame if necessary.
+func (t *Tag) SetDisc(tn TrackNumber) {
+	t.setTextValues(FrameTypeTextPartOfSet, tn.String())
+}
+
+// setTextValues sets the text of the tag's first text frame of the requested
+// type using SetText. Multiple values are joined with slashes in tags older
+// than v2.4.
+func (t *Tag) setTextValues(typ FrameType, values ...string) *FrameText {
+	if len(values) > 1 && t.Version < Version2_4 {
+		values = []string{strings.Join(values, "/")}
+	}
+	return t.SetText(typ, values...)
+}
+
+// CoverArt returns the tag's first front cover attached picture (APIC)
+// frame, or nil if there is none. Linked pictures are ignored.
+func (t *Tag) CoverArt() *FrameAttachedPicture {
+	for _, f := range t.FindFrames(FrameTypeAttachedPicture) {
+		if p, ok := f.(*FrameAttachedPicture); ok && p.PictureType == PictureTypeCoverFront && !p.IsLink() {
+			return p
+		}
+	}
+	return nil
+}
+
+// SetCoverArt replaces the tag's front cover attached picture (APIC) frames
+// with a single frame containing the image data, and marks the frame as
+// modified. It returns the frame. The MIME type is detected from the
+// image's leading bytes using the registered MIME sniffers, falling back to
+// "application/octet-stream" if the format isn't recognized. The text
+// encoding of the description is selected when the tag is written.
+func (t *Tag) SetCoverArt(data []byte, description string) *FrameAttachedPicture {
+	mimeType := DetectMimeType(data)
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	p := &FrameAttachedPicture{
+		Header:      FrameHeader{FrameType: FrameTypeAttachedPicture},
+		MimeType:    WesternString(mimeType),
+		PictureType: PictureTypeCoverFront,
+		Description: description,
+		Data:        data,
+	}
+
+	pos := -1
+	for i := 0; i < len(t.Frames); i++ {
+		if old, ok := t.Frames[i].(*FrameAttachedPicture); ok && old.PictureType == PictureTypeCoverFront {
+			if pos < 0 {
+				pos = i
+			}
+			t.Frames = append(t.Frames[:i], t.Frames[i+1:]...)
+			i--
+		}
+	}
+
+	t.addDefaulted(p)
+	if pos >= 0 {
+		copy(t.Frames[pos+1:], t.Frames[pos:])
+		t.Frames[pos] = p
+	}
+	return p
+}
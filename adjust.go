@@ -0,0 +1,412 @@
+package id3
+
+import "math"
+
+// A VolumeAdjustment describes the volume change and peak volume of a
+// channel in a legacy relative volume adjustment frame. Change is a
+// fraction of full scale: a change c of a frame using b bits scales the
+// channel's volume by a factor of 1 + c/2^b. Peak is the channel's peak
+// volume, also using b bits.
+type VolumeAdjustment struct {
+	Channel ChannelType
+	Change  int64
+	Peak    uint64
+}
+
+// An EqualizationBand describes the volume adjustment of a frequency band
+// in a legacy equalization frame. Adjustment is a fraction of full scale,
+// as in VolumeAdjustment.
+type EqualizationBand struct {
+	Frequency  uint16 // frequency in Hz, less than 32768
+	Adjustment int64
+}
+
+// legacyChannels lists the channels of a legacy relative volume adjustment
+// frame in the order they are stored. Each entry's increment flag bit is
+// given by its index.
+var legacyChannels = []ChannelType{
+	ChannelFrontRight,
+	ChannelFrontLeft,
+	ChannelBackRight,
+	ChannelBackLeft,
+	ChannelFrontCenter,
+	ChannelSubwoofer,
+}
+
+// legacyGroups holds the number of channels stored by each valid legacy
+// relative volume adjustment layout.
+var legacyGroups = []int{2, 4, 5, 6}
+
+// Adjustments returns the channel adjustments stored in the frame.
+func (f *FrameVolumeAdjustment) Adjustments() ([]VolumeAdjustment, error) {
+	n := (int(f.Bits) + 7) / 8
+	if n == 0 || n > 8 || len(f.Data)%n != 0 {
+		return nil, ErrInvalidFrame
+	}
+	values := make([]uint64, len(f.Data)/n)
+	for i := range values {
+		values[i] = readUint(f.Data[i*n : (i+1)*n])
+	}
+
+	// Volume changes and peaks are stored for the channels in pairs
+	// (right/left) or alone (center, bass), each group's changes
+	// preceding its peaks.
+	var adj []VolumeAdjustment
+	for c, i := 0, 0; i < len(values); {
+		if c >= len(legacyChannels) {
+			return nil, ErrInvalidFrame
+		}
+		size := 1
+		if c < 4 {
+			size = 2
+		}
+		if i+2*size > len(values) {
+			return nil, ErrInvalidFrame
+		}
+		for j := 0; j < size; j++ {
+			a := VolumeAdjustment{
+				Channel: legacyChannels[c+j],
+				Change:  int64(values[i+j]),
+				Peak:    values[i+size+j],
+			}
+			if (f.Increment & (1 << uint(c+j))) == 0 {
+				a.Change = -a.Change
+			}
+			adj = append(adj, a)
+		}
+		c, i = c+size, i+2*size
+	}
+	if !validLegacyCount(len(adj)) {
+		return nil, ErrInvalidFrame
+	}
+	return adj, nil
+}
+
+// SetAdjustments replaces the frame's channel adjustments, encoding them with
+// the requested number of bits. Only the channels supported by legacy
+// frames (front right and left, back right and left, front center and
+// subwoofer) may be adjusted. Channels that are not adjusted but are stored
+// before an adjusted channel are stored with no volume change.
+func (f *FrameVolumeAdjustment) SetAdjustments(bits uint8, adj []VolumeAdjustment) error {
+	n := (int(bits) + 7) / 8
+	if n == 0 || n > 8 {
+		return ErrInvalidBits
+	}
+
+	var values [6]VolumeAdjustment
+	count := 2
+	for _, a := range adj {
+		i := legacyChannelIndex(a.Channel)
+		if i < 0 || !fitsBits(uint64(abs64(a.Change)), bits) || !fitsBits(a.Peak, bits) {
+			return ErrInvalidFrame
+		}
+		values[i] = a
+		for !validLegacyCount(count) || count <= i {
+			count++
+		}
+	}
+
+	var increment uint8
+	var data []byte
+	for c := 0; c < count; {
+		size := 1
+		if c < 4 {
+			size = 2
+		}
+		for j := 0; j < size; j++ {
+			if values[c+j].Change >= 0 {
+				increment |= 1 << uint(c+j)
+			}
+			data = append(data, writeUint(uint64(abs64(values[c+j].Change)), n)...)
+		}
+		for j := 0; j < size; j++ {
+			data = append(data, writeUint(values[c+j].Peak, n)...)
+		}
+		c += size
+	}
+
+	f.Increment, f.Bits, f.Data = increment, bits, data
+	return nil
+}
+
+// Bands returns the equalization bands stored in the frame.
+func (f *FrameEqualization) Bands() ([]EqualizationBand, error) {
+	n := (int(f.Bits) + 7) / 8
+	if n == 0 || n > 8 || len(f.Data)%(n+2) != 0 {
+		return nil, ErrInvalidFrame
+	}
+	bands := make([]EqualizationBand, 0, len(f.Data)/(n+2))
+	for b := f.Data; len(b) > 0; b = b[n+2:] {
+		freq := uint16(b[0])<<8 | uint16(b[1])
+		band := EqualizationBand{
+			Frequency:  freq & 0x7fff,
+			Adjustment: int64(readUint(b[2 : n+2])),
+		}
+		if (freq & 0x8000) == 0 {
+			band.Adjustment = -band.Adjustment
+		}
+		bands = append(bands, band)
+	}
+	return bands, nil
+}
+
+// SetBands replaces the frame's equalization bands, encoding their
+// adjustments with the requested number of bits.
+func (f *FrameEqualization) SetBands(bits uint8, bands []EqualizationBand) error {
+	n := (int(bits) + 7) / 8
+	if n == 0 || n > 8 {
+		return ErrInvalidBits
+	}
+
+	data := make([]byte, 0, len(bands)*(n+2))
+	for _, band := range bands {
+		if band.Frequency > 0x7fff || !fitsBits(uint64(abs64(band.Adjustment)), bits) {
+			return ErrInvalidFrame
+		}
+		freq := band.Frequency
+		if band.Adjustment >= 0 {
+			freq |= 0x8000
+		}
+		data = append(data, byte(freq>>8), byte(freq))
+		data = append(data, writeUint(uint64(abs64(band.Adjustment)), n)...)
+	}
+
+	f.Bits, f.Data = bits, data
+	return nil
+}
+
+// ConvertAdjustments translates the tag's relative volume adjustment and
+// equalization frames to the representation used by version v. Legacy
+// (RVAD and EQUA) frames are replaced by v2.4 (RVA2 and EQU2) frames when
+// converting to v2.4, and v2.4 frames are replaced by legacy frames when
+// converting to earlier versions. Legacy frames created by the conversion
+// use 16 bits per value. Channels that legacy frames don't support are
+// dropped, except that a master volume adjustment applies to the front
+// channels if they aren't adjusted. Frames that can't be converted are
+// removed.
+//
+// The tag's version is not changed.
+func (t *Tag) ConvertAdjustments(v Version) {
+	frames := make([]Frame, 0, len(t.Frames))
+	for _, f := range t.Frames {
+		var cf Frame
+		var err error
+		switch ff := f.(type) {
+		case *FrameVolumeAdjustment:
+			if v < Version2_4 {
+				frames = append(frames, f)
+				continue
+			}
+			cf, err = ff.convert()
+		case *FrameVolumeAdjustment2:
+			if v >= Version2_4 {
+				frames = append(frames, f)
+				continue
+			}
+			cf, err = ff.convert()
+		case *FrameEqualization:
+			if v < Version2_4 {
+				frames = append(frames, f)
+				continue
+			}
+			cf, err = ff.convert()
+		case *FrameEqualization2:
+			if v >= Version2_4 {
+				frames = append(frames, f)
+				continue
+			}
+			cf, err = ff.convert()
+		default:
+			frames = append(frames, f)
+			continue
+		}
+		if err == nil {
+			frames = append(frames, cf)
+			t.MarkDirty(cf)
+		}
+	}
+	t.Frames = frames
+}
+
+// convert converts a legacy relative volume adjustment frame to a v2.4
+// frame.
+func (f *FrameVolumeAdjustment) convert() (*FrameVolumeAdjustment2, error) {
+	adj, err := f.Adjustments()
+	if err != nil {
+		return nil, err
+	}
+	cf := NewFrameVolumeAdjustment2("")
+	for _, a := range adj {
+		cf.Channels = append(cf.Channels, ChannelAdjustment{
+			Channel:    a.Channel,
+			Adjustment: changeToDecibels(a.Change, f.Bits),
+			PeakBits:   f.Bits,
+			Peak:       writeUint(a.Peak, (int(f.Bits)+7)/8),
+		})
+	}
+	return cf, nil
+}
+
+// convert converts a v2.4 relative volume adjustment frame to a legacy
+// frame.
+func (f *FrameVolumeAdjustment2) convert() (*FrameVolumeAdjustment, error) {
+	const bits = 16
+
+	var adj []VolumeAdjustment
+	var master *ChannelAdjustment
+	seen := make(map[ChannelType]bool)
+	for i, c := range f.Channels {
+		if c.Channel == ChannelMaster {
+			master = &f.Channels[i]
+		}
+		if legacyChannelIndex(c.Channel) < 0 || seen[c.Channel] {
+			continue
+		}
+		seen[c.Channel] = true
+		adj = append(adj, legacyAdjustment(c, bits))
+	}
+	if master != nil {
+		for _, ch := range []ChannelType{ChannelFrontRight, ChannelFrontLeft} {
+			if !seen[ch] {
+				a := legacyAdjustment(*master, bits)
+				a.Channel = ch
+				adj = append(adj, a)
+			}
+		}
+	}
+	return NewFrameVolumeAdjustment(bits, adj)
+}
+
+// convert converts a legacy equalization frame to a v2.4 frame.
+func (f *FrameEqualization) convert() (*FrameEqualization2, error) {
+	bands, err := f.Bands()
+	if err != nil {
+		return nil, err
+	}
+	cf := NewFrameEqualization2(InterpolationLinear, "")
+	for _, b := range bands {
+		cf.Points = append(cf.Points, EqualizationPoint{
+			Frequency:  b.Frequency * 2,
+			Adjustment: changeToDecibels(b.Adjustment, f.Bits),
+		})
+	}
+	return cf, nil
+}
+
+// convert converts a v2.4 equalization frame to a legacy frame.
+func (f *FrameEqualization2) convert() (*FrameEqualization, error) {
+	const bits = 16
+
+	bands := make([]EqualizationBand, 0, len(f.Points))
+	for _, p := range f.Points {
+		bands = append(bands, EqualizationBand{
+			Frequency:  (p.Frequency + 1) / 2,
+			Adjustment: decibelsToChange(p.Adjustment, bits),
+		})
+	}
+	return NewFrameEqualization(bits, bands)
+}
+
+// legacyAdjustment converts a v2.4 channel adjustment to a legacy
+// adjustment using the requested number of bits.
+func legacyAdjustment(c ChannelAdjustment, bits uint8) VolumeAdjustment {
+	a := VolumeAdjustment{
+		Channel: c.Channel,
+		Change:  decibelsToChange(c.Adjustment, bits),
+	}
+	if c.PeakBits > 0 && len(c.Peak) > 0 {
+		peak := float64(readUint(c.Peak)) / float64(maxUint(c.PeakBits))
+		if peak > 1 {
+			peak = 1
+		}
+		a.Peak = uint64(math.Floor(peak*float64(maxUint(bits)) + 0.5))
+	}
+	return a
+}
+
+// changeToDecibels converts a legacy volume change to a v2.4 adjustment in
+// units of 1/512 dB.
+func changeToDecibels(change int64, bits uint8) int16 {
+	factor := 1 + float64(change)/math.Exp2(float64(bits))
+	if factor <= 0 {
+		return math.MinInt16
+	}
+	db := math.Floor(20*math.Log10(factor)*512 + 0.5)
+	switch {
+	case db > math.MaxInt16:
+		return math.MaxInt16
+	case db < math.MinInt16:
+		return math.MinInt16
+	}
+	return int16(db)
+}
+
+// decibelsToChange converts a v2.4 adjustment in units of 1/512 dB to a
+// legacy volume change using the requested number of bits.
+func decibelsToChange(adj int16, bits uint8) int64 {
+	factor := math.Pow(10, float64(adj)/512/20)
+	change := math.Floor((factor-1)*math.Exp2(float64(bits)) + 0.5)
+	limit := float64(maxUint(bits))
+	switch {
+	case change > limit:
+		change = limit
+	case change < -limit:
+		change = -limit
+	}
+	return int64(change)
+}
+
+func legacyChannelIndex(c ChannelType) int {
+	for i, lc := range legacyChannels {
+		if lc == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func validLegacyCount(n int) bool {
+	for _, g := range legacyGroups {
+		if n == g {
+			return true
+		}
+	}
+	return false
+}
+
+func maxUint(bits uint8) uint64 {
+	if bits >= 64 {
+		return math.MaxUint64
+	}
+	return 1<<bits - 1
+}
+
+func fitsBits(v uint64, bits uint8) bool {
+	return v <= maxUint(bits)
+}
+
+func abs64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// readUint decodes a big-endian unsigned integer.
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// writeUint encodes an unsigned integer as n big-endian bytes.
+func writeUint(v uint64, n int) []byte {
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b
+}
@@ -0,0 +1,124 @@
+package id3
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// A Provider supplies the results of an audio analysis to
+// PopulateFromAnalysis. It must implement at least one of DurationProvider,
+// BPMProvider, KeyProvider and LoudnessProvider.
+type Provider interface{}
+
+// A DurationProvider supplies the duration of the analyzed audio.
+type DurationProvider interface {
+	Duration() time.Duration
+}
+
+// A BPMProvider supplies the tempo of the analyzed audio, in beats per
+// minute.
+type BPMProvider interface {
+	BPM() float64
+}
+
+// A KeyProvider supplies the initial musical key of the analyzed audio,
+// using the notation of the TKEY frame: a note from "A" to "G", optionally
+// followed by "b" (flat) or "#" (sharp) and "m" (minor), or "o" if the
+// audio is off key.
+type KeyProvider interface {
+	Key() string
+}
+
+// A LoudnessProvider supplies the ReplayGain track gain of the analyzed
+// audio, in decibels, and its peak amplitude as a fraction of full scale.
+type LoudnessProvider interface {
+	Loudness() (gain, peak float64)
+}
+
+// rva2Identification identifies the relative volume adjustment (RVA2)
+// frame written by PopulateFromAnalysis.
+const rva2Identification = "track"
+
+// PopulateFromAnalysis stores the results supplied by audio analysis
+// providers in the tag's frames, replacing any values already present:
+//
+//	DurationProvider  TLEN
+//	BPMProvider       TBPM
+//	KeyProvider       TKEY
+//	LoudnessProvider  TXXX replaygain_track_gain and replaygain_track_peak,
+//	                  and the master channel of a "track" RVA2 frame (v2.4)
+//
+// Providers are applied in order, so later providers override the results
+// of earlier ones. It returns ErrUnknownProvider if a provider doesn't
+// implement any of the provider interfaces.
+func (t *Tag) PopulateFromAnalysis(p ...Provider) error {
+	for _, pp := range p {
+		found := false
+		if d, ok := pp.(DurationProvider); ok {
+			t.setTextValues(FrameTypeTextLengthInMs, strconv.FormatInt(int64(d.Duration()/time.Millisecond), 10))
+			found = true
+		}
+		if b, ok := pp.(BPMProvider); ok {
+			t.setTextValues(FrameTypeTextBPM, strconv.Itoa(int(math.Floor(b.BPM()+0.5))))
+			found = true
+		}
+		if k, ok := pp.(KeyProvider); ok {
+			t.setTextValues(FrameTypeTextMusicalKey, k.Key())
+			found = true
+		}
+		if l, ok := pp.(LoudnessProvider); ok {
+			gain, peak := l.Loudness()
+			t.setLoudness(gain, peak)
+			found = true
+		}
+		if !found {
+			return ErrUnknownProvider
+		}
+	}
+	return nil
+}
+
+// setLoudness stores a ReplayGain track gain and peak in the tag.
+func (t *Tag) setLoudness(gain, peak float64) {
+	t.SetUserTextMap(map[string][]string{
+		"replaygain_track_gain": {strconv.FormatFloat(gain, 'f', 2, 64) + " dB"},
+		"replaygain_track_peak": {strconv.FormatFloat(peak, 'f', 6, 64)},
+	})
+
+	if t.Version < Version2_4 {
+		return
+	}
+
+	var f *FrameVolumeAdjustment2
+	for _, ff := range t.FindFrames(FrameTypeVolumeAdjustment2) {
+		if v, ok := ff.(*FrameVolumeAdjustment2); ok && v.Identification == rva2Identification {
+			f = v
+			break
+		}
+	}
+	if f == nil {
+		f = NewFrameVolumeAdjustment2(rva2Identification)
+		t.Frames = append(t.Frames, f)
+	}
+
+	adj := math.Floor(gain*512 + 0.5)
+	adj = math.Max(math.MinInt16, math.Min(math.MaxInt16, adj))
+	p := math.Floor(peak*32768 + 0.5)
+	p = math.Max(0, math.Min(math.MaxUint16, p))
+	c := ChannelAdjustment{
+		Channel:    ChannelMaster,
+		Adjustment: int16(adj),
+		PeakBits:   16,
+		Peak:       []byte{byte(uint16(p) >> 8), byte(uint16(p))},
+	}
+
+	channels := []ChannelAdjustment{c}
+	for _, cc := range f.Channels {
+		if cc.Channel != ChannelMaster {
+			channels = append(channels, cc)
+		}
+	}
+	f.Channels = channels
+	t.MarkDirty(f)
+}
@@ -0,0 +1,60 @@
+package id3
+
+// Well-known annotation keys. Any other key may be used as well.
+const (
+	AnnotationNote       = "note"       // free-form note
+	AnnotationSource     = "source"     // provenance of the frame's data
+	AnnotationConfidence = "confidence" // confidence in the frame's data
+)
+
+// annotations holds the in-memory annotations of a frame.
+type annotations map[string]string
+
+// Annotate sets an annotation of the frame. Annotations carry information
+// about a frame, such as where its data came from, between the stages of a
+// tagging pipeline. They are kept in memory only and never encoded, but
+// they are preserved when the frame is copied or merged into another tag.
+// An empty value removes the annotation.
+func (h *FrameHeader) Annotate(key, value string) {
+	if h.notes == nil {
+		if value == "" {
+			return
+		}
+		h.notes = &annotations{}
+	}
+	if value == "" {
+		delete(*h.notes, key)
+	} else {
+		(*h.notes)[key] = value
+	}
+}
+
+// Annotation returns the value of an annotation of the frame, or the empty
+// string if the frame has no such annotation.
+func (h *FrameHeader) Annotation(key string) string {
+	if h.notes == nil {
+		return ""
+	}
+	return (*h.notes)[key]
+}
+
+// Annotations returns a copy of all the frame's annotations, keyed by
+// annotation key.
+func (h *FrameHeader) Annotations() map[string]string {
+	m := make(map[string]string)
+	if h.notes != nil {
+		for k, v := range *h.notes {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// copy returns a copy of the annotations.
+func (a *annotations) copy() *annotations {
+	c := make(annotations, len(*a))
+	for k, v := range *a {
+		c[k] = v
+	}
+	return &c
+}
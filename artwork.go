@@ -0,0 +1,242 @@
+package id3
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// artworkPadding is the padding given to a tag that must grow to hold
+// replacement artwork.
+const artworkPadding = 1024
+
+// A frameCodec decodes and encodes individual frames.
+type frameCodec interface {
+	decodeFrame(t *Tag, f *Frame, r *reader, opts *DecodeOptions) error
+	encodeFrame(t *Tag, f Frame, w *writer) error
+}
+
+// ReplaceArtwork replaces the attached picture (APIC) frames of the
+// requested picture type in the ID3v2 tag at the start of a file with a
+// single frame holding the image. The first replaced frame keeps its
+// description, text encoding and frame flags; if there is none, the new
+// frame is added after the tag's other frames. Only attached picture frames
+// are decoded and encoded, and the bytes of all other frames are copied
+// unchanged.
+//
+// If the new tag fits within the existing tag, including its padding, the
+// tag is overwritten in place and the audio data is not moved. Otherwise the
+// file is rewritten to a temporary file that is renamed over the original,
+// and the tag is given extra padding for future edits.
+//
+// ReplaceArtwork returns ErrInvalidHeader if the file doesn't start with an
+// ID3v2 tag, and ErrRawEditUnsupported if the tag is unsynchronized, has a
+// footer or CRC, or if a replaced frame is encrypted.
+func ReplaceArtwork(path string, img []byte, mime string, ptype PictureType) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hdr := make([]byte, 10)
+	if _, err := io.ReadFull(f, hdr); err != nil {
+		return ErrInvalidHeader
+	}
+	v, size, err := PeekTag(hdr)
+	if err != nil {
+		return err
+	}
+
+	tag := make([]byte, size)
+	if _, err := f.ReadAt(tag, 0); err != nil {
+		return err
+	}
+
+	body, err := replaceArtwork(tag, v, img, mime, ptype)
+	if err != nil {
+		return err
+	}
+
+	// Overwrite the tag in place, padding it to its previous size, if the
+	// new tag fits.
+	start := 10 + extendedHeaderLen(tag, v)
+	newHdr := append([]byte{}, tag[:start]...)
+	if padding := size - start - len(body); padding >= 0 {
+		setExtendedPadding(newHdr, v, padding)
+		body = append(body, make([]byte, padding)...)
+		_, err = f.WriteAt(append(newHdr, body...), 0)
+		return err
+	}
+
+	// Otherwise rewrite the file with a larger tag.
+	body = append(body, make([]byte, artworkPadding)...)
+	setExtendedPadding(newHdr, v, artworkPadding)
+	if err := encodeSyncSafeUint32(newHdr[6:10], uint32(start-10+len(body))); err != nil {
+		return ErrTagTooLarge
+	}
+	if _, err := f.Seek(int64(size), io.SeekStart); err != nil {
+		return err
+	}
+	return rewriteFile(path, io.MultiReader(bytes.NewReader(newHdr), bytes.NewReader(body), f))
+}
+
+// replaceArtwork returns the frames of an encoded tag with the attached
+// picture frames of the requested type replaced.
+func replaceArtwork(tag []byte, v Version, img []byte, mime string, ptype PictureType) ([]byte, error) {
+	flags := tag[5]
+	switch {
+	case flags&0x80 != 0:
+		return nil, ErrRawEditUnsupported // unsynchronized
+	case v == Version2_4 && flags&0x10 != 0:
+		return nil, ErrRawEditUnsupported // footer
+	case v >= Version2_3 && flags&0x40 != 0 && hasCRC(tag, v):
+		return nil, ErrRawEditUnsupported
+	}
+
+	start := 10 + extendedHeaderLen(tag, v)
+	if start > len(tag) {
+		return nil, ErrInvalidHeader
+	}
+	frames, err := SplitFrames(tag[start:], v)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newCodec(v)
+	if err != nil {
+		return nil, err
+	}
+	fc := c.(frameCodec)
+	t := NewTag(v, 0)
+	vdata, _ := versionDataOf(v)
+	picID := vdata.frameTypes.LookupFrameID(FrameTypeAttachedPicture)
+
+	var pic *FrameAttachedPicture
+	body := newWriter(ioutil.Discard)
+	for _, raw := range frames {
+		if raw.ID != picID {
+			body.StoreBytes(raw.Frame)
+			continue
+		}
+		if raw.Flags&FrameFlagEncrypted != 0 {
+			return nil, ErrRawEditUnsupported
+		}
+
+		var f Frame
+		r := newReader(bytes.NewReader(raw.Frame))
+		r.Load(len(raw.Frame))
+		if err := fc.decodeFrame(t, &f, r, &DecodeOptions{}); err != nil {
+			return nil, err
+		}
+		p, ok := f.(*FrameAttachedPicture)
+		if !ok || p.PictureType != ptype {
+			body.StoreBytes(raw.Frame)
+			continue
+		}
+		if pic != nil {
+			continue // drop duplicates
+		}
+
+		pic = p
+		pic.MimeType = WesternString(mime)
+		pic.Data = img
+		if err := fc.encodeFrame(t, pic, body); err != nil {
+			return nil, err
+		}
+	}
+
+	if pic == nil {
+		pic = NewFrameAttachedPicture(mime, "", ptype, img)
+		pic.Encoding = EncodingISO88591
+		if err := fc.encodeFrame(t, pic, body); err != nil {
+			return nil, err
+		}
+	}
+	if body.err != nil {
+		return nil, body.err
+	}
+	return body.Bytes(), nil
+}
+
+// extendedHeaderLen returns the length of the extended header of an
+// encoded tag, or 0 if it has none.
+func extendedHeaderLen(tag []byte, v Version) int {
+	if v < Version2_3 || tag[5]&0x40 == 0 || len(tag) < 14 {
+		return 0
+	}
+	if v == Version2_3 {
+		return 4 + int(decodeUint32(tag[10:14]))
+	}
+	n, err := decodeSyncSafeUint32(tag[10:14])
+	if err != nil {
+		return len(tag)
+	}
+	return int(n)
+}
+
+// setExtendedPadding updates the padding size stored in the extended header
+// of an encoded v2.3 tag. The extended headers of other versions don't hold
+// the padding size.
+func setExtendedPadding(hdr []byte, v Version, padding int) {
+	if v == Version2_3 && hdr[5]&0x40 != 0 && len(hdr) >= 20 {
+		encodeUint32(hdr[16:20], uint32(padding))
+	}
+}
+
+// hasCRC returns true if the extended header of an encoded tag holds a CRC.
+func hasCRC(tag []byte, v Version) bool {
+	switch {
+	case v == Version2_3 && len(tag) > 14:
+		return tag[14]&0x80 != 0
+	case v == Version2_4 && len(tag) > 15:
+		return tag[15]&0x20 != 0
+	default:
+		return false
+	}
+}
+
+// rewriteFile replaces the contents of a file with the data read from r,
+// which may read from the file itself. The data is written to a temporary
+// file in the same directory, which is synced to stable storage and renamed
+// over the original.
+func rewriteFile(path string, r io.Reader) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".id3-*")
+	if err != nil {
+		return err
+	}
+	name := tmp.Name()
+
+	_, err = io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Chmod(info.Mode().Perm())
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(name, path)
+	}
+	if err != nil {
+		os.Remove(name)
+		return err
+	}
+
+	// Sync the directory so that the rename is durable. Not all systems
+	// support syncing directories, so failures are ignored.
+	if d, err := os.Open(filepath.Dir(path)); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
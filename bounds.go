@@ -0,0 +1,36 @@
+package id3
+
+// FieldBounds describes the range of values the encoder and decoder of an
+// ID3 version accept for a frame field.
+type FieldBounds struct {
+	Min int   // minimum value, inclusive
+	Max int   // maximum value, inclusive
+	Err error // error returned for values outside the range
+}
+
+// Check returns the bounds' error if the value lies outside the range, or
+// nil otherwise.
+func (b FieldBounds) Check(value int) error {
+	if value < b.Min || value > b.Max {
+		return b.Err
+	}
+	return nil
+}
+
+// Bounds returns the ranges of values allowed for bounded frame fields by
+// ID3 version v, keyed by field name (e.g., "Encoding", "PictureType" or
+// "GroupID"). The bounds apply to the fields of that name in all frame
+// types, and are the same bounds enforced when tags are encoded and
+// decoded. The returned map is a copy and may be modified. Bounds returns
+// ErrInvalidVersion if the version is invalid.
+func Bounds(v Version) (map[string]FieldBounds, error) {
+	vdata, err := versionDataOf(v)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]FieldBounds, len(vdata.bounds))
+	for name, b := range vdata.bounds {
+		m[name] = FieldBounds{b.min, b.max, b.err}
+	}
+	return m, nil
+}
@@ -0,0 +1,145 @@
+package id3
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// cdLeadOutTrack is the track number of the lead-out area in a CD table of
+// contents.
+const cdLeadOutTrack = 0xaa
+
+// cdPregap is the length, in sectors, of the two-second pregap preceding
+// the first track of a CD, which disc IDs include in track offsets.
+const cdPregap = 150
+
+// A CDTrack describes a track of an audio CD.
+type CDTrack struct {
+	Number uint8  // track number
+	Offset uint32 // logical block address of the track's first sector
+	Data   bool   // true if the track holds data rather than audio
+}
+
+// A CDTOC is the table of contents of an audio CD, as stored by a music CD
+// identifier (MCDI) frame. Offsets are logical block addresses, measured in
+// sectors of 1/75 second.
+type CDTOC struct {
+	FirstTrack uint8     // number of the first track
+	LastTrack  uint8     // number of the last track
+	Tracks     []CDTrack // tracks, in order
+	LeadOut    uint32    // logical block address of the lead-out area
+}
+
+// ParseTOC parses the binary table of contents stored in the frame. The
+// table has the format returned by the READ TOC command of CD drives, with
+// addresses in logical block address form. It returns ErrInvalidTOC if the
+// table is malformed.
+func (f *FrameMusicCDIdentifier) ParseTOC() (*CDTOC, error) {
+	return ParseTOC(f.TOC)
+}
+
+// ParseTOC parses a binary CD table of contents, as described by
+// FrameMusicCDIdentifier.ParseTOC.
+func ParseTOC(b []byte) (*CDTOC, error) {
+	if len(b) < 4 {
+		return nil, ErrInvalidTOC
+	}
+	n := int(binary.BigEndian.Uint16(b)) + 2
+	if n > len(b) || (n-4)%8 != 0 {
+		return nil, ErrInvalidTOC
+	}
+
+	toc := &CDTOC{FirstTrack: b[2], LastTrack: b[3]}
+	if toc.FirstTrack == 0 || toc.FirstTrack > toc.LastTrack || toc.LastTrack > 99 {
+		return nil, ErrInvalidTOC
+	}
+
+	leadOut := false
+	for d := b[4:n]; len(d) > 0; d = d[8:] {
+		num, addr := d[2], binary.BigEndian.Uint32(d[4:])
+		if num == cdLeadOutTrack {
+			toc.LeadOut, leadOut = addr, true
+			continue
+		}
+		if leadOut || int(num) != int(toc.FirstTrack)+len(toc.Tracks) {
+			return nil, ErrInvalidTOC
+		}
+		if len(toc.Tracks) > 0 && addr <= toc.Tracks[len(toc.Tracks)-1].Offset {
+			return nil, ErrInvalidTOC
+		}
+		toc.Tracks = append(toc.Tracks, CDTrack{num, addr, (d[1] & 0x04) != 0})
+	}
+
+	if !leadOut || len(toc.Tracks) != int(toc.LastTrack-toc.FirstTrack)+1 ||
+		toc.LeadOut <= toc.Tracks[len(toc.Tracks)-1].Offset {
+		return nil, ErrInvalidTOC
+	}
+	return toc, nil
+}
+
+// Bytes returns the binary encoding of the table of contents, suitable for
+// storing in a music CD identifier frame.
+func (toc *CDTOC) Bytes() []byte {
+	n := 4 + 8*(len(toc.Tracks)+1)
+	b := make([]byte, n)
+	binary.BigEndian.PutUint16(b, uint16(n-2))
+	b[2], b[3] = toc.FirstTrack, toc.LastTrack
+
+	d := b[4:]
+	for _, t := range toc.Tracks {
+		d[1] = 0x10 // ADR 1: current position
+		if t.Data {
+			d[1] |= 0x04
+		}
+		d[2] = t.Number
+		binary.BigEndian.PutUint32(d[4:], t.Offset)
+		d = d[8:]
+	}
+	d[1], d[2] = 0x10, cdLeadOutTrack
+	binary.BigEndian.PutUint32(d[4:], toc.LeadOut)
+	return b
+}
+
+// FreeDBDiscID computes the FreeDB (CDDB) disc ID of the CD, which is
+// commonly rendered as 8 hexadecimal digits.
+func (toc *CDTOC) FreeDBDiscID() uint32 {
+	if len(toc.Tracks) == 0 {
+		return 0
+	}
+
+	var sum uint32
+	for _, t := range toc.Tracks {
+		for s := (t.Offset + cdPregap) / 75; s > 0; s /= 10 {
+			sum += s % 10
+		}
+	}
+	length := (toc.LeadOut+cdPregap)/75 - (toc.Tracks[0].Offset+cdPregap)/75
+	return (sum%0xff)<<24 | length<<8 | uint32(len(toc.Tracks))
+}
+
+// MusicBrainzDiscID computes the MusicBrainz disc ID of the CD. All the
+// tracks of the table of contents are included in the computation; callers
+// identifying enhanced CDs, whose trailing data tracks MusicBrainz
+// excludes, should remove them and adjust the lead-out first.
+func (toc *CDTOC) MusicBrainzDiscID() string {
+	var offsets [100]uint32
+	offsets[0] = toc.LeadOut + cdPregap
+	for _, t := range toc.Tracks {
+		if t.Number > 0 && t.Number < 100 {
+			offsets[t.Number] = t.Offset + cdPregap
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%02X%02X", toc.FirstTrack, toc.LastTrack)
+	for _, o := range offsets {
+		fmt.Fprintf(&b, "%08X", o)
+	}
+	sum := sha1.Sum([]byte(b.String()))
+
+	id := base64.StdEncoding.EncodeToString(sum[:])
+	return strings.NewReplacer("+", ".", "/", "_", "=", "-").Replace(id)
+}
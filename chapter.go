@@ -0,0 +1,87 @@
+package id3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"strconv"
+)
+
+// ChapterElementID derives an element ID for a chapter frame from its
+// content: its start and end times and offsets, and its subframes. The
+// chapter's current element ID and header are ignored. Chapters with the
+// same content always get the same ID, so chapters generated repeatedly
+// from unchanged content produce identical tags. IDs have the form "chp"
+// followed by 16 hexadecimal digits. An error is returned if the chapter's
+// subframes can't be encoded.
+func ChapterElementID(f *FrameChapter) (string, error) {
+	c := CloneFrame(f).(*FrameChapter)
+	c.Header = FrameHeader{FrameType: FrameTypeChapter}
+	c.ElementID = ""
+
+	codec, _ := newCodec(Version2_4)
+	w := newWriter(ioutil.Discard)
+	if err := codec.(frameCodec).encodeFrame(NewTag(Version2_4, 0), c, w); err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(w.Bytes())
+	return "chp" + hex.EncodeToString(sum[:8]), nil
+}
+
+// AssignChapterIDs replaces the element IDs of the tag's chapter (CHAP)
+// frames with IDs derived from their content using ChapterElementID.
+// Chapters with identical content are told apart by a numeric suffix in
+// order of appearance (e.g., "-2"). References to the chapters in table of
+// contents (CTOC) frames are updated. Modified frames are marked dirty. If
+// a chapter can't be encoded, its error is returned and the tag is left
+// unchanged.
+func (t *Tag) AssignChapterIDs() error {
+	ids := make([]string, len(t.Frames))
+	seen := make(map[string]int)
+	for i, f := range t.Frames {
+		ch, ok := f.(*FrameChapter)
+		if !ok {
+			continue
+		}
+		id, err := ChapterElementID(ch)
+		if err != nil {
+			return err
+		}
+		if seen[id]++; seen[id] > 1 {
+			id += "-" + strconv.Itoa(seen[id])
+		}
+		ids[i] = id
+	}
+
+	renamed := make(map[string]string)
+	for i, f := range t.Frames {
+		ch, ok := f.(*FrameChapter)
+		if !ok || string(ch.ElementID) == ids[i] {
+			continue
+		}
+		if _, ok := renamed[string(ch.ElementID)]; !ok {
+			renamed[string(ch.ElementID)] = ids[i]
+		}
+		ch.ElementID = WesternString(ids[i])
+		t.MarkDirty(ch)
+	}
+	if len(renamed) == 0 {
+		return nil
+	}
+
+	for _, f := range t.FindFrames(FrameTypeTableOfContents) {
+		toc := f.(*FrameTableOfContents)
+		changed := false
+		for j, id := range toc.ChildElementIDs {
+			if n, ok := renamed[id]; ok {
+				toc.ChildElementIDs[j] = n
+				changed = true
+			}
+		}
+		if changed {
+			t.MarkDirty(toc)
+		}
+	}
+	return nil
+}
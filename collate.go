@@ -0,0 +1,145 @@
+package id3
+
+import (
+	"strings"
+	"unicode"
+)
+
+// A Collator compares two strings of frame text, returning a negative
+// number if a sorts before b, a positive number if a sorts after b, and zero
+// if the strings should be treated as matching. Locale-aware collators, such
+// as those provided by golang.org/x/text/collate, can be adapted with
+// CollatorFunc.
+type Collator interface {
+	Compare(a, b string) int
+}
+
+// CollatorFunc adapts an ordinary comparison function to a Collator.
+type CollatorFunc func(a, b string) int
+
+// Compare returns fn(a, b).
+func (fn CollatorFunc) Compare(a, b string) int {
+	return fn(a, b)
+}
+
+// Built-in collators.
+var (
+	// ExactCollator compares strings byte by byte.
+	ExactCollator Collator = CollatorFunc(strings.Compare)
+
+	// CaseInsensitiveCollator compares strings without regard to case.
+	CaseInsensitiveCollator Collator = CollatorFunc(func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+
+	// AccentInsensitiveCollator compares strings without regard to case or
+	// diacritical marks, so that "Beyoncé" and "beyonce" match.
+	AccentInsensitiveCollator Collator = CollatorFunc(func(a, b string) int {
+		return strings.Compare(foldAccents(a), foldAccents(b))
+	})
+)
+
+// CompareText compares two strings of frame text using the collator c. If c
+// is nil, ExactCollator is used.
+func CompareText(a, b string, c Collator) int {
+	if c == nil {
+		c = ExactCollator
+	}
+	return c.Compare(a, b)
+}
+
+// TextEqual returns true if the collator c treats the two strings of frame
+// text as matching. If c is nil, ExactCollator is used.
+func TextEqual(a, b string, c Collator) bool {
+	return CompareText(a, b, c) == 0
+}
+
+// FrameTextEqual returns true if two text frames have the same type and
+// the collator c treats each of their text strings as matching. If c is
+// nil, ExactCollator is used.
+func FrameTextEqual(a, b *FrameText, c Collator) bool {
+	if a.Header.FrameType != b.Header.FrameType || len(a.Text) != len(b.Text) {
+		return false
+	}
+	for i := range a.Text {
+		if !TextEqual(a.Text[i], b.Text[i], c) {
+			return false
+		}
+	}
+	return true
+}
+
+// FindText returns all text frames of the requested type containing a text
+// string that the collator c treats as matching s. If c is nil,
+// ExactCollator is used.
+func (t *Tag) FindText(typ FrameType, s string, c Collator) []*FrameText {
+	ff := []*FrameText{}
+	for _, f := range t.FindFrames(typ) {
+		ft, ok := f.(*FrameText)
+		if !ok {
+			continue
+		}
+		for _, text := range ft.Text {
+			if TextEqual(text, s, c) {
+				ff = append(ff, ft)
+				break
+			}
+		}
+	}
+	return ff
+}
+
+// accentFolds maps precomposed Latin letters to their unaccented lowercase
+// equivalents.
+var accentFolds = map[rune]string{}
+
+func init() {
+	for _, m := range []struct {
+		to   string
+		from string
+	}{
+		{"a", "àáâãäåāăą"},
+		{"c", "çćĉċč"},
+		{"d", "ďđð"},
+		{"e", "èéêëēĕėęě"},
+		{"g", "ĝğġģ"},
+		{"h", "ĥħ"},
+		{"i", "ìíîïĩīĭįı"},
+		{"j", "ĵ"},
+		{"k", "ķ"},
+		{"l", "ĺļľŀł"},
+		{"n", "ñńņňŉ"},
+		{"o", "òóôõöøōŏő"},
+		{"r", "ŕŗř"},
+		{"s", "śŝşš"},
+		{"t", "ţťŧ"},
+		{"u", "ùúûüũūŭůűų"},
+		{"w", "ŵ"},
+		{"y", "ýÿŷ"},
+		{"z", "źżž"},
+		{"ae", "æ"},
+		{"oe", "œ"},
+		{"ss", "ß"},
+		{"th", "þ"},
+	} {
+		for _, r := range m.from {
+			accentFolds[r] = m.to
+		}
+	}
+}
+
+// foldAccents returns a lowercase copy of s with diacritical marks removed.
+func foldAccents(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch fold, ok := accentFolds[r]; {
+		case ok:
+			b.WriteString(fold)
+		case unicode.Is(unicode.Mn, r):
+			// Drop combining marks left by decomposed text.
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
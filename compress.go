@@ -0,0 +1,172 @@
+package id3
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"sync"
+)
+
+// unpackFrameData decrypts and decompresses a frame's payload, if necessary,
+// and checks the resulting payload length against the frame's data length
+// indicator. It returns errUndecryptable if the frame is encrypted with a
+// method for which the tag has no registered codec.
+func unpackFrameData(t *Tag, h *FrameHeader, r *reader, opts *DecodeOptions) error {
+	if (h.Flags & FrameFlagEncrypted) != 0 {
+		c, ok := t.encryption[h.EncryptMethod]
+		if !ok {
+			return errUndecryptable
+		}
+		b, err := c.Decrypt(*h, r.ConsumeAll())
+		if err != nil {
+			return err
+		}
+		r.ReplaceBuffer(b)
+	}
+
+	if (h.Flags & FrameFlagCompressed) != 0 {
+		// Check the claimed size before inflating, and stop inflating once
+		// the limit is exceeded.
+		cb := r.ConsumeAll()
+		limit := opts.decompressionLimit(len(cb))
+		if limit >= 0 && int64(h.DataLength) > int64(limit) {
+			return &DecompressionError{h.FrameID, len(cb), int(h.DataLength), limit}
+		}
+		b, err := inflate(cb, limit)
+		switch {
+		case err == errDecompressionLimit:
+			return &DecompressionError{h.FrameID, len(cb), -1, limit}
+		case err != nil:
+			return ErrInvalidCompression
+		}
+		r.ReplaceBuffer(b)
+	}
+
+	if (h.Flags&(FrameFlagCompressed|FrameFlagHasDataLength)) != 0 && r.Len() != int(h.DataLength) {
+		if opts.StrictDataLength {
+			return ErrInvalidDataLength
+		}
+		opts.warn(h.FrameID, ErrInvalidDataLength)
+	}
+
+	return nil
+}
+
+// packFrameData compresses and encrypts the frame payload stored in the
+// writer's buffer starting at offset, if the frame requests it. It returns
+// the length of the unprocessed payload. The payloads of encrypted frames
+// that couldn't be decrypted during decoding are stored as is.
+func packFrameData(t *Tag, h *FrameHeader, w *writer, offset int) (int, error) {
+	n := w.Len() - offset
+
+	if (h.Flags & FrameFlagEncrypted) != 0 {
+		if h.FrameType == FrameTypeUnknown {
+			if h.DataLength != 0 {
+				n = int(h.DataLength)
+			}
+			return n, nil
+		}
+
+		c, ok := t.encryption[h.EncryptMethod]
+		if !ok {
+			return n, ErrUnknownEncryptMethod
+		}
+
+		b := w.ConsumeBytesFromOffset(offset)
+		if (h.Flags & FrameFlagCompressed) != 0 {
+			b = deflate(b)
+		}
+		b, err := c.Encrypt(*h, b)
+		if err != nil {
+			return n, err
+		}
+		w.StoreBytes(b)
+		return n, nil
+	}
+
+	if (h.Flags & FrameFlagCompressed) != 0 {
+		w.StoreBytes(deflate(w.ConsumeBytesFromOffset(offset)))
+	}
+	return n, nil
+}
+
+// A Decompressor returns a reader that decompresses the data read from r.
+type Decompressor func(r io.Reader) (io.ReadCloser, error)
+
+type namedDecompressor struct {
+	name string
+	d    Decompressor
+}
+
+var (
+	decompressorsMu sync.RWMutex
+	decompressors   = []namedDecompressor{
+		{"zlib", func(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) }},
+	}
+)
+
+// RegisterDecompressor registers a decompressor for frames compressed with
+// a nonstandard algorithm, such as raw deflate or gzip. Compressed frames
+// are decompressed with the first registered decompressor to succeed, tried
+// in registration order after the standard zlib decompressor. Registering a
+// decompressor with the name of a previously registered decompressor
+// replaces it. Frames are always compressed with zlib when encoding.
+func RegisterDecompressor(name string, d Decompressor) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+
+	for i := range decompressors {
+		if decompressors[i].name == name {
+			decompressors[i].d = d
+			return
+		}
+	}
+	decompressors = append(decompressors, namedDecompressor{name, d})
+}
+
+// inflate decompresses b. If limit is not negative, decompression stops
+// with errDecompressionLimit once the output exceeds limit bytes.
+func inflate(b []byte, limit int) ([]byte, error) {
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+
+	var err error
+	for _, nd := range decompressors {
+		var out []byte
+		out, err = decompress(b, nd.d, limit)
+		if err == nil || err == errDecompressionLimit {
+			return out, err
+		}
+	}
+	return nil, err
+}
+
+func decompress(b []byte, d Decompressor, limit int) ([]byte, error) {
+	dr, err := d(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer dr.Close()
+
+	var src io.Reader = dr
+	if limit >= 0 {
+		src = io.LimitReader(dr, int64(limit)+1)
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(b)*2))
+	if _, err := io.Copy(out, src); err != nil {
+		return nil, err
+	}
+	if limit >= 0 && out.Len() > limit {
+		return nil, errDecompressionLimit
+	}
+	return out.Bytes(), nil
+}
+
+func deflate(b []byte) []byte {
+	out := bytes.NewBuffer(make([]byte, 0, len(b)))
+	zw := zlib.NewWriter(out)
+	zw.Write(b)
+	zw.Close()
+	return out.Bytes()
+}
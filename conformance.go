@@ -0,0 +1,196 @@
+package id3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// A TaggerProfile describes the ID3 features a tagger or player reads
+// correctly. Profiles are used by CheckConformance to determine whether a
+// tag is fully readable by the tagger. Each feature is defined by the
+// section of the ID3v2.4.0 structure (S) or frames (F) document, or the
+// ID3v2.3.0 specification (v2.3), cited next to it.
+type TaggerProfile struct {
+	Name           string     // name of the tagger
+	Versions       []Version  // ID3 versions the tagger reads (S 3.1, v2.3 3.1)
+	Encodings      []Encoding // text encodings the tagger decodes (S 4, v2.3 3.3)
+	MultipleValues bool       // reads all values of multi-valued v2.4 text frames (F 4.2)
+	TagUnsync      bool       // reads tags unsynchronized as a whole (S 6.1, v2.3 5)
+	FrameUnsync    bool       // reads individually unsynchronized v2.4 frames (S 4.1.2)
+	Compression    bool       // decompresses compressed frames (S 4.1.2, v2.3 3.3.1)
+	ExtendedHeader bool       // skips extended headers correctly (S 3.2, v2.3 3.2)
+	Footer         bool       // accepts v2.4 tags with a footer (S 3.4)
+}
+
+// Profiles of widely used taggers and players. A profile claims only the
+// features documented by the source cited above it, usually the tag
+// formats the tagger offers to write, since a tagger reliably reads the
+// tags it writes. Undocumented features are assumed unsupported, so a tag
+// conforming to a profile is readable by the tagger even if the tagger
+// supports more. Callers may define their own profiles for other software
+// or versions.
+var (
+	// iTunes' Convert ID3 Tags command writes ID3v2.2, v2.3 and v2.4 tags
+	// in ISO-8859-1 or UTF-16.
+	ProfileITunes = &TaggerProfile{
+		Name:      "iTunes",
+		Versions:  []Version{Version2_2, Version2_3, Version2_4},
+		Encodings: []Encoding{EncodingISO88591, EncodingUTF16BOM},
+	}
+
+	// Mp3tag's MPEG tag options write ID3v2.3 tags in ISO-8859-1 or UTF-16
+	// and ID3v2.4 tags in UTF-8, storing multiple values null-separated in
+	// ID3v2.4.
+	ProfileMp3tag = &TaggerProfile{
+		Name:           "Mp3tag",
+		Versions:       []Version{Version2_3, Version2_4},
+		Encodings:      []Encoding{EncodingISO88591, EncodingUTF16BOM, EncodingUTF8},
+		MultipleValues: true,
+	}
+
+	// foobar2000's MP3 tagging preferences write ID3v2.4 tags in UTF-8,
+	// storing multiple values null-separated, or ID3v2.3 tags in UTF-16.
+	ProfileFoobar2000 = &TaggerProfile{
+		Name:           "foobar2000",
+		Versions:       []Version{Version2_3, Version2_4},
+		Encodings:      []Encoding{EncodingISO88591, EncodingUTF16BOM, EncodingUTF8},
+		MultipleValues: true,
+	}
+
+	// Picard reads tags with the Mutagen library, whose ID3 reader
+	// supports all versions and encodings, multiple values, whole-tag and
+	// per-frame unsynchronization, zlib-compressed frames, extended
+	// headers and footers.
+	ProfilePicard = &TaggerProfile{
+		Name:           "MusicBrainz Picard",
+		Versions:       []Version{Version2_2, Version2_3, Version2_4},
+		Encodings:      []Encoding{EncodingISO88591, EncodingUTF16BOM, EncodingUTF16, EncodingUTF8},
+		MultipleValues: true,
+		TagUnsync:      true,
+		FrameUnsync:    true,
+		Compression:    true,
+		ExtendedHeader: true,
+		Footer:         true,
+	}
+
+	// Windows' ID3 property handler writes ID3v2.3 tags in ISO-8859-1 or
+	// UTF-16, and reads ID3v2.4 tags limited to the same encodings.
+	ProfileWindowsExplorer = &TaggerProfile{
+		Name:      "Windows Explorer",
+		Versions:  []Version{Version2_3, Version2_4},
+		Encodings: []Encoding{EncodingISO88591, EncodingUTF16BOM},
+	}
+
+	// TaggerProfiles lists all the predefined profiles.
+	TaggerProfiles = []*TaggerProfile{
+		ProfileITunes,
+		ProfileMp3tag,
+		ProfileFoobar2000,
+		ProfilePicard,
+		ProfileWindowsExplorer,
+	}
+)
+
+// A ConformanceIssue describes a feature of a tag that a tagger doesn't
+// read correctly.
+type ConformanceIssue struct {
+	Tagger  string // name of the tagger's profile
+	FrameID string // ID of the affected frame, or empty for the whole tag
+	Problem string // description of the problem
+}
+
+func (i ConformanceIssue) String() string {
+	if i.FrameID == "" {
+		return i.Tagger + ": " + i.Problem
+	}
+	return i.Tagger + ": " + i.FrameID + ": " + i.Problem
+}
+
+// CheckConformance reads an encoded tag from a stream and returns the
+// features of the tag that the tagger described by the profile doesn't read
+// correctly. A tag with no issues is fully readable by the tagger. An error
+// is returned if the tag can't be decoded at all.
+func CheckConformance(r io.Reader, p *TaggerProfile) ([]ConformanceIssue, error) {
+	t := &Tag{}
+	if _, err := t.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	var issues []ConformanceIssue
+	add := func(id, format string, args ...interface{}) {
+		issues = append(issues, ConformanceIssue{p.Name, id, fmt.Sprintf(format, args...)})
+	}
+
+	if !hasVersion(p.Versions, t.Version) {
+		add("", "ID3v2.%d tags are not supported", t.Version)
+		return issues, nil
+	}
+	if (t.Flags&TagFlagUnsync) != 0 && !p.TagUnsync {
+		add("", "unsynchronized tags are not supported")
+	}
+	if (t.Flags&TagFlagExtended) != 0 && !p.ExtendedHeader {
+		add("", "extended headers are not supported")
+	}
+	if (t.Flags&TagFlagFooter) != 0 && !p.Footer {
+		add("", "tag footers are not supported")
+	}
+
+	var check func(f Frame)
+	check = func(f Frame) {
+		h := HeaderOf(f)
+		if (h.Flags&FrameFlagUnsynchronized) != 0 && (t.Flags&TagFlagUnsync) == 0 && !p.FrameUnsync {
+			add(h.FrameID, "unsynchronized frames are not supported")
+		}
+		if (h.Flags&FrameFlagCompressed) != 0 && !p.Compression {
+			add(h.FrameID, "compressed frames are not supported")
+		}
+		if enc := reflect.ValueOf(f).Elem().FieldByName("Encoding"); enc.IsValid() {
+			if e := Encoding(enc.Uint()); !hasEncoding(p.Encodings, e) {
+				add(h.FrameID, "text encoding %d is not supported", e)
+			}
+		}
+		if tf, ok := f.(*FrameText); ok && t.Version >= Version2_4 && len(tf.Text) > 1 && !p.MultipleValues {
+			add(h.FrameID, "only the first of %d values is read", len(tf.Text))
+		}
+		if sf := subframesOf(f); sf != nil {
+			for _, s := range *sf {
+				check(s)
+			}
+		}
+	}
+	for _, f := range t.Frames {
+		check(f)
+	}
+	return issues, nil
+}
+
+// CheckConformance encodes the tag with the requested encoding options and
+// checks the result against a tagger profile, as described by the
+// CheckConformance function. A nil opts selects the default options.
+func (t *Tag) CheckConformance(p *TaggerProfile, opts *EncodeOptions) ([]ConformanceIssue, error) {
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := t.WriteToWithOptions(buf, opts); err != nil && !isFrameErrors(err) {
+		return nil, err
+	}
+	return CheckConformance(buf, p)
+}
+
+func hasVersion(vv []Version, v Version) bool {
+	for _, x := range vv {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func hasEncoding(ee []Encoding, e Encoding) bool {
+	for _, x := range ee {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
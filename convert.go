@@ -0,0 +1,149 @@
+package id3
+
+import (
+	"reflect"
+	"strings"
+)
+
+// ConvertTo migrates the tag to ID3 version v, rewriting it so that it can
+// be encoded by the new version's codec. Frame IDs follow the frames' types
+// (e.g., TYE becomes TYER and then TDRC, and PIC becomes APIC), so frames
+// needn't be renamed. The conversion also:
+//
+//   - combines the year, date and time frames of earlier versions into a
+//     single v2.4 recording time frame, and truncates the original release
+//     time to a year when converting to earlier versions;
+//   - converts credits and volume and equalization adjustments using
+//     ConvertCredits and ConvertAdjustments;
+//   - joins multiple text values with "/" and replaces the UTF-16BE and
+//     UTF-8 text encodings with UTF-16 when converting to earlier versions;
+//   - clears tag and frame flags, restrictions and extended header data the
+//     new version doesn't support.
+//
+// Frames with no equivalent in the new version, and frames whose
+// encryption or grouping can't be represented, are removed. If any frames
+// were removed, ConvertTo returns a FrameErrors describing them, indexed by
+// their positions in the tag before the conversion.
+func (t *Tag) ConvertTo(v Version) error {
+	to, err := versionDataOf(v)
+	if err != nil {
+		return err
+	}
+	from, err := versionDataOf(t.Version)
+	if err != nil {
+		return err
+	}
+	if v == t.Version {
+		return nil
+	}
+
+	// Remember the original positions of the frames, since the date,
+	// credits and adjustments conversions replace and remove frames.
+	index := make(map[Frame]int, len(t.Frames))
+	for i, f := range t.Frames {
+		index[f] = i
+	}
+
+	if v >= Version2_4 {
+		combineDates(t)
+	}
+	t.ConvertCredits(v)
+	t.ConvertAdjustments(v)
+
+	var dropped FrameErrors
+	frames := make([]Frame, 0, len(t.Frames))
+	for _, f := range t.Frames {
+		i, ok := index[f]
+		if !ok {
+			i = -1
+		}
+		if err := convertFrame(f, v, to); err != nil {
+			dropped = append(dropped, FrameError{Index: i, FrameID: frameIDOf(from.frameTypes, f), Err: err})
+			continue
+		}
+		if sf := subframesOf(f); sf != nil {
+			kept := (*sf)[:0]
+			for _, s := range *sf {
+				if err := convertFrame(s, v, to); err != nil {
+					dropped = append(dropped, FrameError{Index: i, FrameID: frameIDOf(from.frameTypes, s), Err: err})
+					continue
+				}
+				kept = append(kept, s)
+			}
+			*sf = kept
+		}
+		frames = append(frames, f)
+		t.MarkDirty(f)
+	}
+	t.Frames = frames
+
+	t.Flags = TagFlags(to.headerFlags.Decode(to.headerFlags.Encode(uint32(t.Flags))) |
+		to.headerExFlags.Decode(to.headerExFlags.Encode(uint32(t.Flags))))
+	if t.Flags&TagFlagHasCRC == 0 {
+		t.CRC = 0
+	}
+	if t.Flags&TagFlagHasRestrictions == 0 {
+		t.Restrictions = 0
+	}
+	t.ExtendedUnknown = nil
+	t.Version = v
+
+	if len(dropped) > 0 {
+		return dropped
+	}
+	return nil
+}
+
+// convertFrame rewrites a single frame for version v. It returns an error
+// if the frame can't be represented by the version.
+func convertFrame(f Frame, v Version, to *versionData) error {
+	h := HeaderOf(f)
+	if u, ok := f.(*FrameUnknown); ok {
+		if (len(u.FrameID) == 3) != (v == Version2_2) {
+			return ErrUnsupportedFrameType
+		}
+	} else if _, ok := to.frameTypes.FrameTypeToFrameID[h.FrameType]; !ok {
+		return ErrUnsupportedFrameType
+	}
+
+	// Encryption and grouping depend on data outside the frame, so they
+	// can't be silently dropped. Other flags only describe how the frame
+	// is stored, and are cleared if unsupported.
+	flags := FrameFlags(to.frameFlags.Decode(to.frameFlags.Encode(uint32(h.Flags))))
+	if lost := h.Flags &^ flags; lost&(FrameFlagEncrypted|FrameFlagHasGroupID) != 0 {
+		return ErrInvalidFrameFlags
+	}
+	h.Flags = flags
+	if h.Flags&FrameFlagHasDataLength == 0 {
+		h.DataLength = 0
+	}
+	if _, ok := f.(*FrameUnknown); !ok {
+		h.FrameID = to.frameTypes.LookupFrameID(h.FrameType)
+	}
+	h.raw = nil
+
+	if v >= Version2_4 {
+		return nil
+	}
+
+	if tf, ok := f.(*FrameText); ok {
+		switch tf.Header.FrameType {
+		case FrameTypeTextInvolvedPeople:
+		case FrameTypeTextOriginalReleaseTime:
+			if len(tf.Text) > 0 {
+				if ts, _, ok := parseTimestamp(tf.Text[0]); ok {
+					tf.Text = []string{ts.Format("2006")}
+				}
+			}
+		default:
+			if len(tf.Text) > 1 {
+				tf.Text = []string{strings.Join(tf.Text, "/")}
+			}
+		}
+	}
+
+	if enc := reflect.ValueOf(f).Elem().FieldByName("Encoding"); enc.IsValid() && enc.Uint() > EncodingUTF16BOM {
+		enc.SetUint(EncodingUTF16BOM)
+	}
+	return nil
+}
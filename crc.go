@@ -0,0 +1,90 @@
+package id3
+
+import "hash/crc32"
+
+// A CRCVariant identifies the range of bytes covered by a tag's CRC. The
+// ID3 specifications define the range differently for each version, and
+// some writers use the range of another version or include the extended
+// header.
+type CRCVariant uint8
+
+// All possible CRCVariant values.
+const (
+	CRCUnchecked      CRCVariant = iota // the tag has no CRC
+	CRCFramesPadding                    // frames and padding (v2.4 standard)
+	CRCFrames                           // frames only (v2.3 standard)
+	CRCExtendedHeader                   // extended header, frames and padding
+)
+
+func (v CRCVariant) String() string {
+	switch v {
+	case CRCUnchecked:
+		return "unchecked"
+	case CRCFramesPadding:
+		return "frames and padding"
+	case CRCFrames:
+		return "frames only"
+	case CRCExtendedHeader:
+		return "extended header, frames and padding"
+	default:
+		return "unknown"
+	}
+}
+
+// checkCRC validates a tag's CRC against the range of bytes defined by the
+// standard variant. If the CRC doesn't match and the options request CRC
+// compatibility, the other known variants are tried as well. The exHdr
+// slice holds the extended header with its CRC field zeroed, data holds the
+// frames and padding, and padding holds the size of the padding, or -1 if
+// it is unknown. The matching variant is recorded in the decode report.
+func (o *DecodeOptions) checkCRC(crc uint32, std CRCVariant, exHdr, data []byte, padding int) error {
+	variants := []CRCVariant{std}
+	if o.CRCCompat {
+		variants = append(variants, CRCFramesPadding, CRCFrames, CRCExtendedHeader)
+	}
+
+	for _, v := range variants {
+		if matchCRC(crc, v, exHdr, data, padding) {
+			if o.Report != nil {
+				o.Report.CRCVariant = v
+			}
+			return nil
+		}
+	}
+	return ErrFailedCRC
+}
+
+// matchCRC returns true if the crc matches the range of bytes defined by a
+// CRC variant.
+func matchCRC(crc uint32, v CRCVariant, exHdr, data []byte, padding int) bool {
+	switch v {
+	case CRCFramesPadding:
+		return crc32.ChecksumIEEE(data) == crc
+	case CRCExtendedHeader:
+		return crc32.Update(crc32.ChecksumIEEE(exHdr), crc32.IEEETable, data) == crc
+	case CRCFrames:
+		if padding >= 0 {
+			return padding <= len(data) && crc32.ChecksumIEEE(data[:len(data)-padding]) == crc
+		}
+
+		// The padding size is unknown, so try every possible boundary
+		// between the frames and the trailing run of zeros.
+		n := len(data)
+		for n > 0 && data[n-1] == 0 {
+			n--
+		}
+		c := crc32.ChecksumIEEE(data[:n])
+		for {
+			if c == crc {
+				return true
+			}
+			if n == len(data) {
+				return false
+			}
+			c = crc32.Update(c, crc32.IEEETable, []byte{0})
+			n++
+		}
+	default:
+		return false
+	}
+}
@@ -0,0 +1,48 @@
+package id3
+
+// ConvertCredits translates the tag's involved people and musician credits
+// frames to the representation used by version v. Versions before v2.4 have
+// no musician credits (TMCL) frame and store all credits in a single
+// involved people (IPL/IPLS) frame, so the credits of TMCL frames are
+// appended to the tag's first involved people frame, which is added in place
+// of the first TMCL frame if necessary, and the TMCL frames are removed.
+// Involved people frames decoded from earlier versions are already valid
+// v2.4 involved people (TIPL) frames; since those versions don't distinguish
+// musician credits, their credits remain in the TIPL frame.
+//
+// The tag's version is not changed.
+func (t *Tag) ConvertCredits(v Version) {
+	if v >= Version2_4 {
+		return
+	}
+
+	var musicians []Credit
+	var people *FrameText
+	at := -1
+	frames := make([]Frame, 0, len(t.Frames))
+	for _, f := range t.Frames {
+		tf, ok := f.(*FrameText)
+		switch {
+		case ok && tf.Header.FrameType == FrameTypeTextMusicians:
+			musicians = append(musicians, tf.Credits()...)
+			if at < 0 {
+				at = len(frames)
+			}
+			continue
+		case ok && tf.Header.FrameType == FrameTypeTextInvolvedPeople && people == nil:
+			people = tf
+		}
+		frames = append(frames, f)
+	}
+	if at < 0 {
+		return
+	}
+
+	if people == nil {
+		people = NewFrameCredits(FrameTypeTextInvolvedPeople, nil)
+		frames = append(frames[:at], append([]Frame{people}, frames[at:]...)...)
+	}
+	people.SetCredits(append(people.Credits(), musicians...))
+	t.Frames = frames
+	t.MarkDirty(people)
+}
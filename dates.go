@@ -0,0 +1,101 @@
+package id3
+
+import (
+	"fmt"
+	"strings"
+)
+
+// combineDates combines the year (TYER), date (TDAT) and time (TIME)
+// frames of a v2.3 or earlier tag into a single timestamp stored in the
+// year frame. The date and time frames are removed. Nothing is changed if
+// the frames don't hold a valid timestamp.
+func combineDates(t *Tag) {
+	if t.Version >= Version2_4 {
+		return
+	}
+	year, ok := t.FindFrame(FrameTypeTextRecordingTime).(*FrameText)
+	if !ok || len(year.Text) == 0 {
+		return
+	}
+
+	ts := strings.TrimSpace(year.Text[0])
+	date, hasDate := t.FindFrame(FrameTypeTextDate).(*FrameText)
+	tm, hasTime := t.FindFrame(FrameTypeTextTime).(*FrameText)
+	if hasDate {
+		ddmm, ok := digits(date.Text, 4)
+		if !ok {
+			return
+		}
+		ts += "-" + ddmm[2:] + "-" + ddmm[:2]
+		if hasTime {
+			hhmm, ok := digits(tm.Text, 4)
+			if !ok {
+				return
+			}
+			ts += "T" + hhmm[:2] + ":" + hhmm[2:]
+		}
+	}
+	if _, _, ok := parseTimestamp(ts); !ok {
+		return
+	}
+
+	year.Text = []string{ts}
+	if hasDate {
+		t.RemoveFrames(FrameTypeTextDate)
+		if hasTime {
+			t.RemoveFrames(FrameTypeTextTime)
+		}
+	}
+}
+
+// digits returns the first of a frame's text values if it consists of n
+// decimal digits.
+func digits(text []string, n int) (string, bool) {
+	if len(text) == 0 {
+		return "", false
+	}
+	s := strings.TrimSpace(text[0])
+	if len(s) != n {
+		return "", false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return "", false
+		}
+	}
+	return s, true
+}
+
+// splitDate splits a timestamp stored in the recording time frame of a
+// v2.3 or earlier tag into year (TYER), date (TDAT) and time (TIME) frames.
+// Date and time frames are produced only if the tag doesn't already hold
+// them. It returns nil if the frame doesn't need splitting.
+func splitDate(t *Tag, f Frame) []Frame {
+	year, ok := f.(*FrameText)
+	if t.Version >= Version2_4 || !ok || year.Header.FrameType != FrameTypeTextRecordingTime || len(year.Text) == 0 {
+		return nil
+	}
+	ts, p, ok := parseTimestamp(year.Text[0])
+	if !ok || p == PrecisionYear {
+		return nil
+	}
+
+	h := year.Header
+	h.raw = nil
+	ff := []Frame{&FrameText{Header: h, Encoding: year.Encoding, Text: []string{ts.Format("2006")}}}
+	if p >= PrecisionDay && t.FindFrame(FrameTypeTextDate) == nil {
+		ff = append(ff, &FrameText{
+			Header:   FrameHeader{FrameType: FrameTypeTextDate},
+			Encoding: EncodingISO88591,
+			Text:     []string{fmt.Sprintf("%02d%02d", ts.Day(), ts.Month())},
+		})
+	}
+	if p >= PrecisionHour && t.FindFrame(FrameTypeTextTime) == nil {
+		ff = append(ff, &FrameText{
+			Header:   FrameHeader{FrameType: FrameTypeTextTime},
+			Encoding: EncodingISO88591,
+			Text:     []string{fmt.Sprintf("%02d%02d", ts.Hour(), ts.Minute())},
+		})
+	}
+	return ff
+}
@@ -0,0 +1,104 @@
+package id3
+
+import (
+	"fmt"
+	"io"
+)
+
+// A rawFrame holds the bytes of a frame as they were stored in a decoded
+// tag.
+type rawFrame struct {
+	version Version
+	data    []byte
+}
+
+// DebugDump writes an annotated hex dump of a frame's bytes, as they were
+// stored in the tag it was decoded from, to w. The frame header's fields
+// are annotated, and at most maxBytes bytes of the payload are dumped; a
+// maxBytes value of 0 or less dumps the entire payload. The frame must have
+// been decoded with DecodeOptions.CaptureRaw enabled, or DebugDump returns
+// ErrNoRawData.
+//
+// Frames in tags using tag-level unsynchronization are dumped with the
+// unsynchronization removed.
+func DebugDump(f Frame, w io.Writer, maxBytes int) error {
+	raw := HeaderOf(f).raw
+	if raw == nil {
+		return ErrNoRawData
+	}
+
+	idLen, sizeLen, flagsLen := 4, 4, 2
+	if raw.version == Version2_2 {
+		idLen, sizeLen, flagsLen = 3, 3, 0
+	}
+	hdrLen := idLen + sizeLen + flagsLen
+	b := raw.data
+	if len(b) < hdrLen {
+		return ErrInvalidFrameHeader
+	}
+
+	ew := &errWriter{w: w}
+	ew.printf("%s frame (v2.%d), %d bytes: %d byte header, %d byte payload\n",
+		b[:idLen], raw.version, len(b), hdrLen, len(b)-hdrLen)
+
+	ew.printf("%06x  %-48s frame ID %q\n", 0, hexBytes(b[:idLen]), b[:idLen])
+	var size uint32
+	for _, c := range b[idLen : idLen+sizeLen] {
+		size = size<<8 | uint32(c)
+	}
+	if raw.version == Version2_4 {
+		size, _ = decodeSyncSafeUint32(b[idLen : idLen+sizeLen])
+	}
+	ew.printf("%06x  %-48s size %d\n", idLen, hexBytes(b[idLen:idLen+sizeLen]), size)
+	if flagsLen > 0 {
+		ew.printf("%06x  %-48s flags %04x\n", idLen+sizeLen, hexBytes(b[idLen+sizeLen:hdrLen]),
+			uint32(b[idLen+sizeLen])<<8|uint32(b[idLen+sizeLen+1]))
+	}
+
+	payload := b[hdrLen:]
+	if maxBytes > 0 && len(payload) > maxBytes {
+		payload = payload[:maxBytes]
+	}
+	for i := 0; i < len(payload); i += 16 {
+		end := i + 16
+		if end > len(payload) {
+			end = len(payload)
+		}
+		line := payload[i:end]
+		ascii := make([]byte, len(line))
+		for j, c := range line {
+			if c < 0x20 || c > 0x7e {
+				c = '.'
+			}
+			ascii[j] = c
+		}
+		ew.printf("%06x  %-48s |%s|\n", hdrLen+i, hexBytes(line), ascii)
+	}
+	if n := len(b) - hdrLen - len(payload); n > 0 {
+		ew.printf("... %d more bytes\n", n)
+	}
+
+	return ew.err
+}
+
+// hexBytes formats a byte slice as space-separated hex byte values.
+func hexBytes(b []byte) string {
+	s := make([]byte, 0, len(b)*3)
+	for _, c := range b {
+		s = append(s, fmt.Sprintf("%02x ", c)...)
+	}
+	return string(s)
+}
+
+// An errWriter writes formatted output, retaining the first error
+// encountered.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
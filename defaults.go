@@ -0,0 +1,172 @@
+package id3
+
+import "reflect"
+
+// SetComment sets the text of the tag's first comment (COMM) frame with the
+// requested description, adding the frame if necessary, and marks the frame
+// as modified. It returns the frame. The language and text encoding of an
+// added frame are selected when the tag is written, using the
+// DefaultLanguage and DefaultEncoding encode options.
+func (t *Tag) SetComment(description, text string) *FrameComment {
+	for _, f := range t.FindFrames(FrameTypeComment) {
+		if c, ok := f.(*FrameComment); ok && c.Description == description {
+			c.Text = text
+			fitEncoding(&c.Encoding, t.Version, text)
+			t.MarkDirty(c)
+			return c
+		}
+	}
+	c := &FrameComment{
+		Header:      FrameHeader{FrameType: FrameTypeComment},
+		Description: description,
+		Text:        text,
+	}
+	t.addDefaulted(c)
+	return c
+}
+
+// SetLyrics sets the lyrics of the tag's first unsynchronized lyrics (USLT)
+// frame with the requested descriptor, adding the frame if necessary, and
+// marks the frame as modified. It returns the frame. The language and text
+// encoding of an added frame are selected when the tag is written, using
+// the DefaultLanguage and DefaultEncoding encode options.
+func (t *Tag) SetLyrics(descriptor, lyrics string) *FrameLyricsUnsync {
+	for _, f := range t.FindFrames(FrameTypeLyricsUnsync) {
+		if l, ok := f.(*FrameLyricsUnsync); ok && l.Descriptor == descriptor {
+			l.Text = lyrics
+			fitEncoding(&l.Encoding, t.Version, lyrics)
+			t.MarkDirty(l)
+			return l
+		}
+	}
+	l := &FrameLyricsUnsync{
+		Header:     FrameHeader{FrameType: FrameTypeLyricsUnsync},
+		Descriptor: descriptor,
+		Text:       lyrics,
+	}
+	t.addDefaulted(l)
+	return l
+}
+
+// SetTermsOfUse sets the text of the tag's first terms of use (USER) frame,
+// adding the frame if necessary, and marks the frame as modified. It
+// returns the frame. The language and text encoding of an added frame are
+// selected when the tag is written, using the DefaultLanguage and
+// DefaultEncoding encode options.
+func (t *Tag) SetTermsOfUse(text string) *FrameTermsOfUse {
+	if u, ok := t.FindFrame(FrameTypeTermsOfUse).(*FrameTermsOfUse); ok {
+		u.Text = text
+		fitEncoding(&u.Encoding, t.Version, text)
+		t.MarkDirty(u)
+		return u
+	}
+	u := &FrameTermsOfUse{
+		Header: FrameHeader{FrameType: FrameTypeTermsOfUse},
+		Text:   text,
+	}
+	t.addDefaulted(u)
+	return u
+}
+
+// addDefaulted adds a frame whose language and encoding are selected when
+// the tag is written.
+func (t *Tag) addDefaulted(f Frame) {
+	if t.defaulted == nil {
+		t.defaulted = make(map[Frame]bool)
+	}
+	t.defaulted[f] = true
+	t.Frames = append(t.Frames, f)
+	t.MarkDirty(f)
+}
+
+// LanguageUnknown is the language code given to frames added by high-level
+// setters such as Tag.SetComment when no DefaultLanguage is requested.
+const LanguageUnknown = "XXX"
+
+// applyDefaults sets the language and text encoding of the frames added by
+// the tag's high-level setters since the tag was last written. A language
+// or encoding set on such a frame after it was added is kept. Each frame is
+// defaulted only once, so writing the tag again leaves it unchanged.
+func (o *EncodeOptions) applyDefaults(t *Tag) {
+	language := o.DefaultLanguage
+	if language == "" {
+		language = LanguageUnknown
+	}
+	for _, f := range t.Frames {
+		if !t.defaulted[f] {
+			continue
+		}
+		v := reflect.ValueOf(f).Elem()
+		if lang := v.FieldByName("Language"); lang.IsValid() && lang.String() == "" {
+			lang.SetString(language)
+		}
+		if enc := v.FieldByName("Encoding"); Encoding(enc.Uint()) == EncodingISO88591 {
+			enc.SetUint(uint64(o.encodingFor(t.Version, v)))
+		}
+	}
+	t.defaulted = nil
+}
+
+// encodingFor returns the text encoding to use for a frame added by a
+// high-level setter. The default encoding is used only if it is supported by
+// the tag's version and can represent all the frame's text.
+func (o *EncodeOptions) encodingFor(ver Version, v reflect.Value) Encoding {
+	fallback := unicodeEncoding(ver)
+
+	switch enc := o.DefaultEncoding; {
+	case enc > EncodingUTF8:
+		return fallback
+	case ver < Version2_4 && enc > EncodingUTF16BOM:
+		return fallback
+	case enc == EncodingISO88591:
+		for i := 0; i < v.NumField(); i++ {
+			switch fv := v.Field(i); {
+			case fv.Kind() == reflect.String && !isLatin1(fv.String()):
+				return fallback
+			case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.String:
+				for j := 0; j < fv.Len(); j++ {
+					if !isLatin1(fv.Index(j).String()) {
+						return fallback
+					}
+				}
+			}
+		}
+		return enc
+	default:
+		return enc
+	}
+}
+
+// unicodeEncoding returns the preferred Unicode text encoding for a
+// version: UTF-8 in v2.4 and UTF-16 with a byte order mark in earlier
+// versions.
+func unicodeEncoding(ver Version) Encoding {
+	if ver < Version2_4 {
+		return EncodingUTF16BOM
+	}
+	return EncodingUTF8
+}
+
+// fitEncoding upgrades an ISO-8859-1 text encoding to the version's
+// preferred Unicode encoding if it can't represent all the text.
+func fitEncoding(enc *Encoding, ver Version, text ...string) {
+	if *enc != EncodingISO88591 {
+		return
+	}
+	for _, s := range text {
+		if !isLatin1(s) {
+			*enc = unicodeEncoding(ver)
+			return
+		}
+	}
+}
+
+// isLatin1 returns true if the string can be represented in ISO-8859-1.
+func isLatin1(s string) bool {
+	for _, r := range s {
+		if r > 0xff {
+			return false
+		}
+	}
+	return true
+}
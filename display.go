@@ -0,0 +1,202 @@
+package id3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimePrecision describes the precision of a timestamp stored in a frame.
+// ID3 timestamps may omit their trailing components, so a timestamp may
+// specify only a year, or a year and a month, and so on.
+type TimePrecision uint8
+
+// All possible TimePrecision values.
+const (
+	PrecisionYear TimePrecision = iota
+	PrecisionMonth
+	PrecisionDay
+	PrecisionHour
+	PrecisionMinute
+	PrecisionSecond
+)
+
+// A DisplayFormatter renders frame values for display in a particular
+// locale. Implement it to match the conventions of a UI's locale.
+type DisplayFormatter interface {
+	FormatNumber(n int64) string
+	FormatDuration(d time.Duration) string
+	FormatTime(t time.Time, p TimePrecision) string
+}
+
+// BasicFormatter is a DisplayFormatter with configurable digit grouping and
+// date layouts. Times of day are always rendered using a 24-hour clock.
+type BasicFormatter struct {
+	GroupSeparator string // separator between groups of thousands
+	DateLayout     string // time.Format layout for dates with day precision
+	MonthLayout    string // time.Format layout for dates with month precision
+}
+
+// DefaultFormatter is the DisplayFormatter used by DisplayString when no
+// formatter is supplied.
+var DefaultFormatter DisplayFormatter = BasicFormatter{
+	GroupSeparator: ",",
+	DateLayout:     "Jan 2, 2006",
+	MonthLayout:    "Jan 2006",
+}
+
+// FormatNumber renders an integer, grouping its digits by thousands.
+func (f BasicFormatter) FormatNumber(n int64) string {
+	return groupDigits(strconv.FormatInt(n, 10), f.GroupSeparator)
+}
+
+// FormatDuration renders a duration as hours, minutes and seconds, e.g.
+// "3:25" or "1:02:03".
+func (f BasicFormatter) FormatDuration(d time.Duration) string {
+	s := int64(d / time.Second)
+	if s >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+	}
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// FormatTime renders a timestamp using the formatter's date layouts,
+// omitting any components beyond the timestamp's precision.
+func (f BasicFormatter) FormatTime(t time.Time, p TimePrecision) string {
+	switch p {
+	case PrecisionYear:
+		return t.Format("2006")
+	case PrecisionMonth:
+		return t.Format(f.MonthLayout)
+	case PrecisionDay:
+		return t.Format(f.DateLayout)
+	case PrecisionHour, PrecisionMinute:
+		return t.Format(f.DateLayout + " 15:04")
+	default:
+		return t.Format(f.DateLayout + " 15:04:05")
+	}
+}
+
+// groupDigits inserts a separator between groups of thousands in a string
+// of decimal digits, which may have a leading minus sign.
+func groupDigits(s, sep string) string {
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(c)
+	}
+	if neg {
+		return "-" + b.String()
+	}
+	return b.String()
+}
+
+// DisplayString renders a frame as a human-readable string using the
+// formatter. Dates, durations and numbers stored in text frames and
+// counters are rendered according to the formatter; all other frames are
+// rendered as their text contents. A nil formatter selects
+// DefaultFormatter.
+func DisplayString(f Frame, df DisplayFormatter) string {
+	if df == nil {
+		df = DefaultFormatter
+	}
+
+	switch ff := f.(type) {
+	case *FrameText:
+		ss := make([]string, len(ff.Text))
+		for i, s := range ff.Text {
+			ss[i] = displayText(ff.Header.FrameType, s, df)
+		}
+		return strings.Join(ss, "; ")
+	case *FrameTextCustom:
+		return ff.Description + ": " + ff.Text
+	case *FrameURL:
+		return string(ff.URL)
+	case *FrameURLCustom:
+		return ff.Description + ": " + string(ff.URL)
+	case *FrameComment:
+		return ff.Text
+	case *FrameLyricsUnsync:
+		return ff.Text
+	case *FramePlayCount:
+		return displayCounter(ff.CounterBytes, df)
+	case *FramePopularimeter:
+		return fmt.Sprintf("%s/255 (%s)", df.FormatNumber(int64(ff.Rating)), displayCounter(ff.CounterBytes, df))
+	case *FrameAttachedPicture:
+		return fmt.Sprintf("%s (%s)", ff.Description, ff.MimeType)
+	case *FrameGeneralObject:
+		return fmt.Sprintf("%s (%s)", ff.FileName, ff.MimeType)
+	case *FrameChapter:
+		return fmt.Sprintf("%s: %s-%s", ff.ElementID,
+			df.FormatDuration(time.Duration(ff.StartTime)*time.Millisecond),
+			df.FormatDuration(time.Duration(ff.EndTime)*time.Millisecond))
+	default:
+		return ""
+	}
+}
+
+// displayText renders a single text frame string according to the frame's
+// type.
+func displayText(typ FrameType, s string, df DisplayFormatter) string {
+	switch typ {
+	case FrameTypeTextLengthInMs, FrameTypeTextPlaylistDelay:
+		if ms, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+			return df.FormatDuration(time.Duration(ms) * time.Millisecond)
+		}
+	case FrameTypeTextBPM, FrameTypeTextSize:
+		if n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+			return df.FormatNumber(n)
+		}
+	case FrameTypeTextRecordingTime, FrameTypeTextReleaseTime,
+		FrameTypeTextOriginalReleaseTime, FrameTypeTextEncodingTime,
+		FrameTypeTextTaggingTime:
+		if t, p, ok := parseTimestamp(s); ok {
+			return df.FormatTime(t, p)
+		}
+	}
+	return s
+}
+
+// displayCounter renders a play counter, which may exceed the range of an
+// int64.
+func displayCounter(b []byte, df DisplayFormatter) string {
+	c := (&FramePlayCount{CounterBytes: b}).Counter()
+	if c.IsInt64() {
+		return df.FormatNumber(c.Int64())
+	}
+	return c.String()
+}
+
+// timestampLayouts holds the layouts of ID3v2.4 timestamps, ordered by
+// precision.
+var timestampLayouts = []string{
+	"2006",
+	"2006-01",
+	"2006-01-02",
+	"2006-01-02T15",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+}
+
+// parseTimestamp parses an ID3v2.4 timestamp, which is a subset of ISO
+// 8601 of the form yyyy[-MM[-dd[THH[:mm[:ss]]]]]. It returns the time and
+// its precision.
+func parseTimestamp(s string) (time.Time, TimePrecision, bool) {
+	s = strings.TrimSpace(s)
+	for i, layout := range timestampLayouts {
+		if len(s) != len(layout) {
+			continue
+		}
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, TimePrecision(i), true
+		}
+	}
+	return time.Time{}, 0, false
+}
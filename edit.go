@@ -0,0 +1,50 @@
+package id3
+
+// A TagTx is a transaction editing a tag, created by Tag.Edit. It embeds a
+// copy of the tag, so all the methods of Tag may be used to modify it.
+// Frames retrieved from the transaction belong to the copy.
+type TagTx struct {
+	*Tag
+}
+
+// Edit modifies the tag atomically. The function fn receives a transaction
+// holding a deep copy of the tag and modifies it. If fn returns an error, or
+// if the modified copy has validation errors (see Tag.Validate) that the
+// tag didn't have, the tag is left unchanged and the error, or a
+// ValidationError listing the new validation errors, is returned.
+// Otherwise, the modified copy replaces the contents of the tag.
+//
+// Since the transaction works on copies of the tag's frames, frames
+// retrieved from the tag before a successful edit no longer belong to it.
+func (t *Tag) Edit(fn func(tx *TagTx) error) error {
+	tx := &TagTx{Tag: t.Clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	// Only errors introduced by the transaction prevent it from being
+	// committed.
+	existing := make(map[string]int)
+	for _, is := range t.Validate() {
+		if is.Severity == SeverityError {
+			existing[is.FrameID+"\x00"+is.Message]++
+		}
+	}
+	var verr ValidationError
+	for _, is := range tx.Validate() {
+		if is.Severity != SeverityError {
+			continue
+		}
+		if key := is.FrameID + "\x00" + is.Message; existing[key] > 0 {
+			existing[key]--
+			continue
+		}
+		verr = append(verr, is)
+	}
+	if len(verr) > 0 {
+		return verr
+	}
+
+	*t = *tx.Tag
+	return nil
+}
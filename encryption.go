@@ -0,0 +1,32 @@
+package id3
+
+// An EncryptionCodec encrypts and decrypts the payloads of frames that use a
+// particular encryption method. Encryption methods are identified by the
+// method symbols registered in a tag's ENCR frames.
+type EncryptionCodec interface {
+	// Decrypt decrypts the payload of a frame with the given header.
+	Decrypt(h FrameHeader, ciphertext []byte) ([]byte, error)
+
+	// Encrypt encrypts the payload of a frame with the given header.
+	Encrypt(h FrameHeader, plaintext []byte) ([]byte, error)
+}
+
+// RegisterEncryptionMethod registers a codec for frames encrypted using the
+// method symbol. Register codecs before decoding the tag with ReadFrom to
+// decrypt its encrypted frames, and before encoding it to encrypt them.
+// Encrypted frames whose method has no registered codec are decoded as
+// FrameUnknown frames holding the encrypted payload, which are re-encoded
+// unchanged.
+func (t *Tag) RegisterEncryptionMethod(method byte, c EncryptionCodec) {
+	if t.encryption == nil {
+		t.encryption = make(map[byte]EncryptionCodec)
+	}
+	t.encryption[method] = c
+}
+
+// opaqueFrame returns an unknown frame holding the undecrypted payload of an
+// encrypted frame.
+func opaqueFrame(h *FrameHeader, r *reader) Frame {
+	h.FrameType = FrameTypeUnknown
+	return &FrameUnknown{Header: *h, FrameID: h.FrameID, Data: r.ConsumeAll()}
+}
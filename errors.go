@@ -2,17 +2,24 @@ package id3
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 )
 
 // Possible errors returned by this package.
 var (
+	ErrDuplicateFrame          = errors.New("frame duplicates an existing frame of the tag")
+	ErrExceedsRestrictions     = errors.New("tag does not satisfy the restrictions")
 	ErrFailedCRC               = errors.New("tag failed CRC check")
 	ErrIncompleteFrame         = errors.New("frame truncated prematurely")
 	ErrInvalidBits             = errors.New("invalid bits value, should be 8 or 16")
 	ErrInvalidBPM              = errors.New("invalid BPM value, must be less than 511")
+	ErrInvalidCompression      = errors.New("invalid compressed frame data")
+	ErrInvalidDataLength       = errors.New("frame data length mismatch")
 	ErrInvalidEncodedString    = errors.New("invalid encoded string")
 	ErrInvalidEncoding         = errors.New("invalid text encoding")
 	ErrInvalidEncryptMethod    = errors.New("invalid encrypt method, must be between 0x80 and 0xf0")
+	ErrInvalidEnvelope         = errors.New("invalid sealed tag envelope")
 	ErrInvalidFixedLenString   = errors.New("invalid fixed length string")
 	ErrInvalidFooter           = errors.New("invalid footer")
 	ErrInvalidFrame            = errors.New("invalid frame structure")
@@ -22,17 +29,171 @@ var (
 	ErrInvalidHeader           = errors.New("invalid tag header")
 	ErrInvalidHeaderFlags      = errors.New("invalid header flags")
 	ErrInvalidLyricContentType = errors.New("invalid lyric content type")
+	ErrInvalidNotice           = errors.New("invalid copyright or produced notice, must begin with a year and a space")
 	ErrInvalidPictureType      = errors.New("invalid picture type")
+	ErrInvalidSignature        = errors.New("tag signature verification failed")
 	ErrInvalidSync             = errors.New("invalid sync code")
 	ErrInvalidTag              = errors.New("invalid id3 tag")
 	ErrInvalidText             = errors.New("invalid text string encountered")
+	ErrInvalidTOC              = errors.New("invalid CD table of contents")
+	ErrInvalidTimestamp        = errors.New("invalid timestamp, must be of the form yyyy[-MM[-dd[THH[:mm[:ss]]]]]")
 	ErrInvalidTimeStampFormat  = errors.New("invalid time stamp format")
+	ErrInvalidTrackNumber      = errors.New("invalid track number, must be of the form \"n\" or \"n/total\"")
 	ErrInvalidVersion          = errors.New("invalid id3 version")
+	ErrMimeTypeMismatch        = errors.New("MIME type does not match frame data")
+	ErrMixedEncodings          = errors.New("frame strings use inconsistent encodings")
+	ErrNoFetcher               = errors.New("no fetcher for linked frame data")
+	ErrNoIdentifier            = errors.New("no fingerprinter and resolver available")
+	ErrNoMatch                 = errors.New("no recording matches the fingerprint")
+	ErrNoRawData               = errors.New("frame has no captured raw data")
+	ErrNoSignature             = errors.New("tag has no signature")
+	ErrNoV1                    = errors.New("no ID3v1 tag found")
+	ErrNoV1Extended            = errors.New("no extended ID3v1 block found")
+	ErrNotUpdate               = errors.New("tag is not an update tag")
+	ErrPaddingNotAllowed       = errors.New("tag with a footer can't contain padding")
+	ErrRawEditUnsupported      = errors.New("tag layout does not support raw frame editing")
+	ErrTagComplete             = errors.New("tag already complete")
+	ErrTagSizeLimit            = errors.New("tag exceeds the maximum tag size")
+	ErrTagSizeMismatch         = errors.New("tag can't be padded to fill the available space")
+	ErrTagTooLarge             = errors.New("tag too large for the available space")
+	ErrTruncatedTag            = errors.New("tag extends past the end of the stream")
+	ErrUnknownEncryptMethod    = errors.New("no codec registered for encryption method")
 	ErrUnknownFrameType        = errors.New("unknown frame type")
+	ErrUnknownProvider         = errors.New("provider implements no analysis interface")
+	ErrUnsupportedFrameType    = errors.New("frame type not supported by the tag's version")
+	ErrUnsupportedKey          = errors.New("unsupported public key type")
 
-	errInsufficientBuffer = errors.New("insufficient buffer")
-	errInvalidPayloadDef  = errors.New("invalid frame payload definition")
-	errPaddingEncountered = errors.New("padding encountered")
-	errUnimplemented      = errors.New("code path unimplemented")
-	errUnknownFieldType   = errors.New("unknown field type")
+	errDecompressionLimit   = errors.New("decompression limit exceeded")
+	errInsufficientBuffer   = errors.New("insufficient buffer")
+	errInvalidPayloadDef    = errors.New("invalid frame payload definition")
+	errPaddingEncountered   = errors.New("padding encountered")
+	errUndecryptable        = errors.New("frame cannot be decrypted")
+	errUnimplemented        = errors.New("code path unimplemented")
+	errUnknownFieldType     = errors.New("unknown field type")
+	errUnknownFieldEncoding = errors.New("unknown field encoding in struct tag")
 )
+
+// A FrameError describes a frame that failed to encode.
+type FrameError struct {
+	Index   int    // index of the frame within the tag's frames
+	FrameID string // ID of the frame
+	Err     error  // the encoding error
+}
+
+func (e FrameError) Error() string {
+	return fmt.Sprintf("frame %d (%s): %v", e.Index, e.FrameID, e.Err)
+}
+
+// FrameErrors is returned when one or more frames are skipped because they
+// failed to encode.
+type FrameErrors []FrameError
+
+func (e FrameErrors) Error() string {
+	s := make([]string, len(e))
+	for i := range e {
+		s[i] = e[i].Error()
+	}
+	return fmt.Sprintf("%d frame(s) failed to encode: %s", len(e), strings.Join(s, "; "))
+}
+
+// A DecompressionError is returned when the decompressed payload of a frame
+// would exceed the limits set by the decode options.
+type DecompressionError struct {
+	FrameID    string // ID of the frame
+	Compressed int    // size of the compressed payload
+	Claimed    int    // size claimed by the data length indicator, or -1
+	Limit      int    // maximum decompressed size allowed for the frame
+}
+
+func (e *DecompressionError) Error() string {
+	if e.Claimed >= 0 {
+		return fmt.Sprintf("frame %s: claimed decompressed size %d exceeds limit of %d bytes", e.FrameID, e.Claimed, e.Limit)
+	}
+	return fmt.Sprintf("frame %s: decompressed size exceeds limit of %d bytes", e.FrameID, e.Limit)
+}
+
+// A TruncatedTagError is returned when the size declared by a tag's header
+// exceeds the number of bytes remaining in the stream. Its Unwrap method
+// returns ErrTruncatedTag.
+type TruncatedTagError struct {
+	Declared  int // size of the tag data declared by the header
+	Available int // bytes of tag data available in the stream
+}
+
+func (e *TruncatedTagError) Error() string {
+	return fmt.Sprintf("tag declares %d bytes of data but only %d are available", e.Declared, e.Available)
+}
+
+// Unwrap returns ErrTruncatedTag.
+func (e *TruncatedTagError) Unwrap() error {
+	return ErrTruncatedTag
+}
+
+// A VerifyMismatch describes a difference between a tag and the tag decoded
+// from its encoding.
+type VerifyMismatch struct {
+	Index   int    // index of the frame within the tag's frames, or -1
+	FrameID string // ID of the frame, if any
+	Field   string // path of the differing field, e.g. "Text[0]"
+	Want    string // value held by the tag
+	Got     string // value decoded from the encoded tag
+}
+
+func (m VerifyMismatch) Error() string {
+	if m.Index < 0 {
+		return fmt.Sprintf("%s: wrote %s, read back %s", m.Field, m.Want, m.Got)
+	}
+	return fmt.Sprintf("frame %d (%s) %s: wrote %s, read back %s", m.Index, m.FrameID, m.Field, m.Want, m.Got)
+}
+
+// VerifyError is returned when a tag written with EncodeOptions.Verify
+// doesn't decode to the tag that was written.
+type VerifyError []VerifyMismatch
+
+func (e VerifyError) Error() string {
+	s := make([]string, len(e))
+	for i := range e {
+		s[i] = e[i].Error()
+	}
+	return fmt.Sprintf("tag verification failed with %d mismatch(es): %s", len(e), strings.Join(s, "; "))
+}
+
+// A RestrictionViolation describes a way in which a tag exceeds the
+// restrictions it declares.
+type RestrictionViolation struct {
+	Index   int    // index of the frame within the tag's frames, or -1
+	FrameID string // ID of the frame, if any
+	Problem string // description of the violation
+}
+
+func (v RestrictionViolation) Error() string {
+	if v.Index < 0 {
+		return v.Problem
+	}
+	return fmt.Sprintf("frame %d (%s): %s", v.Index, v.FrameID, v.Problem)
+}
+
+// RestrictionError is returned when a v2.4 tag flagged with
+// TagFlagHasRestrictions doesn't satisfy its restrictions. Nothing is
+// written.
+type RestrictionError []RestrictionViolation
+
+func (e RestrictionError) Error() string {
+	s := make([]string, len(e))
+	for i := range e {
+		s[i] = e[i].Error()
+	}
+	return fmt.Sprintf("tag violates its restrictions in %d way(s): %s", len(e), strings.Join(s, "; "))
+}
+
+// ValidationError is returned by Tag.Edit when a transaction introduces
+// errors found by Tag.Validate. Its issues are the new errors.
+type ValidationError []ValidationIssue
+
+func (e ValidationError) Error() string {
+	s := make([]string, len(e))
+	for i := range e {
+		s[i] = e[i].String()
+	}
+	return fmt.Sprintf("edit failed validation with %d issue(s): %s", len(e), strings.Join(s, "; "))
+}
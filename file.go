@@ -0,0 +1,189 @@
+package id3
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// An UpdatePlan describes how the ID3 tag of a file may be updated.
+type UpdatePlan struct {
+	Writable  bool // the file may be opened for writing
+	CanRename bool // a replacement file may be created and renamed over the original
+	HasTag    bool // the file starts with a decodable ID3v2 tag
+	TagSize   int  // total size of the existing tag, including its header
+	Padding   int  // padding bytes available within the existing tag
+}
+
+// InPlace returns true if a tag whose total encoded size (including its
+// header) is size bytes may be written over the existing tag without moving
+// the audio data.
+func (p UpdatePlan) InPlace(size int) bool {
+	return p.Writable && p.HasTag && size <= p.TagSize
+}
+
+// CanUpdate returns true if the file's tag may be updated, either in place
+// or by rewriting the file.
+func (p UpdatePlan) CanUpdate() bool {
+	return p.Writable || p.CanRename
+}
+
+// CanUpdateFile inspects a file without modifying it and reports whether
+// its tag may be updated in place (the file is writable and the existing tag
+// has room to spare) or by rewriting the file (its directory permits the
+// creation and renaming of a temporary file).
+func CanUpdateFile(path string) (UpdatePlan, error) {
+	var plan UpdatePlan
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return plan, err
+	}
+	if !info.Mode().IsRegular() {
+		return plan, os.ErrInvalid
+	}
+
+	if f, err := os.OpenFile(path, os.O_WRONLY, 0); err == nil {
+		plan.Writable = true
+		f.Close()
+	}
+
+	plan.CanRename = canRenameIn(filepath.Dir(path))
+
+	f, err := os.Open(path)
+	if err != nil {
+		return plan, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	if p, err := r.Peek(10); err == nil {
+		if _, size, err := PeekTag(p); err == nil {
+			t := &Tag{}
+			if _, err := t.ReadFrom(r); err == nil {
+				plan.HasTag = true
+				plan.TagSize = size
+				plan.Padding = t.Padding
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// canRenameIn checks whether a temporary file may be created in the
+// directory and renamed.
+func canRenameIn(dir string) bool {
+	tmp, err := os.CreateTemp(dir, ".id3-*")
+	if err != nil {
+		return false
+	}
+	name := tmp.Name()
+	tmp.Close()
+	defer os.Remove(name)
+
+	renamed := name + ".rename"
+	if err := os.Rename(name, renamed); err != nil {
+		return false
+	}
+	os.Remove(renamed)
+	return true
+}
+
+// Strip removes all ID3 tags from a file, leaving only the audio data: the
+// ID3v2 tags at its start, the ID3v1 tag and extended ID3v1 block at its
+// end, and v2.4 tags appended to the audio data and identified by their
+// footers. If only trailing tags are found, the file is truncated;
+// otherwise it is rewritten to a temporary file that is renamed over the
+// original.
+func Strip(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	start, end, err := audioBounds(f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case start == 0 && end == info.Size():
+		return nil
+	case start == 0:
+		return os.Truncate(path, end)
+	default:
+		return rewriteFile(path, io.NewSectionReader(f, start, end-start))
+	}
+}
+
+// audioBounds returns the offsets of the start and end of the audio data
+// of a file of the requested size, skipping the leading, appended and
+// trailing tags.
+func audioBounds(r io.ReaderAt, size int64) (start, end int64, err error) {
+	b := make([]byte, 10)
+
+	// Skip consecutive ID3v2 tags at the start.
+	for start+10 <= size {
+		if _, err := r.ReadAt(b, start); err != nil {
+			return 0, 0, err
+		}
+		_, n, err := PeekTag(b)
+		if err != nil || start+int64(n) > size {
+			break
+		}
+		start += int64(n)
+	}
+
+	// Skip the ID3v1 tag and extended block at the end.
+	end = size
+	if end-start >= V1Size {
+		if _, err := r.ReadAt(b[:3], end-V1Size); err != nil {
+			return 0, 0, err
+		}
+		if string(b[:3]) == "TAG" {
+			end -= V1Size
+			if end-start >= V1ExtendedSize {
+				if _, err := r.ReadAt(b[:4], end-V1ExtendedSize); err != nil {
+					return 0, 0, err
+				}
+				if string(b[:4]) == "TAG+" {
+					end -= V1ExtendedSize
+				}
+			}
+		}
+	}
+
+	// Skip appended v2.4 tags, which end with a footer.
+	for end-start >= 20 {
+		if _, err := r.ReadAt(b, end-10); err != nil {
+			return 0, 0, err
+		}
+		n, ok := peekFooter(b)
+		if !ok || end-start < int64(n) {
+			break
+		}
+		end -= int64(n)
+	}
+	return start, end, nil
+}
+
+// peekFooter determines whether a 10-byte buffer holds the footer of a v2.4
+// tag. If it does, peekFooter returns the total size of the tag, including
+// its header and footer.
+func peekFooter(b []byte) (int, bool) {
+	if string(b[:3]) != "3DI" || b[3] != 4 || b[4] != 0 || (b[5]&0x10) == 0 {
+		return 0, false
+	}
+	size, err := decodeSyncSafeUint32(b[6:10])
+	if err != nil {
+		return 0, false
+	}
+	return int(size) + 20, true
+}
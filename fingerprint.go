@@ -0,0 +1,146 @@
+package id3
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// musicBrainzOwner is the owner identifier of the unique file identifier
+// (UFID) frame holding a MusicBrainz recording ID.
+const musicBrainzOwner = "http://musicbrainz.org"
+
+// An AudioFingerprint is an acoustic fingerprint of a file's audio, such as
+// a Chromaprint fingerprint.
+type AudioFingerprint struct {
+	Data     string        // encoded fingerprint
+	Duration time.Duration // duration of the fingerprinted audio
+}
+
+// A Fingerprinter computes the acoustic fingerprint of audio data, which
+// is typically the file's audio following its ID3 tag.
+type Fingerprinter interface {
+	Fingerprint(ctx context.Context, audio io.Reader) (AudioFingerprint, error)
+}
+
+// A Resolver looks up the recording matching an acoustic fingerprint,
+// typically using a service such as AcoustID. It returns a nil recording
+// if there is no match.
+type Resolver interface {
+	Resolve(ctx context.Context, fp AudioFingerprint) (*Recording, error)
+}
+
+// A Recording describes the metadata of a recording identified by a
+// Resolver. Empty fields are left unchanged in the tag by Tag.IdentifyWith.
+type Recording struct {
+	AcoustID    string      // AcoustID track ID
+	RecordingID string      // MusicBrainz recording ID
+	ReleaseID   string      // MusicBrainz release (album) ID
+	ArtistIDs   []string    // MusicBrainz artist IDs
+	Title       string      // recording title
+	Artists     []string    // recording artists
+	Album       string      // release title
+	AlbumArtist string      // release artist
+	Year        int         // release year
+	Track       TrackNumber // track number within the release
+	Disc        TrackNumber // disc number within the release
+}
+
+// An Identifier combines a Fingerprinter and a Resolver to identify the
+// recording held by a file.
+type Identifier struct {
+	Fingerprinter Fingerprinter
+	Resolver      Resolver
+}
+
+// IdentifyWith uses the identifier to fingerprint the audio read from
+// audio, resolves the fingerprint to a recording, and stores the
+// recording's metadata in the tag, replacing any values already present,
+// following the conventions of MusicBrainz Picard:
+//
+//	RecordingID  UFID owned by "http://musicbrainz.org"
+//	AcoustID     TXXX "Acoustid Id"
+//	ReleaseID    TXXX "MusicBrainz Album Id"
+//	ArtistIDs    TXXX "MusicBrainz Artist Id"
+//	Title        TIT2
+//	Artists      TPE1
+//	Album        TALB
+//	AlbumArtist  TPE2
+//	Year         TDRC (v2.4) or TYER (v2.3)
+//	Track        TRCK
+//	Disc         TPOS
+//
+// Empty fields of the recording are ignored. IdentifyWith returns
+// ErrNoIdentifier if the identifier or its fingerprinter or resolver is
+// nil, and ErrNoMatch, leaving the tag unchanged, if the resolver finds no
+// matching recording. Errors returned by the fingerprinter and resolver,
+// including the context's errors, are returned as is.
+func (t *Tag) IdentifyWith(ctx context.Context, audio io.Reader, id *Identifier) error {
+	if id == nil || id.Fingerprinter == nil || id.Resolver == nil {
+		return ErrNoIdentifier
+	}
+
+	fp, err := id.Fingerprinter.Fingerprint(ctx, audio)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rec, err := id.Resolver.Resolve(ctx, fp)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		return ErrNoMatch
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	t.applyRecording(rec)
+	return nil
+}
+
+// applyRecording stores the non-empty fields of a recording in the tag.
+func (t *Tag) applyRecording(rec *Recording) {
+	if rec.RecordingID != "" {
+		t.ReplaceFrame(NewFrameUniqueFileID(musicBrainzOwner, rec.RecordingID))
+	}
+
+	user := make(map[string][]string)
+	if rec.AcoustID != "" {
+		user["Acoustid Id"] = []string{rec.AcoustID}
+	}
+	if rec.ReleaseID != "" {
+		user["MusicBrainz Album Id"] = []string{rec.ReleaseID}
+	}
+	if len(rec.ArtistIDs) > 0 {
+		user["MusicBrainz Artist Id"] = rec.ArtistIDs
+	}
+	if len(user) > 0 {
+		t.SetUserTextMap(user)
+	}
+
+	if rec.Title != "" {
+		t.SetTitle(rec.Title)
+	}
+	if len(rec.Artists) > 0 {
+		t.SetArtist(rec.Artists...)
+	}
+	if rec.Album != "" {
+		t.SetAlbum(rec.Album)
+	}
+	if rec.AlbumArtist != "" {
+		t.setTextValues(FrameTypeTextAlbumArtist, rec.AlbumArtist)
+	}
+	if rec.Year > 0 {
+		t.SetYear(rec.Year)
+	}
+	if rec.Track.Number > 0 {
+		t.SetTrack(rec.Track)
+	}
+	if rec.Disc.Number > 0 {
+		t.SetDisc(rec.Disc)
+	}
+}
@@ -1,6 +1,14 @@
 package id3
 
-import "reflect"
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+)
 
 // A FrameHeader holds the data described by a frame header.
 type FrameHeader struct {
@@ -11,6 +19,37 @@ type FrameHeader struct {
 	GroupID       uint8      // Optional group identifier
 	EncryptMethod uint8      // Optional encryption method identifier
 	DataLength    uint32     // Optional data length (if FrameFlagHasDataLength is set)
+
+	raw   *rawFrame    // frame bytes captured during decoding, if requested
+	notes *annotations // in-memory annotations, never encoded
+}
+
+// NewFrameHeader creates a header for a frame with the requested frame ID
+// in an ID3 tag of version v. It returns ErrUnknownFrameType if the frame ID
+// isn't defined by the version, and ErrInvalidFrameFlags if any of the
+// requested flags can't be represented by the version (e.g., frame
+// unsynchronization in v2.3). Group identifiers and encryption methods must
+// be set using SetGroupID and SetEncryptMethod, so the FrameFlagHasGroupID
+// and FrameFlagEncrypted flags are also rejected.
+func NewFrameHeader(id string, v Version, flags FrameFlags) (FrameHeader, error) {
+	vdata, err := versionDataOf(v)
+	if err != nil {
+		return FrameHeader{}, err
+	}
+
+	typ, ok := vdata.frameTypes.FrameIDToFrameType[id]
+	if !ok || typ == FrameTypeUnknown {
+		return FrameHeader{}, ErrUnknownFrameType
+	}
+
+	if (flags & (FrameFlagHasGroupID | FrameFlagEncrypted)) != 0 {
+		return FrameHeader{}, ErrInvalidFrameFlags
+	}
+	if vdata.frameFlags.Decode(vdata.frameFlags.Encode(uint32(flags))) != uint32(flags) {
+		return FrameHeader{}, ErrInvalidFrameFlags
+	}
+
+	return FrameHeader{FrameType: typ, FrameID: id, Flags: flags}, nil
 }
 
 // SetFlag sets the requested frame flag on or off.
@@ -106,7 +145,7 @@ const (
 	FrameTypeTextOriginalLyricist  // TOLY
 	FrameTypeTextComposer          // TCOM
 	FrameTypeTextMusicians         // TMCL (v2.4 only)
-	FrameTypeTextInvolvedPeople    // TIPL (v2.4 only)
+	FrameTypeTextInvolvedPeople    // TIPL (v2.4) or IPLS (v2.3)
 	FrameTypeTextEncodedBy         // TENC
 
 	// Text frames: Derived and subjective properties (ID3v2.4 spec section 4.2.3)
@@ -147,9 +186,13 @@ const (
 	FrameTypeTextSize           // TSIZ (v2.3 only)
 
 	// Text frames: non-standard frames that commonly appear in the wild
-	FrameTypeTextCompilationItunes       // TCMP (iTunes)
-	FrameTypeTextAlbumSortOrderItunes    // TSO2 (iTunes)
-	FrameTypeTextComposerSortOrderItunes // TSOC (iTunes)
+	FrameTypeTextCompilationItunes        // TCMP (iTunes)
+	FrameTypeTextAlbumSortOrderItunes     // TSO2 (iTunes)
+	FrameTypeTextComposerSortOrderItunes  // TSOC (iTunes)
+	FrameTypeTextPodcastCategoryItunes    // TCAT (iTunes)
+	FrameTypeTextPodcastDescriptionItunes // TDES (iTunes)
+	FrameTypeTextPodcastIDItunes          // TGID (iTunes)
+	FrameTypeTextPodcastFeedItunes        // WFED (iTunes, a text frame despite its ID)
 
 	// Text frames: custom text
 	FrameTypeTextCustom // TXXX
@@ -171,17 +214,28 @@ const (
 	FrameTypeAttachedPicture              // APIC
 	FrameTypeAudioEncryption              // AENC
 	FrameTypeAudioSeekPointIndex          // ASPI
+	FrameTypeChapter                      // CHAP
 	FrameTypeComment                      // COMM
 	FrameTypeEncryptionMethodRegistration // ENCR
+	FrameTypeEqualization                 // EQUA (v2.3 and earlier)
+	FrameTypeEqualization2                // EQU2 (v2.4 only)
+	FrameTypeGeneralObject                // GEOB
 	FrameTypeGroupID                      // GRID
 	FrameTypeLyricsSync                   // SYLT
 	FrameTypeLyricsUnsync                 // USLT
+	FrameTypeMusicCDIdentifier            // MCDI
 	FrameTypePlayCount                    // PCNT
+	FrameTypePodcastItunes                // PCST (iTunes)
 	FrameTypePopularimeter                // POPM
 	FrameTypePrivate                      // PRIV
+	FrameTypeSeek                         // SEEK (v2.4 only)
+	FrameTypeSignature                    // SIGN (v2.4 only)
 	FrameTypeSyncTempoCodes               // SYTC
+	FrameTypeTableOfContents              // CTOC
 	FrameTypeTermsOfUse                   // USER
 	FrameTypeUniqueFileID                 // UFID
+	FrameTypeVolumeAdjustment             // RVAD (v2.3 and earlier)
+	FrameTypeVolumeAdjustment2            // RVA2 (v2.4 only)
 
 	// Non-standard values
 	FrameTypeUnknown
@@ -247,8 +301,17 @@ const (
 )
 
 // FrameAttachedPicture contains the payload of an image frame.
+//
+// MimeType holds the image's MIME type in all versions. The 3-character
+// image format stored by v2.2 tags is translated to and from the MIME type
+// when the frame is decoded and encoded; see PictureFormatMimeType.
+//
+// If the frame was decoded with DecodeOptions.LazyPictureSize enabled, Data
+// may be nil, in which case the image data remains in the source the tag
+// was read from. Use Open to access the image data in either case.
 type FrameAttachedPicture struct {
 	Header      FrameHeader
+	source      *io.SectionReader // source of lazily decoded image data
 	Encoding    Encoding
 	MimeType    WesternString
 	PictureType PictureType
@@ -256,6 +319,22 @@ type FrameAttachedPicture struct {
 	Data        []byte
 }
 
+// Open returns a reader over the picture's image data. If the picture was
+// decoded lazily, the image data is streamed directly from the tag's
+// source without being loaded into memory.
+func (f *FrameAttachedPicture) Open() io.ReadCloser {
+	if f.Data == nil && f.source != nil {
+		return ioutil.NopCloser(io.NewSectionReader(f.source, 0, f.source.Size()))
+	}
+	return ioutil.NopCloser(bytes.NewReader(f.Data))
+}
+
+// IsLazy returns true if the picture's image data has not been loaded into
+// memory.
+func (f *FrameAttachedPicture) IsLazy() bool {
+	return f.Data == nil && f.source != nil
+}
+
 // NewFrameAttachedPicture creates a new attached-picture frame.
 func NewFrameAttachedPicture(mimeType, description string, typ PictureType, data []byte) *FrameAttachedPicture {
 	return &FrameAttachedPicture{
@@ -335,6 +414,48 @@ func (f *FrameAudioSeekPointIndex) AddIndexOffset(o uint32) {
 	f.IndexPoints++
 }
 
+// FrameChapter describes a single chapter within the audio, such as a
+// podcast segment or an audiobook chapter. Start and end times are in
+// milliseconds. Start and end offsets are byte offsets from the start of the
+// audio; an offset of 0xffffffff indicates that the offset is unused.
+// Subframes typically hold the chapter's title and artwork.
+type FrameChapter struct {
+	Header      FrameHeader
+	ElementID   WesternString
+	StartTime   uint32
+	EndTime     uint32
+	StartOffset uint32
+	EndOffset   uint32
+	Subframes   []Frame
+}
+
+// NewFrameChapter creates a new chapter frame without start and end
+// offsets.
+func NewFrameChapter(elementID string, startTime, endTime uint32, subframes ...Frame) *FrameChapter {
+	return &FrameChapter{
+		Header:      FrameHeader{FrameType: FrameTypeChapter},
+		ElementID:   WesternString(elementID),
+		StartTime:   startTime,
+		EndTime:     endTime,
+		StartOffset: 0xffffffff,
+		EndOffset:   0xffffffff,
+		Subframes:   subframes,
+	}
+}
+
+// subframesOf returns a pointer to the subframes embedded in a frame, or nil
+// if the frame can't embed subframes.
+func subframesOf(f Frame) *[]Frame {
+	switch ff := f.(type) {
+	case *FrameChapter:
+		return &ff.Subframes
+	case *FrameTableOfContents:
+		return &ff.Subframes
+	default:
+		return nil
+	}
+}
+
 // FrameComment contains a full-text comment field.
 type FrameComment struct {
 	Header      FrameHeader
@@ -376,6 +497,85 @@ func NewFrameEncryptionMethodRegistration(owner string, method uint8, data []byt
 	}
 }
 
+// FrameEqualization contains a legacy (v2.3 and earlier) equalization
+// curve. Its bands are stored in Data, encoded with the number of bits per
+// adjustment given by Bits. Use Bands and SetBands to access them.
+type FrameEqualization struct {
+	Header FrameHeader
+	Bits   uint8
+	Data   []byte
+}
+
+// NewFrameEqualization creates a new legacy equalization frame containing
+// the requested bands, whose adjustments are encoded with the requested
+// number of bits.
+func NewFrameEqualization(bits uint8, bands []EqualizationBand) (*FrameEqualization, error) {
+	f := &FrameEqualization{Header: FrameHeader{FrameType: FrameTypeEqualization}}
+	if err := f.SetBands(bits, bands); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// An EqualizationPoint describes the volume adjustment at a frequency of a
+// v2.4 equalization curve.
+type EqualizationPoint struct {
+	Frequency  uint16 // frequency, in units of 1/2 Hz
+	Adjustment int16  // volume adjustment, in units of 1/512 dB
+}
+
+// InterpolationMethod describes how a v2.4 equalization curve is
+// interpolated between its points.
+type InterpolationMethod uint8
+
+// All possible InterpolationMethod values.
+const (
+	InterpolationBand   InterpolationMethod = iota // no interpolation
+	InterpolationLinear                            // linear interpolation
+)
+
+// FrameEqualization2 contains a v2.4 equalization curve. Points are sorted
+// by increasing frequency.
+type FrameEqualization2 struct {
+	Header         FrameHeader
+	Interpolation  InterpolationMethod
+	Identification WesternString
+	Points         []EqualizationPoint
+}
+
+// NewFrameEqualization2 creates a new v2.4 equalization frame.
+func NewFrameEqualization2(interpolation InterpolationMethod, identification string) *FrameEqualization2 {
+	return &FrameEqualization2{
+		Header:         FrameHeader{FrameType: FrameTypeEqualization2},
+		Interpolation:  interpolation,
+		Identification: WesternString(identification),
+		Points:         []EqualizationPoint{},
+	}
+}
+
+// FrameGeneralObject contains an encapsulated object of any type, such as
+// a document or a cue sheet.
+type FrameGeneralObject struct {
+	Header      FrameHeader
+	Encoding    Encoding
+	MimeType    WesternString
+	FileName    string
+	Description string
+	Data        []byte
+}
+
+// NewFrameGeneralObject creates a new general encapsulated object frame.
+func NewFrameGeneralObject(mimeType, fileName, description string, data []byte) *FrameGeneralObject {
+	return &FrameGeneralObject{
+		Header:      FrameHeader{FrameType: FrameTypeGeneralObject},
+		Encoding:    EncodingUTF8,
+		MimeType:    WesternString(mimeType),
+		FileName:    fileName,
+		Description: description,
+		Data:        data,
+	}
+}
+
 // FrameGroupID contains information describing the grouping of
 // otherwise unrelated frames. If a frame contains an optional group
 // identifier, there will be a corresponding GRID frame with data
@@ -496,6 +696,23 @@ func NewFrameLyricsUnsync(language, descriptor, lyrics string) *FrameLyricsUnsyn
 	}
 }
 
+// FrameMusicCDIdentifier identifies the audio CD from which the file was
+// ripped. It holds the binary table of contents of the CD, which may be
+// parsed with ParseTOC.
+type FrameMusicCDIdentifier struct {
+	Header FrameHeader
+	TOC    []byte
+}
+
+// NewFrameMusicCDIdentifier creates a new music CD identifier frame holding
+// a binary CD table of contents.
+func NewFrameMusicCDIdentifier(toc []byte) *FrameMusicCDIdentifier {
+	return &FrameMusicCDIdentifier{
+		Header: FrameHeader{FrameType: FrameTypeMusicCDIdentifier},
+		TOC:    toc,
+	}
+}
+
 // FramePrivate contains private information specific to a software
 // producer.
 type FramePrivate struct {
@@ -515,33 +732,116 @@ func NewFramePrivate(owner string, data []byte) *FramePrivate {
 
 // FramePlayCount tracks the number of times the MP3 file has been played.
 type FramePlayCount struct {
-	Header  FrameHeader
-	Counter uint64
+	Header       FrameHeader
+	CounterBytes []byte // big-endian counter, at least 4 bytes long
 }
 
 // NewFramePlayCount creates a new play count frame.
 func NewFramePlayCount(counter uint64) *FramePlayCount {
-	return &FramePlayCount{
-		Header:  FrameHeader{FrameType: FrameTypePlayCount},
-		Counter: counter,
+	f := &FramePlayCount{Header: FrameHeader{FrameType: FrameTypePlayCount}}
+	f.SetCounter(new(big.Int).SetUint64(counter))
+	return f
+}
+
+// Counter returns the number of times the file has been played. The count
+// may exceed the range of a uint64.
+func (f *FramePlayCount) Counter() *big.Int {
+	return new(big.Int).SetBytes(f.CounterBytes)
+}
+
+// SetCounter sets the number of times the file has been played. The count
+// must not be negative.
+func (f *FramePlayCount) SetCounter(c *big.Int) {
+	f.CounterBytes = counterBytes(c)
+}
+
+// FramePodcast marks a file as a podcast episode. The frame is an iTunes
+// extension, and iTunes always stores a zero value.
+type FramePodcast struct {
+	Header FrameHeader
+	Value  uint32
+}
+
+// NewFramePodcast creates a new podcast marker frame.
+func NewFramePodcast() *FramePodcast {
+	return &FramePodcast{
+		Header: FrameHeader{FrameType: FrameTypePodcastItunes},
 	}
 }
 
 // FramePopularimeter tracks the "popularimeter" value for an MP3 file.
 type FramePopularimeter struct {
-	Header  FrameHeader
-	Email   WesternString
-	Rating  uint8
-	Counter uint64
+	Header       FrameHeader
+	Email        WesternString
+	Rating       uint8
+	CounterBytes []byte // big-endian play counter, at least 4 bytes long
 }
 
 // NewFramePopularimeter creates a new "popularimeter" frame.
 func NewFramePopularimeter(email string, rating uint8, counter uint64) *FramePopularimeter {
-	return &FramePopularimeter{
-		Header:  FrameHeader{FrameType: FrameTypePopularimeter},
-		Email:   WesternString(email),
-		Rating:  rating,
-		Counter: counter,
+	f := &FramePopularimeter{
+		Header: FrameHeader{FrameType: FrameTypePopularimeter},
+		Email:  WesternString(email),
+		Rating: rating,
+	}
+	f.SetCounter(new(big.Int).SetUint64(counter))
+	return f
+}
+
+// Counter returns the number of times the file has been played. The count
+// may exceed the range of a uint64.
+func (f *FramePopularimeter) Counter() *big.Int {
+	return new(big.Int).SetBytes(f.CounterBytes)
+}
+
+// SetCounter sets the number of times the file has been played. The count
+// must not be negative.
+func (f *FramePopularimeter) SetCounter(c *big.Int) {
+	f.CounterBytes = counterBytes(c)
+}
+
+// counterBytes encodes a counter as a big-endian byte slice at least 4 bytes
+// long.
+func counterBytes(c *big.Int) []byte {
+	b := c.Bytes()
+	if len(b) < 4 {
+		b = append(make([]byte, 4-len(b)), b...)
+	}
+	return b
+}
+
+// FrameSeek indicates that another tag is located later in the file or
+// stream. Offset holds the minimum number of bytes between the end of the
+// tag containing the frame and the start of the next tag.
+type FrameSeek struct {
+	Header FrameHeader
+	Offset uint32
+}
+
+// NewFrameSeek creates a new seek frame.
+func NewFrameSeek(offset uint32) *FrameSeek {
+	return &FrameSeek{
+		Header: FrameHeader{FrameType: FrameTypeSeek},
+		Offset: offset,
+	}
+}
+
+// FrameSignature contains a signature over the frames belonging to a group.
+// The group symbol identifies the signed frames, which carry it as their
+// group identifier, and must be registered by a group identifier (GRID)
+// frame.
+type FrameSignature struct {
+	Header      FrameHeader
+	GroupSymbol uint8
+	Signature   []byte
+}
+
+// NewFrameSignature creates a new signature frame.
+func NewFrameSignature(groupSymbol uint8, signature []byte) *FrameSignature {
+	return &FrameSignature{
+		Header:      FrameHeader{FrameType: FrameTypeSignature},
+		GroupSymbol: groupSymbol,
+		Signature:   signature,
 	}
 }
 
@@ -586,6 +886,43 @@ func (f *FrameSyncTempoCodes) AddSync(bpm uint16, timestamp uint32) {
 	}
 }
 
+// FrameTableOfContents describes a table of contents listing chapters
+// (CHAP frames) or other tables of contents by element ID. A tag's top-level
+// table of contents is the root of its hierarchy of tables of contents.
+// Subframes typically hold the table's title.
+type FrameTableOfContents struct {
+	Header          FrameHeader
+	ElementID       WesternString
+	Flags           TOCFlags
+	ChildElementIDs []string
+	Subframes       []Frame
+}
+
+// TOCFlags describe the flags of a table of contents.
+type TOCFlags uint8
+
+// All possible TOCFlags.
+const (
+	TOCFlagOrdered  TOCFlags = 1 << iota // child elements are ordered
+	TOCFlagTopLevel                      // root of the table of contents hierarchy
+)
+
+// NewFrameTableOfContents creates a new table of contents frame listing the
+// requested child element IDs in order.
+func NewFrameTableOfContents(elementID string, topLevel bool, children []string, subframes ...Frame) *FrameTableOfContents {
+	f := &FrameTableOfContents{
+		Header:          FrameHeader{FrameType: FrameTypeTableOfContents},
+		ElementID:       WesternString(elementID),
+		Flags:           TOCFlagOrdered,
+		ChildElementIDs: children,
+		Subframes:       subframes,
+	}
+	if topLevel {
+		f.Flags |= TOCFlagTopLevel
+	}
+	return f
+}
+
 // FrameTermsOfUse contains the terms of use description for the MP3.
 type FrameTermsOfUse struct {
 	Header   FrameHeader
@@ -623,7 +960,54 @@ func NewFrameText(typ FrameType, text string) *FrameText {
 	}
 }
 
+// A Credit pairs an involvement (or, for musician credits, an instrument)
+// with the name of the person credited.
+type Credit struct {
+	Role string
+	Name string
+}
+
+// NewFrameCredits creates a new involved people (TIPL/IPLS) or musician
+// credits (TMCL) text frame containing the requested credits.
+func NewFrameCredits(typ FrameType, credits []Credit) *FrameText {
+	f := &FrameText{
+		Header:   FrameHeader{FrameType: typ},
+		Encoding: EncodingUTF8,
+	}
+	f.SetCredits(credits)
+	return f
+}
+
+// Credits interprets the text strings of an involved people (TIPL/IPLS) or
+// musician credits (TMCL) frame as a list of role/name pairs. A trailing
+// role without a name is returned with an empty name.
+func (f *FrameText) Credits() []Credit {
+	credits := make([]Credit, 0, (len(f.Text)+1)/2)
+	for i := 0; i < len(f.Text); i += 2 {
+		c := Credit{Role: f.Text[i]}
+		if i+1 < len(f.Text) {
+			c.Name = f.Text[i+1]
+		}
+		credits = append(credits, c)
+	}
+	return credits
+}
+
+// SetCredits replaces the text strings of an involved people (TIPL/IPLS) or
+// musician credits (TMCL) frame with a list of role/name pairs.
+func (f *FrameText) SetCredits(credits []Credit) {
+	f.Text = make([]string, 0, len(credits)*2)
+	for _, c := range credits {
+		f.Text = append(f.Text, c.Role, c.Name)
+	}
+}
+
 // FrameTextCustom contains a custom text payload.
+//
+// A v2.4 frame may hold multiple values. When such a frame is decoded, Text
+// holds all of them, separated by null characters, and they are encoded as
+// separate null-terminated strings. Use Tag.UserTextMap to retrieve the
+// values separately.
 type FrameTextCustom struct {
 	Header      FrameHeader
 	Encoding    Encoding
@@ -707,93 +1091,198 @@ func NewFrameUniqueFileID(owner, id string) *FrameUniqueFileID {
 	}
 }
 
+// FrameVolumeAdjustment contains a legacy (v2.3 and earlier) relative
+// volume adjustment. Its adjustments are stored in Data, encoded with the
+// number of bits given by Bits, and the direction of each channel's volume
+// change is given by the corresponding bit of Increment. Use Adjustments
+// and SetAdjustments to access them.
+type FrameVolumeAdjustment struct {
+	Header    FrameHeader
+	Increment uint8
+	Bits      uint8
+	Data      []byte
+}
+
+// NewFrameVolumeAdjustment creates a new legacy relative volume adjustment
+// frame containing the requested channel adjustments, encoded with the
+// requested number of bits.
+func NewFrameVolumeAdjustment(bits uint8, adj []VolumeAdjustment) (*FrameVolumeAdjustment, error) {
+	f := &FrameVolumeAdjustment{Header: FrameHeader{FrameType: FrameTypeVolumeAdjustment}}
+	if err := f.SetAdjustments(bits, adj); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// ChannelType identifies the channel affected by a volume adjustment.
+type ChannelType uint8
+
+// All possible ChannelType values.
+const (
+	ChannelOther ChannelType = iota
+	ChannelMaster
+	ChannelFrontRight
+	ChannelFrontLeft
+	ChannelBackRight
+	ChannelBackLeft
+	ChannelFrontCenter
+	ChannelBackCenter
+	ChannelSubwoofer
+)
+
+// A ChannelAdjustment describes the volume adjustment of a channel in a
+// v2.4 relative volume adjustment frame. Peak holds the channel's peak
+// volume as a big-endian number of PeakBits bits, and may be empty.
+type ChannelAdjustment struct {
+	Channel    ChannelType
+	Adjustment int16 // volume adjustment, in units of 1/512 dB
+	PeakBits   uint8
+	Peak       []byte
+}
+
+// FrameVolumeAdjustment2 contains a v2.4 relative volume adjustment.
+type FrameVolumeAdjustment2 struct {
+	Header         FrameHeader
+	Identification WesternString
+	Channels       []ChannelAdjustment
+}
+
+// NewFrameVolumeAdjustment2 creates a new v2.4 relative volume adjustment
+// frame.
+func NewFrameVolumeAdjustment2(identification string) *FrameVolumeAdjustment2 {
+	return &FrameVolumeAdjustment2{
+		Header:         FrameHeader{FrameType: FrameTypeVolumeAdjustment2},
+		Identification: WesternString(identification),
+		Channels:       []ChannelAdjustment{},
+	}
+}
+
 //
 // Frame list and type map
 //
 
-// frameList holds all possible frame payload types supported by ID3.
-var frameList = []struct {
+// A frameSpec describes a frame type supported by this package: the
+// structure holding its payload and the frame ID used by each version of the
+// codec. An empty frame ID indicates the frame type is unavailable in that
+// version.
+type frameSpec struct {
 	frameType   FrameType
 	reflectType reflect.Type
-}{
-	{FrameTypeAttachedPicture, reflect.TypeOf(FrameAttachedPicture{})},
-	{FrameTypeAudioEncryption, reflect.TypeOf(FrameAudioEncryption{})},
-	{FrameTypeAudioSeekPointIndex, reflect.TypeOf(FrameAudioSeekPointIndex{})},
-	{FrameTypeComment, reflect.TypeOf(FrameComment{})},
-	{FrameTypeEncryptionMethodRegistration, reflect.TypeOf(FrameEncryptionMethodRegistration{})},
-	{FrameTypeGroupID, reflect.TypeOf(FrameGroupID{})},
-	{FrameTypeLyricsSync, reflect.TypeOf(FrameLyricsSync{})},
-	{FrameTypeLyricsUnsync, reflect.TypeOf(FrameLyricsUnsync{})},
-	{FrameTypePlayCount, reflect.TypeOf(FramePlayCount{})},
-	{FrameTypePopularimeter, reflect.TypeOf(FramePopularimeter{})},
-	{FrameTypePrivate, reflect.TypeOf(FramePrivate{})},
-	{FrameTypeSyncTempoCodes, reflect.TypeOf(FrameSyncTempoCodes{})},
-	{FrameTypeTermsOfUse, reflect.TypeOf(FrameTermsOfUse{})},
-	{FrameTypeTextAlbumArtist, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextAlbumArtist, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextAlbumName, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextAlbumSortOrder, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextAlbumSortOrderItunes, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextArtist, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextBPM, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextCompilationItunes, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextComposer, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextComposerSortOrderItunes, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextConductor, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextCopyright, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextCustom, reflect.TypeOf(FrameTextCustom{})},
-	{FrameTypeTextDate, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextEncodedBy, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextEncodingSoftware, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextEncodingTime, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextFileType, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextGenre, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextGroupDescription, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextInvolvedPeople, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextISRC, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextLanguage, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextLengthInMs, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextLyricist, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextMediaType, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextMood, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextMusicalKey, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextMusicians, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextOriginalAlbum, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextOriginalFileName, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextOriginalLyricist, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextOriginalPerformer, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextOriginalReleaseTime, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextOwner, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextPartOfSet, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextPerformerSortOrder, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextPlaylistDelay, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextProducedNotice, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextPublisher, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextRadioStation, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextRadioStationOwner, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextRecordingDates, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextRecordingTime, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextReleaseTime, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextRemixer, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextSetSubtitle, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextSize, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextSongSubtitle, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextSongTitle, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextTaggingTime, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextTime, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextTitleSortOrder, reflect.TypeOf(FrameText{})},
-	{FrameTypeTextTrackNumber, reflect.TypeOf(FrameText{})},
-	{FrameTypeUniqueFileID, reflect.TypeOf(FrameUniqueFileID{})},
-	{FrameTypeUnknown, reflect.TypeOf(FrameUnknown{})},
-	{FrameTypeURLArtist, reflect.TypeOf(FrameURL{})},
-	{FrameTypeURLAudioFile, reflect.TypeOf(FrameURL{})},
-	{FrameTypeURLAudioSource, reflect.TypeOf(FrameURL{})},
-	{FrameTypeURLCommercial, reflect.TypeOf(FrameURL{})},
-	{FrameTypeURLCopyright, reflect.TypeOf(FrameURL{})},
-	{FrameTypeURLCustom, reflect.TypeOf(FrameURLCustom{})},
-	{FrameTypeURLPayment, reflect.TypeOf(FrameURL{})},
-	{FrameTypeURLPublisher, reflect.TypeOf(FrameURL{})},
-	{FrameTypeURLRadioStation, reflect.TypeOf(FrameURL{})},
+	v22         string
+	v23         string
+	v24         string
+}
+
+// frameID returns the frame ID used by the requested codec version.
+func (s *frameSpec) frameID(v Version) string {
+	switch v {
+	case Version2_2:
+		return s.v22
+	case Version2_3:
+		return s.v23
+	case Version2_4:
+		return s.v24
+	default:
+		return ""
+	}
+}
+
+// frameSpecs holds the specifications of all frame types supported by this
+// package. To add support for a new frame type, add a row to this table.
+var frameSpecs = []frameSpec{
+	// frame type, payload structure, v2.2 ID, v2.3 ID, v2.4 ID
+	{FrameTypeTextGroupDescription, reflect.TypeOf(FrameText{}), "TT1", "TIT1", "TIT1"},
+	{FrameTypeTextSongTitle, reflect.TypeOf(FrameText{}), "TT2", "TIT2", "TIT2"},
+	{FrameTypeTextSongSubtitle, reflect.TypeOf(FrameText{}), "TT3", "TIT3", "TIT3"},
+	{FrameTypeTextAlbumName, reflect.TypeOf(FrameText{}), "TAL", "TALB", "TALB"},
+	{FrameTypeTextOriginalAlbum, reflect.TypeOf(FrameText{}), "TOT", "TOAL", "TOAL"},
+	{FrameTypeTextTrackNumber, reflect.TypeOf(FrameText{}), "TRK", "TRCK", "TRCK"},
+	{FrameTypeTextPartOfSet, reflect.TypeOf(FrameText{}), "TPA", "TPOS", "TPOS"},
+	{FrameTypeTextSetSubtitle, reflect.TypeOf(FrameText{}), "", "", "TSST"},
+	{FrameTypeTextISRC, reflect.TypeOf(FrameText{}), "TRC", "TSRC", "TSRC"},
+	{FrameTypeTextArtist, reflect.TypeOf(FrameText{}), "TP1", "TPE1", "TPE1"},
+	{FrameTypeTextAlbumArtist, reflect.TypeOf(FrameText{}), "TP2", "TPE2", "TPE2"},
+	{FrameTypeTextConductor, reflect.TypeOf(FrameText{}), "TP3", "TPE3", "TPE3"},
+	{FrameTypeTextRemixer, reflect.TypeOf(FrameText{}), "TP4", "TPE4", "TPE4"},
+	{FrameTypeTextOriginalPerformer, reflect.TypeOf(FrameText{}), "TOA", "TOPE", "TOPE"},
+	{FrameTypeTextLyricist, reflect.TypeOf(FrameText{}), "TXT", "TEXT", "TEXT"},
+	{FrameTypeTextOriginalLyricist, reflect.TypeOf(FrameText{}), "TOL", "TOLY", "TOLY"},
+	{FrameTypeTextComposer, reflect.TypeOf(FrameText{}), "TCM", "TCOM", "TCOM"},
+	{FrameTypeTextMusicians, reflect.TypeOf(FrameText{}), "", "", "TMCL"},
+	{FrameTypeTextInvolvedPeople, reflect.TypeOf(FrameText{}), "IPL", "IPLS", "TIPL"},
+	{FrameTypeTextEncodedBy, reflect.TypeOf(FrameText{}), "TEN", "TENC", "TENC"},
+	{FrameTypeTextBPM, reflect.TypeOf(FrameText{}), "TBP", "TBPM", "TBPM"},
+	{FrameTypeTextLengthInMs, reflect.TypeOf(FrameText{}), "TLE", "TLEN", "TLEN"},
+	{FrameTypeTextMusicalKey, reflect.TypeOf(FrameText{}), "TKE", "TKEY", "TKEY"},
+	{FrameTypeTextLanguage, reflect.TypeOf(FrameText{}), "TLA", "TLAN", "TLAN"},
+	{FrameTypeTextGenre, reflect.TypeOf(FrameText{}), "TCO", "TCON", "TCON"},
+	{FrameTypeTextFileType, reflect.TypeOf(FrameText{}), "TFT", "TFLT", "TFLT"},
+	{FrameTypeTextMediaType, reflect.TypeOf(FrameText{}), "TMT", "TMED", "TMED"},
+	{FrameTypeTextMood, reflect.TypeOf(FrameText{}), "", "", "TMOO"},
+	{FrameTypeTextCopyright, reflect.TypeOf(FrameText{}), "TCR", "TCOP", "TCOP"},
+	{FrameTypeTextProducedNotice, reflect.TypeOf(FrameText{}), "", "", "TPRO"},
+	{FrameTypeTextPublisher, reflect.TypeOf(FrameText{}), "TPB", "TPUB", "TPUB"},
+	{FrameTypeTextOwner, reflect.TypeOf(FrameText{}), "", "TOWN", "TOWN"},
+	{FrameTypeTextRadioStation, reflect.TypeOf(FrameText{}), "", "TRSN", "TRSN"},
+	{FrameTypeTextRadioStationOwner, reflect.TypeOf(FrameText{}), "", "TRSO", "TRSO"},
+	{FrameTypeTextOriginalFileName, reflect.TypeOf(FrameText{}), "TOF", "TOFN", "TOFN"},
+	{FrameTypeTextPlaylistDelay, reflect.TypeOf(FrameText{}), "TDY", "TDLY", "TDLY"},
+	{FrameTypeTextEncodingTime, reflect.TypeOf(FrameText{}), "", "", "TDEN"},
+	{FrameTypeTextOriginalReleaseTime, reflect.TypeOf(FrameText{}), "TOR", "TORY", "TDOR"},
+	{FrameTypeTextRecordingTime, reflect.TypeOf(FrameText{}), "TYE", "TYER", "TDRC"},
+	{FrameTypeTextReleaseTime, reflect.TypeOf(FrameText{}), "", "", "TDRL"},
+	{FrameTypeTextTaggingTime, reflect.TypeOf(FrameText{}), "", "", "TDTG"},
+	{FrameTypeTextEncodingSoftware, reflect.TypeOf(FrameText{}), "TSS", "TSSE", "TSSE"},
+	{FrameTypeTextAlbumSortOrder, reflect.TypeOf(FrameText{}), "", "", "TSOA"},
+	{FrameTypeTextPerformerSortOrder, reflect.TypeOf(FrameText{}), "", "", "TSOP"},
+	{FrameTypeTextTitleSortOrder, reflect.TypeOf(FrameText{}), "", "", "TSOT"},
+	{FrameTypeTextDate, reflect.TypeOf(FrameText{}), "TDA", "TDAT", ""},
+	{FrameTypeTextTime, reflect.TypeOf(FrameText{}), "TIM", "TIME", ""},
+	{FrameTypeTextRecordingDates, reflect.TypeOf(FrameText{}), "TRD", "TRDA", ""},
+	{FrameTypeTextSize, reflect.TypeOf(FrameText{}), "TSI", "TSIZ", ""},
+	{FrameTypeTextCompilationItunes, reflect.TypeOf(FrameText{}), "TCP", "TCMP", "TCMP"},
+	{FrameTypeTextAlbumSortOrderItunes, reflect.TypeOf(FrameText{}), "TS2", "TSO2", "TSO2"},
+	{FrameTypeTextComposerSortOrderItunes, reflect.TypeOf(FrameText{}), "TSC", "TSOC", "TSOC"},
+	{FrameTypeTextPodcastCategoryItunes, reflect.TypeOf(FrameText{}), "TCT", "TCAT", "TCAT"},
+	{FrameTypeTextPodcastDescriptionItunes, reflect.TypeOf(FrameText{}), "TDS", "TDES", "TDES"},
+	{FrameTypeTextPodcastIDItunes, reflect.TypeOf(FrameText{}), "TID", "TGID", "TGID"},
+	{FrameTypeTextPodcastFeedItunes, reflect.TypeOf(FrameText{}), "WFD", "WFED", "WFED"},
+	{FrameTypeTextCustom, reflect.TypeOf(FrameTextCustom{}), "TXX", "TXXX", "TXXX"},
+	{FrameTypeURLArtist, reflect.TypeOf(FrameURL{}), "WAR", "WOAR", "WOAR"},
+	{FrameTypeURLAudioFile, reflect.TypeOf(FrameURL{}), "WAF", "WOAF", "WOAF"},
+	{FrameTypeURLAudioSource, reflect.TypeOf(FrameURL{}), "WAS", "WOAS", "WOAS"},
+	{FrameTypeURLCommercial, reflect.TypeOf(FrameURL{}), "WCM", "WCOM", "WCOM"},
+	{FrameTypeURLCopyright, reflect.TypeOf(FrameURL{}), "WCP", "WCOP", "WCOP"},
+	{FrameTypeURLPayment, reflect.TypeOf(FrameURL{}), "", "WPAY", "WPAY"},
+	{FrameTypeURLPublisher, reflect.TypeOf(FrameURL{}), "WPB", "WPUB", "WPUB"},
+	{FrameTypeURLRadioStation, reflect.TypeOf(FrameURL{}), "", "WORS", "WORS"},
+	{FrameTypeURLCustom, reflect.TypeOf(FrameURLCustom{}), "WXX", "WXXX", "WXXX"},
+	{FrameTypeAttachedPicture, reflect.TypeOf(FrameAttachedPicture{}), "PIC", "APIC", "APIC"},
+	{FrameTypeAudioEncryption, reflect.TypeOf(FrameAudioEncryption{}), "CRA", "AENC", "AENC"},
+	{FrameTypeAudioSeekPointIndex, reflect.TypeOf(FrameAudioSeekPointIndex{}), "", "", "ASPI"},
+	{FrameTypeChapter, reflect.TypeOf(FrameChapter{}), "", "CHAP", "CHAP"},
+	{FrameTypeComment, reflect.TypeOf(FrameComment{}), "COM", "COMM", "COMM"},
+	{FrameTypeEncryptionMethodRegistration, reflect.TypeOf(FrameEncryptionMethodRegistration{}), "", "ENCR", "ENCR"},
+	{FrameTypeEqualization, reflect.TypeOf(FrameEqualization{}), "EQU", "EQUA", ""},
+	{FrameTypeEqualization2, reflect.TypeOf(FrameEqualization2{}), "", "", "EQU2"},
+	{FrameTypeGeneralObject, reflect.TypeOf(FrameGeneralObject{}), "GEO", "GEOB", "GEOB"},
+	{FrameTypeGroupID, reflect.TypeOf(FrameGroupID{}), "", "GRID", "GRID"},
+	{FrameTypeLyricsSync, reflect.TypeOf(FrameLyricsSync{}), "SLT", "SYLT", "SYLT"},
+	{FrameTypeLyricsUnsync, reflect.TypeOf(FrameLyricsUnsync{}), "ULT", "USLT", "USLT"},
+	{FrameTypeMusicCDIdentifier, reflect.TypeOf(FrameMusicCDIdentifier{}), "MCI", "MCDI", "MCDI"},
+	{FrameTypePlayCount, reflect.TypeOf(FramePlayCount{}), "CNT", "PCNT", "PCNT"},
+	{FrameTypePodcastItunes, reflect.TypeOf(FramePodcast{}), "PCS", "PCST", "PCST"},
+	{FrameTypePopularimeter, reflect.TypeOf(FramePopularimeter{}), "POP", "POPM", "POPM"},
+	{FrameTypePrivate, reflect.TypeOf(FramePrivate{}), "", "PRIV", "PRIV"},
+	{FrameTypeSeek, reflect.TypeOf(FrameSeek{}), "", "", "SEEK"},
+	{FrameTypeSignature, reflect.TypeOf(FrameSignature{}), "", "", "SIGN"},
+	{FrameTypeSyncTempoCodes, reflect.TypeOf(FrameSyncTempoCodes{}), "STC", "SYTC", "SYTC"},
+	{FrameTypeTableOfContents, reflect.TypeOf(FrameTableOfContents{}), "", "CTOC", "CTOC"},
+	{FrameTypeTermsOfUse, reflect.TypeOf(FrameTermsOfUse{}), "", "USER", "USER"},
+	{FrameTypeUniqueFileID, reflect.TypeOf(FrameUniqueFileID{}), "UFI", "UFID", "UFID"},
+	{FrameTypeVolumeAdjustment, reflect.TypeOf(FrameVolumeAdjustment{}), "RVA", "RVAD", ""},
+	{FrameTypeVolumeAdjustment2, reflect.TypeOf(FrameVolumeAdjustment2{}), "", "", "RVA2"},
+	{FrameTypeUnknown, reflect.TypeOf(FrameUnknown{}), "ZZZ", "ZZZZ", "ZZZZ"},
 }
 
 type frameTypeMap struct {
@@ -802,23 +1291,75 @@ type frameTypeMap struct {
 	FrameIDToReflectType map[string]reflect.Type
 }
 
-func newFrameTypeMap(frameTypeToFrameID map[FrameType]string) *frameTypeMap {
+func newFrameTypeMap(v Version) *frameTypeMap {
 	m := &frameTypeMap{
-		FrameTypeToFrameID:   frameTypeToFrameID,
+		FrameTypeToFrameID:   make(map[FrameType]string),
 		FrameIDToFrameType:   make(map[string]FrameType),
 		FrameIDToReflectType: make(map[string]reflect.Type),
 	}
 
-	for k, v := range m.FrameTypeToFrameID {
-		m.FrameIDToFrameType[v] = k
+	for i := range frameSpecs {
+		s := &frameSpecs[i]
+		id := s.frameID(v)
+		if id == "" {
+			continue
+		}
+		m.FrameTypeToFrameID[s.frameType] = id
+		m.FrameIDToFrameType[id] = s.frameType
+		m.FrameIDToReflectType[id] = s.reflectType
 	}
 
-	for _, f := range frameList {
-		id := m.FrameTypeToFrameID[f.frameType]
-		m.FrameIDToReflectType[id] = f.reflectType
+	return m
+}
+
+// RepairFrameID attempts to map a malformed frame ID containing lowercase
+// letters or trailing spaces (e.g., "Tit2" or "COM ") to a canonical frame ID.
+// It returns false if the ID isn't malformed or if the repair would be
+// ambiguous.
+func (m *frameTypeMap) RepairFrameID(id string) (string, bool) {
+	if id == strings.ToUpper(id) && !strings.HasSuffix(id, " ") {
+		return "", false
 	}
 
-	return m
+	prefix := strings.ToUpper(strings.TrimRight(id, " "))
+	if prefix == "" || strings.Contains(prefix, " ") {
+		return "", false
+	}
+
+	var match string
+	for k, t := range m.FrameIDToFrameType {
+		if t == FrameTypeUnknown || !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if k == prefix {
+			return k, true
+		}
+		if match != "" {
+			return "", false // ambiguous
+		}
+		match = k
+	}
+	return match, match != ""
+}
+
+// FrameAfterPadding returns the offset of a frame header with a known frame
+// ID that follows a run of zero padding bytes at the start of b. It returns
+// -1 if b contains nothing but padding or if the padding is followed by
+// anything other than a known frame ID.
+func (m *frameTypeMap) FrameAfterPadding(b []byte, idLen int) int {
+	i := 0
+	for i < len(b) && b[i] == 0 {
+		i++
+	}
+	if i+idLen > len(b) {
+		return -1
+	}
+
+	t, ok := m.FrameIDToFrameType[string(b[i:i+idLen])]
+	if !ok || t == FrameTypeUnknown {
+		return -1
+	}
+	return i
 }
 
 func (m *frameTypeMap) LookupFrameID(t FrameType) string {
@@ -844,3 +1385,78 @@ func (m *frameTypeMap) LookupFrameType(id string) FrameType {
 	}
 	return t
 }
+
+// A FrameSpec documents the layout of a frame type supported by this
+// package.
+type FrameSpec struct {
+	Type    FrameType          // frame type
+	Payload string             // name of the structure holding the frame's payload
+	IDs     map[Version]string // frame ID used by each version supporting the frame
+	Fields  []FieldSpec        // payload fields, in encoding order
+}
+
+// A FieldSpec documents a single payload field within a frame.
+type FieldSpec struct {
+	Name    string // field name
+	Type    string // Go type of the field
+	Options string // options from the field's id3 struct tag, if any
+}
+
+// FrameSpecs returns the specifications of all frame types supported by this
+// package, in frame type order. It is intended for use by documentation and
+// schema tooling.
+func FrameSpecs() []FrameSpec {
+	specs := make([]FrameSpec, 0, len(frameSpecs))
+	for i := range frameSpecs {
+		s := &frameSpecs[i]
+		spec := FrameSpec{
+			Type:    s.frameType,
+			Payload: s.reflectType.Name(),
+			IDs:     make(map[Version]string),
+		}
+		for _, v := range []Version{Version2_2, Version2_3, Version2_4} {
+			if id := s.frameID(v); id != "" {
+				spec.IDs[v] = id
+			}
+		}
+		for j, n := 0, s.reflectType.NumField(); j < n; j++ {
+			field := s.reflectType.Field(j)
+			if field.Type == reflect.TypeOf(FrameHeader{}) || field.PkgPath != "" {
+				continue
+			}
+			spec.Fields = append(spec.Fields, FieldSpec{
+				Name:    field.Name,
+				Type:    field.Type.String(),
+				Options: field.Tag.Get("id3"),
+			})
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// FrameSupported returns true if the frame ID identifies a frame type
+// supported by the requested version of the codec.
+func FrameSupported(id string, v Version) bool {
+	for i := range frameSpecs {
+		s := &frameSpecs[i]
+		if s.frameType != FrameTypeUnknown && id != "" && s.frameID(v) == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportedFrames returns a sorted list of the frame IDs supported by the
+// requested version of the codec.
+func SupportedFrames(v Version) []string {
+	ids := []string{}
+	for i := range frameSpecs {
+		s := &frameSpecs[i]
+		if id := s.frameID(v); id != "" && s.frameType != FrameTypeUnknown {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,178 @@
+package id3
+
+import (
+	"io"
+	"reflect"
+)
+
+// A FrozenTag is a read-only view of a tag. Unlike a Tag, a FrozenTag may
+// be shared by multiple goroutines without synchronization, for example by
+// server request handlers sharing a decoded tag.
+//
+// Frames retrieved from a FrozenTag are shared with the view, so reading
+// them costs no copies, and they must not be modified. Use Thaw to obtain
+// a modifiable copy of the tag.
+type FrozenTag struct {
+	t *Tag
+}
+
+// Freeze returns a read-only view of the tag. Later changes to the tag and
+// its frames, such as adding frames or setting their fields, don't affect
+// the view. Byte slices held by the tag and its frames, such as picture and
+// private data, are shared with the view rather than copied, so they must
+// be replaced rather than modified in place.
+func (t *Tag) Freeze() *FrozenTag {
+	return &FrozenTag{t: t.copyOnWrite()}
+}
+
+// Thaw returns a modifiable copy of the tag viewed by the frozen tag.
+// Frame data byte slices are shared with the view, so they must be replaced
+// rather than modified in place.
+func (ft *FrozenTag) Thaw() *Tag {
+	return ft.t.copyOnWrite()
+}
+
+// Version returns the ID3 version of the tag.
+func (ft *FrozenTag) Version() Version {
+	return ft.t.Version
+}
+
+// Flags returns the tag's flags.
+func (ft *FrozenTag) Flags() TagFlags {
+	return ft.t.Flags
+}
+
+// NumFrames returns the number of frames in the tag.
+func (ft *FrozenTag) NumFrames() int {
+	return len(ft.t.Frames)
+}
+
+// Frame returns the tag's i-th frame, which must not be modified.
+func (ft *FrozenTag) Frame(i int) Frame {
+	return ft.t.Frames[i]
+}
+
+// FindFrame returns the tag's first frame of the requested type, or nil if
+// there is none. The frame must not be modified.
+func (ft *FrozenTag) FindFrame(typ FrameType) Frame {
+	return ft.t.FindFrame(typ)
+}
+
+// FindFrames returns all the tag's frames of the requested type, which
+// must not be modified.
+func (ft *FrozenTag) FindFrames(typ FrameType) []Frame {
+	return ft.t.FindFrames(typ)
+}
+
+// WriteTo writes the tag to an output stream. It returns the number of bytes
+// written and any error encountered during encoding.
+func (ft *FrozenTag) WriteTo(w io.Writer) (int64, error) {
+	return ft.Thaw().WriteTo(w)
+}
+
+// Clone returns a deep copy of the tag. The copy's frames, including their
+// byte slices, are copies of the tag's frames, so the copy may be modified
+// without affecting the tag or the buffers the tag was decoded from. Frames
+// marked as modified in the tag are marked as modified in the copy.
+func (t *Tag) Clone() *Tag {
+	c := t.copyTag(true)
+	if t.ExtendedUnknown != nil {
+		c.ExtendedUnknown = append([]byte{}, t.ExtendedUnknown...)
+	}
+	return c
+}
+
+// CloneFrame returns a deep copy of a frame, including its byte slices,
+// embedded subframes and annotations.
+func CloneFrame(f Frame) Frame {
+	return copyFrame(f, true)
+}
+
+// copyOnWrite returns a copy of the tag whose frames are copies of the
+// tag's frames, sharing frame data byte slices. No frames of the copy are
+// marked as modified.
+func (t *Tag) copyOnWrite() *Tag {
+	c := t.copyTag(false)
+	c.dirty = nil
+	return c
+}
+
+// copyTag returns a copy of the tag whose frames are copies of the tag's
+// frames. Byte slices are copied only if copyBytes is true.
+func (t *Tag) copyTag(copyBytes bool) *Tag {
+	c := *t
+	c.dirty = nil
+	c.defaulted = nil
+	c.Frames = make([]Frame, len(t.Frames))
+	for i, f := range t.Frames {
+		c.Frames[i] = copyFrame(f, copyBytes)
+		if t.dirty[f] {
+			c.MarkDirty(c.Frames[i])
+		}
+		if t.defaulted[f] {
+			if c.defaulted == nil {
+				c.defaulted = make(map[Frame]bool)
+			}
+			c.defaulted[c.Frames[i]] = true
+		}
+	}
+	if t.encryption != nil {
+		c.encryption = make(map[byte]EncryptionCodec, len(t.encryption))
+		for k, v := range t.encryption {
+			c.encryption[k] = v
+		}
+	}
+	return &c
+}
+
+// copyFrame returns a copy of a frame. The slices held by the frame are
+// copied, and embedded subframes are copied recursively. Byte slices are
+// copied only if copyBytes is true; otherwise they are shared. The frame's
+// annotations are always copied.
+func copyFrame(f Frame, copyBytes bool) Frame {
+	src := reflect.ValueOf(f).Elem()
+	dst := reflect.New(src.Type()).Elem()
+	dst.Set(src)
+	copySlices(dst, copyBytes)
+
+	c := dst.Addr().Interface().(Frame)
+	if h := HeaderOf(c); h.notes != nil {
+		h.notes = h.notes.copy()
+	}
+	return c
+}
+
+// copySlices replaces the slices held by the fields of a struct with
+// copies. The slices held by struct elements of the slices are copied as
+// well.
+func copySlices(v reflect.Value, copyBytes bool) {
+	for i, n := 0, v.NumField(); i < n; i++ {
+		fv := v.Field(i)
+		if fv.Kind() != reflect.Slice || fv.IsNil() || !fv.CanSet() {
+			continue
+		}
+
+		switch fv.Type().Elem().Kind() {
+		case reflect.Uint8:
+			if !copyBytes {
+				continue
+			}
+		case reflect.Interface:
+			ff := make([]Frame, fv.Len())
+			for j := range ff {
+				ff[j] = copyFrame(fv.Index(j).Interface(), copyBytes)
+			}
+			fv.Set(reflect.ValueOf(ff))
+			continue
+		}
+
+		c := reflect.MakeSlice(fv.Type(), fv.Len(), fv.Len())
+		reflect.Copy(c, fv)
+		if c.Type().Elem().Kind() == reflect.Struct {
+			for j := 0; j < c.Len(); j++ {
+				copySlices(c.Index(j), copyBytes)
+			}
+		}
+		fv.Set(c)
+	}
+}
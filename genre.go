@@ -0,0 +1,176 @@
+package id3
+
+import (
+	"strconv"
+	"strings"
+)
+
+// V1Genres holds the genre names of the ID3v1 genre table, indexed by
+// genre number. Entries 80 and above are the Winamp extensions to the
+// original table. ID3v2 content type (TCON) frames may refer to genres by
+// their number in this table.
+var V1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge",
+	"Hip-Hop", "Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B",
+	"Rap", "Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska",
+	"Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient",
+	"Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance", "Classical",
+	"Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative",
+	"Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic", "Darkwave",
+	"Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap",
+	"Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave",
+	"Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi", "Tribal",
+	"Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll",
+	"Hard Rock",
+
+	// Winamp extensions
+	"Folk", "Folk-Rock", "National Folk", "Swing", "Fast Fusion", "Bebob",
+	"Latin", "Revival", "Celtic", "Bluegrass", "Avantgarde", "Gothic Rock",
+	"Progressive Rock", "Psychedelic Rock", "Symphonic Rock", "Slow Rock",
+	"Big Band", "Chorus", "Easy Listening", "Acoustic", "Humour", "Speech",
+	"Chanson", "Opera", "Chamber Music", "Sonata", "Symphony", "Booty Bass",
+	"Primus", "Porn Groove", "Satire", "Slow Jam", "Club", "Tango", "Samba",
+	"Folklore", "Ballad", "Power Ballad", "Rhythmic Soul", "Freestyle",
+	"Duet", "Punk Rock", "Drum Solo", "A capella", "Euro-House",
+	"Dance Hall", "Goa", "Drum & Bass", "Club-House", "Hardcore", "Terror",
+	"Indie", "BritPop", "Negerpunk", "Polsk Punk", "Beat",
+	"Christian Gangsta Rap", "Heavy Metal", "Black Metal", "Crossover",
+	"Contemporary Christian", "Christian Rock", "Merengue", "Salsa",
+	"Thrash Metal", "Anime", "JPop", "Synthpop", "Abstract", "Art Rock",
+	"Baroque", "Bhangra", "Big Beat", "Breakbeat", "Chillout", "Downtempo",
+	"Dub", "EBM", "Eclectic", "Electro", "Electroclash", "Emo",
+	"Experimental", "Garage", "Global", "IDM", "Illbient", "Industro-Goth",
+	"Jam Band", "Krautrock", "Leftfield", "Lounge", "Math Rock",
+	"New Romantic", "Nu-Breakz", "Post-Punk", "Post-Rock", "Psytrance",
+	"Shoegaze", "Space Rock", "Trop Rock", "World Music", "Neoclassical",
+	"Audiobook", "Audio Theatre", "Neue Deutsche Welle", "Podcast",
+	"Indie Rock", "G-Funk", "Dubstep", "Garage Rock", "Psybient",
+}
+
+// Genre names of the special content type references.
+const (
+	GenreRemix = "Remix"
+	GenreCover = "Cover"
+)
+
+// Genres returns the genres stored in the tag's content type (TCON) frame.
+// Numeric references to the ID3v1 genre table, whether bare (v2.4) or
+// parenthesized (v2.3), are replaced by genre names, and the "RX" and "CR"
+// references by GenreRemix and GenreCover. A refinement in tags older than
+// v2.4 is split into several genres at slashes, as stored by SetGenres. A
+// refinement that repeats the name of a reference is dropped, as are
+// duplicate and empty genres.
+func (t *Tag) Genres() []string {
+	f, ok := t.FindFrame(FrameTypeTextGenre).(*FrameText)
+	if !ok {
+		return nil
+	}
+
+	var genres []string
+	seen := make(map[string]bool)
+	add := func(g string) {
+		if g = strings.TrimSpace(g); g != "" && !seen[g] {
+			genres = append(genres, g)
+			seen[g] = true
+		}
+	}
+
+	for _, s := range f.Text {
+		s = strings.TrimSpace(s)
+		if g, ok := genreReference(s); ok {
+			add(g)
+			continue
+		}
+
+		// Consume v2.3 parenthesized references, which precede an
+		// optional refinement. A refinement starting with "(" is escaped
+		// as "((".
+		for strings.HasPrefix(s, "(") && !strings.HasPrefix(s, "((") {
+			i := strings.Index(s, ")")
+			if i < 0 {
+				break
+			}
+			g, ok := genreReference(s[1:i])
+			if !ok {
+				break
+			}
+			add(g)
+			s = s[i+1:]
+		}
+		if strings.HasPrefix(s, "((") {
+			s = s[1:]
+		}
+		if t.Version >= Version2_4 {
+			add(s)
+			continue
+		}
+		for _, g := range strings.Split(s, "/") {
+			add(g)
+		}
+	}
+	return genres
+}
+
+// SetGenres stores genres in the tag's content type (TCON) frame, adding
+// the frame if necessary. Genres are stored by name in v2.4 tags, one per
+// text value. In earlier versions, genres found in the ID3v1 genre table,
+// along with GenreRemix and GenreCover, are stored as parenthesized
+// references, followed by the remaining genres joined by slashes, so
+// genre names in these versions shouldn't contain slashes.
+func (t *Tag) SetGenres(genres ...string) {
+	if t.Version >= Version2_4 {
+		t.setTextValues(FrameTypeTextGenre, genres...)
+		return
+	}
+
+	var refs string
+	var names []string
+	for _, g := range genres {
+		switch n := V1GenreNumber(g); {
+		case g == GenreRemix:
+			refs += "(RX)"
+		case g == GenreCover:
+			refs += "(CR)"
+		case n >= 0:
+			refs += "(" + strconv.Itoa(n) + ")"
+		default:
+			names = append(names, g)
+		}
+	}
+
+	s := strings.Join(names, "/")
+	if strings.HasPrefix(s, "(") {
+		s = "(" + s
+	}
+	t.setTextValues(FrameTypeTextGenre, refs+s)
+}
+
+// V1GenreNumber returns the number of a genre in the ID3v1 genre table,
+// matching its name without regard to case. It returns -1 if the genre
+// isn't in the table.
+func V1GenreNumber(name string) int {
+	for i, g := range V1Genres {
+		if strings.EqualFold(g, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// genreReference resolves a genre reference: a number in the ID3v1 genre
+// table, "RX" or "CR".
+func genreReference(s string) (string, bool) {
+	switch s {
+	case "RX":
+		return GenreRemix, true
+	case "CR":
+		return GenreCover, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n >= len(V1Genres) || strings.TrimSpace(s) != s {
+		return "", false
+	}
+	return V1Genres[n], true
+}
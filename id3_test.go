@@ -2,8 +2,32 @@ package id3
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestHeader(t *testing.T) {
@@ -28,7 +52,7 @@ func TestHeader(t *testing.T) {
 		{[]byte{0x49, 0x44, 0x33, 0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x00, 0x00}, Tag{Version: Version2_3, Size: 5, Padding: 5}, ""},
 		{[]byte{0x49, 0x44, 0x33, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x00}, Tag{}, "unexpected EOF"},
 		{[]byte{0x49, 0x44, 0x33, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}, Tag{}, "unexpected EOF"},
-		{[]byte{0x49, 0x44, 0x33, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0c}, Tag{}, "unexpected EOF"},
+		{[]byte{0x49, 0x44, 0x33, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0c}, Tag{}, "tag declares 12 bytes of data but only 0 are available"},
 		{[]byte{0x49, 0x44, 0x33, 0x04, 0x10, 0x40, 0x00, 0x00, 0x00, 0x0c}, Tag{}, "invalid id3 tag"},
 		{[]byte{0x48, 0x44, 0x33, 0x04, 0x00, 0x00, 0x7f, 0x7f, 0x7f, 0x7f}, Tag{}, "invalid id3 tag"},
 		{[]byte{0x49, 0x44, 0x33, 0x04, 0x00, 0x00, 0x00, 0x00, 0x39}, Tag{}, "unexpected EOF"},
@@ -523,6 +547,34 @@ func TestPCNT(t *testing.T) {
 	}
 }
 
+func TestLargeCounter(t *testing.T) {
+	c := new(big.Int).Lsh(big.NewInt(0x1234), 80)
+
+	f := NewFramePlayCount(0)
+	f.SetCounter(c)
+	serialize(t, f)
+
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames, f)
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	f2 := tag2.FindFrame(FrameTypePlayCount).(*FramePlayCount)
+	if f2.Counter().Cmp(c) != 0 {
+		t.Errorf("counter mismatch: %v != %v", f2.Counter(), c)
+	}
+
+	if len(NewFramePlayCount(1).CounterBytes) != 4 {
+		t.Error("counter shorter than 4 bytes")
+	}
+}
+
 func TestPOPM(t *testing.T) {
 	for _, c := range counts {
 		f := NewFramePopularimeter("johndoe@gmail.com", 80, c)
@@ -561,3 +613,4694 @@ func TestUFID(t *testing.T) {
 	f := NewFrameUniqueFileID("owner", "b28f6045-9958-44b5-9da8-34703f5ffa13")
 	serialize(t, f)
 }
+
+func TestIPLS(t *testing.T) {
+	credits := []Credit{{"producer", "John Doe"}, {"engineer", "Jane Doe"}}
+
+	tag1 := Tag{Version: Version2_3}
+	tag1.Frames = append(tag1.Frames, NewFrameCredits(FrameTypeTextInvolvedPeople, credits))
+	tag1.Frames[0].(*FrameText).Encoding = EncodingISO88591
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag1.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("IPLS")) {
+		t.Error("IPLS frame not encoded")
+	}
+
+	tag2 := Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	f, ok := tag2.FindFrame(FrameTypeTextInvolvedPeople).(*FrameText)
+	if !ok {
+		t.Fatal("IPLS frame not decoded")
+	}
+	got := f.Credits()
+	if len(got) != len(credits) || got[0] != credits[0] || got[1] != credits[1] {
+		t.Errorf("IPLS credits mismatch: got %v, expected %v", got, credits)
+	}
+
+	// Upgrading the tag to v2.4 should produce a TIPL frame.
+	tag2.Version = Version2_4
+	buf.Reset()
+	if _, err := tag2.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("TIPL")) {
+		t.Error("TIPL frame not encoded on upgrade")
+	}
+}
+
+func TestSealTag(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag1 := NewTag(Version2_4, 0)
+	tag1.Frames = append(tag1.Frames, NewFrameText(FrameTypeTextSongTitle, "Title"))
+
+	b, err := SealTag(tag1, aead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("Title")) {
+		t.Error("sealed tag contains plaintext")
+	}
+
+	tag2, err := OpenTag(b, aead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, ok := tag2.FindFrame(FrameTypeTextSongTitle).(*FrameText)
+	if !ok || f.Text[0] != "Title" {
+		t.Error("opened tag missing title frame")
+	}
+
+	b[len(b)-1] ^= 0xff
+	if _, err := OpenTag(b, aead); err != ErrInvalidEnvelope {
+		t.Errorf("tampered envelope: got %v, expected %v", err, ErrInvalidEnvelope)
+	}
+}
+
+func TestFitV1Text(t *testing.T) {
+	ascii := func(s string) string {
+		return strings.NewReplacer("ř", "r", "á", "a").Replace(s)
+	}
+
+	var cases = []struct {
+		input string
+		n     int
+		opts  V1TextOptions
+		fit   string
+		lost  string
+	}{
+		{"Short", 30, V1TextOptions{}, "Short", ""},
+		{"ÆÆÆÆÆ", 3, V1TextOptions{}, "ÆÆÆ", "ÆÆ"},
+		{"The quick brown fox", 12, V1TextOptions{}, "The quick br", "own fox"},
+		{"The quick brown fox", 12, V1TextOptions{WordBoundary: true}, "The quick", "brown fox"},
+		{"Dvořák", 6, V1TextOptions{Transliterate: ascii}, "Dvorak", ""},
+	}
+
+	for i, c := range cases {
+		fit, lost := FitV1Text(c.input, c.n, c.opts)
+		if fit != c.fit || lost != c.lost {
+			t.Errorf("case %d: got (%q, %q), expected (%q, %q)", i, fit, lost, c.fit, c.lost)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tag := NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameText(FrameTypeTextArtist, "AC/DC"),
+		NewFrameText(FrameTypeTextTrackNumber, "3/12"),
+		NewFrameText(FrameTypeTextRecordingTime, "1999"),
+		NewFrameText(FrameTypeTextGenre, "(17)"),
+		NewFrameText(FrameTypeTextLengthInMs, "215000"),
+		NewFrameAttachedPicture("image/jpeg", "", PictureTypeCoverFront, []byte{}),
+	)
+
+	info := Summarize(tag)
+	switch {
+	case info.Title != "Title":
+		t.Errorf("title: got %q", info.Title)
+	case len(info.Artists) != 1 || info.Artists[0] != "AC/DC":
+		t.Errorf("artists: got %q", info.Artists)
+	case info.Track != 3 || info.TrackTotal != 12:
+		t.Errorf("track: got %d/%d", info.Track, info.TrackTotal)
+	case info.Year != 1999:
+		t.Errorf("year: got %d", info.Year)
+	case info.Genre != "Rock":
+		t.Errorf("genre: got %q", info.Genre)
+	case info.DurationMs != 215000:
+		t.Errorf("duration: got %d", info.DurationMs)
+	case !info.HasArt:
+		t.Error("art not detected")
+	}
+}
+
+func TestCanUpdateFile(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextSongTitle, "Title"))
+	tag.Padding = 256
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tagSize := buf.Len()
+	buf.WriteString("audio data")
+
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := CanUpdateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case !plan.HasTag:
+		t.Error("tag not detected")
+	case plan.TagSize != tagSize:
+		t.Errorf("tag size: got %d, expected %d", plan.TagSize, tagSize)
+	case plan.Padding != 256:
+		t.Errorf("padding: got %d, expected 256", plan.Padding)
+	case !plan.InPlace(tagSize) || plan.InPlace(tagSize+1):
+		t.Error("in-place check incorrect")
+	case !plan.CanUpdate():
+		t.Error("file should be updatable")
+	}
+}
+
+func TestFrameSpecs(t *testing.T) {
+	var found bool
+	for _, s := range FrameSpecs() {
+		if s.Type != FrameTypeComment {
+			continue
+		}
+		found = true
+		if s.Payload != "FrameComment" || s.IDs[Version2_4] != "COMM" {
+			t.Errorf("COMM spec incorrect: %+v", s)
+		}
+		if len(s.Fields) != 4 || s.Fields[1].Name != "Language" {
+			t.Errorf("COMM fields incorrect: %+v", s.Fields)
+		}
+	}
+	if !found {
+		t.Error("COMM spec not found")
+	}
+}
+
+func TestSupportedFrames(t *testing.T) {
+	var cases = []struct {
+		id        string
+		version   Version
+		supported bool
+	}{
+		{"TDRC", Version2_4, true},
+		{"TDRC", Version2_3, false},
+		{"TYER", Version2_3, true},
+		{"TYER", Version2_4, false},
+		{"IPLS", Version2_3, true},
+		{"ZZZZ", Version2_4, false},
+		{"", Version2_4, false},
+	}
+
+	for i, c := range cases {
+		if FrameSupported(c.id, c.version) != c.supported {
+			t.Errorf("case %d: FrameSupported(%q, %v) != %v", i, c.id, c.version, c.supported)
+		}
+	}
+
+	ids := SupportedFrames(Version2_4)
+	if !sort.StringsAreSorted(ids) || len(ids) == 0 {
+		t.Error("SupportedFrames returned an invalid list")
+	}
+}
+
+func TestReadAllTags(t *testing.T) {
+	tag2 := NewTag(Version2_4, 0)
+	tag2.Frames = append(tag2.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Updated title"),
+		NewFrameComment("eng", "", "Second comment"),
+		NewFrameURL(FrameTypeURLArtist, "http://a.example.com"),
+		NewFrameURL(FrameTypeURLArtist, "http://b.example.com"),
+	)
+
+	tag1 := NewTag(Version2_4, 0)
+	tag1.Frames = append(tag1.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameComment("eng", "", "First comment"),
+		NewFrameURL(FrameTypeURLArtist, "http://old.example.com"),
+		NewFrameSeek(5),
+	)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag1.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString("audio")
+	if _, err := tag2.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := ReadAllTags(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	titles := tag.FindFrames(FrameTypeTextSongTitle)
+	if len(titles) != 1 || titles[0].(*FrameText).Text[0] != "Updated title" {
+		t.Error("title frame not replaced by chained tag")
+	}
+	if len(tag.FindFrames(FrameTypeComment)) != 2 {
+		t.Error("comment frames not merged")
+	}
+	urls := tag.FindFrames(FrameTypeURLArtist)
+	if len(urls) != 2 || urls[0].(*FrameURL).URL != "http://a.example.com" {
+		t.Error("URL frames not replaced by chained tag")
+	}
+	if tag.FindFrame(FrameTypeSeek) != nil {
+		t.Error("seek frame not removed")
+	}
+}
+
+func TestApplyUpdate(t *testing.T) {
+	discard := NewFrameUnknown("XYZW", []byte{1})
+	discard.Header.Flags = FrameFlagDiscardOnTagAlteration
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameComment("eng", "", "First comment"),
+		discard,
+		NewFrameUnknown("XYZV", []byte{2}),
+	)
+
+	update := NewTag(Version2_4, 0)
+	update.Frames = append(update.Frames,
+		NewFrameComment("eng", "", "Replaced comment"),
+		NewFrameComment("deu", "", "Added comment"),
+		NewFrameText(FrameTypeTextSongTitle, "Updated title"),
+	)
+	if err := tag.ApplyUpdate(update); err != ErrNotUpdate {
+		t.Fatalf("expected ErrNotUpdate, got %v", err)
+	}
+
+	kept := tag.Frames[3]
+	update.Flags = TagFlagIsUpdate
+	if err := tag.ApplyUpdate(update); err != nil {
+		t.Fatal(err)
+	}
+	want := []Frame{update.Frames[2], update.Frames[0], kept, update.Frames[1]}
+	if len(tag.Frames) != len(want) {
+		t.Fatalf("got %d frames, expected %d", len(tag.Frames), len(want))
+	}
+	for i := range want {
+		if tag.Frames[i] != want[i] {
+			t.Errorf("frame %d: got %v", i, tag.Frames[i])
+		}
+	}
+	if len(tag.DirtyFrames()) != 3 {
+		t.Errorf("got %d dirty frames, expected 3", len(tag.DirtyFrames()))
+	}
+}
+
+func TestReadTagChain(t *testing.T) {
+	buf := bytes.NewBuffer([]byte{})
+	for i, title := range []string{"First", "Second", "Third"} {
+		tag := NewTag(Version2_4, 0)
+		tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextSongTitle, title))
+		if i < 2 {
+			tag.Frames = append(tag.Frames, NewFrameSeek(uint32(i)))
+		}
+		if _, err := tag.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		buf.WriteString("ab"[:i])
+	}
+
+	tags, err := ReadTagChain(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 3 {
+		t.Fatalf("expected 3 tags, got %d", len(tags))
+	}
+	for i, title := range []string{"First", "Second", "Third"} {
+		if s := tags[i].FindFrame(FrameTypeTextSongTitle).(*FrameText).Text[0]; s != title {
+			t.Errorf("tag %d: expected title %q, got %q", i, title, s)
+		}
+	}
+	if tags[0].FindFrame(FrameTypeSeek) == nil {
+		t.Error("seek frame removed from chained tag")
+	}
+
+	// A broken link returns the tags read so far.
+	b := buf.Bytes()
+	tags, err = ReadTagChain(bytes.NewReader(b[:len(b)-5]))
+	if err == nil || len(tags) != 2 {
+		t.Errorf("expected 2 tags and an error, got %d tags and %v", len(tags), err)
+	}
+}
+
+func TestSEEK(t *testing.T) {
+	f := NewFrameSeek(0x12345)
+	serialize(t, f)
+}
+
+func TestCompressedFrames(t *testing.T) {
+	text := strings.Repeat("compressible text ", 20)
+	for _, v := range []Version{Version2_3, Version2_4} {
+		f := NewFrameText(FrameTypeTextSongTitle, text)
+		f.Header.SetFlag(FrameFlagCompressed, true)
+
+		tag1 := NewTag(v, 0)
+		tag1.Frames = append(tag1.Frames, f)
+
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag1.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(buf.Bytes(), []byte(text)) {
+			t.Errorf("v2.%d: frame not compressed", v)
+		}
+
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFrom(buf); err != nil {
+			t.Fatal(err)
+		}
+		ft, ok := tag2.FindFrame(FrameTypeTextSongTitle).(*FrameText)
+		if !ok || ft.Text[0] != text {
+			t.Errorf("v2.%d: compressed frame not decoded", v)
+		}
+		if int(ft.Header.DataLength) != len(text)+1 {
+			t.Errorf("v2.%d: data length incorrect: %d", v, ft.Header.DataLength)
+		}
+	}
+}
+
+func TestDataLengthMismatch(t *testing.T) {
+	f := NewFrameText(FrameTypeTextSongTitle, "Title")
+	f.Header.SetFlag(FrameFlagHasDataLength, true)
+
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames, f)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the data length indicator following the frame header.
+	b := buf.Bytes()
+	copy(b[20:24], []byte{0, 0, 0, 1})
+
+	opts := &DecodeOptions{StrictDataLength: true}
+	if _, err := new(Tag).ReadFromWithOptions(bytes.NewReader(b), opts); err != ErrInvalidDataLength {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidDataLength)
+	}
+
+	report := &DecodeReport{}
+	opts = &DecodeOptions{Report: report}
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFromWithOptions(bytes.NewReader(b), opts); err != nil {
+		t.Fatal(err)
+	}
+	if tag2.FindFrame(FrameTypeTextSongTitle) == nil {
+		t.Error("frame not decoded")
+	}
+	if len(report.Warnings) != 1 || report.Warnings[0].Err != ErrInvalidDataLength {
+		t.Errorf("warning not reported: %v", report.Warnings)
+	}
+}
+
+// countingReader counts the bytes read from a seekable stream.
+type countingReader struct {
+	*bytes.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestLazyPicture(t *testing.T) {
+	data := make([]byte, 4096)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	for _, v := range []Version{Version2_2, Version2_3, Version2_4} {
+		pic := NewFrameAttachedPicture("image/jpeg", "cover", PictureTypeCoverFront, data)
+		pic.Encoding = EncodingUTF16BOM
+
+		title := NewFrameText(FrameTypeTextSongTitle, "Title")
+		title.Encoding = EncodingUTF16BOM
+
+		tag1 := NewTag(v, 0)
+		tag1.Frames = append(tag1.Frames, pic, title)
+
+		buf := bytes.NewBuffer([]byte("junk"))
+		if _, err := tag1.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		encoded := buf.Bytes()[4:]
+
+		r := &countingReader{Reader: bytes.NewReader(buf.Bytes())}
+		r.Seek(4, io.SeekStart)
+
+		tag2 := &Tag{}
+		n, err := tag2.ReadFromWithOptions(r, &DecodeOptions{LazyPictureSize: 1024})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(n) != len(encoded) {
+			t.Errorf("v%d: read %d bytes, want %d", v, n, len(encoded))
+		}
+		if r.n >= len(data) {
+			t.Errorf("v%d: image data was read from the source", v)
+		}
+		if title := tag2.FindFrame(FrameTypeTextSongTitle); title == nil || title.(*FrameText).Text[0] != "Title" {
+			t.Errorf("v%d: frames after the picture not decoded", v)
+		}
+
+		pic2 := tag2.FindFrame(FrameTypeAttachedPicture).(*FrameAttachedPicture)
+		if !pic2.IsLazy() {
+			t.Fatalf("v%d: picture not decoded lazily", v)
+		}
+		if pic2.Description != "cover" || pic2.MimeType != "image/jpeg" {
+			t.Errorf("v%d: picture fields decoded incorrectly", v)
+		}
+		got, err := ioutil.ReadAll(pic2.Open())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("v%d: lazy picture data mismatch", v)
+		}
+
+		out := bytes.NewBuffer([]byte{})
+		if _, err := tag2.WriteTo(out); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out.Bytes(), encoded) {
+			t.Errorf("v%d: lazy picture re-encoded incorrectly", v)
+		}
+	}
+}
+
+func TestSignTag(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eckey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var keys = []struct {
+		signer crypto.Signer
+		public crypto.PublicKey
+	}{
+		{priv, pub},
+		{eckey, &eckey.PublicKey},
+	}
+
+	for i, k := range keys {
+		tag := NewTag(Version2_4, 0)
+		tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextSongTitle, "Title"))
+
+		if err := VerifyTag(tag, k.public); err != ErrNoSignature {
+			t.Errorf("key %d: got %v, expected %v", i, err, ErrNoSignature)
+		}
+		if err := SignTag(tag, k.signer); err != nil {
+			t.Fatal(err)
+		}
+
+		// Round-trip the signed tag through an encode and decode.
+		buf := bytes.NewBuffer([]byte{})
+		tag.Padding = 128
+		if _, err := tag.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFrom(buf); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyTag(tag2, k.public); err != nil {
+			t.Errorf("key %d: verification failed: %v", i, err)
+		}
+
+		tag2.Frames[0].(*FrameText).Text[0] = "Altered"
+		if err := VerifyTag(tag2, k.public); err != ErrInvalidSignature {
+			t.Errorf("key %d: got %v, expected %v", i, err, ErrInvalidSignature)
+		}
+	}
+}
+
+func TestFrameIDRepair(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameComment("eng", "", "Comment"),
+		NewFrameText(FrameTypeTextSongSubtitle, "Subtitle"),
+	)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the frame IDs the way buggy taggers do.
+	b := buf.Bytes()
+	b = bytes.Replace(b, []byte("TIT2"), []byte("Tit2"), 1)
+	b = bytes.Replace(b, []byte("COMM"), []byte("COM "), 1)
+	b = bytes.Replace(b, []byte("TIT3"), []byte("TIT "), 1)
+
+	report := &DecodeReport{}
+	tag2 := &Tag{}
+	_, err := tag2.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{Lenient: true, Report: report})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tag2.FindFrame(FrameTypeTextSongTitle) == nil {
+		t.Error("Tit2 not repaired")
+	}
+	if tag2.FindFrame(FrameTypeComment) == nil {
+		t.Error("COM not repaired")
+	}
+	if tag2.FindFrame(FrameTypeTextSongSubtitle) != nil {
+		t.Error("ambiguous TIT frame ID repaired")
+	}
+	if len(report.Repairs) != 2 {
+		t.Errorf("expected 2 repairs, got %v", report.Repairs)
+	}
+}
+
+func TestDecodeV22(t *testing.T) {
+	frame := func(id string, payload ...byte) []byte {
+		n := len(payload)
+		b := append([]byte(id), byte(n>>16), byte(n>>8), byte(n))
+		return append(b, payload...)
+	}
+
+	var frames []byte
+	frames = append(frames, frame("TT2", append([]byte{0}, "Title"...)...)...)
+	frames = append(frames, frame("TP1", append([]byte{0}, "Artist"...)...)...)
+	frames = append(frames, frame("COM", append([]byte{0}, "eng\x00Comment"...)...)...)
+	frames = append(frames, frame("PIC", append([]byte{0}, "JPG\x03Cover\x00\xff\xd8\xff"...)...)...)
+	frames = append(frames, make([]byte, 16)...)
+
+	n := len(frames)
+	b := []byte{'I', 'D', '3', 2, 0, 0, byte(n >> 21), byte(n>>14) & 0x7f, byte(n>>7) & 0x7f, byte(n) & 0x7f}
+	b = append(b, frames...)
+
+	tag := &Tag{}
+	if _, err := tag.ReadFrom(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+
+	if tag.Version != Version2_2 || tag.Padding != 16 || len(tag.Frames) != 4 {
+		t.Fatalf("unexpected tag: %+v", tag)
+	}
+
+	title, ok := tag.FindFrame(FrameTypeTextSongTitle).(*FrameText)
+	if !ok || title.Text[0] != "Title" || title.Header.FrameID != "TT2" {
+		t.Errorf("TT2 frame decoded incorrectly: %+v", title)
+	}
+
+	comm, ok := tag.FindFrame(FrameTypeComment).(*FrameComment)
+	if !ok || comm.Language != "eng" || comm.Text != "Comment" {
+		t.Errorf("COM frame decoded incorrectly: %+v", comm)
+	}
+
+	pic, ok := tag.FindFrame(FrameTypeAttachedPicture).(*FrameAttachedPicture)
+	if !ok || pic.MimeType != "image/jpeg" || pic.PictureType != 3 ||
+		pic.Description != "Cover" || !bytes.Equal(pic.Data, []byte{0xff, 0xd8, 0xff}) {
+		t.Errorf("PIC frame decoded incorrectly: %+v", pic)
+	}
+}
+
+func TestMixedEncodings(t *testing.T) {
+	// A TXXX frame declaring UTF-16, with an 8-bit description and a UTF-16
+	// text value.
+	payload := []byte{byte(EncodingUTF16BOM)}
+	payload = append(payload, "Desc\x00"...)
+	payload = append(payload, 0xfe, 0xff, 0, 'V', 0, 'a', 0, 'l')
+
+	n := len(payload)
+	frame := append([]byte("TXXX"), 0, 0, 0, byte(n), 0, 0)
+	frame = append(frame, payload...)
+
+	n = len(frame)
+	b := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, byte(n)}
+	b = append(b, frame...)
+
+	report := &DecodeReport{}
+	tag := &Tag{}
+	_, err := tag.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{Report: report})
+	if err != ErrInvalidText {
+		t.Errorf("got error %v, expected %v", err, ErrInvalidText)
+	}
+	if len(report.Warnings) != 1 || report.Warnings[0].Err != ErrMixedEncodings {
+		t.Errorf("mixed encodings not detected: %v", report.Warnings)
+	}
+
+	report = &DecodeReport{}
+	tag = &Tag{}
+	_, err = tag.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{Lenient: true, Report: report})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, ok := tag.FindFrame(FrameTypeTextCustom).(*FrameTextCustom)
+	if !ok || f.Description != "Desc" || f.Text != "Val" {
+		t.Errorf("mixed encodings not repaired: %+v", f)
+	}
+	if len(report.Repairs) != 1 {
+		t.Errorf("expected 1 repair, got %v", report.Repairs)
+	}
+}
+
+func TestEncodeV22(t *testing.T) {
+	tag := NewTag(Version2_2, 0)
+	tag.Padding = 32
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameComment("eng", "", "Comment"),
+		NewFrameAttachedPicture("PNG", "Cover", PictureTypeCoverFront, []byte{1, 2, 3}),
+	)
+
+	// v2.2 supports only ISO-8859-1 and UTF-16 text.
+	tag.Frames[0].(*FrameText).Encoding = EncodingISO88591
+	tag.Frames[1].(*FrameComment).Encoding = EncodingUTF16BOM
+	tag.Frames[2].(*FrameAttachedPicture).Encoding = EncodingISO88591
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if !bytes.Contains(b, []byte("TT2")) || !bytes.Contains(b, []byte("PIC\x00\x00\x0e\x00PNG\x03")) {
+		t.Errorf("unexpected v2.2 encoding: %x", b)
+	}
+
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if len(tag2.Frames) != 3 || tag2.Padding != 32 {
+		t.Fatalf("unexpected decoded tag: %+v", tag2)
+	}
+	pic := tag2.FindFrame(FrameTypeAttachedPicture).(*FrameAttachedPicture)
+	if pic.MimeType != "image/png" || pic.Description != "Cover" || !bytes.Equal(pic.Data, []byte{1, 2, 3}) {
+		t.Errorf("PIC frame round-tripped incorrectly: %+v", pic)
+	}
+
+	// Frames that can't be represented in v2.2 must be rejected.
+	tag.Frames = append(tag.Frames[:3], NewFrameText(FrameTypeTextMood, "Happy"))
+	if _, err := tag.WriteTo(ioutil.Discard); err != ErrUnsupportedFrameType {
+		t.Errorf("expected ErrUnsupportedFrameType, got %v", err)
+	}
+	tag.Frames = append(tag.Frames[:3], NewFrameText(FrameTypeTextArtist, "Artist"))
+	if _, err := tag.WriteTo(ioutil.Discard); err != ErrInvalidEncoding {
+		t.Errorf("expected ErrInvalidEncoding, got %v", err)
+	}
+}
+
+func TestPushDecoder(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tag.Padding = 64
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameText(FrameTypeTextArtist, "Artist"),
+	)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	size := buf.Len()
+	stream := append(buf.Bytes(), "audio"...)
+
+	// Push the stream in small chunks.
+	d := NewPushDecoder()
+	consumed := 0
+	for !d.Done() {
+		end := consumed + 7
+		if end > len(stream) {
+			end = len(stream)
+		}
+		n, err := d.Write(stream[consumed:end])
+		consumed += n
+		if err != nil && err != ErrTagComplete {
+			t.Fatal(err)
+		}
+	}
+
+	if consumed != size || string(stream[consumed:]) != "audio" {
+		t.Errorf("consumed %d bytes, expected %d", consumed, size)
+	}
+	if d.Needed() != 0 {
+		t.Errorf("Needed returned %d", d.Needed())
+	}
+
+	tag2, err := d.Tag()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tag2.Frames) != 2 || tag2.Padding != 64 {
+		t.Errorf("unexpected decoded tag: %+v", tag2)
+	}
+
+	if _, err := d.Write([]byte("more")); err != ErrTagComplete {
+		t.Errorf("expected ErrTagComplete, got %v", err)
+	}
+
+	// Invalid headers are reported as soon as the header is complete.
+	d = NewPushDecoder()
+	if _, err := d.Write([]byte("RIFF....WAVE")); err != ErrInvalidHeader || !d.Done() {
+		t.Errorf("expected ErrInvalidHeader, got %v", err)
+	}
+}
+
+func TestDecodeV23(t *testing.T) {
+	// A title frame whose size isn't representable as a sync-safe integer.
+	title := strings.Repeat("t", 199)
+	frames := append([]byte("TIT2"), 0, 0, 0, 200, 0, 0, byte(EncodingISO88591))
+	frames = append(frames, title...)
+	crc := crc32.ChecksumIEEE(frames)
+	frames = append(frames, make([]byte, 8)...)
+
+	ex := []byte{0, 0, 0, 10, 0x80, 0, 0, 0, 0, 8,
+		byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc)}
+
+	n := len(ex) + len(frames)
+	b := []byte{'I', 'D', '3', 3, 0, 0x40, 0, 0, byte(n >> 7), byte(n) & 0x7f}
+	b = append(b, ex...)
+	b = append(b, frames...)
+
+	tag := &Tag{}
+	if _, err := tag.ReadFrom(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if (tag.Flags&TagFlagHasCRC) == 0 || tag.CRC != crc || tag.Padding != 8 {
+		t.Errorf("extended header decoded incorrectly: %+v", tag)
+	}
+	f, ok := tag.FindFrame(FrameTypeTextSongTitle).(*FrameText)
+	if !ok || f.Text[0] != title {
+		t.Errorf("TIT2 frame decoded incorrectly: %+v", f)
+	}
+}
+
+func TestAnalyzeLanguages(t *testing.T) {
+	var tags []*Tag
+	for _, lang := range []string{"eng", "ENG", "deu", "xxx", "eng", "fra"} {
+		tag := NewTag(Version2_4, 0)
+		tag.Frames = append(tag.Frames, NewFrameComment(lang, "", "Comment"))
+		tags = append(tags, tag)
+	}
+
+	stats := AnalyzeLanguages(tags)
+	if stats.Dominant != "eng" || stats.Counts["eng"] != 3 || len(stats.Counts) != 3 {
+		t.Errorf("unexpected language stats: %+v", stats)
+	}
+	if len(stats.Outliers) != 2 || stats.Outliers[0].Tag != 2 || stats.Outliers[1].Language != "fra" {
+		t.Errorf("unexpected outliers: %+v", stats.Outliers)
+	}
+}
+
+func TestEncodeV23(t *testing.T) {
+	title := strings.Repeat("t", 199)
+	f := NewFrameText(FrameTypeTextSongTitle, title)
+	f.Encoding = EncodingISO88591
+
+	tag := NewTag(Version2_3, TagFlagHasCRC)
+	tag.Padding = 16
+	tag.Frames = append(tag.Frames, f)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	// The extended header excludes its size field and stores the padding
+	// size ahead of the CRC.
+	if !bytes.Equal(b[10:20], []byte{0, 0, 0, 10, 0x80, 0, 0, 0, 0, 16}) {
+		t.Errorf("invalid extended header: %x", b[10:24])
+	}
+
+	// Frame sizes aren't sync-safe in v2.3.
+	if !bytes.Equal(b[24:32], []byte{'T', 'I', 'T', '2', 0, 0, 0, 200}) {
+		t.Errorf("invalid frame header: %x", b[24:34])
+	}
+
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if tag2.CRC != tag.CRC || tag2.Padding != 16 || tag2.Frames[0].(*FrameText).Text[0] != title {
+		t.Errorf("tag round-tripped incorrectly: %+v", tag2)
+	}
+}
+
+func TestRegisterDecompressor(t *testing.T) {
+	text := "gzip-compressed title"
+	payload := append([]byte{byte(EncodingISO88591)}, text...)
+
+	var z bytes.Buffer
+	zw := gzip.NewWriter(&z)
+	zw.Write(payload)
+	zw.Close()
+
+	// Build a v2.3 tag containing a gzip-compressed frame.
+	n := 4 + z.Len()
+	frame := append([]byte("TIT2"), byte(n>>24), byte(n>>16), byte(n>>8), byte(n), 0, 0x80)
+	frame = append(frame, 0, 0, 0, byte(len(payload)))
+	frame = append(frame, z.Bytes()...)
+
+	n = len(frame)
+	b := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, byte(n >> 7), byte(n) & 0x7f}
+	b = append(b, frame...)
+
+	tag := &Tag{}
+	if _, err := tag.ReadFrom(bytes.NewReader(b)); err != ErrInvalidCompression {
+		t.Errorf("expected ErrInvalidCompression, got %v", err)
+	}
+
+	// Restore the registered decompressors when the test completes.
+	decompressorsMu.RLock()
+	saved := append([]namedDecompressor{}, decompressors...)
+	decompressorsMu.RUnlock()
+	defer func() {
+		decompressorsMu.Lock()
+		decompressors = saved
+		decompressorsMu.Unlock()
+	}()
+
+	RegisterDecompressor("gzip", func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	})
+
+	tag = &Tag{}
+	if _, err := tag.ReadFrom(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	f, ok := tag.FindFrame(FrameTypeTextSongTitle).(*FrameText)
+	if !ok || f.Text[0] != text {
+		t.Errorf("gzip-compressed frame decoded incorrectly: %+v", f)
+	}
+}
+
+func TestCRCV23(t *testing.T) {
+	for _, flags := range []TagFlags{TagFlagHasCRC, TagFlagHasCRC | TagFlagUnsync} {
+		f := NewFrameAttachedPicture("image/jpeg", "", PictureTypeCoverFront, []byte{0xff, 0xe0, 0xff, 0x00})
+		f.Encoding = EncodingISO88591
+
+		tag := NewTag(Version2_3, flags)
+		tag.Padding = 10
+		tag.Frames = append(tag.Frames, f)
+
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		// The CRC covers the frames but not the padding.
+		b := buf.Bytes()
+		frames := b[24:]
+		if (flags & TagFlagUnsync) != 0 {
+			frames = removeUnsyncCodes(b[10:])[14:]
+		}
+		if crc32.ChecksumIEEE(frames[:len(frames)-10]) != tag.CRC {
+			t.Errorf("flags %v: CRC doesn't cover exactly the frames", flags)
+		}
+
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFrom(bytes.NewReader(b)); err != nil {
+			t.Fatalf("flags %v: %v", flags, err)
+		}
+		if (tag2.Flags&TagFlagHasCRC) == 0 || tag2.CRC != tag.CRC || tag2.Padding != 10 {
+			t.Errorf("flags %v: tag round-tripped incorrectly: %+v", flags, tag2)
+		}
+
+		// Corrupting a frame must fail the CRC check.
+		b[len(b)-11] ^= 0x01
+		if _, err := (&Tag{}).ReadFrom(bytes.NewReader(b)); err != ErrFailedCRC {
+			t.Errorf("flags %v: expected ErrFailedCRC, got %v", flags, err)
+		}
+	}
+}
+
+func TestSkipInvalidFrames(t *testing.T) {
+	bad := NewFrameText(FrameTypeTextArtist, "Artist")
+	bad.Encoding = 9
+
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		bad,
+		NewFrameText(FrameTypeTextAlbumName, "Album"),
+	)
+
+	if _, err := tag.WriteTo(ioutil.Discard); err != ErrInvalidEncoding {
+		t.Errorf("expected ErrInvalidEncoding, got %v", err)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	_, err := tag.WriteToWithOptions(buf, &EncodeOptions{SkipInvalidFrames: true})
+	failed, ok := err.(FrameErrors)
+	if !ok || len(failed) != 1 || failed[0].Index != 1 || failed[0].FrameID != "TPE1" ||
+		failed[0].Err != ErrInvalidEncoding {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(tag2.Frames) != 2 || tag2.FindFrame(FrameTypeTextAlbumName) == nil {
+		t.Errorf("invalid frame not skipped: %+v", tag2.Frames)
+	}
+}
+
+func TestFooter(t *testing.T) {
+	tag := NewTag(Version2_4, TagFlagFooter)
+	tag.Padding = 100
+	tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextSongTitle, "Title"))
+
+	buf := bytes.NewBuffer([]byte{})
+	n, err := tag.WriteTo(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	if tag.Padding != 0 {
+		t.Error("padding written with footer")
+	}
+	if !bytes.Equal(b[len(b)-10:len(b)-7], []byte("3DI")) || !bytes.Equal(b[len(b)-7:], b[3:10]) {
+		t.Errorf("invalid footer: %x", b[len(b)-10:])
+	}
+	if _, size, _ := PeekTag(b); size != int(n) {
+		t.Errorf("PeekTag size %d != %d", size, n)
+	}
+
+	tag2 := &Tag{}
+	n2, err := tag2.ReadFrom(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n2 != n || (tag2.Flags&TagFlagFooter) == 0 || len(tag2.Frames) != 1 {
+		t.Errorf("tag with footer decoded incorrectly: %+v", tag2)
+	}
+
+	b[len(b)-10] = 'X'
+	if _, err := (&Tag{}).ReadFrom(bytes.NewReader(b)); err != ErrInvalidFooter {
+		t.Errorf("expected ErrInvalidFooter, got %v", err)
+	}
+}
+
+type bufferAt []byte
+
+func (b bufferAt) WriteAt(p []byte, off int64) (int, error) {
+	return copy(b[off:], p), nil
+}
+
+func TestOverwrite(t *testing.T) {
+	prev := NewTag(Version2_4, 0)
+	prev.Padding = 64
+	prev.Frames = append(prev.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "A rather long song title"),
+		NewFrameText(FrameTypeTextArtist, "Artist"),
+	)
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := prev.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	size, used := buf.Len(), buf.Len()-prev.Padding
+	orig := append(buf.Bytes(), "AUDIO"...)
+
+	// Mark the previous padding so untouched bytes can be detected.
+	for i := used + 4; i < size; i++ {
+		orig[i] = 0xee
+	}
+
+	for _, fill := range []PaddingFill{PaddingFillZero, PaddingFillPreserve} {
+		b := bufferAt(append([]byte{}, orig...))
+
+		tag := NewTag(Version2_4, 0)
+		tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextSongTitle, "Short"))
+		if err := tag.Overwrite(b, prev, &EncodeOptions{PaddingFill: fill}); err != nil {
+			t.Fatal(err)
+		}
+		if string(b[size:]) != "AUDIO" {
+			t.Errorf("fill %d: audio data overwritten", fill)
+		}
+
+		// Bytes that held the previous tag's data must always be zeroed.
+		start := size - tag.Padding
+		for i := start; i < used; i++ {
+			if b[i] != 0 {
+				t.Errorf("fill %d: stale byte at offset %d", fill, i)
+				break
+			}
+		}
+
+		preserved := b[size-1] == 0xee
+		if preserved != (fill == PaddingFillPreserve) {
+			t.Errorf("fill %d: previous padding preserved = %v", fill, preserved)
+		}
+
+		if fill == PaddingFillZero {
+			tag2 := &Tag{}
+			if _, err := tag2.ReadFrom(bytes.NewReader(b)); err != nil {
+				t.Fatal(err)
+			}
+			if len(tag2.Frames) != 1 || tag2.Size+10 != size {
+				t.Errorf("overwritten tag decoded incorrectly: %+v", tag2)
+			}
+		}
+	}
+
+	// A tag that doesn't fit must be rejected.
+	large := NewTag(Version2_4, 0)
+	large.Frames = append(large.Frames, NewFrameText(FrameTypeTextSongTitle, strings.Repeat("x", 200)))
+	if err := large.Overwrite(bufferAt(make([]byte, size)), prev, nil); err != ErrTagTooLarge {
+		t.Errorf("expected ErrTagTooLarge, got %v", err)
+	}
+
+	// Space that can't be filled with padding must be rejected, both when
+	// it is smaller than the minimum padding and when the tag has a footer.
+	exact := NewTag(Version2_4, TagFlagFooter)
+	exact.Frames = append(exact.Frames, NewFrameText(FrameTypeTextSongTitle, "Title!"))
+	buf.Reset()
+	if _, err := exact.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	orig = buf.Bytes()
+
+	var tests = []struct {
+		flags TagFlags
+		title string
+		err   error
+	}{
+		{TagFlagFooter, "Title?", nil},
+		{TagFlagFooter, "Title", ErrTagSizeMismatch},
+		{TagFlagFooter, "T", ErrTagSizeMismatch},
+		{0, strings.Repeat("x", 16), nil},
+		{0, strings.Repeat("x", 15), ErrTagSizeMismatch},
+		{0, strings.Repeat("x", 13), ErrTagSizeMismatch},
+		{0, strings.Repeat("x", 12), nil},
+		{0, "", nil},
+	}
+	for i, test := range tests {
+		b := bufferAt(append([]byte{}, orig...))
+		tag := NewTag(Version2_4, test.flags)
+		tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextSongTitle, test.title))
+		err := tag.Overwrite(b, exact, nil)
+		if err != test.err {
+			t.Errorf("test %d: got error %v, expected %v", i, err, test.err)
+			continue
+		}
+		if err != nil {
+			if !bytes.Equal(b, orig) {
+				t.Errorf("test %d: rejected tag was written", i)
+			}
+			continue
+		}
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFrom(bytes.NewReader(b)); err != nil || tag2.Title() != test.title {
+			t.Errorf("test %d: overwritten tag decoded incorrectly: %v", i, err)
+		}
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	tag := NewTag(Version2_4, TagFlagHasCRC|TagFlagFooter)
+	tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextSongTitle, "Title"))
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	size := buf.Len()
+
+	v1 := make([]byte, 128)
+	copy(v1, "TAG")
+	buf.Write(make([]byte, 1000))
+	buf.Write(v1)
+
+	info, err := Identify(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != Version2_4 || info.Size != size || !info.HasExtended || !info.HasFooter ||
+		(info.Flags&TagFlagHasCRC) == 0 || !info.HasV1 {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	info, err = Identify(bytes.NewReader([]byte("not a tag")))
+	if err != nil || info != (Info{}) {
+		t.Errorf("unexpected info for untagged data: %+v, %v", info, err)
+	}
+}
+
+type xorCodec byte
+
+func (c xorCodec) Decrypt(h FrameHeader, b []byte) ([]byte, error) {
+	return c.Encrypt(h, b)
+}
+
+func (c xorCodec) Encrypt(h FrameHeader, b []byte) ([]byte, error) {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[i] = b[i] ^ byte(c)
+	}
+	return out, nil
+}
+
+func TestEncryptedFrames(t *testing.T) {
+	for _, v := range []Version{Version2_3, Version2_4} {
+		f := NewFrameText(FrameTypeTextSongTitle, "Secret title")
+		f.Header.SetEncryptMethod(0x80)
+
+		tag := NewTag(v, 0)
+		tag.Frames = append(tag.Frames, f)
+
+		// Encrypted frames can't be encoded without a codec.
+		if _, err := tag.WriteTo(ioutil.Discard); err != ErrUnknownEncryptMethod {
+			t.Errorf("v2.%d: expected ErrUnknownEncryptMethod, got %v", v, err)
+		}
+
+		tag.RegisterEncryptionMethod(0x80, xorCodec(0x5a))
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(buf.Bytes(), []byte("Secret")) {
+			t.Errorf("v2.%d: frame not encrypted", v)
+		}
+
+		// Without a codec, the frame is decoded as an opaque unknown frame
+		// and re-encoded unchanged.
+		opaque := &Tag{}
+		if _, err := opaque.ReadFrom(bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		u, ok := opaque.Frames[0].(*FrameUnknown)
+		if !ok || u.FrameID != "TIT2" {
+			t.Fatalf("v2.%d: encrypted frame not decoded as unknown: %+v", v, opaque.Frames[0])
+		}
+		buf2 := bytes.NewBuffer([]byte{})
+		if _, err := opaque.WriteTo(buf2); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), buf2.Bytes()) {
+			t.Errorf("v2.%d: opaque frame not re-encoded unchanged", v)
+		}
+
+		tag2 := &Tag{}
+		tag2.RegisterEncryptionMethod(0x80, xorCodec(0x5a))
+		if _, err := tag2.ReadFrom(buf2); err != nil {
+			t.Fatal(err)
+		}
+		ft, ok := tag2.FindFrame(FrameTypeTextSongTitle).(*FrameText)
+		if !ok || ft.Text[0] != "Secret title" {
+			t.Errorf("v2.%d: encrypted frame decoded incorrectly: %+v", v, tag2.Frames[0])
+		}
+	}
+}
+
+func TestFramesAfterPadding(t *testing.T) {
+	frame := func(id, text string) []byte {
+		n := len(text) + 1
+		b := append([]byte(id), 0, 0, 0, byte(n), 0, 0, byte(EncodingISO88591))
+		return append(b, text...)
+	}
+
+	var frames []byte
+	frames = append(frames, frame("TIT2", "Title")...)
+	frames = append(frames, make([]byte, 20)...)
+	frames = append(frames, frame("TPE1", "Artist")...)
+	frames = append(frames, make([]byte, 12)...)
+
+	n := len(frames)
+	b := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, byte(n)}
+	b = append(b, frames...)
+
+	tag := &Tag{}
+	if _, err := tag.ReadFrom(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if len(tag.Frames) != 1 {
+		t.Errorf("expected 1 frame in strict mode, got %d", len(tag.Frames))
+	}
+
+	report := &DecodeReport{}
+	tag = &Tag{}
+	_, err := tag.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{Lenient: true, Report: report})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tag.Frames) != 2 || tag.FindFrame(FrameTypeTextArtist) == nil || tag.Padding != 12 {
+		t.Errorf("frames after padding not recovered: %+v", tag)
+	}
+	if len(report.Repairs) != 1 {
+		t.Errorf("expected 1 repair, got %v", report.Repairs)
+	}
+}
+
+func TestMimeSniffers(t *testing.T) {
+	// Restore the registered sniffers when the test completes.
+	mimeSniffersMu.RLock()
+	saved := append([]namedMimeSniffer{}, mimeSniffers...)
+	mimeSniffersMu.RUnlock()
+	defer func() {
+		mimeSniffersMu.Lock()
+		mimeSniffers = saved
+		mimeSniffersMu.Unlock()
+	}()
+
+	pdf := []byte("%PDF-1.4\n...")
+	RegisterMimeSniffer("pdf", func(b []byte) string {
+		if bytes.HasPrefix(b, []byte("%PDF-")) {
+			return "application/pdf"
+		}
+		return ""
+	})
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameAttachedPicture("", "cover", PictureTypeCoverFront, png),
+		NewFrameGeneralObject("", "notes.pdf", "liner notes", pdf),
+		NewFrameGeneralObject("", "data.bin", "", []byte{1, 2, 3}),
+	)
+
+	if n := tag.FillMimeTypes(); n != 2 {
+		t.Errorf("expected 2 filled MIME types, got %d", n)
+	}
+	if m := tag.Frames[1].(*FrameGeneralObject).MimeType; m != "application/pdf" {
+		t.Errorf("unexpected GEOB MIME type %q", m)
+	}
+
+	// Round-trip the GEOB frame.
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	g, ok := tag2.Frames[1].(*FrameGeneralObject)
+	if !ok || g.MimeType != "application/pdf" || g.FileName != "notes.pdf" ||
+		g.Description != "liner notes" || !bytes.Equal(g.Data, pdf) {
+		t.Errorf("GEOB frame differs after round trip: %+v", tag2.Frames[1])
+	}
+
+	if errs := tag2.CheckMimeTypes(); len(errs) != 0 {
+		t.Errorf("unexpected MIME mismatches: %v", errs)
+	}
+	tag2.Frames[0].(*FrameAttachedPicture).MimeType = "image/jpeg"
+	errs := tag2.CheckMimeTypes()
+	if len(errs) != 1 || errs[0].Index != 0 || errs[0].Err != ErrMimeTypeMismatch {
+		t.Errorf("unexpected MIME mismatches: %v", errs)
+	}
+}
+
+func TestSignatureFrame(t *testing.T) {
+	const symbol = 0x81
+
+	title := NewFrameText(FrameTypeTextSongTitle, "Title")
+	title.Header.SetGroupID(symbol)
+	artist := NewFrameText(FrameTypeTextArtist, "Artist")
+
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameGroupID("signer", symbol, nil),
+		title,
+		artist,
+		NewFrameSignature(symbol, []byte("Title")),
+	)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	calls := 0
+	verify := func(sig *FrameSignature, group *FrameGroupID, frames []Frame) error {
+		calls++
+		if group == nil || group.Owner != "signer" {
+			return ErrInvalidGroupID
+		}
+		if len(frames) != 1 || frames[0].(*FrameText).Text[0] != string(sig.Signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+
+	tag2 := &Tag{}
+	_, err := tag2.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{VerifySignature: verify})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 verifier call, got %d", calls)
+	}
+	if s, ok := tag2.FindFrame(FrameTypeSignature).(*FrameSignature); !ok || s.GroupSymbol != symbol {
+		t.Errorf("signature frame not decoded")
+	}
+
+	// Tamper with the signed frame.
+	i := bytes.Index(b, []byte("Title"))
+	b[i] = 't'
+	_, err = (&Tag{}).ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{VerifySignature: verify})
+	if err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestChapterFrames(t *testing.T) {
+	for _, v := range []Version{Version2_3, Version2_4} {
+		c := NewFrameChapter("chp0", 0, 90000,
+			NewFrameText(FrameTypeTextSongTitle, "Introduction"),
+			NewFrameURL(FrameTypeURLAudioFile, "https://example.com/intro"),
+		)
+		c.Subframes[0].(*FrameText).Encoding = EncodingISO88591
+		c.StartOffset = 1024
+
+		tag := NewTag(v, 0)
+		tag.Frames = append(tag.Frames, c, NewFrameChapter("chp1", 90000, 180000))
+
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFrom(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		ff := tag2.FindFrames(FrameTypeChapter)
+		if len(ff) != 2 {
+			t.Fatalf("v%d: expected 2 chapters, got %d", v, len(ff))
+		}
+		c2 := ff[0].(*FrameChapter)
+		if c2.ElementID != "chp0" || c2.StartTime != 0 || c2.EndTime != 90000 ||
+			c2.StartOffset != 1024 || c2.EndOffset != 0xffffffff || len(c2.Subframes) != 2 {
+			t.Errorf("v%d: chapter differs after round trip: %+v", v, c2)
+			continue
+		}
+		if ft, ok := c2.Subframes[0].(*FrameText); !ok || ft.Text[0] != "Introduction" {
+			t.Errorf("v%d: unexpected title subframe %+v", v, c2.Subframes[0])
+		}
+		if fu, ok := c2.Subframes[1].(*FrameURL); !ok || fu.URL != "https://example.com/intro" {
+			t.Errorf("v%d: unexpected URL subframe %+v", v, c2.Subframes[1])
+		}
+		if c3 := ff[1].(*FrameChapter); c3.ElementID != "chp1" || len(c3.Subframes) != 0 {
+			t.Errorf("v%d: chapter differs after round trip: %+v", v, c3)
+		}
+	}
+}
+
+func TestCheckURLs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		case "/get-only":
+			if r.Method != "GET" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameURL(FrameTypeURLArtist, srv.URL+"/ok"),
+		NewFrameURLCustom("old", srv.URL+"/moved"),
+		NewFrameChapter("chp0", 0, 1000, NewFrameURL(FrameTypeURLAudioFile, srv.URL+"/get-only")),
+		NewFrameURL(FrameTypeURLPayment, srv.URL+"/gone"),
+		NewFrameURL(FrameTypeURLPublisher, "http://%zz"),
+	)
+
+	ss := tag.CheckURLs(context.Background(), srv.Client())
+	if len(ss) != 5 {
+		t.Fatalf("expected 5 statuses, got %d", len(ss))
+	}
+	if !ss[0].OK() || ss[0].Redirects != 0 {
+		t.Errorf("unexpected status %+v", ss[0])
+	}
+	if !ss[1].OK() || ss[1].Redirects != 1 || ss[1].FinalURL != srv.URL+"/ok" {
+		t.Errorf("unexpected status %+v", ss[1])
+	}
+	if !ss[2].OK() {
+		t.Errorf("unexpected status %+v", ss[2])
+	}
+	if ss[3].OK() || ss[3].StatusCode != http.StatusNotFound {
+		t.Errorf("unexpected status %+v", ss[3])
+	}
+	if ss[4].OK() || ss[4].Err == nil {
+		t.Errorf("unexpected status %+v", ss[4])
+	}
+}
+
+func TestNewFrameHeader(t *testing.T) {
+	var tests = []struct {
+		id    string
+		v     Version
+		flags FrameFlags
+		err   error
+	}{
+		{"TIT2", Version2_4, FrameFlagUnsynchronized | FrameFlagHasDataLength, nil},
+		{"TIT2", Version2_3, FrameFlagCompressed | FrameFlagReadOnly, nil},
+		{"TT2", Version2_2, 0, nil},
+		{"TIT2", Version2_3, FrameFlagUnsynchronized, ErrInvalidFrameFlags},
+		{"TIT2", Version2_3, FrameFlagHasDataLength, ErrInvalidFrameFlags},
+		{"TT2", Version2_2, FrameFlagCompressed, ErrInvalidFrameFlags},
+		{"TIT2", Version2_4, FrameFlagEncrypted, ErrInvalidFrameFlags},
+		{"TIT2", Version2_2, 0, ErrUnknownFrameType},
+		{"SEEK", Version2_3, 0, ErrUnknownFrameType},
+		{"TIT2", Version(5), 0, ErrInvalidVersion},
+	}
+
+	for _, test := range tests {
+		h, err := NewFrameHeader(test.id, test.v, test.flags)
+		if err != test.err {
+			t.Errorf("NewFrameHeader(%s, %d, %x): expected error %v, got %v", test.id, test.v, test.flags, test.err, err)
+			continue
+		}
+		if err == nil && (h.FrameID != test.id || h.Flags != test.flags) {
+			t.Errorf("NewFrameHeader(%s, %d, %x): unexpected header %+v", test.id, test.v, test.flags, h)
+		}
+	}
+
+	h, _ := NewFrameHeader("TIT2", Version2_4, 0)
+	if h.FrameType != FrameTypeTextSongTitle {
+		t.Errorf("unexpected frame type %v", h.FrameType)
+	}
+}
+
+func TestLinkedPictures(t *testing.T) {
+	const url = "https://example.com/cover.jpg"
+	image := []byte{0xff, 0xd8, 0xff, 0xe0}
+
+	tag := NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameAttachedPicture(LinkMimeType, "", PictureTypeCoverFront, []byte(url)),
+		NewFrameAttachedPicture("image/jpeg", "", PictureTypeCoverBack, image),
+		NewFrameGeneralObject(LinkMimeType, "", "", []byte(url+"\x00")),
+	)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	fetched := ""
+	fetch := func(u string) (io.ReadCloser, error) {
+		fetched = u
+		return ioutil.NopCloser(bytes.NewReader(image)), nil
+	}
+
+	link := tag2.Frames[0].(*FrameAttachedPicture)
+	if !link.IsLink() || link.LinkURL() != url {
+		t.Errorf("expected link to %s, got %q", url, link.LinkURL())
+	}
+	rc, err := link.Fetch(fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(rc); fetched != url || !bytes.Equal(b, image) {
+		t.Errorf("linked picture not fetched")
+	}
+
+	embedded := tag2.Frames[1].(*FrameAttachedPicture)
+	if embedded.IsLink() || embedded.LinkURL() != "" {
+		t.Errorf("embedded picture reported as a link")
+	}
+
+	obj := tag2.Frames[2].(*FrameGeneralObject)
+	if !obj.IsLink() || obj.LinkURL() != url {
+		t.Errorf("expected link to %s, got %q", url, obj.LinkURL())
+	}
+	if _, err := link.Fetch(nil); err != ErrNoFetcher {
+		t.Errorf("expected ErrNoFetcher, got %v", err)
+	}
+	if _, err := obj.Fetch(nil); err != ErrNoFetcher {
+		t.Errorf("expected ErrNoFetcher, got %v", err)
+	}
+
+	if errs := tag2.CheckMimeTypes(); len(errs) != 0 {
+		t.Errorf("unexpected MIME mismatches: %v", errs)
+	}
+}
+
+func TestNotices(t *testing.T) {
+	f, err := NewFrameCopyright(1999, "Example Records")
+	if err != nil || f.Text[0] != "1999 Example Records" || f.Header.FrameType != FrameTypeTextCopyright {
+		t.Errorf("unexpected copyright frame %+v (%v)", f, err)
+	}
+	f, err = NewFrameProducedNotice(987, " Producer ")
+	if err != nil || f.Text[0] != "0987 Producer" || f.Header.FrameType != FrameTypeTextProducedNotice {
+		t.Errorf("unexpected produced notice frame %+v (%v)", f, err)
+	}
+	if _, err := NewFrameCopyright(10000, "Holder"); err != ErrInvalidNotice {
+		t.Errorf("expected ErrInvalidNotice, got %v", err)
+	}
+	if _, err := NewFrameCopyright(2000, ""); err != ErrInvalidNotice {
+		t.Errorf("expected ErrInvalidNotice, got %v", err)
+	}
+
+	year, holder, err := ParseNotice("2004 Holder Inc.")
+	if err != nil || year != 2004 || holder != "Holder Inc." {
+		t.Errorf("ParseNotice: got %d %q %v", year, holder, err)
+	}
+	for _, s := range []string{"", "(c) 2004 Holder", "2004Holder", "04 Holder", "2004 "} {
+		if _, _, err := ParseNotice(s); err != ErrInvalidNotice {
+			t.Errorf("ParseNotice(%q): expected ErrInvalidNotice, got %v", s, err)
+		}
+	}
+
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextCopyright, "Copyright Holder"))
+	if _, err := tag.WriteTo(ioutil.Discard); err != nil {
+		t.Errorf("unexpected encode error %v", err)
+	}
+	_, err = tag.WriteToWithOptions(ioutil.Discard, &EncodeOptions{StrictNotices: true})
+	if err != ErrInvalidNotice {
+		t.Errorf("expected ErrInvalidNotice on encode, got %v", err)
+	}
+}
+
+type upperFormatter struct{ BasicFormatter }
+
+func (f upperFormatter) FormatTime(t time.Time, p TimePrecision) string {
+	return strings.ToUpper(f.BasicFormatter.FormatTime(t, p))
+}
+
+func TestDisplayString(t *testing.T) {
+	de := BasicFormatter{GroupSeparator: ".", DateLayout: "02.01.2006", MonthLayout: "01.2006"}
+
+	var tests = []struct {
+		f      Frame
+		df     DisplayFormatter
+		expect string
+	}{
+		{NewFrameText(FrameTypeTextLengthInMs, "205000"), nil, "3:25"},
+		{NewFrameText(FrameTypeTextLengthInMs, "3723000"), nil, "1:02:03"},
+		{NewFrameText(FrameTypeTextSize, "1234567"), nil, "1,234,567"},
+		{NewFrameText(FrameTypeTextSize, "1234567"), de, "1.234.567"},
+		{NewFrameText(FrameTypeTextRecordingTime, "1999"), nil, "1999"},
+		{NewFrameText(FrameTypeTextRecordingTime, "1999-03"), nil, "Mar 1999"},
+		{NewFrameText(FrameTypeTextRecordingTime, "1999-03-07"), nil, "Mar 7, 1999"},
+		{NewFrameText(FrameTypeTextRecordingTime, "1999-03-07"), de, "07.03.1999"},
+		{NewFrameText(FrameTypeTextRecordingTime, "1999-03-07T14:30"), de, "07.03.1999 14:30"},
+		{NewFrameText(FrameTypeTextRecordingTime, "1999-03"), upperFormatter{DefaultFormatter.(BasicFormatter)}, "MAR 1999"},
+		{NewFrameText(FrameTypeTextRecordingTime, "sometime"), nil, "sometime"},
+		{NewFrameText(FrameTypeTextSongTitle, "1234"), nil, "1234"},
+		{NewFramePlayCount(1000000), nil, "1,000,000"},
+		{NewFrameChapter("ch1", 0, 61000), nil, "ch1: 0:00-1:01"},
+	}
+
+	for _, test := range tests {
+		if s := DisplayString(test.f, test.df); s != test.expect {
+			t.Errorf("DisplayString: expected %q, got %q", test.expect, s)
+		}
+	}
+}
+
+func TestPartialDecode(t *testing.T) {
+	tag := NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameText(FrameTypeTextArtist, "Artist"),
+		NewFrameText(FrameTypeTextAlbumName, "Album"),
+	)
+	for _, f := range tag.Frames {
+		f.(*FrameText).Encoding = EncodingISO88591
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	// Corrupt the encoding of the third frame.
+	offset := bytes.Index(b, []byte("TALB"))
+	b[offset+10] = 9
+
+	report := &DecodeReport{}
+	tag2 := &Tag{}
+	_, err := tag2.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{Report: report})
+	if err != ErrInvalidEncoding {
+		t.Fatalf("expected ErrInvalidEncoding, got %v", err)
+	}
+	if len(tag2.Frames) != 2 || tag2.Frames[1].(*FrameText).Text[0] != "Artist" {
+		t.Errorf("expected 2 salvaged frames, got %d", len(tag2.Frames))
+	}
+	f := report.Failure
+	if f == nil || f.Offset != offset || f.FrameID != "TALB" || f.Err != ErrInvalidEncoding {
+		t.Errorf("unexpected failure %+v", f)
+	}
+}
+
+func TestDebugDump(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFramePrivate("owner", make([]byte, 40)),
+	)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if err := DebugDump(tag2.Frames[0], ioutil.Discard, 0); err != ErrNoRawData {
+		t.Errorf("expected ErrNoRawData, got %v", err)
+	}
+
+	tag2 = &Tag{}
+	if _, err := tag2.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{CaptureRaw: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	out := bytes.NewBuffer([]byte{})
+	if err := DebugDump(tag2.Frames[0], out, 0); err != nil {
+		t.Fatal(err)
+	}
+	expected := `TIT2 frame (v2.4), 16 bytes: 10 byte header, 6 byte payload
+000000  54 49 54 32                                      frame ID "TIT2"
+000004  00 00 00 06                                      size 6
+000008  00 00                                            flags 0000
+00000a  03 54 69 74 6c 65                                |.Title|
+`
+	if out.String() != expected {
+		t.Errorf("unexpected dump:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := DebugDump(tag2.Frames[1], out, 20); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 7 || lines[6] != "... 26 more bytes" {
+		t.Errorf("unexpected truncated dump:\n%s", out.String())
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	art := make([]byte, 1024)
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameAttachedPicture("image/jpeg", "", PictureTypeCoverFront, art),
+		NewFrameChapter("ch1", 0, 1000, NewFrameText(FrameTypeTextSongTitle, "Chapter")),
+	)
+
+	ft := tag.Freeze()
+
+	// Modifying the original tag doesn't affect the view.
+	tag.Frames[0].(*FrameText).Text[0] = "Changed"
+	tag.Frames[2].(*FrameChapter).Subframes[0].(*FrameText).Text = []string{"Changed"}
+	tag.Frames = tag.Frames[:1]
+
+	if ft.NumFrames() != 3 {
+		t.Fatalf("expected 3 frames, got %d", ft.NumFrames())
+	}
+	if s := ft.Frame(0).(*FrameText).Text[0]; s != "Title" {
+		t.Errorf("frozen title changed to %q", s)
+	}
+	if s := ft.FindFrame(FrameTypeChapter).(*FrameChapter).Subframes[0].(*FrameText).Text[0]; s != "Chapter" {
+		t.Errorf("frozen chapter title changed to %q", s)
+	}
+
+	// Retrieved frames are shared, and modifying thawed copies doesn't
+	// affect the view.
+	if ft.Frame(0) != ft.FindFrame(FrameTypeTextSongTitle) {
+		t.Errorf("frames were copied")
+	}
+	thawed := ft.Thaw()
+	thawed.Frames[0].(*FrameText).Text[0] = "Changed"
+	thawed.Frames[2].(*FrameChapter).Subframes[0].(*FrameText).Text[0] = "Changed"
+	if s := ft.Frame(0).(*FrameText).Text[0]; s != "Title" {
+		t.Errorf("frozen title changed to %q", s)
+	}
+	if s := ft.Frame(2).(*FrameChapter).Subframes[0].(*FrameText).Text[0]; s != "Chapter" {
+		t.Errorf("frozen chapter title changed to %q", s)
+	}
+
+	// Picture data is shared.
+	if p := ft.FindFrame(FrameTypeAttachedPicture).(*FrameAttachedPicture); &p.Data[0] != &art[0] {
+		t.Errorf("picture data was copied")
+	}
+
+	// Concurrent encoding.
+	done := make(chan []byte)
+	for i := 0; i < 4; i++ {
+		go func() {
+			buf := bytes.NewBuffer([]byte{})
+			ft.WriteTo(buf)
+			done <- buf.Bytes()
+		}()
+	}
+	first := <-done
+	for i := 1; i < 4; i++ {
+		if b := <-done; !bytes.Equal(b, first) {
+			t.Errorf("concurrent encodings differ")
+		}
+	}
+}
+
+func TestUserTextMap(t *testing.T) {
+	for _, v := range []Version{Version2_3, Version2_4} {
+		tag := NewTag(v, 0)
+		tag.Frames = append(tag.Frames,
+			NewFrameTextCustom("REPLAYGAIN_TRACK_GAIN", "-6.50 dB"),
+			NewFrameTextCustom("MUSICBRAINZ ALBUM ID", "abc"),
+			NewFrameTextCustom("Custom", "value"),
+		)
+		for _, f := range tag.Frames {
+			f.(*FrameTextCustom).Encoding = EncodingUTF16BOM
+		}
+
+		m := tag.UserTextMap()
+		if len(m) != 3 || m["replaygain_track_gain"][0] != "-6.50 dB" ||
+			m["MusicBrainz Album Id"][0] != "abc" || m["Custom"][0] != "value" {
+			t.Errorf("v%d: unexpected map %v", v, m)
+		}
+
+		tag.SetUserTextMap(map[string][]string{
+			"musicbrainz album id": {"def"},
+			"acoustid_id":          {"id1", "id2"},
+			"Custom":               nil,
+		})
+
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFrom(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		m = tag2.UserTextMap()
+		expected := map[string][]string{
+			"replaygain_track_gain": {"-6.50 dB"},
+			"MusicBrainz Album Id":  {"def"},
+			"ACOUSTID_ID":           {"id1", "id2"},
+		}
+		if v < Version2_4 {
+			expected["ACOUSTID_ID"] = []string{"id1/id2"}
+		}
+		if fmt.Sprint(m) != fmt.Sprint(expected) {
+			t.Errorf("v%d: expected %v, got %v", v, expected, m)
+		}
+		if d := tag2.Frames[1].(*FrameTextCustom).Description; d != "MusicBrainz Album Id" {
+			t.Errorf("v%d: frame not replaced in place: %q", v, d)
+		}
+
+		// Decoded v2.4 frames hold all their values, null-separated.
+		text := tag2.Frames[2].(*FrameTextCustom).Text
+		if want := strings.Join([]string{"id1", "id2"}, userTextSeparator(v)); text != want {
+			t.Errorf("v%d: got text %q, expected %q", v, text, want)
+		}
+	}
+
+	// Slashes in values of v2.3 frames written by other taggers are kept.
+	tag := NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameTextCustom("Artist Site", "http://example.com/acdc"),
+		NewFrameTextCustom("Band", "AC/DC"),
+	)
+	m := tag.UserTextMap()
+	if len(m["Artist Site"]) != 1 || m["Artist Site"][0] != "http://example.com/acdc" ||
+		len(m["Band"]) != 1 || m["Band"][0] != "AC/DC" {
+		t.Errorf("v2.3 values split: %v", m)
+	}
+}
+
+func TestStamp(t *testing.T) {
+	tag := NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextEncodingSoftware, "Old Tagger"))
+	tag.Frames[0].(*FrameText).Encoding = EncodingISO88591
+
+	if _, err := tag.WriteTo(ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if s := firstText(tag, FrameTypeTextEncodingSoftware); s != "Old Tagger" {
+		t.Errorf("unexpected stamp without option: %q", s)
+	}
+
+	opts := &EncodeOptions{Stamp: &Stamp{Application: "MyTagger 2.1", EncodedBy: "Archive Team"}}
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteToWithOptions(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "MyTagger 2.1 (github.com/beevik/id3)"
+	if v := LibraryVersion(); v != "" {
+		expected = "MyTagger 2.1 (github.com/beevik/id3 " + v + ")"
+	}
+	if s := firstText(tag2, FrameTypeTextEncodingSoftware); s != expected {
+		t.Errorf("expected stamp %q, got %q", expected, s)
+	}
+	if s := firstText(tag2, FrameTypeTextEncodedBy); s != "Archive Team" {
+		t.Errorf("unexpected encoded-by %q", s)
+	}
+	if n := len(tag2.FindFrames(FrameTypeTextEncodingSoftware)); n != 1 {
+		t.Errorf("expected 1 TSSE frame, got %d", n)
+	}
+
+	// The tag itself isn't stamped.
+	if s := firstText(tag, FrameTypeTextEncodingSoftware); s != "Old Tagger" || len(tag.Frames) != 1 {
+		t.Errorf("tag was stamped: %q, %d frames", s, len(tag.Frames))
+	}
+}
+
+func TestDirtyFrames(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameText(FrameTypeTextArtist, "Artist"),
+		NewFrameComment("eng", "", "Comment"),
+	)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(tag2.DirtyFrames()); n != 0 {
+		t.Errorf("expected no dirty frames, got %d", n)
+	}
+
+	tag2.SetText(FrameTypeTextAlbumName, "Album")
+	tag2.SetText(FrameTypeTextSongTitle, "New Title")
+	c := tag2.FindFrame(FrameTypeComment).(*FrameComment)
+	c.Text = "New comment"
+	tag2.MarkDirty(c)
+
+	dirty := tag2.DirtyFrames()
+	if len(dirty) != 3 || dirty[0] != tag2.Frames[0] || dirty[1] != tag2.Frames[2] || dirty[2] != tag2.Frames[3] {
+		t.Errorf("unexpected dirty frames %v", dirty)
+	}
+
+	tag2.RemoveFrames(FrameTypeComment)
+	if n := len(tag2.DirtyFrames()); n != 2 {
+		t.Errorf("expected 2 dirty frames, got %d", n)
+	}
+
+	tag2.ClearDirty()
+	if n := len(tag2.DirtyFrames()); n != 0 {
+		t.Errorf("expected no dirty frames, got %d", n)
+	}
+}
+
+func TestCRCCompat(t *testing.T) {
+	tag := NewTag(Version2_4, TagFlagHasCRC)
+	tag.Padding = 64
+	tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextSongTitle, "Title\x00"))
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	// The extended header holds the CRC at offset 7.
+	const exStart, crcAt = 10, 17
+	exEnd := exStart + 12
+	frames := b[exEnd : len(b)-tag.Padding]
+	exHdr := append([]byte{}, b[exStart:exEnd]...)
+	copy(exHdr[crcAt-exStart:], make([]byte, 5))
+
+	var tests = []struct {
+		crc     uint32
+		variant CRCVariant
+	}{
+		{crc32.ChecksumIEEE(b[exEnd:]), CRCFramesPadding},
+		{crc32.ChecksumIEEE(frames), CRCFrames},
+		{crc32.ChecksumIEEE(append(exHdr, b[exEnd:]...)), CRCExtendedHeader},
+	}
+
+	for _, test := range tests {
+		encodeSyncSafeUint32(b[crcAt:crcAt+5], test.crc)
+
+		_, err := (&Tag{}).ReadFrom(bytes.NewReader(b))
+		if test.variant == CRCFramesPadding && err != nil {
+			t.Errorf("%v: unexpected error %v", test.variant, err)
+		}
+		if test.variant != CRCFramesPadding && err != ErrFailedCRC {
+			t.Errorf("%v: expected ErrFailedCRC, got %v", test.variant, err)
+		}
+
+		report := &DecodeReport{}
+		opts := &DecodeOptions{CRCCompat: true, Report: report}
+		if _, err := (&Tag{}).ReadFromWithOptions(bytes.NewReader(b), opts); err != nil {
+			t.Errorf("%v: unexpected error %v", test.variant, err)
+		}
+		if report.CRCVariant != test.variant {
+			t.Errorf("expected variant %v, got %v", test.variant, report.CRCVariant)
+		}
+	}
+
+	encodeSyncSafeUint32(b[crcAt:crcAt+5], 12345)
+	_, err := (&Tag{}).ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{CRCCompat: true})
+	if err != ErrFailedCRC {
+		t.Errorf("expected ErrFailedCRC, got %v", err)
+	}
+}
+
+func TestUnknownFrameHook(t *testing.T) {
+	frame := func(id string) []byte {
+		return append([]byte(id), 0, 0, 0, 2, 0, 0, 0, 'a')
+	}
+
+	var frames []byte
+	frames = append(frames, frame("XYZ1")...)
+	frames = append(frames, frame("TIT2")...)
+	frames = append(frames, frame("XYZ1")...)
+	frames = append(frames, frame("ABCD")...)
+
+	b := []byte{'I', 'D', '3', 3, 0, 0, 0, 0, 0, byte(len(frames))}
+	b = append(b, frames...)
+
+	var seen []string
+	report := &DecodeReport{}
+	opts := &DecodeOptions{
+		Report:       report,
+		UnknownFrame: func(id string) { seen = append(seen, id) },
+	}
+	tag := &Tag{}
+	if _, err := tag.ReadFromWithOptions(bytes.NewReader(b), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(seen, ",") != "XYZ1,XYZ1,ABCD" {
+		t.Errorf("unexpected hook calls %v", seen)
+	}
+	if len(report.UnknownFrames) != 2 || report.UnknownFrames["XYZ1"] != 2 || report.UnknownFrames["ABCD"] != 1 {
+		t.Errorf("unexpected unknown frame counts %v", report.UnknownFrames)
+	}
+}
+
+func TestSplitUserText(t *testing.T) {
+	long := strings.Repeat("0123456789é日😀", 20)
+
+	for _, enc := range []Encoding{EncodingUTF8, EncodingUTF16BOM} {
+		f := NewFrameTextCustom("blob", long)
+		f.Encoding = enc
+
+		parts := SplitUserText(f, 32)
+		if len(parts) < 2 {
+			t.Fatalf("enc %d: expected multiple parts, got %d", enc, len(parts))
+		}
+		joined := ""
+		for i, p := range parts {
+			if b, _ := encodeString(p.Text, enc); len(b) > 32 {
+				t.Errorf("enc %d: part %d is %d bytes", enc, i, len(b))
+			}
+			if p.Description != fmt.Sprintf("blob [part %d/%d]", i+1, len(parts)) {
+				t.Errorf("enc %d: unexpected description %q", enc, p.Description)
+			}
+			joined += p.Text
+		}
+		if joined != long {
+			t.Errorf("enc %d: parts don't reassemble", enc)
+		}
+
+		tag := NewTag(Version2_4, 0)
+		tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextSongTitle, "Title"), f,
+			NewFrameTextCustom("short", "value"))
+
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag.WriteToWithOptions(buf, &EncodeOptions{MaxTextFrameSize: 32}); err != nil {
+			t.Fatal(err)
+		}
+		if n := bytes.Count(buf.Bytes(), []byte("TXXX")); n != len(parts)+1 {
+			t.Errorf("enc %d: expected %d TXXX frames, got %d", enc, len(parts)+1, n)
+		}
+
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFrom(buf); err != nil {
+			t.Fatal(err)
+		}
+		if len(tag2.Frames) != 3 {
+			t.Fatalf("enc %d: expected 3 frames, got %d", enc, len(tag2.Frames))
+		}
+		if f2 := tag2.Frames[1].(*FrameTextCustom); f2.Description != "blob" || f2.Text != long {
+			t.Errorf("enc %d: value not reassembled: %q", enc, f2.Description)
+		}
+	}
+
+	// Incomplete sequences are left untouched.
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameTextCustom("x [part 1/3]", "a"),
+		NewFrameTextCustom("x [part 2/3]", "b"),
+	)
+	buf := bytes.NewBuffer([]byte{})
+	tag.WriteTo(buf)
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(tag2.Frames) != 2 {
+		t.Errorf("incomplete sequence was joined")
+	}
+}
+
+func TestEncodeDefaults(t *testing.T) {
+	tag := NewTag(Version2_3, 0)
+	c := tag.SetComment("", "plain")
+	l := tag.SetLyrics("verse", "日本語")
+	u := tag.SetTermsOfUse("terms")
+	u.Encoding = EncodingUTF16
+	other := NewFrameComment("deu", "other", "text")
+	tag.Frames = append(tag.Frames, other)
+
+	if tag.SetComment("", "replaced") != c || c.Text != "replaced" {
+		t.Errorf("SetComment didn't update the existing frame")
+	}
+
+	opts := &EncodeOptions{DefaultLanguage: "fra", DefaultEncoding: EncodingISO88591}
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteToWithOptions(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	if c.Language != "fra" || c.Encoding != EncodingISO88591 {
+		t.Errorf("comment: got %q/%d", c.Language, c.Encoding)
+	}
+	if l.Language != "fra" || l.Encoding != EncodingUTF16BOM {
+		t.Errorf("lyrics: got %q/%d", l.Language, l.Encoding)
+	}
+	if u.Language != "fra" || u.Encoding != EncodingUTF16 {
+		t.Errorf("terms: got %q/%d", u.Language, u.Encoding)
+	}
+	if other.Language != "deu" || other.Encoding != EncodingUTF8 {
+		t.Errorf("other comment: got %q/%d", other.Language, other.Encoding)
+	}
+
+	// Defaults are applied only once.
+	opts = &EncodeOptions{DefaultLanguage: "ita", DefaultEncoding: EncodingUTF16BOM}
+	if _, err := tag.WriteToWithOptions(ioutil.Discard, opts); err != nil {
+		t.Fatal(err)
+	}
+	if c.Language != "fra" || c.Encoding != EncodingISO88591 {
+		t.Errorf("comment defaulted again: got %q/%d", c.Language, c.Encoding)
+	}
+
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	if l2, ok := tag2.FindFrame(FrameTypeLyricsUnsync).(*FrameLyricsUnsync); !ok || l2.Text != "日本語" || l2.Language != "fra" {
+		t.Errorf("lyrics didn't survive a round trip")
+	}
+
+	// Encodings unsupported by the version fall back, and no language
+	// yields LanguageUnknown. Replaced text upgrades the encoding.
+	tag = NewTag(Version2_3, 0)
+	c = tag.SetComment("", "x")
+	if _, err := tag.WriteToWithOptions(ioutil.Discard, &EncodeOptions{DefaultEncoding: EncodingUTF8}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Language != LanguageUnknown || c.Encoding != EncodingUTF16BOM {
+		t.Errorf("fallback: got %q/%d", c.Language, c.Encoding)
+	}
+	c.Encoding = EncodingISO88591
+	if tag.SetComment("", "日本語"); c.Encoding != EncodingUTF16BOM {
+		t.Errorf("encoding not upgraded: got %d", c.Encoding)
+	}
+}
+
+func TestPictureFormats(t *testing.T) {
+	formats := []struct {
+		format   string
+		mimeType string
+	}{
+		{"JPG", "image/jpeg"},
+		{"PNG", "image/png"},
+		{"-->", "-->"},
+		{"WEB", "image/web"},
+	}
+	for _, f := range formats {
+		if m := PictureFormatMimeType(f.format); m != f.mimeType {
+			t.Errorf("format %s: got MIME type %q", f.format, m)
+		}
+		if format, ok := MimeTypePictureFormat(f.mimeType); !ok || format != f.format {
+			t.Errorf("MIME type %s: got format %q", f.mimeType, format)
+		}
+	}
+
+	mimeTypes := []struct {
+		mimeType string
+		format   string
+		ok       bool
+	}{
+		{"image/jpg", "JPG", true},
+		{"Image/PNG", "PNG", true},
+		{"png", "PNG", true},
+		{"image/x", "X  ", true},
+		{"image/", "", false},
+		{"jpeg", "", false},
+	}
+	for _, m := range mimeTypes {
+		if format, ok := MimeTypePictureFormat(m.mimeType); ok != m.ok || format != m.format {
+			t.Errorf("MIME type %s: got format %q, %v", m.mimeType, format, ok)
+		}
+	}
+
+	// Pictures are written to v2.2 tags using the equivalent format, and
+	// MIME types with no equivalent are rejected.
+	tag := NewTag(Version2_2, 0)
+	pic := NewFrameAttachedPicture("image/jpeg", "", PictureTypeCoverFront, []byte{0xff, 0xd8})
+	pic.Encoding = EncodingISO88591
+	tag.Frames = append(tag.Frames, pic)
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("PIC\x00\x00\x08\x00JPG\x03")) {
+		t.Errorf("unexpected PIC frame: %x", buf.Bytes())
+	}
+	pic.MimeType = "jpeg"
+	if _, err := tag.WriteTo(ioutil.Discard); err != ErrInvalidFrame {
+		t.Errorf("expected ErrInvalidFrame, got %v", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameAttachedPicture("image/png", "Cover", PictureTypeCoverFront, []byte{1, 2, 3}),
+		NewFrameChapter("ch1", 0, 1000, NewFrameText(FrameTypeTextSongTitle, "Intro")),
+	)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteToWithOptions(buf, &EncodeOptions{Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() == 0 {
+		t.Errorf("verified tag wasn't written")
+	}
+
+	// Text that can't be represented in the frame's encoding is altered by
+	// the encoder, so verification fails and nothing is written.
+	title := tag.Frames[0].(*FrameText)
+	title.Encoding = EncodingISO88591
+	title.Text = []string{"日本"}
+	ch := tag.Frames[2].(*FrameChapter)
+	ch.Subframes[0].(*FrameText).Encoding = EncodingISO88591
+	ch.Subframes[0].(*FrameText).Text = []string{"Ω"}
+
+	buf.Reset()
+	_, err := tag.WriteToWithOptions(buf, &EncodeOptions{Verify: true})
+	verr, ok := err.(VerifyError)
+	if !ok || len(verr) != 2 {
+		t.Fatalf("expected 2 mismatches, got %v", err)
+	}
+	if verr[0].Index != 0 || verr[0].FrameID != "TIT2" || verr[0].Field != "Text" {
+		t.Errorf("unexpected mismatch: %+v", verr[0])
+	}
+	if verr[1].Index != 2 || verr[1].Field != "Subframes[0].Text" {
+		t.Errorf("unexpected mismatch: %+v", verr[1])
+	}
+	if buf.Len() != 0 {
+		t.Errorf("tag written despite failed verification")
+	}
+
+	// Frames skipped because they failed to encode aren't verified.
+	title.Encoding = EncodingUTF8
+	ch.Subframes[0].(*FrameText).Encoding = EncodingUTF8
+	tag.Frames = append(tag.Frames, &FrameText{Header: FrameHeader{FrameType: FrameTypeTextBPM},
+		Encoding: Encoding(9), Text: []string{"x"}})
+	_, err = tag.WriteToWithOptions(buf, &EncodeOptions{Verify: true, SkipInvalidFrames: true})
+	if _, ok := err.(FrameErrors); !ok {
+		t.Errorf("expected FrameErrors, got %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Errorf("tag with skipped frames wasn't written")
+	}
+}
+
+func TestConvertCredits(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameCredits(FrameTypeTextMusicians, []Credit{{"guitar", "A"}}),
+		NewFrameCredits(FrameTypeTextInvolvedPeople, []Credit{{"producer", "B"}}),
+		NewFrameCredits(FrameTypeTextMusicians, []Credit{{"drums", "C"}}),
+	)
+
+	tag.ConvertCredits(Version2_4)
+	if len(tag.Frames) != 4 {
+		t.Fatalf("v2.4 credits changed")
+	}
+
+	tag.ConvertCredits(Version2_3)
+	if len(tag.Frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(tag.Frames))
+	}
+	f := tag.Frames[1].(*FrameText)
+	want := []Credit{{"producer", "B"}, {"guitar", "A"}, {"drums", "C"}}
+	got := f.Credits()
+	if f.Header.FrameType != FrameTypeTextInvolvedPeople || len(got) != len(want) {
+		t.Fatalf("unexpected credits: %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("credit %d: got %v, expected %v", i, got[i], want[i])
+		}
+	}
+
+	// Without an involved people frame, one takes the place of the first
+	// musician credits frame, and the tag can be written as v2.3.
+	tag = NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameCredits(FrameTypeTextMusicians, []Credit{{"bass", "D"}}),
+		NewFrameText(FrameTypeTextAlbumName, "Album"),
+	)
+	tag.ConvertCredits(Version2_3)
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("IPLS")) || bytes.Contains(buf.Bytes(), []byte("TMCL")) {
+		t.Errorf("credits not converted to IPLS")
+	}
+	if f, ok := tag.Frames[1].(*FrameText); !ok || f.Credits()[0] != (Credit{"bass", "D"}) {
+		t.Errorf("IPLS frame not added in place of TMCL")
+	}
+}
+
+func TestAlignment(t *testing.T) {
+	for _, v := range []Version{Version2_2, Version2_3, Version2_4} {
+		for _, align := range []int{1, 2, 512, 4096} {
+			tag := NewTag(v, 0)
+			tag.Padding = 100
+			title := NewFrameText(FrameTypeTextSongTitle, "Title")
+			title.Encoding = EncodingISO88591
+			tag.Frames = append(tag.Frames, title)
+
+			buf := bytes.NewBuffer([]byte{})
+			n, err := tag.WriteToWithOptions(buf, &EncodeOptions{Alignment: align})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int(n) != buf.Len() || n%int64(align) != 0 {
+				t.Errorf("v2.%d align %d: tag size %d isn't aligned", v, align, n)
+			}
+			if tag.Padding < 100 || tag.Padding >= 100+align+4 {
+				t.Errorf("v2.%d align %d: unexpected padding %d", v, align, tag.Padding)
+			}
+
+			tag2 := &Tag{}
+			if _, err := tag2.ReadFrom(buf); err != nil {
+				t.Fatal(err)
+			}
+			if tag2.Padding != tag.Padding {
+				t.Errorf("v2.%d align %d: decoded padding %d, expected %d", v, align, tag2.Padding, tag.Padding)
+			}
+		}
+	}
+
+	tag := NewTag(Version2_4, TagFlagFooter)
+	if _, err := tag.WriteToWithOptions(ioutil.Discard, &EncodeOptions{Alignment: 16}); err != ErrPaddingNotAllowed {
+		t.Errorf("expected ErrPaddingNotAllowed, got %v", err)
+	}
+}
+
+func TestVolumeAdjustment(t *testing.T) {
+	// A v2.3 RVAD frame with right, left and center channels.
+	adj := []VolumeAdjustment{
+		{ChannelFrontRight, 0x1000, 0x8000},
+		{ChannelFrontLeft, -0x0800, 0x7000},
+		{ChannelFrontCenter, 0x0100, 0},
+	}
+	rvad, err := NewFrameVolumeAdjustment(16, adj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bands := []EqualizationBand{{100, 0x2000}, {1000, -0x1000}}
+	equa, err := NewFrameEqualization(16, bands)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames, rvad, equa)
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	rvad2, ok := tag2.FindFrame(FrameTypeVolumeAdjustment).(*FrameVolumeAdjustment)
+	if !ok {
+		t.Fatal("RVAD frame not decoded")
+	}
+	got, err := rvad2.Adjustments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Back channels precede the center channel and are stored unadjusted.
+	if len(got) != 5 || got[0] != adj[0] || got[1] != adj[1] || got[4] != adj[2] ||
+		got[2] != (VolumeAdjustment{Channel: ChannelBackRight}) {
+		t.Errorf("unexpected adjustments: %v", got)
+	}
+	equa2, ok := tag2.FindFrame(FrameTypeEqualization).(*FrameEqualization)
+	if !ok {
+		t.Fatal("EQUA frame not decoded")
+	}
+	if gb, err := equa2.Bands(); err != nil || len(gb) != 2 || gb[0] != bands[0] || gb[1] != bands[1] {
+		t.Errorf("unexpected bands: %v, %v", gb, err)
+	}
+
+	// Convert to v2.4 and back.
+	tag2.ConvertAdjustments(Version2_4)
+	tag2.Version = Version2_4
+	rva2, ok := tag2.FindFrame(FrameTypeVolumeAdjustment2).(*FrameVolumeAdjustment2)
+	if !ok || len(rva2.Channels) != 5 {
+		t.Fatalf("RVAD not converted to RVA2: %v", tag2.Frames)
+	}
+	// 1 + 0x1000/0x10000 = 1.0625, or 0.5266 dB.
+	if c := rva2.Channels[0]; c.Channel != ChannelFrontRight || c.Adjustment != 270 ||
+		c.PeakBits != 16 || !bytes.Equal(c.Peak, []byte{0x80, 0}) {
+		t.Errorf("unexpected RVA2 channel: %+v", c)
+	}
+	eq2, ok := tag2.FindFrame(FrameTypeEqualization2).(*FrameEqualization2)
+	if !ok || len(eq2.Points) != 2 || eq2.Points[0].Frequency != 200 || eq2.Points[1].Adjustment >= 0 {
+		t.Fatalf("EQUA not converted to EQU2: %v", eq2)
+	}
+
+	buf.Reset()
+	if _, err := tag2.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tag3 := &Tag{}
+	if _, err := tag3.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	rva3, ok := tag3.FindFrame(FrameTypeVolumeAdjustment2).(*FrameVolumeAdjustment2)
+	if !ok || len(rva3.Channels) != 5 || rva3.Channels[1].Adjustment != rva2.Channels[1].Adjustment ||
+		!bytes.Equal(rva3.Channels[1].Peak, rva2.Channels[1].Peak) {
+		t.Errorf("RVA2 frame round-tripped incorrectly: %+v", rva3)
+	}
+
+	tag3.ConvertAdjustments(Version2_3)
+	rvad3, ok := tag3.FindFrame(FrameTypeVolumeAdjustment).(*FrameVolumeAdjustment)
+	if !ok {
+		t.Fatal("RVA2 not converted to RVAD")
+	}
+	got, _ = rvad3.Adjustments()
+	for i, a := range []VolumeAdjustment{adj[0], adj[1], got[2], got[3], adj[2]} {
+		if got[i].Channel != a.Channel || got[i].Peak != a.Peak || abs64(got[i].Change-a.Change) > 16 {
+			t.Errorf("channel %d: got %v, expected %v", i, got[i], a)
+		}
+	}
+	equa3, ok := tag3.FindFrame(FrameTypeEqualization).(*FrameEqualization)
+	if !ok {
+		t.Fatal("EQU2 not converted to EQUA")
+	}
+	if gb, _ := equa3.Bands(); len(gb) != 2 || gb[1].Frequency != 1000 || abs64(gb[1].Adjustment-bands[1].Adjustment) > 16 {
+		t.Errorf("unexpected bands: %v", gb)
+	}
+
+	// A master adjustment applies to the front channels.
+	rva := NewFrameVolumeAdjustment2("track")
+	rva.Channels = append(rva.Channels, ChannelAdjustment{Channel: ChannelMaster, Adjustment: -512})
+	legacy, err := rva.convert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := legacy.Adjustments(); len(got) != 2 || got[0].Change != got[1].Change || got[0].Change >= 0 {
+		t.Errorf("unexpected master conversion: %v", got)
+	}
+}
+
+func TestScrubTimestamps(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tdrc := NewFrameText(FrameTypeTextRecordingTime, "2019-06-21T13:45:10")
+	tdrl := NewFrameText(FrameTypeTextReleaseTime, "2019")
+	tden := NewFrameText(FrameTypeTextEncodingTime, "2020-01-02T03:04")
+	tdtg := NewFrameText(FrameTypeTextTaggingTime, "yesterday")
+	title := NewFrameText(FrameTypeTextSongTitle, "2019-06-21")
+	tag.Frames = append(tag.Frames, title, tdrc, tdrl, tden, tdtg)
+
+	n := tag.Scrub(&ScrubOptions{
+		Timestamps: map[FrameType]TimestampPolicy{
+			FrameTypeTextRecordingTime: {Precision: PrecisionYear},
+			FrameTypeTextReleaseTime:   {Precision: PrecisionDay},
+			FrameTypeTextEncodingTime:  {Strip: true},
+			FrameTypeTextTaggingTime:   {Precision: PrecisionMonth},
+		},
+	})
+	if n != 3 {
+		t.Errorf("expected 3 frames scrubbed, got %d", n)
+	}
+	if len(tag.Frames) != 3 || tag.Frames[0] != title || tag.Frames[1] != tdrc || tag.Frames[2] != tdrl {
+		t.Fatalf("unexpected frames: %v", tag.Frames)
+	}
+	if tdrc.Text[0] != "2019" || tdrl.Text[0] != "2019" || title.Text[0] != "2019-06-21" {
+		t.Errorf("unexpected timestamps: %v %v", tdrc.Text, tdrl.Text)
+	}
+	if d := tag.DirtyFrames(); len(d) != 1 || d[0] != tdrc {
+		t.Errorf("unexpected dirty frames: %v", d)
+	}
+
+	// Nil options select the defaults.
+	tdrc = NewFrameText(FrameTypeTextRecordingTime, "2019-06-21")
+	tag.Frames = []Frame{tdrc, tden, tdtg}
+	if n := tag.Scrub(nil); n != 3 || len(tag.Frames) != 1 || tdrc.Text[0] != "2019" {
+		t.Errorf("got %d scrubbed, frames %v", n, tag.Frames)
+	}
+}
+
+func TestCombineDates(t *testing.T) {
+	text := func(typ FrameType, s string) *FrameText {
+		f := NewFrameText(typ, s)
+		f.Encoding = EncodingISO88591
+		return f
+	}
+
+	tag := NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames,
+		text(FrameTypeTextRecordingTime, "2019"),
+		text(FrameTypeTextDate, "2106"),
+		text(FrameTypeTextTime, "1345"),
+		text(FrameTypeTextSongTitle, "Title"),
+	)
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{CombineDates: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(tag2.Frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(tag2.Frames))
+	}
+	if f := tag2.FindFrame(FrameTypeTextRecordingTime).(*FrameText); f.Text[0] != "2019-06-21T13:45" {
+		t.Errorf("unexpected timestamp %q", f.Text[0])
+	}
+
+	// Writing the tag splits the timestamp again.
+	buf.Reset()
+	if _, err := tag2.WriteToWithOptions(buf, &EncodeOptions{Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+	tag3 := &Tag{}
+	if _, err := tag3.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := map[FrameType]string{
+		FrameTypeTextRecordingTime: "2019",
+		FrameTypeTextDate:          "2106",
+		FrameTypeTextTime:          "1345",
+	}
+	for typ, s := range want {
+		if f, ok := tag3.FindFrame(typ).(*FrameText); !ok || f.Text[0] != s {
+			t.Errorf("frame type %d: expected %q", typ, s)
+		}
+	}
+
+	// Without the option, frames are left alone; invalid dates aren't
+	// combined.
+	tag4 := &Tag{}
+	tag4.ReadFrom(bytes.NewReader(b))
+	if len(tag4.Frames) != 4 {
+		t.Errorf("dates combined without the option")
+	}
+	tag.Frames[1].(*FrameText).Text = []string{"3106"}
+	buf.Reset()
+	tag.WriteTo(buf)
+	tag4.ReadFromWithOptions(buf, &DecodeOptions{CombineDates: true})
+	if len(tag4.Frames) != 4 {
+		t.Errorf("invalid date combined")
+	}
+}
+
+func TestPodcastFrames(t *testing.T) {
+	for _, v := range []Version{Version2_2, Version2_3, Version2_4} {
+		tag := NewTag(v, 0)
+		tag.Frames = append(tag.Frames,
+			NewFramePodcast(),
+			NewFrameText(FrameTypeTextPodcastCategoryItunes, "Technology"),
+			NewFrameText(FrameTypeTextPodcastDescriptionItunes, "An episode"),
+			NewFrameText(FrameTypeTextPodcastIDItunes, "urn:example:1"),
+			NewFrameText(FrameTypeTextPodcastFeedItunes, "http://example.com/feed"),
+		)
+		for _, f := range tag.Frames[1:] {
+			f.(*FrameText).Encoding = EncodingISO88591
+		}
+
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		wantID := map[Version]string{Version2_2: "WFD", Version2_3: "WFED", Version2_4: "WFED"}[v]
+		if !bytes.Contains(buf.Bytes(), []byte(wantID+"\x00")) {
+			t.Errorf("v2.%d: feed frame ID %s not found", v, wantID)
+		}
+
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFrom(buf); err != nil {
+			t.Fatal(err)
+		}
+		if len(tag2.Frames) != 5 {
+			t.Fatalf("v2.%d: expected 5 frames, got %d", v, len(tag2.Frames))
+		}
+		if _, ok := tag2.Frames[0].(*FramePodcast); !ok {
+			t.Errorf("v2.%d: podcast frame decoded as %T", v, tag2.Frames[0])
+		}
+		feed, ok := tag2.FindFrame(FrameTypeTextPodcastFeedItunes).(*FrameText)
+		if !ok || feed.Text[0] != "http://example.com/feed" {
+			t.Errorf("v2.%d: feed frame decoded incorrectly", v)
+		}
+	}
+}
+
+func TestDecompressionLimit(t *testing.T) {
+	text := strings.Repeat("a", 100000)
+	f := NewFrameText(FrameTypeTextSongTitle, text)
+	f.Header.SetFlag(FrameFlagCompressed, true)
+	tag := NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames, f)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	// The default limit admits the frame.
+	if _, err := new(Tag).ReadFrom(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The claimed size exceeds the limit.
+	_, err := new(Tag).ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{MaxDecompressedSize: 1000})
+	if de, ok := err.(*DecompressionError); !ok || de.FrameID != "TIT2" || de.Claimed != len(text)+1 || de.Limit != 1000 {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// The ratio limit stops inflation.
+	_, err = new(Tag).ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{MaxCompressionRatio: 10})
+	if _, ok := err.(*DecompressionError); !ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// A frame claiming a small size but inflating beyond the limit fails
+	// without inflating the entire payload.
+	copy(b[20:24], []byte{0, 0, 0, 1})
+	report := &DecodeReport{}
+	_, err = new(Tag).ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{MaxDecompressedSize: 1000, Report: report})
+	if de, ok := err.(*DecompressionError); !ok || de.Claimed != -1 {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if report.Failure == nil || report.Failure.FrameID != "TIT2" {
+		t.Errorf("failure not reported: %+v", report.Failure)
+	}
+
+	// Negative limits disable the check.
+	_, err = new(Tag).ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{MaxDecompressedSize: -1, StrictDataLength: true})
+	if err != ErrInvalidDataLength {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	for _, v := range []Version{Version2_3, Version2_4} {
+		cover := NewFrameAttachedPicture("image/png", "", PictureTypeCoverFront, []byte{1, 2, 3})
+		ch1 := NewFrameChapter("ch1", 0, 1000, NewFrameText(FrameTypeTextSongTitle, "One"), cover)
+		ch2 := NewFrameChapter("ch2", 1000, 2000, NewFrameText(FrameTypeTextSongTitle, "Two"))
+		sub := NewFrameTableOfContents("toc2", false, []string{"ch2"})
+		toc := NewFrameTableOfContents("toc", true, []string{"ch1", "toc2"},
+			NewFrameText(FrameTypeTextSongTitle, "Contents"), sub)
+
+		tag := NewTag(v, 0)
+		tag.Frames = append(tag.Frames, toc, ch1, ch2)
+
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag.WriteToWithOptions(buf, &EncodeOptions{Verify: true}); err != nil {
+			t.Fatal(err)
+		}
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFrom(buf); err != nil {
+			t.Fatal(err)
+		}
+		if len(tag2.Frames) != 3 {
+			t.Fatalf("v2.%d: expected 3 frames, got %d", v, len(tag2.Frames))
+		}
+
+		toc2, ok := tag2.Frames[0].(*FrameTableOfContents)
+		if !ok || toc2.ElementID != "toc" || toc2.Flags != TOCFlagOrdered|TOCFlagTopLevel ||
+			len(toc2.ChildElementIDs) != 2 || toc2.ChildElementIDs[1] != "toc2" || len(toc2.Subframes) != 2 {
+			t.Fatalf("v2.%d: unexpected table of contents: %+v", v, toc2)
+		}
+		nested, ok := toc2.Subframes[1].(*FrameTableOfContents)
+		if !ok || nested.ElementID != "toc2" || nested.Flags != TOCFlagOrdered ||
+			len(nested.ChildElementIDs) != 1 || len(nested.Subframes) != 0 {
+			t.Errorf("v2.%d: unexpected nested table of contents: %+v", v, toc2.Subframes[1])
+		}
+		c1 := tag2.Frames[1].(*FrameChapter)
+		if p, ok := c1.Subframes[1].(*FrameAttachedPicture); !ok || !bytes.Equal(p.Data, []byte{1, 2, 3}) {
+			t.Errorf("v2.%d: chapter artwork not decoded", v)
+		}
+	}
+}
+
+func TestExtendedUnknown(t *testing.T) {
+	for _, v := range []Version{Version2_3, Version2_4} {
+		for _, flags := range []TagFlags{0, TagFlagHasCRC} {
+			tag := NewTag(v, flags)
+			tag.ExtendedUnknown = []byte{0xde, 0xad, 0xbe, 0xef}
+			tag.Frames = append(tag.Frames, NewFrameText(FrameTypeTextSongTitle, "Title"))
+
+			buf := bytes.NewBuffer([]byte{})
+			if _, err := tag.WriteToWithOptions(buf, &EncodeOptions{PreserveExtended: true}); err != nil {
+				t.Fatal(err)
+			}
+			b := buf.Bytes()
+
+			tag2 := &Tag{}
+			if _, err := tag2.ReadFrom(bytes.NewReader(b)); err != nil {
+				t.Fatalf("v2.%d: %v", v, err)
+			}
+			if !bytes.Equal(tag2.ExtendedUnknown, tag.ExtendedUnknown) || len(tag2.Frames) != 1 {
+				t.Errorf("v2.%d: extended header data not preserved: %x", v, tag2.ExtendedUnknown)
+			}
+
+			// Re-encoding the decoded tag reproduces it exactly.
+			buf.Reset()
+			if _, err := tag2.WriteToWithOptions(buf, &EncodeOptions{PreserveExtended: true}); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), b) {
+				t.Errorf("v2.%d: tag not reproduced", v)
+			}
+
+			// Without the option, the data is dropped.
+			buf.Reset()
+			tag2.WriteTo(buf)
+			tag3 := &Tag{}
+			if _, err := tag3.ReadFrom(buf); err != nil {
+				t.Fatal(err)
+			}
+			if tag3.ExtendedUnknown != nil {
+				t.Errorf("v2.%d: extended header data written without the option", v)
+			}
+		}
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	tag := NewTag(Version2_3, 0)
+	tag.SetTitle("Title")
+	tag.SetArtist("Beyoncé", "Jay-Z")
+	tag.SetAlbum("日本語")
+	tag.SetYear(2003)
+	tag.SetTrack(TrackNumber{3, 12})
+	tag.SetTitle("New Title")
+
+	if n := len(tag.FindFrames(FrameTypeTextSongTitle)); n != 1 {
+		t.Errorf("got %d title frames, expected 1", n)
+	}
+	if f := tag.FindFrame(FrameTypeTextArtist).(*FrameText); len(f.Text) != 1 || f.Text[0] != "Beyoncé/Jay-Z" {
+		t.Errorf("artists not joined: %q", f.Text)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	if f := tag.FindFrame(FrameTypeTextAlbumName).(*FrameText); f.Encoding != EncodingUTF16BOM {
+		t.Errorf("album encoding: got %d", f.Encoding)
+	}
+
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	if tag2.Title() != "New Title" || tag2.Album() != "日本語" || tag2.Year() != 2003 {
+		t.Errorf("got %q/%q/%d", tag2.Title(), tag2.Album(), tag2.Year())
+	}
+	if a := tag2.Artists(); len(a) != 1 || tag2.Artist() != "Beyoncé/Jay-Z" {
+		t.Errorf("artists: got %q", a)
+	}
+	if tn := tag2.Track(); tn != (TrackNumber{3, 12}) {
+		t.Errorf("track: got %v", tn)
+	}
+
+	// Setting the year of a v2.4 tag preserves the rest of the timestamp.
+	tag = NewTag(Version2_4, 0)
+	tag.SetText(FrameTypeTextRecordingTime, "1999-05-01")
+	tag.SetYear(2001)
+	tag.SetArtist("A", "B")
+	if s := firstText(tag, FrameTypeTextRecordingTime); s != "2001-05-01" {
+		t.Errorf("timestamp: got %q", s)
+	}
+	if a := tag.Artists(); len(a) != 2 || tag.FindFrame(FrameTypeTextArtist).(*FrameText).Text[1] != "B" {
+		t.Errorf("v2.4 artists: got %q", a)
+	}
+}
+
+func TestCollation(t *testing.T) {
+	cases := []struct {
+		a, b  string
+		c     Collator
+		equal bool
+	}{
+		{"Beyoncé", "Beyonce", nil, false},
+		{"Beyoncé", "beyoncé", CaseInsensitiveCollator, true},
+		{"Beyoncé", "Beyonce", CaseInsensitiveCollator, false},
+		{"Beyoncé", "BEYONCE", AccentInsensitiveCollator, true},
+		{"Beyoncé", "Beyonce", AccentInsensitiveCollator, true},
+		{"Straße", "strasse", AccentInsensitiveCollator, true},
+		{"Sigur Rós", "Sigur Ros", CollatorFunc(func(a, b string) int { return 0 }), true},
+	}
+	for i, c := range cases {
+		if eq := TextEqual(c.a, c.b, c.c); eq != c.equal {
+			t.Errorf("case %d: TextEqual(%q, %q) = %v", i, c.a, c.b, eq)
+		}
+	}
+
+	tag := NewTag(Version2_4, 0)
+	tag.SetArtist("Jay-Z", "Beyoncé")
+	if n := len(tag.FindText(FrameTypeTextArtist, "beyonce", nil)); n != 0 {
+		t.Errorf("exact FindText found %d frames", n)
+	}
+	if n := len(tag.FindText(FrameTypeTextArtist, "beyonce", AccentInsensitiveCollator)); n != 1 {
+		t.Errorf("accent-insensitive FindText found %d frames", n)
+	}
+
+	a := NewFrameText(FrameTypeTextAlbumName, "Café")
+	b := NewFrameText(FrameTypeTextAlbumName, "CAFE")
+	if FrameTextEqual(a, b, nil) || !FrameTextEqual(a, b, AccentInsensitiveCollator) {
+		t.Errorf("FrameTextEqual mismatch")
+	}
+}
+
+func TestCoverArt(t *testing.T) {
+	tag := NewTag(Version2_3, 0)
+	tag.SetTitle("x")
+	tag.Frames = append(tag.Frames,
+		NewFrameAttachedPicture("image/png", "old", PictureTypeCoverFront, []byte("old")),
+		NewFrameAttachedPicture("image/png", "back", PictureTypeCoverBack, []byte("back")),
+		NewFrameAttachedPicture("image/png", "older", PictureTypeCoverFront, []byte("older")))
+	if tag.CoverArt().Description != "old" {
+		t.Errorf("CoverArt didn't return the first front cover")
+	}
+
+	gif := []byte("GIF89a\x01\x00\x01\x00")
+	p := tag.SetCoverArt(gif, "cover")
+	if len(tag.Frames) != 3 || tag.Frames[1] != p {
+		t.Fatalf("front covers not replaced in place")
+	}
+	if p.MimeType != "image/gif" || p.PictureType != PictureTypeCoverFront {
+		t.Errorf("got %q/%d", p.MimeType, p.PictureType)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	if c := tag2.CoverArt(); c == nil || !bytes.Equal(c.Data, gif) || c.Description != "cover" {
+		t.Errorf("cover art didn't survive a round trip")
+	}
+
+	// Images of unknown formats can be stored in all versions.
+	for _, v := range []Version{Version2_2, Version2_3, Version2_4} {
+		tag := NewTag(v, 0)
+		if p := tag.SetCoverArt([]byte("????"), ""); p.MimeType != "application/octet-stream" {
+			t.Errorf("v2.%d: unknown format: got %q", v, p.MimeType)
+		}
+		if _, err := tag.WriteTo(ioutil.Discard); err != nil {
+			t.Errorf("v2.%d: %v", v, err)
+		}
+	}
+}
+
+type testAnalysis struct{}
+
+func (testAnalysis) Duration() time.Duration {
+	return 3*time.Minute + 25*time.Second + 500*time.Millisecond
+}
+func (testAnalysis) BPM() float64                   { return 127.6 }
+func (testAnalysis) Loudness() (gain, peak float64) { return -6.5, 0.5 }
+
+type testKey string
+
+func (k testKey) Key() string { return string(k) }
+
+func TestPopulateFromAnalysis(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	if err := tag.PopulateFromAnalysis(testAnalysis{}, testKey("Abm")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tag.PopulateFromAnalysis(42); err != ErrUnknownProvider {
+		t.Errorf("got %v, expected ErrUnknownProvider", err)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	texts := map[FrameType]string{
+		FrameTypeTextLengthInMs: "205500",
+		FrameTypeTextBPM:        "128",
+		FrameTypeTextMusicalKey: "Abm",
+	}
+	for typ, want := range texts {
+		if got := firstText(tag2, typ); got != want {
+			t.Errorf("frame %d: got %q, expected %q", typ, got, want)
+		}
+	}
+	m := tag2.UserTextMap()
+	if m["replaygain_track_gain"][0] != "-6.50 dB" || m["replaygain_track_peak"][0] != "0.500000" {
+		t.Errorf("replaygain: got %q", m)
+	}
+	f, ok := tag2.FindFrame(FrameTypeVolumeAdjustment2).(*FrameVolumeAdjustment2)
+	if !ok || len(f.Channels) != 1 {
+		t.Fatalf("missing RVA2 frame")
+	}
+	if c := f.Channels[0]; c.Channel != ChannelMaster || c.Adjustment != -3328 || !bytes.Equal(c.Peak, []byte{0x40, 0x00}) {
+		t.Errorf("RVA2: got %+v", c)
+	}
+
+	// v2.3 tags have no RVA2 frame.
+	tag = NewTag(Version2_3, 0)
+	tag.PopulateFromAnalysis(testAnalysis{})
+	if tag.FindFrame(FrameTypeVolumeAdjustment2) != nil {
+		t.Errorf("RVA2 frame added to a v2.3 tag")
+	}
+}
+
+func TestOmitFrames(t *testing.T) {
+	tag := NewTag(Version2_3, 0)
+	tag.SetTitle("title")
+	tag.Frames = append(tag.Frames,
+		NewFrameTextCustom("SERATO_PLAYCOUNT", "12"),
+		NewFrameTextCustom("replaygain_track_gain", "-6.50 dB"),
+		NewFrameURL(FrameTypeURLArtist, "http://example.com"),
+		NewFrameComment("eng", "Serato Note", "text"))
+
+	opts := &EncodeOptions{OmitFrames: []string{"w*", "TXXX:serato*", "COMM:serato*"}, Verify: true}
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteToWithOptions(buf, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(tag.Frames) != 5 {
+		t.Errorf("tag modified by OmitFrames")
+	}
+
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	if len(tag2.Frames) != 2 || tag2.Title() != "title" {
+		t.Fatalf("got %d frames, expected 2", len(tag2.Frames))
+	}
+	if f := tag2.Frames[1].(*FrameTextCustom); f.Description != "replaygain_track_gain" {
+		t.Errorf("wrong frame kept: %q", f.Description)
+	}
+}
+
+func TestTrackNumber(t *testing.T) {
+	cases := []struct {
+		s    string
+		tn   TrackNumber
+		err  error
+		want string
+	}{
+		{"3", TrackNumber{3, 0}, nil, "3"},
+		{" 3 / 12 ", TrackNumber{3, 12}, nil, "3/12"},
+		{"03/12", TrackNumber{3, 12}, nil, "3/12"},
+		{"A/12", TrackNumber{}, ErrInvalidTrackNumber, "0"},
+		{"3/", TrackNumber{}, ErrInvalidTrackNumber, "0"},
+		{"-1", TrackNumber{}, ErrInvalidTrackNumber, "0"},
+	}
+	for _, c := range cases {
+		tn, err := ParseTrackNumber(c.s)
+		if tn != c.tn || err != c.err || tn.String() != c.want {
+			t.Errorf("%q: got %v/%q/%v", c.s, tn, tn.String(), err)
+		}
+	}
+
+	tag := NewTag(Version2_4, 0)
+	tag.SetTrack(TrackNumber{5, 10})
+	tag.SetDisc(TrackNumber{Number: 2})
+	if s := firstText(tag, FrameTypeTextTrackNumber); s != "5/10" {
+		t.Errorf("TRCK: got %q", s)
+	}
+	if s := firstText(tag, FrameTypeTextPartOfSet); s != "2" {
+		t.Errorf("TPOS: got %q", s)
+	}
+	if tag.Track() != (TrackNumber{5, 10}) || tag.Disc() != (TrackNumber{2, 0}) {
+		t.Errorf("got %v and %v", tag.Track(), tag.Disc())
+	}
+}
+
+type testEncodingFrame struct {
+	Header   FrameHeader
+	Encoding Encoding
+	Owner    string `id3:"encoding=utf8always"`
+	Text     string
+}
+
+func TestFieldEncoding(t *testing.T) {
+	rf := newReflector(Version2_4, newCodec24().vdata)
+	f := testEncodingFrame{Encoding: EncodingUTF16BOM, Owner: "é", Text: "é"}
+
+	w := newWriter(ioutil.Discard)
+	rf.outputStruct(w, property{typ: reflect.TypeOf(f), value: reflect.ValueOf(f)}, &state{})
+	if w.err != nil {
+		t.Fatal(w.err)
+	}
+	want := []byte{1, 0xc3, 0xa9, 0, 0xfe, 0xff, 0, 0xe9}
+	if !bytes.Equal(w.Bytes(), want) {
+		t.Errorf("got %x, expected %x", w.Bytes(), want)
+	}
+
+	r := newReader(bytes.NewReader(want))
+	r.Load(len(want))
+	p := property{typ: reflect.TypeOf(f), value: reflect.New(reflect.TypeOf(f))}
+	rf.scanStruct(r, p, &state{})
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if f2 := p.value.Elem().Interface().(testEncodingFrame); f2 != f {
+		t.Errorf("got %+v, expected %+v", f2, f)
+	}
+
+	// An unknown encoding fails instead of panicking.
+	type badFrame struct {
+		Header FrameHeader
+		Text   string `id3:"encoding=ebcdic"`
+	}
+	b := badFrame{Text: "text"}
+	w = newWriter(ioutil.Discard)
+	rf.outputStruct(w, property{typ: reflect.TypeOf(b), value: reflect.ValueOf(b)}, &state{})
+	if w.err != errUnknownFieldEncoding {
+		t.Errorf("got %v, expected errUnknownFieldEncoding", w.err)
+	}
+	r = newReader(bytes.NewReader([]byte("text")))
+	r.Load(4)
+	p = property{typ: reflect.TypeOf(b), value: reflect.New(reflect.TypeOf(b))}
+	rf.scanStruct(r, p, &state{})
+	if r.err != errUnknownFieldEncoding {
+		t.Errorf("got %v, expected errUnknownFieldEncoding", r.err)
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	cases := []struct {
+		s   string
+		p   TimePrecision
+		err error
+	}{
+		{"2003", PrecisionYear, nil},
+		{"2003-05", PrecisionMonth, nil},
+		{" 2003-05-21 ", PrecisionDay, nil},
+		{"2003-05-21T18:30", PrecisionMinute, nil},
+		{"2003-05-21T18:30:12", PrecisionSecond, nil},
+		{"2003-13", 0, ErrInvalidTimestamp},
+		{"May 2003", 0, ErrInvalidTimestamp},
+	}
+	for _, c := range cases {
+		ts, err := ParseTimestamp(c.s)
+		if err != c.err || ts.Precision != c.p {
+			t.Errorf("%q: got %v/%v", c.s, ts, err)
+		}
+		if err == nil && ts.String() != strings.TrimSpace(c.s) {
+			t.Errorf("%q: formatted as %q", c.s, ts.String())
+		}
+	}
+
+	a, _ := ParseTimestamp("2003")
+	b, _ := ParseTimestamp("2003-01")
+	c, _ := ParseTimestamp("2002-12-31T23")
+	if !a.Before(b) || b.Before(a) || !c.Before(a) {
+		t.Errorf("timestamps misordered")
+	}
+
+	tag := NewTag(Version2_4, 0)
+	tag.SetTimestamp(FrameTypeTextReleaseTime, b)
+	if ts, ok := tag.Timestamp(FrameTypeTextReleaseTime); !ok || ts != b {
+		t.Errorf("got %v, expected %v", ts, b)
+	}
+	if _, ok := tag.Timestamp(FrameTypeTextTaggingTime); ok {
+		t.Errorf("found a missing timestamp")
+	}
+
+	// v2.3 tags split the recording time into TYER, TDAT and TIME frames.
+	tag = NewTag(Version2_3, 0)
+	ts, _ := ParseTimestamp("2003-05-21T18:30")
+	tag.SetTimestamp(FrameTypeTextRecordingTime, ts)
+	tag.SetTimestamp(FrameTypeTextOriginalReleaseTime, ts)
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFromWithOptions(buf, &DecodeOptions{CombineDates: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := tag2.Timestamp(FrameTypeTextRecordingTime); got != ts {
+		t.Errorf("recording time: got %v, expected %v", got, ts)
+	}
+	if got, _ := tag2.Timestamp(FrameTypeTextOriginalReleaseTime); got != a {
+		t.Errorf("original release time: got %v, expected %v", got, a)
+	}
+}
+
+func TestSplitFrames(t *testing.T) {
+	for _, v := range []Version{Version2_2, Version2_3, Version2_4} {
+		tag := NewTag(v, 0)
+		tag.Padding = 16
+		tag.SetTitle("title")
+		tag.SetAlbum("album")
+
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		body := buf.Bytes()[10:]
+
+		frames, err := SplitFrames(body, v)
+		if err != nil {
+			t.Fatalf("v2.%d: %v", v, err)
+		}
+		if len(frames) != 2 {
+			t.Fatalf("v2.%d: got %d frames, expected 2", v, len(frames))
+		}
+		ids := []string{"TIT2", "TALB"}
+		if v == Version2_2 {
+			ids = []string{"TT2", "TAL"}
+		}
+		joined := []byte{}
+		for i, f := range frames {
+			if f.ID != ids[i] || f.Flags != 0 || f.Payload[0] != byte(EncodingISO88591) {
+				t.Errorf("v2.%d: frame %d: got %+v", v, i, f)
+			}
+			if !bytes.Equal(f.Frame, body[f.Offset:f.Offset+len(f.Frame)]) {
+				t.Errorf("v2.%d: frame %d: bad offset", v, i)
+			}
+			joined = append(joined, f.Frame...)
+		}
+		if len(joined) != len(body)-16 || string(frames[1].Payload[1:]) != "album" {
+			t.Errorf("v2.%d: frames don't cover the tag body", v)
+		}
+
+		if _, err := SplitFrames(body[:len(body)-20], v); err != ErrIncompleteFrame {
+			t.Errorf("v2.%d: got %v, expected ErrIncompleteFrame", v, err)
+		}
+	}
+
+	if _, err := SplitFrames(nil, Version(5)); err != ErrInvalidVersion {
+		t.Errorf("got %v, expected ErrInvalidVersion", err)
+	}
+}
+
+func TestGenres(t *testing.T) {
+	if V1Genres[17] != "Rock" || V1Genres[80] != "Folk" || V1Genres[191] != "Psybient" {
+		t.Errorf("genre table misaligned")
+	}
+	if V1GenreNumber("hip-hop") != 7 || V1GenreNumber("Nope") != -1 {
+		t.Errorf("V1GenreNumber failed")
+	}
+
+	cases := []struct {
+		v    Version
+		text []string
+		want []string
+	}{
+		{Version2_3, []string{"(17)"}, []string{"Rock"}},
+		{Version2_3, []string{"(17)Rock"}, []string{"Rock"}},
+		{Version2_3, []string{"(4)(RX)Eurodisco"}, []string{"Disco", "Remix", "Eurodisco"}},
+		{Version2_3, []string{"(CR)((Live)"}, []string{"Cover", "(Live)"}},
+		{Version2_3, []string{"Shoegaze"}, []string{"Shoegaze"}},
+		{Version2_3, []string{"(17)Shoegaze/Dream Pop/"}, []string{"Rock", "Shoegaze", "Dream Pop"}},
+		{Version2_3, []string{"(999)x"}, []string{"(999)x"}},
+		{Version2_4, []string{"17", "RX", "Dream Pop", "Rock"}, []string{"Rock", "Remix", "Dream Pop"}},
+	}
+	for i, c := range cases {
+		tag := NewTag(c.v, 0)
+		tag.SetText(FrameTypeTextGenre, c.text...)
+		if got := tag.Genres(); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("case %d: got %q, expected %q", i, got, c.want)
+		}
+	}
+
+	tag := NewTag(Version2_3, 0)
+	genres := []string{"Rock", "Remix", "Dream Pop", "(Live)"}
+	tag.SetGenres(genres...)
+	if s := firstText(tag, FrameTypeTextGenre); s != "(17)(RX)Dream Pop/(Live)" {
+		t.Errorf("v2.3 SetGenres: got %q", s)
+	}
+	if g := tag.Genres(); !reflect.DeepEqual(g, genres) {
+		t.Errorf("v2.3 Genres: got %q, expected %q", g, genres)
+	}
+	genres = []string{"(Live)", "Dream Pop"}
+	tag.SetGenres(genres...)
+	if g := tag.Genres(); !reflect.DeepEqual(g, genres) {
+		t.Errorf("v2.3 Genres: got %q, expected %q", g, genres)
+	}
+
+	tag = NewTag(Version2_4, 0)
+	tag.SetGenres("Rock", "Dream Pop")
+	if g := tag.Genres(); !reflect.DeepEqual(g, []string{"Rock", "Dream Pop"}) {
+		t.Errorf("v2.4 Genres: got %q", g)
+	}
+}
+
+func TestReplaceArtwork(t *testing.T) {
+	front := NewFrameAttachedPicture("image/png", "front", PictureTypeCoverFront, []byte("old front"))
+	front.Encoding = EncodingISO88591
+	back := NewFrameAttachedPicture("image/png", "back", PictureTypeCoverBack, []byte("back"))
+	back.Encoding = EncodingISO88591
+
+	tag := NewTag(Version2_3, 0)
+	tag.Padding = 64
+	tag.SetTitle("title")
+	tag.Frames = append(tag.Frames, front, back)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tagSize := buf.Len()
+	buf.WriteString("audio data")
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(img []byte, size int) *Tag {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size > 0 && len(b) != size {
+			t.Errorf("file size: got %d, expected %d", len(b), size)
+		}
+		if !bytes.HasSuffix(b, []byte("audio data")) {
+			t.Errorf("audio data lost")
+		}
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFrom(bytes.NewReader(b)); err != nil {
+			t.Fatal(err)
+		}
+		if c := tag2.CoverArt(); c == nil || !bytes.Equal(c.Data, img) || c.MimeType != "image/jpeg" {
+			t.Errorf("cover art not replaced")
+		}
+		return tag2
+	}
+
+	// The new image fits within the padding.
+	img := []byte("\xff\xd8\xff new front")
+	if err := ReplaceArtwork(path, img, "image/jpeg", PictureTypeCoverFront); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := check(img, tagSize+10)
+	if len(tag2.Frames) != 3 || tag2.Title() != "title" || tag2.CoverArt().Description != "front" {
+		t.Errorf("other frames modified")
+	}
+	if p := tag2.Frames[2].(*FrameAttachedPicture); p.Description != "back" || string(p.Data) != "back" {
+		t.Errorf("back cover modified")
+	}
+
+	// The new image requires the file to be rewritten.
+	img = append([]byte("\xff\xd8\xff"), make([]byte, 500)...)
+	if err := ReplaceArtwork(path, img, "image/jpeg", PictureTypeCoverFront); err != nil {
+		t.Fatal(err)
+	}
+	check(img, 0)
+
+	// Tags without artwork have a frame added.
+	tag = NewTag(Version2_4, 0)
+	tag.SetTitle("title")
+	buf.Reset()
+	tag.WriteTo(buf)
+	buf.WriteString("audio data")
+	os.WriteFile(path, buf.Bytes(), 0644)
+	if err := ReplaceArtwork(path, img, "image/jpeg", PictureTypeCoverFront); err != nil {
+		t.Fatal(err)
+	}
+	if tag2 := check(img, 0); len(tag2.Frames) != 2 {
+		t.Errorf("got %d frames, expected 2", len(tag2.Frames))
+	}
+
+	// The padding size stored in a v2.3 extended header is updated.
+	tag = NewTag(Version2_3, TagFlagExtended)
+	tag.Padding = 600
+	tag.SetTitle("title")
+	buf.Reset()
+	tag.WriteTo(buf)
+	buf.WriteString("audio data")
+	os.WriteFile(path, buf.Bytes(), 0644)
+	for _, n := range []int{100, 1000} {
+		img = append([]byte("\xff\xd8\xff"), make([]byte, n)...)
+		if err := ReplaceArtwork(path, img, "image/jpeg", PictureTypeCoverFront); err != nil {
+			t.Fatal(err)
+		}
+		tag2 := check(img, 0)
+		b, _ := os.ReadFile(path)
+		if p := int(decodeUint32(b[16:20])); p != tag2.Padding {
+			t.Errorf("%d byte image: got padding size %d, expected %d", n, p, tag2.Padding)
+		}
+	}
+
+	// Unsynchronized tags aren't supported.
+	tag = NewTag(Version2_3, TagFlagUnsync)
+	tag.SetTitle("title")
+	buf.Reset()
+	tag.WriteTo(buf)
+	os.WriteFile(path, buf.Bytes(), 0644)
+	if err := ReplaceArtwork(path, img, "image/jpeg", PictureTypeCoverFront); err != ErrRawEditUnsupported {
+		t.Errorf("got %v, expected ErrRawEditUnsupported", err)
+	}
+}
+
+func TestFramesByID(t *testing.T) {
+	tag := NewTag(Version2_2, 0)
+	tag.SetTitle("title")
+	tag.SetText(FrameTypeTextSongSubtitle, "subtitle")
+	c1 := NewFrameComment("eng", "", "one")
+	c2 := NewFrameComment("eng", "x", "two")
+	u := &FrameUnknown{Header: FrameHeader{FrameType: FrameTypeUnknown}, FrameID: "XYZ"}
+	tag.Frames = append(tag.Frames, c1, u, c2)
+
+	if ff := tag.FramesByID("COM"); len(ff) != 2 || ff[0] != c1 || ff[1] != c2 {
+		t.Errorf("FramesByID(COM) failed: %v", ff)
+	}
+	if f := tag.FirstFrameByID("TT2"); f == nil || f.(*FrameText).Text[0] != "title" {
+		t.Errorf("FirstFrameByID(TT2) failed")
+	}
+	if tag.FirstFrameByID("XYZ") != u || tag.FirstFrameByID("TIT2") != nil {
+		t.Errorf("FirstFrameByID failed")
+	}
+	if ff := tag.FramesByType(FrameTypeComment); len(ff) != 2 || tag.FirstFrameByType(FrameTypeComment) != c1 {
+		t.Errorf("FramesByType failed")
+	}
+}
+
+func TestAddFrame(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	title := NewFrameText(FrameTypeTextSongTitle, "one")
+	if err := tag.AddFrame(title); err != nil {
+		t.Fatal(err)
+	}
+	if err := tag.AddFrame(NewFrameText(FrameTypeTextSongTitle, "two")); err != ErrDuplicateFrame {
+		t.Errorf("duplicate title: got %v", err)
+	}
+
+	adds := []Frame{
+		NewFrameComment("eng", "", "a"),
+		NewFrameComment("fra", "", "b"),
+		NewFrameComment("eng", "x", "c"),
+		NewFrameTextCustom("one", "1"),
+		NewFrameTextCustom("two", "2"),
+		NewFrameURL(FrameTypeURLArtist, "http://a"),
+		NewFrameURL(FrameTypeURLArtist, "http://b"),
+		NewFrameAttachedPicture("image/png", "a", PictureTypeIcon, nil),
+		NewFrameAttachedPicture("image/png", "b", PictureTypeCoverFront, nil),
+		NewFrameUnknown("LINK", []byte("WCOMhttp://a\x00")),
+		NewFrameUnknown("LINK", []byte("WCOMhttp://b\x00")),
+		NewFrameUnknown("COMR", []byte("\x00EUR1.00\x00")),
+		NewFrameUnknown("COMR", []byte("\x00USD1.00\x00")),
+		NewFrameUnknown("XYZW", []byte("x")),
+		NewFrameUnknown("XYZW", []byte("x")),
+	}
+	for i, f := range adds {
+		if err := tag.AddFrame(f); err != nil {
+			t.Errorf("frame %d: %v", i, err)
+		}
+	}
+
+	dups := []Frame{
+		NewFrameComment("eng", "x", "d"),
+		NewFrameTextCustom("one", "3"),
+		NewFrameURL(FrameTypeURLArtist, "http://a"),
+		NewFrameAttachedPicture("image/png", "c", PictureTypeIcon, nil),
+		NewFrameUnknown("LINK", []byte("WCOMhttp://a\x00")),
+		NewFrameUnknown("COMR", []byte("\x00USD1.00\x00")),
+	}
+	for i, f := range dups {
+		if err := tag.AddFrame(f); err != ErrDuplicateFrame {
+			t.Errorf("duplicate %d: got %v", i, err)
+		}
+	}
+
+	n := len(tag.Frames)
+	c := NewFrameComment("fra", "", "replaced")
+	if old := tag.ReplaceFrame(c); old != adds[1] || tag.Frames[2] != c || len(tag.Frames) != n {
+		t.Errorf("ReplaceFrame didn't replace in place")
+	}
+	if old := tag.ReplaceFrame(NewFrameComment("deu", "", "new")); old != nil || len(tag.Frames) != n+1 {
+		t.Errorf("ReplaceFrame didn't add")
+	}
+	if !tag.RemoveFrame(c) || tag.RemoveFrame(c) || len(tag.Frames) != n {
+		t.Errorf("RemoveFrame failed")
+	}
+	if len(tag.DirtyFrames()) != len(tag.Frames) {
+		t.Errorf("added frames not marked dirty")
+	}
+}
+
+func TestTruncatedTag(t *testing.T) {
+	for _, v := range []Version{Version2_2, Version2_3, Version2_4} {
+		tag := NewTag(v, 0)
+		tag.SetTitle("title")
+		tag.SetAlbum("album")
+		tag.SetArtist("artist")
+
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		b := buf.Bytes()[:buf.Len()-3]
+
+		_, err := (&Tag{}).ReadFrom(bytes.NewReader(b))
+		te, ok := err.(*TruncatedTagError)
+		if !ok {
+			t.Fatalf("v2.%d: got %v, expected a TruncatedTagError", v, err)
+		}
+		if te.Declared != buf.Len()-10 || te.Available != len(b)-10 || te.Unwrap() != ErrTruncatedTag {
+			t.Errorf("v2.%d: got %+v", v, te)
+		}
+
+		report := &DecodeReport{}
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{Lenient: true, Report: report}); err != nil {
+			t.Fatalf("v2.%d: %v", v, err)
+		}
+		if len(tag2.Frames) != 2 || tag2.Album() != "album" || len(report.Repairs) != 2 {
+			t.Errorf("v2.%d: got %d frames and %d repairs", v, len(tag2.Frames), len(report.Repairs))
+		}
+
+		// A header declaring more bytes than the stream holds keeps all
+		// the complete frames, however large the shortfall.
+		for _, missing := range []uint32{3, 40, 200} {
+			b := append([]byte{}, buf.Bytes()...)
+			if err := encodeSyncSafeUint32(b[6:10], uint32(len(b)-10)+missing); err != nil {
+				t.Fatal(err)
+			}
+			tag3 := &Tag{}
+			if _, err := tag3.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{Lenient: true}); err != nil {
+				t.Fatalf("v2.%d missing %d: %v", v, missing, err)
+			}
+			if len(tag3.Frames) != 3 || tag3.Artist() != "artist" {
+				t.Errorf("v2.%d missing %d: got %d frames", v, missing, len(tag3.Frames))
+			}
+		}
+	}
+}
+
+func TestConvertTo(t *testing.T) {
+	tag := NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames,
+		&FrameText{Header: FrameHeader{FrameType: FrameTypeTextSongTitle}, Encoding: EncodingUTF16BOM, Text: []string{"Title"}},
+		NewFrameText(FrameTypeTextRecordingTime, "2003"),
+		NewFrameText(FrameTypeTextDate, "1505"),
+		NewFrameText(FrameTypeTextRecordingDates, "May 15"),
+		NewFrameText(FrameTypeTextTime, "1230"),
+	)
+
+	err := tag.ConvertTo(Version2_4)
+	fe, ok := err.(FrameErrors)
+	if !ok || len(fe) != 1 || fe[0].Index != 3 || fe[0].FrameID != "TRDA" || fe[0].Err != ErrUnsupportedFrameType {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tag.Version != Version2_4 || len(tag.Frames) != 2 {
+		t.Fatalf("got version %d and %d frames", tag.Version, len(tag.Frames))
+	}
+	if ts := firstText(tag, FrameTypeTextRecordingTime); ts != "2003-05-15T12:30" {
+		t.Errorf("got recording time %q", ts)
+	}
+	if id := tag.Frames[1].(*FrameText).Header.FrameID; id != "TDRC" {
+		t.Errorf("got frame ID %s", id)
+	}
+
+	tag = NewTag(Version2_4, TagFlagFooter|TagFlagExtended|TagFlagHasRestrictions)
+	tag.Restrictions = 5
+	tag.Frames = append(tag.Frames,
+		&FrameText{Header: FrameHeader{FrameType: FrameTypeTextSongTitle}, Encoding: EncodingUTF8, Text: []string{"A", "B"}},
+		NewFrameText(FrameTypeTextReleaseTime, "2001"),
+		NewFrameText(FrameTypeTextOriginalReleaseTime, "2001-02-03"),
+	)
+	err = tag.ConvertTo(Version2_2)
+	if fe, ok := err.(FrameErrors); !ok || len(fe) != 1 || fe[0].Index != 1 || fe[0].FrameID != "TDRL" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tag.Flags != 0 || tag.Restrictions != 0 {
+		t.Errorf("got flags %#x and restrictions %d", tag.Flags, tag.Restrictions)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	title := tag2.Frames[0].(*FrameText)
+	if title.Header.FrameID != "TT2" || title.Encoding != EncodingUTF16BOM || title.Text[0] != "A/B" {
+		t.Errorf("got title %+v", title)
+	}
+	if ts := firstText(tag2, FrameTypeTextOriginalReleaseTime); ts != "2001" {
+		t.Errorf("got original release time %q", ts)
+	}
+
+	if err := tag.ConvertTo(Version(5)); err != ErrInvalidVersion {
+		t.Errorf("expected ErrInvalidVersion, got %v", err)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tag.SetTitle("Title")
+	plain := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(plain); err != nil {
+		t.Fatal(err)
+	}
+
+	h := HeaderOf(tag.Frames[0])
+	h.Annotate(AnnotationSource, "musicbrainz")
+	h.Annotate(AnnotationConfidence, "0.9")
+	h.Annotate(AnnotationNote, "")
+	if h.Annotation(AnnotationSource) != "musicbrainz" || len(h.Annotations()) != 2 {
+		t.Fatalf("got annotations %v", h.Annotations())
+	}
+
+	annotated := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(annotated); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plain.Bytes(), annotated.Bytes()) {
+		t.Errorf("annotations were encoded")
+	}
+
+	// Copies carry their own annotations.
+	c := HeaderOf(tag.Freeze().Thaw().Frames[0])
+	c.Annotate(AnnotationSource, "discogs")
+	if c.Annotation(AnnotationConfidence) != "0.9" || h.Annotation(AnnotationSource) != "musicbrainz" {
+		t.Errorf("annotations not copied")
+	}
+
+	h.Annotate(AnnotationSource, "")
+	h.Annotate(AnnotationConfidence, "")
+	if len(h.Annotations()) != 0 {
+		t.Errorf("annotations not removed")
+	}
+}
+
+func TestRestrictions(t *testing.T) {
+	pngImage := func(w, h int) []byte {
+		buf := bytes.NewBuffer([]byte{})
+		if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	tag := NewTag(Version2_4, 0)
+	tag.SetTitle("Title")
+	tag.Frames = append(tag.Frames, NewFrameAttachedPicture("image/png", "", PictureTypeCoverFront, pngImage(64, 64)))
+
+	var r Restrictions
+	if err := r.InferFrom(tag); err != nil {
+		t.Fatal(err)
+	}
+	if r != 0xff || tag.Flags != 0 {
+		t.Errorf("got restrictions %#x and flags %#x", r, tag.Flags)
+	}
+	if frames, n := r.TagSize(); frames != 32 || n != 4096 {
+		t.Errorf("got tag size %d frames, %d bytes", frames, n)
+	}
+	if max, exact := r.ImageSize(); max != 64 || !exact || r.TextSize() != 30 {
+		t.Errorf("got image size %d, %v and text size %d", max, exact, r.TextSize())
+	}
+
+	tag.Frames = append(tag.Frames,
+		&FrameText{Header: FrameHeader{FrameType: FrameTypeTextAlbumName}, Encoding: EncodingUTF16BOM, Text: []string{strings.Repeat("a", 100), strings.Repeat("b", 100)}},
+		NewFrameAttachedPicture("image/jpg", "", PictureTypeCoverBack, pngImage(300, 10)),
+		NewFrameAttachedPicture("image/png", "", PictureTypeArtist, pngImage(4000, 1)),
+	)
+	if err := r.InferFrom(tag); err != nil {
+		t.Fatal(err)
+	}
+	if want := RestrictTagSize4KB | RestrictTextSize1024 | RestrictImageEncoding; r != want {
+		t.Errorf("got restrictions %#x, expected %#x", r, want)
+	}
+
+	tag.Frames[1].(*FrameAttachedPicture).MimeType = "image/gif"
+	tag.Frames = tag.Frames[:2]
+	if err := r.InferFrom(tag); err != nil {
+		t.Fatal(err)
+	}
+	if want := RestrictTagSize4KB | RestrictTextEncoding | RestrictTextSize30 | RestrictImageSize64Exact; r != want {
+		t.Errorf("got restrictions %#x, expected %#x", r, want)
+	}
+
+	tag.Padding = 2 * 1024 * 1024
+	if err := r.InferFrom(tag); err != ErrExceedsRestrictions {
+		t.Errorf("expected ErrExceedsRestrictions, got %v", err)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 20, 300)), nil); err != nil {
+		t.Fatal(err)
+	}
+	sizes := []struct {
+		data []byte
+		w, h int
+		ok   bool
+	}{
+		{pngImage(300, 10), 300, 10, true},
+		{buf.Bytes(), 20, 300, true},
+		{[]byte("GIF89a"), 0, 0, false},
+		{[]byte{0xff, 0xd8, 0xff, 0xc0, 0, 17}, 0, 0, false},
+	}
+	for i, c := range sizes {
+		if w, h, ok := imageSize(c.data); w != c.w || h != c.h || ok != c.ok {
+			t.Errorf("image %d: got %dx%d, %v", i, w, h, ok)
+		}
+	}
+
+	// Inferring restrictions leaves the tag's defaults to be applied when
+	// it is written.
+	tag = NewTag(Version2_4, 0)
+	c := tag.SetComment("", "comment")
+	if err := r.InferFrom(tag); err != nil {
+		t.Fatal(err)
+	}
+	opts := &EncodeOptions{DefaultLanguage: "fra"}
+	if _, err := tag.WriteToWithOptions(ioutil.Discard, opts); err != nil {
+		t.Fatal(err)
+	}
+	if c.Language != "fra" {
+		t.Errorf("got comment language %q after InferFrom", c.Language)
+	}
+}
+
+func TestReadContext(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames, NewFrameAttachedPicture("image/png", "", PictureTypeCoverFront, make([]byte, 100000)))
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{MaxTagSize: 1000}); err != ErrTagSizeLimit {
+		t.Errorf("expected ErrTagSizeLimit, got %v", err)
+	}
+	if _, err := tag2.ReadFromWithOptions(bytes.NewReader(b), &DecodeOptions{MaxTagSize: len(b)}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// A stream stalling after 50000 bytes is abandoned once the context's
+	// deadline passes, after the first complete chunk of tag data.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go pw.Write(b[:50000])
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	n, err := tag2.ReadFromWithOptions(pr, &DecodeOptions{Context: ctx})
+	if err != context.DeadlineExceeded || n != 10+loadChunkSize {
+		t.Errorf("got %d bytes and error %v", n, err)
+	}
+}
+
+func TestClone(t *testing.T) {
+	rva := NewFrameVolumeAdjustment2("track")
+	rva.Channels = []ChannelAdjustment{{Channel: ChannelMaster, Adjustment: 512, PeakBits: 8, Peak: []byte{0x40}}}
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameAttachedPicture("image/png", "", PictureTypeCoverFront, []byte{1, 2, 3}),
+		rva,
+		NewFrameChapter("ch1", 0, 1000, NewFrameText(FrameTypeTextSongTitle, "Chapter")),
+	)
+	tag.ExtendedUnknown = []byte{9}
+	tag.MarkDirty(rva)
+
+	c := tag.Clone()
+	c.Frames[0].(*FrameAttachedPicture).Data[0] = 0
+	c.Frames[1].(*FrameVolumeAdjustment2).Channels[0].Peak[0] = 0
+	c.Frames[2].(*FrameChapter).Subframes[0].(*FrameText).Text[0] = "Changed"
+	c.ExtendedUnknown[0] = 0
+
+	if tag.Frames[0].(*FrameAttachedPicture).Data[0] != 1 || rva.Channels[0].Peak[0] != 0x40 ||
+		tag.Frames[2].(*FrameChapter).Subframes[0].(*FrameText).Text[0] != "Chapter" || tag.ExtendedUnknown[0] != 9 {
+		t.Errorf("clone shares data with the tag")
+	}
+	if dirty := c.DirtyFrames(); len(dirty) != 1 || dirty[0] != c.Frames[1] {
+		t.Errorf("dirty frames not cloned: %v", dirty)
+	}
+
+	f := CloneFrame(rva).(*FrameVolumeAdjustment2)
+	f.Channels[0].Peak[0] = 1
+	if f == rva || rva.Channels[0].Peak[0] != 0x40 {
+		t.Errorf("CloneFrame shares data with the frame")
+	}
+}
+
+func TestSortFrames(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameAttachedPicture("image/png", "", PictureTypeCoverFront, []byte{1}),
+		NewFrameComment("eng", "", "comment"),
+		NewFrameTextCustom("b", "2"),
+		NewFrameText(FrameTypeTextAlbumName, "Album"),
+		NewFrameTextCustom("a", "1"),
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameUniqueFileID("owner", "id"),
+		NewFrameText(FrameTypeTextComposer, "Composer"),
+	)
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteToWithOptions(buf, &EncodeOptions{CanonicalOrder: true, Verify: true}); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"UFID", "TIT2", "TALB", "TCOM", "TXXX", "TXXX", "COMM", "APIC"}
+	for i, f := range tag2.Frames {
+		if id := HeaderOf(f).FrameID; id != want[i] {
+			t.Errorf("frame %d: got %s, expected %s", i, id, want[i])
+		}
+	}
+	if d := tag2.Frames[4].(*FrameTextCustom).Description; d != "b" {
+		t.Errorf("frames with the same ID reordered")
+	}
+}
+
+func TestCheckConformance(t *testing.T) {
+	tag := NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames,
+		&FrameText{Header: FrameHeader{FrameType: FrameTypeTextSongTitle}, Encoding: EncodingUTF16BOM, Text: []string{"Title"}})
+	for _, p := range TaggerProfiles {
+		issues, err := tag.CheckConformance(p, nil)
+		if err != nil || len(issues) != 0 {
+			t.Errorf("%s: got %v, %v", p.Name, issues, err)
+		}
+	}
+
+	tag = NewTag(Version2_4, TagFlagFooter)
+	tag.Frames = append(tag.Frames,
+		&FrameText{Header: FrameHeader{FrameType: FrameTypeTextArtist, Flags: FrameFlagCompressed}, Encoding: EncodingUTF8, Text: []string{"A", "B"}})
+	issues, err := tag.CheckConformance(ProfileWindowsExplorer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Windows Explorer: tag footers are not supported",
+		"Windows Explorer: TPE1: compressed frames are not supported",
+		"Windows Explorer: TPE1: text encoding 3 is not supported",
+		"Windows Explorer: TPE1: only the first of 2 values is read",
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("got issues %v", issues)
+	}
+	for i := range want {
+		if issues[i].String() != want[i] {
+			t.Errorf("issue %d: got %q, expected %q", i, issues[i], want[i])
+		}
+	}
+
+	if issues, _ := tag.CheckConformance(ProfilePicard, nil); len(issues) != 0 {
+		t.Errorf("got issues %v", issues)
+	}
+	if _, err := CheckConformance(bytes.NewReader([]byte("not a tag")), ProfileITunes); err != ErrInvalidTag {
+		t.Errorf("expected ErrInvalidTag, got %v", err)
+	}
+}
+
+func TestConformanceMatrix(t *testing.T) {
+	title := func(enc Encoding, flags FrameFlags, text ...string) Frame {
+		return &FrameText{Header: FrameHeader{FrameType: FrameTypeTextSongTitle, Flags: flags}, Encoding: enc, Text: text}
+	}
+
+	// Each sample exercises one feature, and lists the profiles that read
+	// it without issues.
+	samples := []struct {
+		name    string
+		v       Version
+		flags   TagFlags
+		f       Frame
+		readers []*TaggerProfile
+	}{
+		{"v2.2", Version2_2, 0, title(EncodingISO88591, 0, "T"),
+			[]*TaggerProfile{ProfileITunes, ProfilePicard}},
+		{"UTF-16BE", Version2_4, 0, title(EncodingUTF16, 0, "T"),
+			[]*TaggerProfile{ProfilePicard}},
+		{"UTF-8", Version2_4, 0, title(EncodingUTF8, 0, "T"),
+			[]*TaggerProfile{ProfileMp3tag, ProfileFoobar2000, ProfilePicard}},
+		{"multiple values", Version2_4, 0, title(EncodingISO88591, 0, "A", "B"),
+			[]*TaggerProfile{ProfileMp3tag, ProfileFoobar2000, ProfilePicard}},
+		{"tag unsync", Version2_3, TagFlagUnsync, title(EncodingISO88591, 0, "\xff\xe0"),
+			[]*TaggerProfile{ProfilePicard}},
+		{"frame unsync", Version2_4, 0, title(EncodingISO88591, FrameFlagUnsynchronized, "\xff\xe0"),
+			[]*TaggerProfile{ProfilePicard}},
+		{"compression", Version2_3, 0, title(EncodingISO88591, FrameFlagCompressed, "T"),
+			[]*TaggerProfile{ProfilePicard}},
+		{"extended header", Version2_4, TagFlagExtended, title(EncodingISO88591, 0, "T"),
+			[]*TaggerProfile{ProfilePicard}},
+		{"footer", Version2_4, TagFlagFooter, title(EncodingISO88591, 0, "T"),
+			[]*TaggerProfile{ProfilePicard}},
+	}
+	for _, c := range samples {
+		tag := NewTag(c.v, c.flags)
+		tag.Frames = append(tag.Frames, c.f)
+		for _, p := range TaggerProfiles {
+			issues, err := tag.CheckConformance(p, nil)
+			if err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+			reads := false
+			for _, r := range c.readers {
+				reads = reads || r == p
+			}
+			if reads != (len(issues) == 0) {
+				t.Errorf("%s: %s: got issues %v", c.name, p.Name, issues)
+			}
+		}
+	}
+}
+
+func TestV1Extended(t *testing.T) {
+	title := "A Very Long Song Title That Is " + "Longer Than Thirty Characters"
+	v1Title, extTitle := SplitV1Text(title)
+	if v1Title != "A Very Long Song Title That Is" || extTitle != " Longer Than Thirty Characters" {
+		t.Errorf("got split %q, %q", v1Title, extTitle)
+	}
+
+	e := &V1Extended{
+		Title:     extTitle,
+		Artist:    "Dvořák",
+		Speed:     V1SpeedFast,
+		Genre:     "Chamber Music",
+		StartTime: "000:05",
+		EndTime:   "004:30",
+	}
+
+	// Store the extended block in front of an ID3v1 tag whose title field
+	// has its trailing space removed.
+	v1 := make([]byte, 128)
+	copy(v1, "TAG"+strings.TrimRight(v1Title, " "))
+	file := append(append([]byte("audio"), e.Bytes()...), v1...)
+
+	info, err := Identify(bytes.NewReader(file))
+	if err != nil || !info.HasV1 || !info.HasV1Extended {
+		t.Errorf("got info %+v, %v", info, err)
+	}
+
+	e2, err := ReadV1Extended(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Artist = "Dvo.ák"
+	e.Title = strings.TrimRight(e.Title, " ")
+	if *e2 != *e {
+		t.Errorf("got %+v, expected %+v", e2, e)
+	}
+	if full := JoinV1Text(decodeV1String(v1[3:33]), e2.Title); full != title {
+		t.Errorf("got joined title %q", full)
+	}
+
+	if _, err := ReadV1Extended(bytes.NewReader(v1)); err != ErrNoV1Extended {
+		t.Errorf("expected ErrNoV1Extended, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	compressed := NewFrameText(FrameTypeTextAlbumName, "Album")
+	compressed.Header.Flags = FrameFlagCompressed
+	tag := NewTag(Version2_4, TagFlagFooter)
+	tag.Padding = 10
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextSongTitle, "Title"),
+		NewFrameText(FrameTypeTextRecordingTime, "2003-13"),
+		NewFrameComment("ENG", "", "comment"),
+		NewFrameComment("en", "", "comment"),
+		compressed,
+		NewFrameUnknown("abc1", []byte{1}),
+	)
+
+	want := []string{
+		"error: tag: tag with a footer can't contain padding",
+		`error: frame 1 (TDRC) at offset 26: invalid timestamp "2003-13"`,
+		`warning: frame 2 (COMM) at offset 44: language code "ENG" is not lowercase`,
+		`error: frame 3 (COMM) at offset 66: language code "en" is not three letters long`,
+		"error: frame 4 (TALB) at offset 66: compressed frame lacks a data length indicator",
+		`error: frame 5 (abc1) at offset 99: invalid frame ID "abc1"`,
+	}
+	issues := tag.Validate()
+	if len(issues) != len(want) {
+		t.Fatalf("got issues %v", issues)
+	}
+	for i := range want {
+		if issues[i].String() != want[i] {
+			t.Errorf("issue %d: got %q, expected %q", i, issues[i], want[i])
+		}
+	}
+	if tag.Padding != 10 || tag.Frames[3].(*FrameComment).Language != "en" {
+		t.Errorf("tag modified")
+	}
+
+	// Frames added by setters are checked with their defaults applied.
+	tag = NewTag(Version2_3, 0)
+	tag.SetComment("", "comment")
+	tag.SetLyrics("", "lyrics")
+	if issues := tag.Validate(); len(issues) != 0 {
+		t.Errorf("got issues %v for frames added by setters", issues)
+	}
+	if c := tag.FindFrame(FrameTypeComment).(*FrameComment); c.Language != "" {
+		t.Errorf("tag modified")
+	}
+
+	tag = NewTag(Version2_3, 0)
+	tag.Frames = append(tag.Frames,
+		NewFrameText(FrameTypeTextDate, "3112"),
+		NewFrameText(FrameTypeTextTime, "2460"),
+	)
+	want = []string{
+		"warning: frame 0 (TDAT) at offset 10: text encoding 3 not defined by ID3v2.3",
+		"warning: frame 1 (TIME) at offset 25: text encoding 3 not defined by ID3v2.3",
+		`error: frame 1 (TIME) at offset 25: invalid time "2460", must be of the form HHMM`,
+	}
+	issues = tag.Validate()
+	if len(issues) != len(want) {
+		t.Fatalf("got issues %v", issues)
+	}
+	for i := range want {
+		if issues[i].String() != want[i] {
+			t.Errorf("issue %d: got %q, expected %q", i, issues[i], want[i])
+		}
+	}
+}
+
+func TestEnforceRestrictions(t *testing.T) {
+	tag := NewTag(Version2_4, TagFlagHasRestrictions)
+	tag.Restrictions = uint8(RestrictTagSize4KB | RestrictTextEncoding | RestrictTextSize30 | RestrictImageEncoding)
+	tag.SetTitle("Title")
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	tag.Frames = append(tag.Frames,
+		&FrameText{Header: FrameHeader{FrameType: FrameTypeTextAlbumName}, Encoding: EncodingUTF16BOM, Text: []string{strings.Repeat("a", 31)}},
+		NewFrameAttachedPicture("image/gif", "", PictureTypeCoverFront, make([]byte, 5000)),
+	)
+	buf.Reset()
+	_, err := tag.WriteTo(buf)
+	re, ok := err.(RestrictionError)
+	if !ok || buf.Len() != 0 {
+		t.Fatalf("expected RestrictionError, got %v", err)
+	}
+	want := []string{
+		"tag holds 3 frames in 5132 bytes, exceeding the limit of 32 frames in 4096 bytes",
+		"frame 1 (TALB): text encoding is not ISO-8859-1 or UTF-8",
+		"frame 1 (TALB): text is longer than 30 characters",
+		"frame 2 (APIC): image is not a PNG or JPEG image",
+	}
+	if len(re) != len(want) {
+		t.Fatalf("got %v", re)
+	}
+	for i := range want {
+		if re[i].Error() != want[i] {
+			t.Errorf("violation %d: got %q, expected %q", i, re[i].Error(), want[i])
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	tag := NewTag(Version2_4, 0)
+	tag.SetTitle("Title")
+	tag.ClearDirty()
+	title := tag.Frames[0]
+
+	errAbort := fmt.Errorf("abort")
+	err := tag.Edit(func(tx *TagTx) error {
+		tx.SetTitle("Changed")
+		tx.SetAlbum("Album")
+		return errAbort
+	})
+	if err != errAbort || len(tag.Frames) != 1 || tag.Title() != "Title" || tag.Frames[0] != title {
+		t.Errorf("aborted edit changed the tag")
+	}
+
+	err = tag.Edit(func(tx *TagTx) error {
+		tx.SetAlbum("Album")
+		tx.Frames = append(tx.Frames, NewFrameText(FrameTypeTextRecordingTime, "yesterday"))
+		return nil
+	})
+	verr, ok := err.(ValidationError)
+	if !ok || len(verr) != 1 || verr[0].FrameID != "TDRC" || len(tag.Frames) != 1 {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	err = tag.Edit(func(tx *TagTx) error {
+		tx.SetTitle("Changed")
+		tx.SetAlbum("Album")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Title() != "Changed" || tag.Album() != "Album" || title.(*FrameText).Text[0] != "Title" {
+		t.Errorf("edit not committed")
+	}
+	if len(tag.DirtyFrames()) != 2 {
+		t.Errorf("got %d dirty frames, expected 2", len(tag.DirtyFrames()))
+	}
+
+	// Frames added by the setters, whose languages and encodings are
+	// selected when the tag is written, don't fail validation.
+	tag = NewTag(Version2_3, 0)
+	err = tag.Edit(func(tx *TagTx) error {
+		tx.SetComment("", "hi")
+		tx.SetLyrics("", "la la la")
+		tx.SetTermsOfUse("none")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := tag.FindFrame(FrameTypeComment); c == nil || c.(*FrameComment).Text != "hi" {
+		t.Errorf("edit not committed")
+	}
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPaddingPolicy(t *testing.T) {
+	var tests = []struct {
+		policy PaddingPolicy
+		align  int
+		size   int
+	}{
+		{PaddingNone, 0, 26},
+		{PaddingFixed(1000), 0, 1026},
+		{PaddingFixed(1), 0, 30},
+		{PaddingToSize(4096), 0, 4096},
+		{PaddingToSize(10), 0, 26},
+		{PaddingPercent(100), 0, 52},
+		{PaddingPercent(10), 0, 30},
+		{PaddingFixed(100), 512, 512},
+	}
+
+	for i, test := range tests {
+		tag := NewTag(Version2_4, 0)
+		tag.Padding = 300
+		tag.SetTitle("Title")
+		buf := bytes.NewBuffer([]byte{})
+		n, err := tag.WriteToWithOptions(buf, &EncodeOptions{Padding: test.policy, Alignment: test.align})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(n) != test.size || buf.Len() != test.size {
+			t.Errorf("test %d: tag size %d, expected %d", i, n, test.size)
+		}
+		if tag.Padding != test.size-26 {
+			t.Errorf("test %d: padding %d, expected %d", i, tag.Padding, test.size-26)
+		}
+	}
+
+	tag := NewTag(Version2_4, TagFlagFooter)
+	if _, err := tag.WriteToWithOptions(ioutil.Discard, &EncodeOptions{Padding: PaddingFixed(16)}); err != ErrPaddingNotAllowed {
+		t.Errorf("expected ErrPaddingNotAllowed, got %v", err)
+	}
+	if _, err := tag.WriteToWithOptions(ioutil.Discard, &EncodeOptions{Padding: PaddingNone}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMusicCDIdentifier(t *testing.T) {
+	offsets := []uint32{150, 9700, 25887, 39297, 53795, 63735, 77517, 94877,
+		107270, 123552, 135522, 148422, 161197, 174790, 192022, 205545,
+		218010, 228700, 239590, 255470, 266932, 288750}
+	toc := &CDTOC{FirstTrack: 1, LastTrack: 22, LeadOut: 303602 - 150}
+	for i, o := range offsets {
+		toc.Tracks = append(toc.Tracks, CDTrack{Number: uint8(i + 1), Offset: o - 150})
+	}
+
+	if id := toc.FreeDBDiscID(); id != 0x370fce16 {
+		t.Errorf("FreeDB disc ID %08x, expected 370fce16", id)
+	}
+	if id := toc.MusicBrainzDiscID(); id != "xUp1F2NkfP8s8jaeFn_Av3jNEI4-" {
+		t.Errorf("MusicBrainz disc ID %s, expected xUp1F2NkfP8s8jaeFn_Av3jNEI4-", id)
+	}
+
+	for _, v := range []Version{Version2_2, Version2_3, Version2_4} {
+		tag := NewTag(v, 0)
+		tag.Frames = append(tag.Frames, NewFrameMusicCDIdentifier(toc.Bytes()))
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+
+		tag2 := &Tag{}
+		if _, err := tag2.ReadFrom(buf); err != nil {
+			t.Fatal(err)
+		}
+		f, ok := tag2.FindFrame(FrameTypeMusicCDIdentifier).(*FrameMusicCDIdentifier)
+		if !ok {
+			t.Fatalf("v2.%d: music CD identifier frame not decoded", v)
+		}
+		toc2, err := f.ParseTOC()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(toc2, toc) {
+			t.Errorf("v2.%d: parsed TOC %+v, expected %+v", v, toc2, toc)
+		}
+	}
+
+	b := toc.Bytes()
+	b[len(b)-14] = 23 // renumber the last track
+	if _, err := ParseTOC(b); err != ErrInvalidTOC {
+		t.Errorf("expected ErrInvalidTOC, got %v", err)
+	}
+	if _, err := ParseTOC(b[:len(b)-8]); err != ErrInvalidTOC {
+		t.Errorf("expected ErrInvalidTOC for truncated TOC, got %v", err)
+	}
+}
+
+func TestChapterElementIDs(t *testing.T) {
+	build := func() *Tag {
+		tag := NewTag(Version2_4, 0)
+		tag.Frames = append(tag.Frames,
+			NewFrameTableOfContents("toc", true, []string{"c1", "c2", "c3"}),
+			NewFrameChapter("c1", 0, 1000, NewFrameText(FrameTypeTextSongTitle, "Intro")),
+			NewFrameChapter("c2", 1000, 5000, NewFrameText(FrameTypeTextSongTitle, "Part")),
+			NewFrameChapter("c3", 1000, 5000, NewFrameText(FrameTypeTextSongTitle, "Part")),
+		)
+		if err := tag.AssignChapterIDs(); err != nil {
+			t.Fatal(err)
+		}
+		return tag
+	}
+
+	tag := build()
+	var ids []string
+	for _, f := range tag.FindFrames(FrameTypeChapter) {
+		ids = append(ids, string(f.(*FrameChapter).ElementID))
+	}
+	if len(ids[0]) != 19 || !strings.HasPrefix(ids[0], "chp") || ids[0] == ids[1] || ids[2] != ids[1]+"-2" {
+		t.Errorf("unexpected chapter IDs %q", ids)
+	}
+	if toc := tag.Frames[0].(*FrameTableOfContents); !reflect.DeepEqual(toc.ChildElementIDs, ids) {
+		t.Errorf("table of contents lists %q, expected %q", toc.ChildElementIDs, ids)
+	}
+	if len(tag.DirtyFrames()) != 4 {
+		t.Errorf("got %d dirty frames, expected 4", len(tag.DirtyFrames()))
+	}
+
+	buf1 := bytes.NewBuffer([]byte{})
+	buf2 := bytes.NewBuffer([]byte{})
+	tag.WriteTo(buf1)
+	build().WriteTo(buf2)
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Errorf("regenerated chapters produced a different tag")
+	}
+
+	id, _ := ChapterElementID(NewFrameChapter("x", 0, 1000, NewFrameText(FrameTypeTextSongTitle, "Outro")))
+	if id == ids[0] {
+		t.Errorf("chapters with different titles got the same ID")
+	}
+}
+
+func TestEncodedSize(t *testing.T) {
+	unsynced := []byte{0xff, 0xe0, 0xff, 0xff, 0x00, 0xff}
+
+	tag23 := NewTag(Version2_3, TagFlagUnsync|TagFlagExtended|TagFlagHasCRC)
+	tag23.Padding = 100
+	tag23.SetTitle("Title")
+	tag23.Frames = append(tag23.Frames, NewFramePrivate("owner", unsynced))
+
+	tag22 := NewTag(Version2_2, TagFlagUnsync)
+	tag22.Padding = 1
+	tag22.Frames = append(tag22.Frames,
+		&FrameText{Header: FrameHeader{FrameType: FrameTypeTextSongTitle}, Text: []string{"Title"}},
+		&FrameUnknown{Header: FrameHeader{FrameID: "XYZ"}, Data: unsynced},
+	)
+
+	tag24 := NewTag(Version2_4, TagFlagFooter|TagFlagUnsync|TagFlagHasRestrictions)
+	tag24.Restrictions = uint8(RestrictTagSize4KB)
+	tag24.SetTitle("Title")
+	tag24.SetComment("", "Comment")
+	tag24.Frames = append(tag24.Frames, NewFramePrivate("owner", unsynced))
+
+	for _, tag := range []*Tag{tag23, tag22, tag24} {
+		padding, frames := tag.Padding, len(tag.Frames)
+		for _, opts := range []*EncodeOptions{nil, {Alignment: 512}, {Stamp: &Stamp{Application: "Test"}}} {
+			if tag.Version == Version2_4 && opts != nil && opts.Alignment > 0 {
+				continue
+			}
+			n, err := tag.EncodedSize(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tag.Padding != padding || len(tag.Frames) != frames || HeaderOf(tag.Frames[0]).Size != 0 {
+				t.Errorf("EncodedSize modified the tag")
+			}
+
+			buf := bytes.NewBuffer([]byte{})
+			if _, err := tag.Clone().WriteToWithOptions(buf, opts); err != nil {
+				t.Fatal(err)
+			}
+			if n != buf.Len() {
+				t.Errorf("v2.%d: EncodedSize returned %d, tag encoded to %d bytes", tag.Version, n, buf.Len())
+			}
+		}
+	}
+}
+
+func TestBounds(t *testing.T) {
+	if _, err := Bounds(Version(9)); err != ErrInvalidVersion {
+		t.Errorf("expected ErrInvalidVersion, got %v", err)
+	}
+
+	b22, _ := Bounds(Version2_2)
+	b24, _ := Bounds(Version2_4)
+	if b22["Encoding"].Max != 1 || b24["Encoding"].Max != 3 {
+		t.Errorf("unexpected encoding bounds %v, %v", b22["Encoding"], b24["Encoding"])
+	}
+	if _, ok := b22["GroupID"]; ok {
+		t.Errorf("v2.2 has no group IDs")
+	}
+	want := FieldBounds{0x80, 0xf0, ErrInvalidGroupID}
+	if b24["GroupID"] != want {
+		t.Errorf("got GroupID bounds %v, expected %v", b24["GroupID"], want)
+	}
+
+	// The bounds agree with the encoder.
+	pb := b24["PictureType"]
+	for _, pt := range []int{pb.Max, pb.Max + 1} {
+		tag := NewTag(Version2_4, 0)
+		tag.Frames = append(tag.Frames, NewFrameAttachedPicture("image/png", "", PictureType(pt), []byte{1}))
+		_, err := tag.WriteTo(ioutil.Discard)
+		if err != pb.Check(pt) {
+			t.Errorf("picture type %d: encoder returned %v, bounds returned %v", pt, err, pb.Check(pt))
+		}
+	}
+}
+
+func TestTagFile(t *testing.T) {
+	dir := t.TempDir()
+	audio := []byte("audio data")
+
+	// A file without a tag.
+	path := filepath.Join(dir, "untagged.mp3")
+	if err := os.WriteFile(path, audio, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tf, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tf.AudioOffset() != 0 || tf.Tag().Version != Version2_4 || len(tf.Tag().Frames) != 0 {
+		t.Errorf("unexpected tag for untagged file")
+	}
+	tf.Tag().SetTitle("Title")
+	if err := tf.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The saved tag is followed by the audio data.
+	b, _ := os.ReadFile(path)
+	if _, size, err := PeekTag(b); err != nil || tf.AudioOffset() != int64(size) {
+		t.Errorf("audio offset %d after save, tag size %d", tf.AudioOffset(), size)
+	}
+	if !bytes.Equal(b[tf.AudioOffset():], audio) {
+		t.Errorf("audio data not preserved")
+	}
+
+	// Saving again uses the reopened file.
+	tf.Tag().SetArtist("Artist")
+	if err := tf.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tf.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tf, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tf.Close()
+	if tf.Tag().Title() != "Title" || tf.Tag().Artist() != "Artist" {
+		t.Errorf("tag not saved")
+	}
+	b, _ = os.ReadFile(path)
+	if !bytes.Equal(b[tf.AudioOffset():], audio) {
+		t.Errorf("audio data not preserved")
+	}
+}
+
+type testFingerprinter struct{}
+
+func (testFingerprinter) Fingerprint(ctx context.Context, audio io.Reader) (AudioFingerprint, error) {
+	b, err := ioutil.ReadAll(audio)
+	return AudioFingerprint{Data: string(b), Duration: time.Minute}, err
+}
+
+type testResolver map[string]*Recording
+
+func (r testResolver) Resolve(ctx context.Context, fp AudioFingerprint) (*Recording, error) {
+	return r[fp.Data], nil
+}
+
+func TestTagIdentify(t *testing.T) {
+	id := &Identifier{testFingerprinter{}, testResolver{
+		"known": {
+			AcoustID:    "acoustid",
+			RecordingID: "recording",
+			ReleaseID:   "release",
+			ArtistIDs:   []string{"artist1", "artist2"},
+			Title:       "Title",
+			Artists:     []string{"A", "B"},
+			Year:        1999,
+			Track:       TrackNumber{3, 12},
+		},
+	}}
+
+	tag := NewTag(Version2_4, 0)
+	tag.SetAlbum("Album")
+	if err := tag.IdentifyWith(context.Background(), strings.NewReader("known"), nil); err != ErrNoIdentifier {
+		t.Errorf("expected ErrNoIdentifier, got %v", err)
+	}
+	if err := tag.IdentifyWith(context.Background(), strings.NewReader("unknown"), id); err != ErrNoMatch {
+		t.Errorf("expected ErrNoMatch, got %v", err)
+	}
+	if len(tag.Frames) != 1 {
+		t.Errorf("unmatched identification changed the tag")
+	}
+
+	if err := tag.IdentifyWith(context.Background(), strings.NewReader("known"), id); err != nil {
+		t.Fatal(err)
+	}
+	s := Summarize(tag)
+	if s.Title != "Title" || !reflect.DeepEqual(s.Artists, []string{"A", "B"}) || s.Album != "Album" || s.Year != 1999 || tag.Track() != (TrackNumber{3, 12}) {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	ufid, ok := tag.FindFrame(FrameTypeUniqueFileID).(*FrameUniqueFileID)
+	if !ok || string(ufid.Identifier) != "recording" {
+		t.Errorf("recording ID not stored")
+	}
+	user := tag.UserTextMap()
+	if user["Acoustid Id"][0] != "acoustid" || user["MusicBrainz Album Id"][0] != "release" || len(user["MusicBrainz Artist Id"]) != 2 {
+		t.Errorf("unexpected user text %v", user)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := tag.IdentifyWith(ctx, strings.NewReader("known"), id); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestTagFileInPlace(t *testing.T) {
+	tag := NewTag(Version2_3, 0)
+	tag.SetTitle("Title")
+	tag.Padding = 1024
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	size := buf.Len()
+	audio := []byte("audio data")
+	buf.Write(audio)
+
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.Stat(path)
+
+	tf, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tf.Close()
+
+	// A tag that fits is overwritten in place.
+	for i := 0; i < 2; i++ {
+		tf.Tag().SetAlbum(strings.Repeat("Album", i+1))
+		if err := tf.Save(); err != nil {
+			t.Fatal(err)
+		}
+		after, _ := os.Stat(path)
+		if !os.SameFile(before, after) || after.Size() != before.Size() || tf.AudioOffset() != int64(size) {
+			t.Errorf("save %d: tag not overwritten in place", i)
+		}
+	}
+	b, _ := os.ReadFile(path)
+	tag2 := &Tag{}
+	if _, err := tag2.ReadFrom(bytes.NewReader(b)); err != nil {
+		t.Fatal(err)
+	}
+	if tag2.Album() != "AlbumAlbum" || !bytes.Equal(b[size:], audio) {
+		t.Errorf("in-place save produced an incorrect file")
+	}
+
+	// A tag that doesn't fit causes the file to be rewritten.
+	tf.Tag().SetComment("", strings.Repeat("x", 2000))
+	if err := tf.Save(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if os.SameFile(before, after) || tf.AudioOffset() <= int64(size) {
+		t.Errorf("file not rewritten")
+	}
+	b, _ = os.ReadFile(path)
+	if !bytes.Equal(b[tf.AudioOffset():], audio) {
+		t.Errorf("audio data not preserved")
+	}
+}
+
+func TestTagFileRewrite(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xff, 0xfb, 0x90, 0x00}, 50000)
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(path, audio, 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	tf, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tf.Close()
+
+	var calls int
+	var written, total int64
+	tf.PreserveModTime = true
+	tf.Progress = func(w, t int64) {
+		calls++
+		written, total = w, t
+	}
+	tf.Tag().SetTitle("Title")
+	if err := tf.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls < 2 || written != total || total != info.Size() || total != tf.AudioOffset()+int64(len(audio)) {
+		t.Errorf("progress: %d calls, %d of %d bytes written, file size %d", calls, written, total, info.Size())
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("modification time %v, expected %v", info.ModTime(), mtime)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("permissions %v, expected 0640", info.Mode().Perm())
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind")
+	}
+}
+
+func TestTagFileReadOnly(t *testing.T) {
+	audio := bytes.Repeat([]byte{0xff, 0xfb, 0x90, 0x00}, 1000)
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	if err := os.WriteFile(path, audio, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	tf, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tf.Close()
+
+	// Privileged users may open the file for writing regardless of its
+	// permissions, so treat it as opened read-only.
+	tf.writable = false
+
+	tf.Tag().SetTitle("Title")
+	if err := tf.Save(); err != os.ErrPermission {
+		t.Errorf("expected os.ErrPermission, got %v", err)
+	}
+	if b, err := os.ReadFile(path); err != nil || !bytes.Equal(b, audio) {
+		t.Errorf("read-only file modified")
+	}
+}
+
+func TestReadV1(t *testing.T) {
+	v1 := make([]byte, 128)
+	copy(v1, "TAGTitle")
+	copy(v1[33:], "Artist")
+	copy(v1[63:], "Album   ")
+	copy(v1[93:], "1999")
+	copy(v1[97:], "Comment")
+	v1[126] = 7
+	v1[127] = 17
+	file := append([]byte("audio"), v1...)
+
+	tag, err := ReadV1(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &V1Tag{"Title", "Artist", "Album", "1999", "Comment", 7, 17, nil}
+	if !reflect.DeepEqual(tag, want) {
+		t.Errorf("got %+v, expected %+v", tag, want)
+	}
+	if tag.GenreName() != "Rock" {
+		t.Errorf("got genre %q, expected Rock", tag.GenreName())
+	}
+
+	// An ID3v1.0 tag uses the whole comment field.
+	copy(v1[97:], strings.Repeat("c", 30))
+	tag, _ = ParseV1(v1)
+	if tag.Track != 0 || len(tag.Comment) != 30 {
+		t.Errorf("got v1.0 tag %+v", tag)
+	}
+
+	// A preceding extended block is read too.
+	e := &V1Extended{Title: "continued", Genre: "Chamber Music"}
+	file = append(append([]byte("audio"), e.Bytes()...), v1...)
+	tag, err = ReadV1(bytes.NewReader(file))
+	if err != nil || tag.Extended == nil || tag.Extended.Title != "continued" || tag.GenreName() != "Chamber Music" {
+		t.Errorf("extended block not read: %+v, %v", tag, err)
+	}
+
+	if _, err := ReadV1(bytes.NewReader(file[:len(file)-1])); err != ErrNoV1 {
+		t.Errorf("expected ErrNoV1, got %v", err)
+	}
+}
+
+func TestSyncV1FromV2(t *testing.T) {
+	v1 := &V1Tag{"Title", "Artist", "Album", "1999", "Comment", 7, 17, nil}
+	if v, err := ParseV1(v1.Bytes()); err != nil || !reflect.DeepEqual(v, v1) {
+		t.Errorf("round trip produced %+v, %v", v, err)
+	}
+
+	tag := NewTag(Version2_4, 0)
+	tag.SetTitle("A Very Long Song Title That Doesn't Fit")
+	tag.SetArtist("Dvořák", "Other")
+	tag.SetAlbum("Album")
+	tag.SetYear(1999)
+	tag.SetTrack(TrackNumber{3, 12})
+	tag.SetGenres("Nonexistent", "Jazz")
+	tag.SetComment("desc", "Described")
+	tag.SetComment("", "Plain comment that is longer than 28 characters")
+
+	want := &V1Tag{
+		Title:   "A Very Long Song Title That",
+		Artist:  "Dvořák/Other",
+		Album:   "Album",
+		Year:    "1999",
+		Comment: "Plain comment that is longer",
+		Track:   3,
+		Genre:   8,
+	}
+	got := V1FromV2(tag, V1TextOptions{WordBoundary: true})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, expected %+v", got, want)
+	}
+
+	// Append a tag to a file without one, then overwrite it, removing an
+	// extended block.
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	audio := []byte("audio data")
+	ext := &V1Extended{Title: "stale"}
+	for i, contents := range [][]byte{audio, append(append(append([]byte{}, audio...), ext.Bytes()...), v1.Bytes()...)} {
+		if err := os.WriteFile(path, contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := SyncV1FromV2(path, tag, V1TextOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := os.ReadFile(path)
+		if len(b) != len(audio)+V1Size || !bytes.Equal(b[:len(audio)], audio) {
+			t.Errorf("file %d: unexpected contents %q", i, b)
+			continue
+		}
+		v, err := ReadV1(bytes.NewReader(b))
+		if err != nil || v.Title != "A Very Long Song Title That Do" || v.Extended != nil {
+			t.Errorf("file %d: got %+v, %v", i, v, err)
+		}
+	}
+
+	// Store text that doesn't fit in an extended block, then replace the
+	// block with a tag that doesn't need one.
+	for i, want := range []int{V1ExtendedSize, 0} {
+		if i == 1 {
+			tag.SetTitle("Short")
+		}
+		if err := SyncV1FromV2(path, tag, V1TextOptions{Extended: true}); err != nil {
+			t.Fatal(err)
+		}
+		b, _ := os.ReadFile(path)
+		if len(b) != len(audio)+want+V1Size || !bytes.Equal(b[:len(audio)], audio) {
+			t.Errorf("extended %d: unexpected contents %q", i, b)
+			continue
+		}
+		v, err := ReadV1(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if title := JoinV1Text(v.Title, extendedTitle(v)); title != tag.Title() {
+			t.Errorf("extended %d: got title %q", i, title)
+		}
+	}
+}
+
+// extendedTitle returns the title stored in an ID3v1 tag's extended block,
+// if it has one.
+func extendedTitle(v *V1Tag) string {
+	if v.Extended == nil {
+		return ""
+	}
+	return v.Extended.Title
+}
+
+func TestNewTagFromV1(t *testing.T) {
+	v1 := &V1Tag{"A Very Long Song Title That Is", "Artist", "", "1999", "Comment", 7, 17, nil}
+	v1.Extended = &V1Extended{Title: " Continued"}
+
+	for _, v := range []Version{Version2_3, Version2_4} {
+		tag, err := NewTagFromV1(v1, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag.Version != v || tag.Title() != "A Very Long Song Title That Is Continued" || tag.Artist() != "Artist" ||
+			tag.FindFrame(FrameTypeTextAlbumName) != nil || tag.Year() != 1999 || tag.Track() != (TrackNumber{7, 0}) {
+			t.Errorf("v2.%d: unexpected tag %+v", v, Summarize(tag))
+		}
+		if g := tag.Genres(); len(g) != 1 || g[0] != "Rock" {
+			t.Errorf("v2.%d: got genres %q", v, g)
+		}
+		if c, ok := tag.FindFrame(FrameTypeComment).(*FrameComment); !ok || c.Text != "Comment" || c.Description != "" {
+			t.Errorf("v2.%d: comment not converted", v)
+		}
+		if _, err := tag.WriteTo(ioutil.Discard); err != nil {
+			t.Errorf("v2.%d: %v", v, err)
+		}
+	}
+
+	if _, err := NewTagFromV1(v1, Version(1)); err != ErrInvalidVersion {
+		t.Errorf("expected ErrInvalidVersion, got %v", err)
+	}
+}
+
+func TestStrip(t *testing.T) {
+	encode := func(tag *Tag) []byte {
+		buf := bytes.NewBuffer([]byte{})
+		if _, err := tag.WriteTo(buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	lead1 := NewTag(Version2_3, 0)
+	lead1.SetTitle("Leading")
+	lead1.Padding = 64
+	lead2 := NewTag(Version2_4, 0)
+	lead2.SetAlbum("Leading")
+	appended := NewTag(Version2_4, TagFlagFooter)
+	appended.SetTitle("Appended")
+	v1 := &V1Tag{Title: "v1", Genre: V1GenreNone}
+	audio := bytes.Repeat([]byte{0xff, 0xfb, 0x90, 0x00}, 100)
+
+	var files = [][][]byte{
+		{encode(lead1), encode(lead2), audio, encode(appended), (&V1Extended{}).Bytes(), v1.Bytes()},
+		{audio, encode(appended)},
+		{encode(lead2), audio},
+		{audio, v1.Bytes()},
+		{audio},
+	}
+
+	path := filepath.Join(t.TempDir(), "test.mp3")
+	for i, parts := range files {
+		if err := os.WriteFile(path, bytes.Join(parts, nil), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Strip(path); err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := os.ReadFile(path); !bytes.Equal(b, audio) {
+			t.Errorf("file %d: stripped to %d bytes, expected %d bytes of audio", i, len(b), len(audio))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package id3
+
+import (
+	"io"
+	"os"
+)
+
+// Info describes the ID3 tags present in a file, as determined by Identify.
+type Info struct {
+	Version     Version  // ID3v2 version, or 0 if there is no ID3v2 tag
+	Flags       TagFlags // ID3v2 header and extended header flags
+	Size        int      // total size of the ID3v2 tag, including header and footer
+	HasExtended bool     // true if the ID3v2 tag has an extended header
+	HasFooter   bool     // true if the ID3v2 tag has a footer
+	HasV1       bool     // true if an ID3v1 tag exists at the end of the file
+
+	// HasV1Extended is true if an extended ID3v1 ("TAG+") block precedes
+	// the ID3v1 tag.
+	HasV1Extended bool
+}
+
+// Identify examines the ID3 tags stored in r without decoding them, reading
+// only a few dozen bytes. An ID3v2 tag is recognized at the start of r. An
+// ID3v1 tag is recognized at the end of r only if r's size is known, which
+// requires r to provide a Size or Stat method (as *bytes.Reader,
+// *io.SectionReader and *os.File do). If r contains no ID3 tags, Identify
+// returns a zero Info and no error.
+func Identify(r io.ReaderAt) (Info, error) {
+	var info Info
+
+	hdr := make([]byte, 10)
+	if _, err := r.ReadAt(hdr, 0); err != nil && err != io.EOF {
+		return info, err
+	}
+
+	if v, size, err := PeekTag(hdr); err == nil {
+		info.Version = v
+		info.Size = size
+		info.Flags = identifyFlags(r, v, hdr[5])
+		info.HasExtended = (info.Flags & TagFlagExtended) != 0
+		info.HasFooter = (info.Flags & TagFlagFooter) != 0
+	}
+
+	if size, ok := readerSize(r); ok && size >= 128 {
+		id := make([]byte, 3)
+		if _, err := r.ReadAt(id, size-128); err != nil {
+			return info, err
+		}
+		info.HasV1 = string(id) == "TAG"
+	}
+	if size, ok := readerSize(r); ok && info.HasV1 && size >= V1ExtendedSize+128 {
+		id := make([]byte, 4)
+		if _, err := r.ReadAt(id, size-V1ExtendedSize-128); err != nil {
+			return info, err
+		}
+		info.HasV1Extended = string(id) == "TAG+"
+	}
+
+	return info, nil
+}
+
+// identifyFlags decodes the flags of a tag's header and, if it has one, its
+// extended header.
+func identifyFlags(r io.ReaderAt, v Version, hdrFlags byte) TagFlags {
+	var vdata *versionData
+	switch v {
+	case Version2_2:
+		vdata = newCodec22().vdata
+	case Version2_3:
+		vdata = newCodec23().vdata
+	default:
+		vdata = newCodec24().vdata
+	}
+
+	flags := vdata.headerFlags.Decode(uint32(hdrFlags))
+	if (flags&uint32(TagFlagExtended)) == 0 || v == Version2_2 {
+		return TagFlags(flags)
+	}
+
+	ex := make([]byte, 6)
+	if _, err := r.ReadAt(ex, 10); err != nil {
+		return TagFlags(flags)
+	}
+	if v == Version2_3 {
+		flags |= vdata.headerExFlags.Decode(uint32(ex[4]) << 8)
+	} else {
+		flags |= vdata.headerExFlags.Decode(uint32(ex[5]))
+	}
+	return TagFlags(flags)
+}
+
+// readerSize returns the size of the data underlying r, if it can be
+// determined.
+func readerSize(r io.ReaderAt) (int64, bool) {
+	switch rr := r.(type) {
+	case interface{ Size() int64 }:
+		return rr.Size(), true
+	case interface{ Stat() (os.FileInfo, error) }:
+		if fi, err := rr.Stat(); err == nil {
+			return fi.Size(), true
+		}
+	}
+	return 0, false
+}
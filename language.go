@@ -0,0 +1,83 @@
+package id3
+
+import (
+	"sort"
+	"strings"
+)
+
+// LanguageStats summarizes the languages declared by the comment and lyrics
+// frames (COMM, USLT and SYLT) of a set of tags.
+type LanguageStats struct {
+	Counts   map[string]int    // number of frames declaring each language
+	Dominant string            // most commonly declared language
+	Outliers []LanguageOutlier // frames declaring another language
+}
+
+// A LanguageOutlier identifies a frame whose declared language differs from
+// the dominant language of the analyzed tags.
+type LanguageOutlier struct {
+	Tag      int    // index of the frame's tag within the analyzed set
+	Frame    Frame  // the outlying frame
+	Language string // language declared by the frame
+}
+
+// AnalyzeLanguages tallies the ISO-639-2 language codes declared by the
+// comment and lyrics frames of the tags, infers the dominant language, and
+// flags frames declaring any other language. Codes are compared without
+// regard to case, and undefined codes ("xxx" or empty) are ignored. Ties
+// for the dominant language are broken alphabetically.
+func AnalyzeLanguages(tags []*Tag) LanguageStats {
+	stats := LanguageStats{Counts: make(map[string]int)}
+
+	for _, t := range tags {
+		for _, f := range t.Frames {
+			if lang, ok := frameLanguage(f); ok {
+				stats.Counts[lang]++
+			}
+		}
+	}
+
+	langs := make([]string, 0, len(stats.Counts))
+	for lang := range stats.Counts {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		if stats.Counts[lang] > stats.Counts[stats.Dominant] {
+			stats.Dominant = lang
+		}
+	}
+
+	for i, t := range tags {
+		for _, f := range t.Frames {
+			if lang, ok := frameLanguage(f); ok && lang != stats.Dominant {
+				stats.Outliers = append(stats.Outliers, LanguageOutlier{i, f, lang})
+			}
+		}
+	}
+
+	return stats
+}
+
+// frameLanguage returns the normalized language code declared by a comment
+// or lyrics frame. It returns false if the frame doesn't declare a defined
+// language.
+func frameLanguage(f Frame) (string, bool) {
+	var lang string
+	switch ff := f.(type) {
+	case *FrameComment:
+		lang = ff.Language
+	case *FrameLyricsUnsync:
+		lang = ff.Language
+	case *FrameLyricsSync:
+		lang = ff.Language
+	default:
+		return "", false
+	}
+
+	lang = strings.ToLower(strings.TrimRight(lang, "\x00 "))
+	if lang == "" || lang == "xxx" {
+		return "", false
+	}
+	return lang, true
+}
@@ -0,0 +1,187 @@
+package id3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// LinkMimeType is the MIME type of attached picture and general
+// encapsulated object frames whose data holds a URL referencing the
+// picture or object rather than the picture or object itself.
+const LinkMimeType = "-->"
+
+// A Fetcher retrieves the data referenced by the URL of a linked frame.
+type Fetcher func(url string) (io.ReadCloser, error)
+
+// IsLink returns true if the picture's data holds a URL referencing the
+// image rather than the image itself.
+func (f *FrameAttachedPicture) IsLink() bool {
+	return f.MimeType == LinkMimeType
+}
+
+// LinkURL returns the URL referencing the image if the picture is a link,
+// or an empty string otherwise.
+func (f *FrameAttachedPicture) LinkURL() string {
+	if !f.IsLink() {
+		return ""
+	}
+	return linkURL(f.Open())
+}
+
+// Fetch returns a reader over the picture's image data. The data of linked
+// pictures is retrieved using the fetcher; the data of all other pictures
+// is read from the frame. Fetching a linked picture with a nil fetcher
+// returns ErrNoFetcher.
+func (f *FrameAttachedPicture) Fetch(fetch Fetcher) (io.ReadCloser, error) {
+	if !f.IsLink() {
+		return f.Open(), nil
+	}
+	if fetch == nil {
+		return nil, ErrNoFetcher
+	}
+	return fetch(f.LinkURL())
+}
+
+// IsLink returns true if the object's data holds a URL referencing the
+// object rather than the object itself.
+func (f *FrameGeneralObject) IsLink() bool {
+	return f.MimeType == LinkMimeType
+}
+
+// LinkURL returns the URL referencing the object if the object is a link,
+// or an empty string otherwise.
+func (f *FrameGeneralObject) LinkURL() string {
+	if !f.IsLink() {
+		return ""
+	}
+	return linkURL(ioutil.NopCloser(bytes.NewReader(f.Data)))
+}
+
+// Fetch returns a reader over the object's data. The data of linked objects
+// is retrieved using the fetcher; the data of all other objects is read
+// from the frame. Fetching a linked object with a nil fetcher returns
+// ErrNoFetcher.
+func (f *FrameGeneralObject) Fetch(fetch Fetcher) (io.ReadCloser, error) {
+	if !f.IsLink() {
+		return ioutil.NopCloser(bytes.NewReader(f.Data)), nil
+	}
+	if fetch == nil {
+		return nil, ErrNoFetcher
+	}
+	return fetch(f.LinkURL())
+}
+
+// linkURL reads the URL stored in a linked frame's data, which may be null
+// terminated.
+func linkURL(rc io.ReadCloser) string {
+	defer rc.Close()
+	b, _ := ioutil.ReadAll(rc)
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// A URLStatus describes the health of a URL stored in a URL frame.
+type URLStatus struct {
+	Frame      Frame  // the URL frame
+	URL        string // the URL stored in the frame
+	StatusCode int    // HTTP status code of the final response, if any
+	FinalURL   string // URL of the final response after redirects
+	Redirects  int    // number of redirects followed
+	Err        error  // error preventing a response, if any
+}
+
+// OK returns true if the URL was reachable and its final response
+// indicated success.
+func (s URLStatus) OK() bool {
+	return s.Err == nil && s.StatusCode >= 200 && s.StatusCode < 300
+}
+
+// CheckURLs checks every URL stored in the tag's URL frames (W***),
+// including those embedded in chapter subframes, by issuing HEAD requests
+// with the client and following any redirects. Servers that don't support
+// HEAD requests are checked with a GET request instead. A nil client
+// selects http.DefaultClient. CheckURLs returns a status for each URL in
+// frame order.
+func (t *Tag) CheckURLs(ctx context.Context, client *http.Client) []URLStatus {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var statuses []URLStatus
+	for _, f := range urlFrames(t.Frames) {
+		var url string
+		switch ff := f.(type) {
+		case *FrameURL:
+			url = string(ff.URL)
+		case *FrameURLCustom:
+			url = string(ff.URL)
+		}
+		statuses = append(statuses, checkURL(ctx, client, f, url))
+	}
+	return statuses
+}
+
+// urlFrames returns all URL frames in the frame list, including those
+// embedded as subframes.
+func urlFrames(ff []Frame) []Frame {
+	var uf []Frame
+	for _, f := range ff {
+		switch f.(type) {
+		case *FrameURL, *FrameURLCustom:
+			uf = append(uf, f)
+		}
+		if sf := subframesOf(f); sf != nil {
+			uf = append(uf, urlFrames(*sf)...)
+		}
+	}
+	return uf
+}
+
+func checkURL(ctx context.Context, client *http.Client, f Frame, url string) URLStatus {
+	s := URLStatus{Frame: f, URL: url}
+
+	// Count redirects using a copy of the client.
+	c := *client
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		s.Redirects = len(via)
+		if client.CheckRedirect != nil {
+			return client.CheckRedirect(req, via)
+		}
+		if len(via) >= 10 {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+
+	resp, err := doRequest(ctx, &c, "HEAD", url)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		s.Redirects = 0
+		resp, err = doRequest(ctx, &c, "GET", url)
+	}
+	if err != nil {
+		s.Err = err
+		return s
+	}
+
+	s.StatusCode = resp.StatusCode
+	s.FinalURL = resp.Request.URL.String()
+	return s
+}
+
+func doRequest(ctx context.Context, c *http.Client, method, url string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
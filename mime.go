@@ -0,0 +1,222 @@
+package id3
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// A MimeSniffer inspects the leading bytes of a picture or object payload
+// and returns its MIME type, or an empty string if it doesn't recognize
+// the payload.
+type MimeSniffer func(data []byte) string
+
+type namedMimeSniffer struct {
+	name string
+	s    MimeSniffer
+}
+
+var (
+	mimeSniffersMu sync.RWMutex
+	mimeSniffers   = []namedMimeSniffer{
+		{"image", sniffImage},
+	}
+)
+
+// sniffLen is the number of leading payload bytes passed to MIME sniffers.
+const sniffLen = 512
+
+// RegisterMimeSniffer registers a MIME sniffer for payloads beyond the
+// built-in image types, such as PDF documents or cue sheets stored in
+// general encapsulated object (GEOB) frames. Sniffers are tried in
+// registration order after the built-in image sniffer, and the first
+// non-empty result is used. Registering a sniffer with the name of a
+// previously registered sniffer replaces it.
+func RegisterMimeSniffer(name string, s MimeSniffer) {
+	mimeSniffersMu.Lock()
+	defer mimeSniffersMu.Unlock()
+
+	for i := range mimeSniffers {
+		if mimeSniffers[i].name == name {
+			mimeSniffers[i].s = s
+			return
+		}
+	}
+	mimeSniffers = append(mimeSniffers, namedMimeSniffer{name, s})
+}
+
+// DetectMimeType returns the MIME type of a picture or object payload, or
+// an empty string if no sniffer recognizes it.
+func DetectMimeType(data []byte) string {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+
+	mimeSniffersMu.RLock()
+	defer mimeSniffersMu.RUnlock()
+
+	for _, ns := range mimeSniffers {
+		if m := ns.s(data); m != "" {
+			return m
+		}
+	}
+	return ""
+}
+
+// sniffImage recognizes the image formats commonly stored in attached
+// picture frames.
+func sniffImage(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xff, 0xd8, 0xff}):
+		return "image/jpeg"
+	case bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(b, []byte("GIF87a")), bytes.HasPrefix(b, []byte("GIF89a")):
+		return "image/gif"
+	case bytes.HasPrefix(b, []byte("BM")) && len(b) >= 14:
+		return "image/bmp"
+	case len(b) >= 12 && bytes.Equal(b[0:4], []byte("RIFF")) && bytes.Equal(b[8:12], []byte("WEBP")):
+		return "image/webp"
+	default:
+		return ""
+	}
+}
+
+// FillMimeTypes sets the MIME type of every attached picture and general
+// encapsulated object frame that lacks one, other than linked frames,
+// using the registered MIME sniffers. It returns the number of frames
+// updated.
+func (t *Tag) FillMimeTypes() int {
+	n := 0
+	for _, f := range t.Frames {
+		m, data := mimeTypeOf(f)
+		if m == nil || *m != "" {
+			continue
+		}
+		if d := DetectMimeType(data); d != "" {
+			*m = WesternString(d)
+			n++
+		}
+	}
+	return n
+}
+
+// CheckMimeTypes compares the MIME type of every attached picture and
+// general encapsulated object frame, other than linked frames, against
+// the type detected by the registered MIME sniffers. It returns an error
+// of ErrMimeTypeMismatch for each frame whose payload is recognized as a
+// different type. Frames with unrecognized payloads are not reported.
+func (t *Tag) CheckMimeTypes() []FrameError {
+	var errs []FrameError
+	for i, f := range t.Frames {
+		m, data := mimeTypeOf(f)
+		if m == nil {
+			continue
+		}
+		d := DetectMimeType(data)
+		if d != "" && normalizeMimeType(string(*m)) != d {
+			errs = append(errs, FrameError{i, HeaderOf(f).FrameID, ErrMimeTypeMismatch})
+		}
+	}
+	return errs
+}
+
+// mimeTypeOf returns a pointer to the MIME type of a picture or object
+// frame along with the leading bytes of its payload. It returns a nil
+// pointer for linked frames and all other frames.
+func mimeTypeOf(f Frame) (*WesternString, []byte) {
+	switch ff := f.(type) {
+	case *FrameAttachedPicture:
+		if ff.IsLink() {
+			return nil, nil
+		}
+		rc := ff.Open()
+		defer rc.Close()
+		b := make([]byte, sniffLen)
+		n, _ := io.ReadFull(rc, b)
+		return &ff.MimeType, b[:n]
+	case *FrameGeneralObject:
+		if ff.IsLink() {
+			return nil, nil
+		}
+		return &ff.MimeType, ff.Data
+	default:
+		return nil, nil
+	}
+}
+
+// normalizeMimeType converts a MIME type to lowercase and replaces common
+// nonstandard aliases with their standard names.
+func normalizeMimeType(m string) string {
+	m = strings.ToLower(strings.TrimSpace(m))
+	switch m {
+	case "image/jpg":
+		return "image/jpeg"
+	}
+	return m
+}
+
+// pictureFormats maps the image formats stored by v2.2 attached picture
+// (PIC) frames to MIME types.
+var pictureFormats = []struct {
+	format   string
+	mimeType string
+}{
+	{"JPG", "image/jpeg"},
+	{"PNG", "image/png"},
+	{"GIF", "image/gif"},
+	{"BMP", "image/bmp"},
+	{"TIF", "image/tiff"},
+	{LinkMimeType, LinkMimeType},
+}
+
+// PictureFormatMimeType returns the MIME type equivalent to the 3-character
+// image format stored by a v2.2 attached picture (PIC) frame. Attached
+// picture frames decoded from v2.2 tags hold the MIME type, so that their
+// MimeType field is consistent across versions. Unrecognized formats are
+// mapped to "image/" followed by the format in lowercase.
+func PictureFormatMimeType(format string) string {
+	format = strings.TrimRight(format, " \x00")
+	for _, p := range pictureFormats {
+		if strings.EqualFold(format, p.format) {
+			return p.mimeType
+		}
+	}
+	return "image/" + strings.ToLower(format)
+}
+
+// MimeTypePictureFormat returns the 3-character image format equivalent to
+// a MIME type, for storage in a v2.2 attached picture (PIC) frame. MIME
+// types not recognized are mapped to the first three characters of their
+// subtype in uppercase, padded with spaces. A MIME type that is already a
+// 3-character format is returned in uppercase. It returns false if the MIME
+// type has no format equivalent.
+func MimeTypePictureFormat(mimeType string) (string, bool) {
+	n := normalizeMimeType(mimeType)
+	for _, p := range pictureFormats {
+		if n == p.mimeType {
+			return p.format, true
+		}
+	}
+
+	var format string
+	switch i := strings.IndexByte(n, '/'); {
+	case i >= 0:
+		format = n[i+1:]
+	case len(n) == 3:
+		format = n
+	}
+	if format == "" {
+		return "", false
+	}
+	if len(format) > 3 {
+		format = format[:3]
+	}
+	for _, c := range format {
+		if c < 0x20 || c > 0x7e {
+			return "", false
+		}
+	}
+	return strings.ToUpper(format + "   "[:3-len(format)]), true
+}
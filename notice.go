@@ -0,0 +1,76 @@
+package id3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NewFrameCopyright creates a new copyright message (TCOP) text frame
+// containing a spec-compliant notice of the form "YYYY holder". The year
+// must be between 0 and 9999, and the holder must not be empty.
+func NewFrameCopyright(year int, holder string) (*FrameText, error) {
+	s, err := formatNotice(year, holder)
+	if err != nil {
+		return nil, err
+	}
+	return NewFrameText(FrameTypeTextCopyright, s), nil
+}
+
+// NewFrameProducedNotice creates a new produced notice (TPRO) text frame
+// containing a spec-compliant notice of the form "YYYY holder". The year
+// must be between 0 and 9999, and the holder must not be empty.
+func NewFrameProducedNotice(year int, holder string) (*FrameText, error) {
+	s, err := formatNotice(year, holder)
+	if err != nil {
+		return nil, err
+	}
+	return NewFrameText(FrameTypeTextProducedNotice, s), nil
+}
+
+// ParseNotice parses a copyright message or produced notice of the form
+// "YYYY holder", returning the year and the holder. It returns
+// ErrInvalidNotice if the notice doesn't begin with a four-digit year
+// followed by a space.
+func ParseNotice(s string) (year int, holder string, err error) {
+	if len(s) < 6 || s[4] != ' ' {
+		return 0, "", ErrInvalidNotice
+	}
+	for i := 0; i < 4; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, "", ErrInvalidNotice
+		}
+	}
+	year, _ = strconv.Atoi(s[:4])
+	holder = strings.TrimSpace(s[5:])
+	if holder == "" {
+		return 0, "", ErrInvalidNotice
+	}
+	return year, holder, nil
+}
+
+func formatNotice(year int, holder string) (string, error) {
+	holder = strings.TrimSpace(holder)
+	if year < 0 || year > 9999 || holder == "" {
+		return "", ErrInvalidNotice
+	}
+	return fmt.Sprintf("%04d %s", year, holder), nil
+}
+
+// checkNotice returns ErrInvalidNotice if the frame is a copyright message
+// or produced notice that isn't of the form "YYYY holder".
+func checkNotice(f Frame) error {
+	ft, ok := f.(*FrameText)
+	if !ok {
+		return nil
+	}
+	switch ft.Header.FrameType {
+	case FrameTypeTextCopyright, FrameTypeTextProducedNotice:
+		for _, s := range ft.Text {
+			if _, _, err := ParseNotice(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
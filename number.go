@@ -0,0 +1,48 @@
+package id3
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A TrackNumber holds a position within a numbered set, such as the track
+// number stored in a TRCK frame or the disc number stored in a TPOS frame,
+// along with the optional size of the set. A Total of 0 means the size of
+// the set is unknown.
+type TrackNumber struct {
+	Number int
+	Total  int
+}
+
+// ParseTrackNumber parses a "3" or "3/12" style value stored in a TRCK or
+// TPOS frame. Surrounding spaces are ignored. It returns
+// ErrInvalidTrackNumber if the value isn't of this form.
+func ParseTrackNumber(s string) (TrackNumber, error) {
+	var tn TrackNumber
+	ss := strings.SplitN(s, "/", 2)
+
+	n, err := strconv.Atoi(strings.TrimSpace(ss[0]))
+	if err != nil || n < 0 {
+		return TrackNumber{}, ErrInvalidTrackNumber
+	}
+	tn.Number = n
+
+	if len(ss) > 1 {
+		n, err = strconv.Atoi(strings.TrimSpace(ss[1]))
+		if err != nil || n < 0 {
+			return TrackNumber{}, ErrInvalidTrackNumber
+		}
+		tn.Total = n
+	}
+	return tn, nil
+}
+
+// String formats the track number as it is stored in a TRCK or TPOS frame:
+// "3", or "3/12" if the total is known.
+func (tn TrackNumber) String() string {
+	s := strconv.Itoa(tn.Number)
+	if tn.Total > 0 {
+		s += "/" + strconv.Itoa(tn.Total)
+	}
+	return s
+}
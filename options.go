@@ -0,0 +1,711 @@
+package id3
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"strings"
+)
+
+// DecodeOptions control optional behaviors of the tag decoder.
+type DecodeOptions struct {
+	// Lenient enables the repair of common defects written by buggy
+	// taggers, such as frame IDs containing lowercase letters or trailing
+	// spaces. It also causes the complete frames of a tag extending past
+	// the end of the stream to be decoded instead of failing with a
+	// TruncatedTagError. Repairs are recorded in the report.
+	Lenient bool
+
+	// StrictDataLength causes frames whose data length indicator does not
+	// match the length of their decoded payload to fail with
+	// ErrInvalidDataLength. By default such frames are accepted, with a
+	// warning recorded in the report, since some encoders write incorrect
+	// data lengths even though the frame data itself is fine.
+	StrictDataLength bool
+
+	// LazyPictureSize, if positive, enables lazy decoding of attached
+	// pictures whose image data is at least this many bytes long. The
+	// decoder seeks past the image data of lazily decoded pictures instead
+	// of reading it, and FrameAttachedPicture.Open streams it from the
+	// source on demand. Lazy decoding requires the decoded stream to
+	// implement io.ReaderAt and io.Seeker (e.g., an *os.File) and to remain
+	// open while the tag is in use. It is not used for pictures in tags
+	// with an extended header or unsynchronization, for pictures in frames
+	// with format flags such as compression or encryption, or when
+	// CaptureRaw is set.
+	LazyPictureSize int
+
+	// MaxDecompressedSize limits the size in bytes of the decompressed
+	// payload of a compressed frame, protecting against frames that expand
+	// to enormous sizes. Zero selects DefaultMaxDecompressedSize, and a
+	// negative value removes the limit. Frames exceeding the limit, or
+	// whose data length indicator claims a size exceeding it, fail to
+	// decode with a *DecompressionError.
+	MaxDecompressedSize int
+
+	// MaxCompressionRatio, if positive, further limits the decompressed
+	// size of a compressed frame to this multiple of its compressed size.
+	MaxCompressionRatio int
+
+	// CRCCompat causes tags whose CRC doesn't cover the range of bytes
+	// defined by the tag's version to be checked against the ranges used
+	// by other known writers before failing with ErrFailedCRC. The
+	// matching range is recorded in the report.
+	CRCCompat bool
+
+	// CombineDates causes the year (TYER), date (TDAT) and time (TIME)
+	// frames of v2.3 and earlier tags to be combined into a single
+	// timestamp of the form yyyy-MM-ddTHH:mm, as stored by v2.4 recording
+	// time (TDRC) frames. The timestamp replaces the text of the year frame,
+	// which has the same frame type as TDRC, and the date and time frames
+	// are removed. Timestamps are split again when the tag is written.
+	CombineDates bool
+
+	// CaptureRaw causes the bytes of each frame, as stored in the tag, to be
+	// retained alongside the decoded frame for use by DebugDump.
+	CaptureRaw bool
+
+	// VerifySignature, if non-nil, is called for every signature (SIGN)
+	// frame once the tag's frames are decoded. An error returned by the
+	// verifier aborts decoding.
+	VerifySignature SignatureVerifier
+
+	// UnknownFrame, if non-nil, is called with the frame ID of every frame
+	// of an unknown type encountered while decoding. Such frames are
+	// decoded as FrameUnknown frames and counted in the report.
+	UnknownFrame func(frameID string)
+
+	// Report, if non-nil, receives a description of any non-fatal problems
+	// encountered while decoding the tag.
+	Report *DecodeReport
+
+	// Context, if non-nil, cancels reading the tag from a slow stream, such
+	// as the body of an HTTP response. When the context is done, reading
+	// stops with the context's error, even if a read from the stream is
+	// blocked, and the number of bytes read so far is returned. The stream
+	// must not be used once the context is done.
+	Context context.Context
+
+	// MaxTagSize, if positive, limits the size in bytes of the tags read.
+	// Tags declaring a larger size fail with ErrTagSizeLimit before their
+	// data is read.
+	MaxTagSize int
+
+	source   io.ReaderAt               // source for lazily decoded pictures
+	seeker   io.Seeker                 // seeker used to skip image data
+	pictures map[int]*io.SectionReader // skipped image data, by buffer remainder
+}
+
+// DefaultMaxDecompressedSize is the maximum decompressed size of a frame
+// used when DecodeOptions.MaxDecompressedSize is zero.
+const DefaultMaxDecompressedSize = 64 << 20
+
+// A DecodeReport describes non-fatal problems encountered while decoding a
+// tag.
+type DecodeReport struct {
+	Warnings []DecodeWarning
+	Repairs  []DecodeRepair
+	Failure  *DecodeFailure // frame that caused decoding to fail, if any
+
+	// UnknownFrames counts the frames of unknown types encountered, by
+	// frame ID.
+	UnknownFrames map[string]int
+
+	// CRCVariant identifies the range of bytes covered by the tag's CRC, or
+	// CRCUnchecked if the tag has no CRC.
+	CRCVariant CRCVariant
+}
+
+// A DecodeFailure describes the frame that caused decoding to fail. The
+// frames preceding it remain in the decoded tag, so they can be salvaged.
+type DecodeFailure struct {
+	Offset  int    // offset of the frame within the tag's resynchronized data
+	FrameID string // ID of the frame, as found in the tag
+	Err     error  // the decoding error
+}
+
+// A DecodeWarning describes a single non-fatal problem encountered while
+// decoding a tag.
+type DecodeWarning struct {
+	FrameID string // ID of the frame with the problem, if any
+	Err     error  // description of the problem
+}
+
+func (w DecodeWarning) String() string {
+	if w.FrameID == "" {
+		return w.Err.Error()
+	}
+	return w.FrameID + ": " + w.Err.Error()
+}
+
+// A DecodeRepair describes a defect that was repaired while decoding a tag
+// in lenient mode.
+type DecodeRepair struct {
+	FrameID string // ID of the repaired frame, as found in the tag
+	Repair  string // description of the repair
+}
+
+func (r DecodeRepair) String() string {
+	if r.FrameID == "" {
+		return r.Repair
+	}
+	return r.FrameID + ": " + r.Repair
+}
+
+// repair records a repair in the options' decode report, if there is one.
+func (o *DecodeOptions) repair(frameID, repair string) {
+	if o.Report != nil {
+		o.Report.Repairs = append(o.Report.Repairs, DecodeRepair{frameID, repair})
+	}
+}
+
+// loadTag loads the size bytes of tag data following a tag header into the
+// reader's buffer. If the stream ends first, it returns a TruncatedTagError,
+// unless lenient decoding is enabled, in which case the available data is
+// kept and truncated is true.
+func (o *DecodeOptions) loadTag(r *reader, t *Tag) (truncated bool, err error) {
+	size := t.Size
+	if o.MaxTagSize > 0 && size > o.MaxTagSize {
+		return false, ErrTagSizeLimit
+	}
+
+	var n int
+	if o.source != nil && o.seeker != nil {
+		if n, err = o.loadFrames(r, t); err != nil {
+			return false, err
+		}
+	}
+
+	m, _ := r.Load(size - n)
+	if n += m; n == size {
+		o.indexPictures(r)
+		return false, nil
+	}
+	if r.err != io.ErrUnexpectedEOF {
+		return false, r.err
+	}
+	if !o.Lenient {
+		return false, &TruncatedTagError{Declared: size, Available: n}
+	}
+
+	r.err = nil
+	o.indexPictures(r)
+	o.repair("", fmt.Sprintf("decoded %d of %d bytes of truncated tag", n, size))
+	return true, nil
+}
+
+// loadFrames loads the tag's frames into the reader's buffer one at a time,
+// skipping over the image data of attached pictures at least
+// LazyPictureSize bytes long instead of reading it. The size of each
+// skipped picture's frame is reduced in the buffer to exclude its image
+// data. Loading stops at the first frame that can't be handled this way,
+// and the number of bytes of the tag consumed from the source is returned.
+func (o *DecodeOptions) loadFrames(r *reader, t *Tag) (int, error) {
+	if (t.Flags&(TagFlagUnsync|TagFlagExtended)) != 0 || o.CaptureRaw {
+		return 0, nil
+	}
+
+	idLen, hdrLen, picID := 4, 10, "APIC"
+	if t.Version == Version2_2 {
+		idLen, hdrLen, picID = 3, 6, "PIC"
+	}
+
+	var n int
+	for t.Size-n >= hdrLen {
+		got, _ := r.Load(hdrLen)
+		if n += got; r.err != nil {
+			return n, nil
+		}
+
+		hdAt := len(r.buf) - hdrLen
+		hd := r.buf[hdAt:]
+		size, ok := frameSizeOf(t.Version, hd)
+		if !ok || hd[0] == 0 || size > t.Size-n {
+			return n, nil
+		}
+
+		// Load frames other than large, unflagged pictures in full.
+		if string(hd[:idLen]) != picID || size < o.LazyPictureSize || (hdrLen == 10 && hd[9] != 0) {
+			got, _ := r.Load(size)
+			if n += got; r.err != nil {
+				return n, nil
+			}
+			continue
+		}
+
+		// Load the picture's fields up to the start of its image data.
+		start := len(r.buf)
+		prefix, loaded := -1, 0
+		for prefix < 0 && loaded < size {
+			chunk := size - loaded
+			if chunk > 256 {
+				chunk = 256
+			}
+			got, _ := r.Load(chunk)
+			loaded += got
+			if n += got; r.err != nil {
+				return n, nil
+			}
+			prefix = pictureDataOffset(t.Version, r.buf[start:])
+		}
+
+		dataLen := size - prefix
+		if prefix < 0 || dataLen < o.LazyPictureSize {
+			got, _ := r.Load(size - loaded)
+			if n += got; r.err != nil {
+				return n, nil
+			}
+			continue
+		}
+
+		// Skip the image data, and discard any of it that was loaded with
+		// the picture's fields.
+		pos, err := o.seeker.Seek(int64(size-loaded), io.SeekCurrent)
+		if err != nil {
+			return n, err
+		}
+		r.n += size - loaded
+		n += size - loaded
+		r.buf = r.buf[:start+prefix]
+		setFrameSize(t.Version, r.buf[hdAt:], prefix)
+
+		if o.pictures == nil {
+			o.pictures = make(map[int]*io.SectionReader)
+		}
+		o.pictures[len(r.buf)] = io.NewSectionReader(o.source, pos-int64(dataLen), int64(dataLen))
+	}
+	return n, nil
+}
+
+// indexPictures rekeys the skipped pictures recorded by loadFrames, which
+// are keyed by the buffer offset of the end of their frames, by the number
+// of bytes that remain in the fully loaded buffer after their frames.
+func (o *DecodeOptions) indexPictures(r *reader) {
+	if o.pictures == nil {
+		return
+	}
+	pictures := make(map[int]*io.SectionReader, len(o.pictures))
+	for end, s := range o.pictures {
+		pictures[len(r.buf)-end] = s
+	}
+	o.pictures = pictures
+}
+
+// frameSizeOf returns the payload size stored in a frame header.
+func frameSizeOf(v Version, hd []byte) (int, bool) {
+	switch v {
+	case Version2_2:
+		return int(hd[3])<<16 | int(hd[4])<<8 | int(hd[5]), true
+	case Version2_3:
+		return int(binary.BigEndian.Uint32(hd[4:8])), true
+	default:
+		size, err := decodeSyncSafeUint32(hd[4:8])
+		return int(size), err == nil
+	}
+}
+
+// setFrameSize stores a payload size in a frame header.
+func setFrameSize(v Version, hd []byte, size int) {
+	switch v {
+	case Version2_2:
+		hd[3], hd[4], hd[5] = byte(size>>16), byte(size>>8), byte(size)
+	case Version2_3:
+		binary.BigEndian.PutUint32(hd[4:8], uint32(size))
+	default:
+		encodeSyncSafeUint32(hd[4:8], uint32(size))
+	}
+}
+
+// pictureDataOffset returns the offset of the image data within the
+// payload of an attached picture frame, or -1 if b doesn't hold all of the
+// fields preceding it.
+func pictureDataOffset(v Version, b []byte) int {
+	if len(b) < 1 {
+		return -1
+	}
+	enc, i := b[0], 1
+
+	// Skip the image format or MIME type, and the picture type.
+	if v == Version2_2 {
+		i += 3
+	} else {
+		j := bytes.IndexByte(b[i:], 0)
+		if j < 0 {
+			return -1
+		}
+		i += j + 1
+	}
+	i++
+
+	// Skip the description and its terminator.
+	if enc == 1 || enc == 2 {
+		for ; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return i + 2
+			}
+		}
+		return -1
+	}
+	for ; i < len(b); i++ {
+		if b[i] == 0 {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// warn records a warning in the options' decode report, if there is one.
+func (o *DecodeOptions) warn(frameID string, err error) {
+	if o.Report != nil {
+		o.Report.Warnings = append(o.Report.Warnings, DecodeWarning{frameID, err})
+	}
+}
+
+// fail records a frame decoding failure in the options' decode report, if
+// there is one. The frame header starts at the beginning of b.
+func (o *DecodeOptions) fail(offset int, b []byte, idLen int, err error) {
+	if o.Report == nil {
+		return
+	}
+	if len(b) > idLen {
+		b = b[:idLen]
+	}
+	o.Report.Failure = &DecodeFailure{offset, string(b), err}
+}
+
+// unknown records a frame of an unknown type in the options' decode report
+// and calls the unknown frame hook.
+func (o *DecodeOptions) unknown(frameID string) {
+	if o.Report != nil {
+		if o.Report.UnknownFrames == nil {
+			o.Report.UnknownFrames = make(map[string]int)
+		}
+		o.Report.UnknownFrames[frameID]++
+	}
+	if o.UnknownFrame != nil {
+		o.UnknownFrame(frameID)
+	}
+}
+
+// decompressionLimit returns the maximum decompressed size of a frame
+// whose compressed payload is n bytes long, or -1 if there is no limit.
+func (o *DecodeOptions) decompressionLimit(n int) int {
+	limit := o.MaxDecompressedSize
+	switch {
+	case limit == 0:
+		limit = DefaultMaxDecompressedSize
+	case limit < 0:
+		limit = -1
+	}
+	if o.MaxCompressionRatio > 0 {
+		if r := n * o.MaxCompressionRatio; limit < 0 || r < limit {
+			limit = r
+		}
+	}
+	return limit
+}
+
+// capture retains a copy of a decoded frame's bytes, if requested.
+func (o *DecodeOptions) capture(f Frame, v Version, b []byte) {
+	if o.CaptureRaw {
+		HeaderOf(f).raw = &rawFrame{version: v, data: append([]byte{}, b...)}
+	}
+}
+
+// deferPicture replaces the image data of a decoded attached picture frame
+// with a reference to its location within the source, if the image data
+// was skipped while the tag was loaded. The rest value holds the number of
+// bytes remaining in the reader's buffer after the frame.
+func (o *DecodeOptions) deferPicture(f Frame, rest int) {
+	p, ok := f.(*FrameAttachedPicture)
+	if !ok {
+		return
+	}
+	if s, ok := o.pictures[rest]; ok {
+		p.source, p.Data = s, nil
+	}
+}
+
+// EncodeOptions control optional behaviors of the tag encoder.
+type EncodeOptions struct {
+	// SkipInvalidFrames causes frames that fail to encode to be omitted
+	// from the output instead of aborting the write at the first failure.
+	// The rest of the tag is still written, and the failures are returned
+	// as a FrameErrors value.
+	SkipInvalidFrames bool
+
+	// StrictNotices causes copyright message (TCOP) and produced notice
+	// (TPRO) frames that don't begin with a four-digit year and a space to
+	// fail to encode with ErrInvalidNotice. Malformed notices are common in
+	// existing tags, so they are written as is by default.
+	StrictNotices bool
+
+	// PaddingFill selects how the padding region is written when a tag is
+	// overwritten in place with Tag.Overwrite.
+	PaddingFill PaddingFill
+
+	// Padding, if non-nil, determines the amount of padding written after
+	// the tag's frames, replacing the tag's Padding field, which is updated
+	// with the padding written. See PaddingPolicy. Padding is ignored by
+	// Tag.Overwrite, which always preserves the previous tag's size.
+	Padding PaddingPolicy
+
+	// Alignment, if positive, causes padding to be added to the tag so that
+	// its encoded size, and therefore the offset of the audio data that
+	// follows it, is a multiple of Alignment bytes. The tag's Padding field,
+	// or the padding chosen by the padding policy, is treated as the
+	// minimum amount of padding, and the Padding field is updated with the
+	// padding written. The aligned size is the byte count returned by
+	// Tag.WriteToWithOptions. Tags with a footer can't contain padding, so
+	// aligning them fails with ErrPaddingNotAllowed. Alignment is ignored
+	// by Tag.Overwrite, which always preserves the previous tag's size.
+	Alignment int
+
+	// MaxTextFrameSize, if positive, limits the size in bytes of the
+	// encoded value of user-defined text (TXXX) frames. Longer values are
+	// split across multiple continuation frames, which are reassembled
+	// transparently when the tag is read. See SplitUserText for a
+	// description of the convention.
+	MaxTextFrameSize int
+
+	// DefaultLanguage is the ISO-639-2 language code given to frames added
+	// by high-level setters such as Tag.SetComment, unless their language
+	// was set after they were added. If it is empty, LanguageUnknown is
+	// used. Other frames are unchanged.
+	DefaultLanguage string
+
+	// DefaultEncoding is the text encoding given to frames added by
+	// high-level setters such as Tag.SetComment, unless their encoding was
+	// set after they were added. If the encoding isn't supported by the
+	// tag's version or, for the zero value EncodingISO88591, can't
+	// represent the frame's text, the frame is encoded in UTF-8 (v2.4) or
+	// UTF-16 with byte order mark (earlier versions) instead. Frames are
+	// given their defaults when the tag is first written.
+	DefaultEncoding Encoding
+
+	// Verify causes the encoded tag to be decoded again and compared with
+	// the tag before it is written. If they differ, nothing is written and
+	// a VerifyError describing the differences is returned. Frames skipped
+	// because of SkipInvalidFrames are excluded from the comparison.
+	Verify bool
+
+	// PreserveExtended causes the unrecognized extended header bytes held
+	// by Tag.ExtendedUnknown to be written at the end of the extended
+	// header, which is added to the tag if necessary. It is ignored for
+	// v2.2 tags, which have no extended header.
+	PreserveExtended bool
+
+	// Stamp, if non-nil, records the software writing the tag in the
+	// encoded tag's frames. Stamping is disabled by default.
+	Stamp *Stamp
+
+	// OmitFrames lists patterns of frames that are silently left out of
+	// the encoded tag; the tag itself is unchanged. A pattern matches the
+	// frame ID used by the tag's version, using the wildcard syntax of
+	// path.Match (e.g., "W*" or "T???"). A pattern may be followed by a
+	// colon and a second pattern, matched without regard to case against
+	// the frame's description, descriptor or owner field (e.g.,
+	// "TXXX:serato*"). Frames lacking such a field never match a pattern
+	// with a colon.
+	OmitFrames []string
+
+	// CanonicalOrder causes the tag's frames to be sorted with
+	// Tag.SortFrames before the tag is encoded, producing output that is
+	// friendlier to players and stable across edits. The tag's frames are
+	// reordered. Tags should be sorted before they are signed, since
+	// reordering their frames invalidates their signatures.
+	CanonicalOrder bool
+
+	unrestricted bool // don't enforce the tag's restrictions
+}
+
+// A Stamp describes the software writing a tag. When encoding with a
+// stamp, the tag's encoding software (TSSE) frame is set to the
+// application string followed by this package's import path and version,
+// and, if EncodedBy is not empty, its encoded-by (TENC) frame is set to
+// EncodedBy. Only the encoded tag is stamped; the tag and its frames are
+// unchanged.
+type Stamp struct {
+	Application string // application name and version, e.g. "MyTagger 2.1"
+	EncodedBy   string // optional person or organization encoding the file
+}
+
+// String returns the text stored in the encoding software frame. The
+// package's version is omitted if LibraryVersion doesn't know it.
+func (s *Stamp) String() string {
+	lib := modulePath
+	if v := LibraryVersion(); v != "" {
+		lib += " " + v
+	}
+	if s.Application == "" {
+		return lib
+	}
+	return s.Application + " (" + lib + ")"
+}
+
+// stamp returns the tag's frames with the options' stamp applied. Stamped
+// frames are copies, so the tag's own frames are unchanged.
+func (o *EncodeOptions) stamp(t *Tag) []Frame {
+	ff := append([]Frame{}, t.Frames...)
+	ff = stampText(ff, t.Version, FrameTypeTextEncodingSoftware, o.Stamp.String())
+	if o.Stamp.EncodedBy != "" {
+		ff = stampText(ff, t.Version, FrameTypeTextEncodedBy, o.Stamp.EncodedBy)
+	}
+	return ff
+}
+
+// stampText replaces the first text frame of the requested type with a
+// copy holding the text, or appends a new frame if there is none.
+func stampText(ff []Frame, v Version, typ FrameType, text string) []Frame {
+	f := &FrameText{Header: FrameHeader{FrameType: typ}}
+	i := 0
+	for ; i < len(ff); i++ {
+		if old, ok := ff[i].(*FrameText); ok && old.Header.FrameType == typ {
+			*f = *old
+			f.Header.raw = nil
+			break
+		}
+	}
+	f.Text = []string{text}
+	fitEncoding(&f.Encoding, v, text)
+	if i < len(ff) {
+		ff[i] = f
+		return ff
+	}
+	return append(ff, f)
+}
+
+// PaddingFill describes how the padding region of a tag is written when the
+// tag is overwritten in place.
+type PaddingFill uint8
+
+// Possible PaddingFill values.
+const (
+	// PaddingFillZero writes zeros over the entire padding region, as
+	// required by the ID3 specification.
+	PaddingFillZero PaddingFill = iota
+
+	// PaddingFillPreserve leaves untouched the bytes that were already
+	// padding in the overwritten tag, writing zeros only over the bytes
+	// that previously held tag data. This minimizes the number of bytes
+	// written without exposing stale tag data in the padding region.
+	PaddingFillPreserve
+)
+
+// encodeFrames encodes each of the tag's frames using the codec's frame
+// encoder. If the options allow it, frames that fail to encode are removed
+// from the output and returned as FrameErrors.
+func (o *EncodeOptions) encodeFrames(t *Tag, w *writer, types *frameTypeMap,
+	encode func(t *Tag, f Frame, w *writer) error) (FrameErrors, error) {
+	return o.encodeFramesTo(t, w, types, encode, nil)
+}
+
+// encodeFramesTo encodes the tag's frames like encodeFrames. If flush is
+// not nil, the encoded bytes of each frame are removed from the writer and
+// passed to it, so that only one frame is held in memory at a time.
+func (o *EncodeOptions) encodeFramesTo(t *Tag, w *writer, types *frameTypeMap,
+	encode func(t *Tag, f Frame, w *writer) error, flush func(b []byte)) (FrameErrors, error) {
+
+	var failed FrameErrors
+	for i, f := range t.Frames {
+		if o.omitsFrame(types, f) {
+			continue
+		}
+
+		offset := w.Len()
+		var err error
+		if o.StrictNotices {
+			err = checkNotice(f)
+		}
+		if err == nil {
+			for _, p := range o.expandFrame(t, f) {
+				if err = encode(t, p, w); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			if !o.SkipInvalidFrames {
+				return nil, err
+			}
+			w.ConsumeBytesFromOffset(offset)
+			w.err = nil
+
+			id := types.LookupFrameID(HeaderOf(f).FrameType)
+			failed = append(failed, FrameError{Index: i, FrameID: id, Err: err})
+			continue
+		}
+		if flush != nil {
+			flush(w.ConsumeBytesFromOffset(offset))
+		}
+	}
+	return failed, nil
+}
+
+// expandFrame returns the frames encoded in place of a frame. A frame may
+// be split into several frames to meet the requirements of the tag's version
+// or of the options.
+func (o *EncodeOptions) expandFrame(t *Tag, f Frame) []Frame {
+	if ff := splitDate(t, f); ff != nil {
+		return ff
+	}
+	return splitUserText(f, o.MaxTextFrameSize)
+}
+
+// omitsFrame returns true if the frame matches one of the OmitFrames
+// patterns.
+func (o *EncodeOptions) omitsFrame(types *frameTypeMap, f Frame) bool {
+	if len(o.OmitFrames) == 0 {
+		return false
+	}
+
+	id := frameIDOf(types, f)
+	for _, p := range o.OmitFrames {
+		idPattern, descPattern := p, ""
+		if i := strings.IndexByte(p, ':'); i >= 0 {
+			idPattern, descPattern = p[:i], p[i+1:]
+		}
+		if ok, _ := path.Match(strings.ToUpper(idPattern), id); !ok {
+			continue
+		}
+		if descPattern == "" && idPattern == p {
+			return true
+		}
+		desc, ok := frameDescription(f)
+		if !ok {
+			continue
+		}
+		if ok, _ := path.Match(strings.ToLower(descPattern), strings.ToLower(desc)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// frameDescription returns the value of the frame's field distinguishing it
+// from other frames of the same type: its description, descriptor or owner.
+func frameDescription(f Frame) (string, bool) {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return "", false
+	}
+	for _, name := range []string{"Description", "Descriptor", "Owner"} {
+		if fv := v.Elem().FieldByName(name); fv.IsValid() && fv.Kind() == reflect.String {
+			return fv.String(), true
+		}
+	}
+	return "", false
+}
+
+// extendedUnknown returns the unrecognized extended header bytes to write
+// for the tag.
+func (o *EncodeOptions) extendedUnknown(t *Tag) []byte {
+	if !o.PreserveExtended {
+		return nil
+	}
+	return t.ExtendedUnknown
+}
@@ -0,0 +1,79 @@
+package id3
+
+import "sort"
+
+// leadingFrameTypes lists, in order, the frame types placed first by
+// SortFrames. These are the frames most commonly used to identify a file,
+// which the ID3 specification recommends placing at the start of a tag.
+var leadingFrameTypes = []FrameType{
+	FrameTypeUniqueFileID,
+	FrameTypeTextSongTitle,
+	FrameTypeTextArtist,
+	FrameTypeTextAlbumArtist,
+	FrameTypeTextAlbumName,
+	FrameTypeTextTrackNumber,
+	FrameTypeTextPartOfSet,
+	FrameTypeTextRecordingTime,
+	FrameTypeTextGenre,
+}
+
+// trailingFrameTypes lists the frame types of large binary frames, which
+// are placed last by SortFrames.
+var trailingFrameTypes = map[FrameType]bool{
+	FrameTypeAttachedPicture: true,
+	FrameTypeGeneralObject:   true,
+}
+
+// SortFrames sorts the tag's frames into a canonical order, so that tags
+// holding the same frames are encoded identically. The frames used to
+// identify a file come first, in this order: unique file identifier
+// (UFID), title (TIT2), artist (TPE1), album artist (TPE2), album (TALB),
+// track number (TRCK), part of set (TPOS), recording time (TDRC) and genre
+// (TCON). They are followed by the other text frames, then by all other
+// frames except pictures (APIC) and general encapsulated objects (GEOB),
+// which come last so that players can read the rest of the tag before the
+// large binary frames. Apart from the leading frames, frames are sorted by
+// frame ID, and frames with the same ID keep their relative order.
+func (t *Tag) SortFrames() {
+	vdata, err := versionDataOf(t.Version)
+	if err != nil {
+		return
+	}
+
+	type key struct {
+		group int
+		rank  int
+		id    string
+	}
+	keys := make(map[Frame]key, len(t.Frames))
+	for _, f := range t.Frames {
+		typ := HeaderOf(f).FrameType
+		id := frameIDOf(vdata.frameTypes, f)
+		k := key{group: 2, id: id}
+		switch {
+		case trailingFrameTypes[typ]:
+			k.group = 3
+		case len(id) > 0 && id[0] == 'T':
+			k.group = 1
+		}
+		for i, lt := range leadingFrameTypes {
+			if typ == lt {
+				k = key{group: 0, rank: i}
+				break
+			}
+		}
+		keys[f] = k
+	}
+
+	sort.SliceStable(t.Frames, func(i, j int) bool {
+		a, b := keys[t.Frames[i]], keys[t.Frames[j]]
+		switch {
+		case a.group != b.group:
+			return a.group < b.group
+		case a.rank != b.rank:
+			return a.rank < b.rank
+		default:
+			return a.id < b.id
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package id3
+
+// A PaddingPolicy determines how much padding is written after a tag's
+// frames when the tag is encoded with EncodeOptions.Padding. Padding leaves
+// room for the tag to grow, so that later edits can be saved in place
+// without rewriting the audio data that follows the tag.
+type PaddingPolicy interface {
+	// Padding returns the number of padding bytes to write after a tag
+	// whose encoded size without padding is size bytes. Positive values
+	// smaller than the size of a frame ID are rounded up to it.
+	Padding(size int) int
+}
+
+// PaddingFunc is an adapter allowing an ordinary function to be used as a
+// padding policy.
+type PaddingFunc func(size int) int
+
+// Padding returns f(size).
+func (f PaddingFunc) Padding(size int) int {
+	return f(size)
+}
+
+// PaddingNone is a padding policy writing no padding.
+var PaddingNone PaddingPolicy = PaddingFunc(func(int) int { return 0 })
+
+// PaddingFixed returns a padding policy writing n bytes of padding.
+func PaddingFixed(n int) PaddingPolicy {
+	return PaddingFunc(func(int) int { return n })
+}
+
+// PaddingToSize returns a padding policy padding tags so that they occupy
+// n bytes. Tags already occupying n bytes or more get no padding.
+func PaddingToSize(n int) PaddingPolicy {
+	return PaddingFunc(func(size int) int {
+		if size >= n {
+			return 0
+		}
+		return n - size
+	})
+}
+
+// PaddingPercent returns a padding policy writing padding amounting to
+// percent percent of the tag's size without padding, rounded up.
+func PaddingPercent(percent int) PaddingPolicy {
+	return PaddingFunc(func(size int) int {
+		return (size*percent + 99) / 100
+	})
+}
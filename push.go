@@ -0,0 +1,121 @@
+package id3
+
+import (
+	"bytes"
+	"io"
+)
+
+// A PushDecoder decodes an ID3 tag from byte chunks pushed into it as they
+// arrive, for callers that can't provide an io.Reader. Write chunks to the
+// decoder until Done reports true, then retrieve the tag with Tag.
+type PushDecoder struct {
+	opts *DecodeOptions
+	buf  []byte
+	size int // total size of the tag in bytes, or 0 if not yet known
+	tag  *Tag
+	err  error
+	done bool
+}
+
+// NewPushDecoder creates a push decoder using the default decoding options.
+func NewPushDecoder() *PushDecoder {
+	return NewPushDecoderWithOptions(nil)
+}
+
+// NewPushDecoderWithOptions creates a push decoder using the requested
+// decoding options. A nil opts selects the default options.
+func NewPushDecoderWithOptions(opts *DecodeOptions) *PushDecoder {
+	return &PushDecoder{opts: opts}
+}
+
+// Write pushes a chunk of bytes into the decoder. Only the bytes belonging
+// to the tag are consumed. If the chunk extends beyond the end of the tag,
+// Write returns the number of bytes consumed and ErrTagComplete, and the
+// unconsumed remainder of the chunk belongs to whatever follows the tag in
+// the stream. Once the tag is complete, or if the tag fails to decode,
+// Write consumes nothing further.
+func (d *PushDecoder) Write(p []byte) (int, error) {
+	if d.done {
+		if d.err != nil {
+			return 0, d.err
+		}
+		return 0, ErrTagComplete
+	}
+
+	// Accumulate the tag header to discover the tag's size.
+	n := 0
+	if d.size == 0 {
+		n = 10 - len(d.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+		d.buf = append(d.buf, p[:n]...)
+		if len(d.buf) < 10 {
+			return n, nil
+		}
+
+		_, size, err := PeekTag(d.buf)
+		if err != nil {
+			d.done, d.err = true, err
+			return n, err
+		}
+		d.size = size
+	}
+
+	// Accumulate the rest of the tag.
+	m := d.size - len(d.buf)
+	if m > len(p)-n {
+		m = len(p) - n
+	}
+	d.buf = append(d.buf, p[n:n+m]...)
+	n += m
+	if len(d.buf) < d.size {
+		return n, nil
+	}
+
+	// Decode the completed tag.
+	d.done = true
+	t := &Tag{}
+	if _, err := t.ReadFromWithOptions(bytes.NewReader(d.buf), d.opts); err != nil {
+		d.err = err
+		return n, err
+	}
+	d.tag = t
+
+	if n < len(p) {
+		return n, ErrTagComplete
+	}
+	return n, nil
+}
+
+// Done returns true once the decoder has received the entire tag or has
+// failed to decode it.
+func (d *PushDecoder) Done() bool {
+	return d.done
+}
+
+// Needed returns the number of additional bytes the decoder requires to
+// complete the tag. Until the tag header has been received, the count
+// covers only the remainder of the header.
+func (d *PushDecoder) Needed() int {
+	if d.done {
+		return 0
+	}
+	if d.size == 0 {
+		return 10 - len(d.buf)
+	}
+	return d.size - len(d.buf)
+}
+
+// Tag returns the decoded tag. It returns io.ErrUnexpectedEOF if the tag is
+// not yet complete, or the error encountered while decoding the tag.
+func (d *PushDecoder) Tag() (*Tag, error) {
+	switch {
+	case !d.done:
+		return nil, io.ErrUnexpectedEOF
+	case d.err != nil:
+		return nil, d.err
+	default:
+		return d.tag, nil
+	}
+}
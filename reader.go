@@ -1,14 +1,21 @@
 package id3
 
 import (
+	"context"
 	"io"
 	"strings"
 )
 
+// loadChunkSize is the maximum number of bytes pulled from the input stream
+// by a single read. Loading in chunks keeps the buffer proportional to the
+// data actually received when a tag declares a size larger than the stream.
+const loadChunkSize = 32 * 1024
+
 // A reader represents a buffer that may be consumed by the caller. The
 // buffer is populated from an input stream.
 type reader struct {
 	r   io.Reader
+	ctx context.Context // cancels loads from the stream, if non-nil
 	buf []byte
 	n   int
 	err error
@@ -28,21 +35,67 @@ func (r *reader) Len() int {
 	return len(r.buf)
 }
 
-// LoadFrom pulls exactly n bytes from a stream into the reader's buffer.
+// Load pulls exactly n bytes from a stream into the reader's buffer. The
+// bytes are read in chunks of at most loadChunkSize bytes, and the reader's
+// context, if any, is checked before each chunk.
 func (r *reader) Load(n int) (int, error) {
-	l := len(r.buf)
-	r.buf = append(r.buf, make([]byte, n)...)
-
 	var nn int
-	nn, r.err = io.ReadFull(r.r, r.buf[l:])
-	r.n += nn
+	r.err = nil
+	for nn < n && r.err == nil {
+		chunk := n - nn
+		if chunk > loadChunkSize {
+			chunk = loadChunkSize
+		}
+
+		l := len(r.buf)
+		r.buf = append(r.buf, make([]byte, chunk)...)
+
+		var cn int
+		cn, r.err = r.read(r.buf[l:])
+		r.buf = r.buf[:l+cn]
+		r.n += cn
+		nn += cn
+	}
 
-	if nn < n {
+	if nn < n && (r.err == nil || r.err == io.EOF) {
 		r.err = io.ErrUnexpectedEOF
 	}
 	return nn, r.err
 }
 
+// read fills p from the input stream. If the reader has a context, a read
+// blocked on the stream is abandoned when the context is done. The
+// abandoned read completes in the background into a separate buffer, so the
+// stream must not be used once the context is done.
+func (r *reader) read(p []byte) (int, error) {
+	if r.ctx == nil || r.ctx.Done() == nil {
+		return io.ReadFull(r.r, p)
+	}
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	type result struct {
+		b   []byte
+		n   int
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		b := make([]byte, len(p))
+		n, err := io.ReadFull(r.r, b)
+		ch <- result{b, n, err}
+	}()
+
+	select {
+	case res := <-ch:
+		copy(p, res.b[:res.n])
+		return res.n, res.err
+	case <-r.ctx.Done():
+		return 0, r.ctx.Err()
+	}
+}
+
 // ReplaceBuffer replaces the contents of the reader's buffer with the
 // provided byte slice.
 func (r *reader) ReplaceBuffer(p []byte) {
@@ -2,7 +2,9 @@ package id3
 
 import (
 	"fmt"
+	"io/ioutil"
 	"reflect"
+	"strings"
 )
 
 // A reflector uses reflection to scan or output the contents of frame
@@ -25,6 +27,7 @@ type property struct {
 	typ   reflect.Type
 	value reflect.Value
 	name  string
+	tag   reflect.StructTag
 }
 
 // The state structure keeps track of persistent state required while
@@ -68,6 +71,16 @@ func (rf *reflector) SetFrameHeader(f Frame, h *FrameHeader) {
 // OutputFrame uses reflection to output the contents of an ID3 frame to
 // a writer buffer.
 func (rf *reflector) OutputFrame(w *writer, f Frame) (frameID string, err error) {
+	// Load the image data of lazily decoded pictures into a temporary copy
+	// of the frame.
+	if p, ok := f.(*FrameAttachedPicture); ok && p.IsLazy() {
+		cp := *p
+		if cp.Data, err = ioutil.ReadAll(p.Open()); err != nil {
+			return "", err
+		}
+		f = &cp
+	}
+
 	frameType := HeaderOf(f).FrameType
 	frameID = rf.vdata.frameTypes.LookupFrameID(frameType)
 
@@ -84,7 +97,8 @@ func (rf *reflector) OutputFrame(w *writer, f Frame) (frameID string, err error)
 		return "", w.err
 	}
 
-	return frameID, nil
+	// Unknown frames supply their own frame ID.
+	return state.frameID, nil
 }
 
 func (rf *reflector) scanStruct(r *reader, p property, state *state) {
@@ -103,11 +117,15 @@ func (rf *reflector) scanStruct(r *reader, p property, state *state) {
 		}
 
 		field := p.typ.Field(ii)
+		if field.PkgPath != "" {
+			continue // skip unexported fields
+		}
 
 		fp := property{
 			typ:   field.Type,
 			value: p.value.Elem().Field(ii),
 			name:  field.Name,
+			tag:   field.Tag,
 		}
 
 		switch field.Type.Kind() {
@@ -120,8 +138,8 @@ func (rf *reflector) scanStruct(r *reader, p property, state *state) {
 		case reflect.Uint32:
 			rf.scanUint32(r, fp, state)
 
-		case reflect.Uint64:
-			rf.scanUint64(r, fp, state)
+		case reflect.Int16:
+			rf.scanInt16(r, fp, state)
 
 		case reflect.Slice:
 			switch field.Type.Elem().Kind() {
@@ -133,6 +151,8 @@ func (rf *reflector) scanStruct(r *reader, p property, state *state) {
 				rf.scanStringSlice(r, fp, state)
 			case reflect.Struct:
 				rf.scanStructSlice(r, fp, state)
+			case reflect.Interface:
+				// Embedded subframes are decoded by the codec.
 			default:
 				panic(errUnknownFieldType)
 			}
@@ -210,25 +230,17 @@ func (rf *reflector) scanUint32(r *reader, p property, state *state) {
 	p.value.SetUint(value)
 }
 
-func (rf *reflector) scanUint64(r *reader, p property, state *state) {
+func (rf *reflector) scanInt16(r *reader, p property, state *state) {
 	if r.err != nil {
 		return
 	}
 
-	var b []byte
-	switch p.name {
-	case "Counter":
-		b = r.ConsumeAll()
-	default:
-		panic(errUnknownFieldType)
-	}
-
-	var value uint64
-	for _, bb := range b {
-		value = (value << 8) | uint64(bb)
+	b := r.ConsumeBytes(2)
+	if r.err != nil {
+		return
 	}
 
-	p.value.SetUint(value)
+	p.value.SetInt(int64(int16(uint16(b[0])<<8 | uint16(b[1]))))
 }
 
 func (rf *reflector) scanByteSlice(r *reader, p property, state *state) {
@@ -236,7 +248,20 @@ func (rf *reflector) scanByteSlice(r *reader, p property, state *state) {
 		return
 	}
 
-	b := r.ConsumeAll()
+	var b []byte
+	switch p.name {
+	case "Peak":
+		// A peak volume occupies the number of bytes needed to hold the
+		// number of bits given by the preceding field.
+		bits := int(state.structStack.top().FieldByName("PeakBits").Uint())
+		b = r.ConsumeBytes((bits + 7) / 8)
+		if r.err != nil {
+			return
+		}
+		b = append([]byte{}, b...)
+	default:
+		b = r.ConsumeAll()
+	}
 	p.value.Set(reflect.ValueOf(b))
 }
 
@@ -292,20 +317,50 @@ func (rf *reflector) scanStringSlice(r *reader, p property, state *state) {
 		return
 	}
 
-	sf := state.structStack.first()
-	enc := Encoding(sf.FieldByName("Encoding").Uint())
+	// A table of contents stores the number of child element IDs followed
+	// by the null-terminated IDs.
+	if p.name == "ChildElementIDs" {
+		n := int(r.ConsumeByte())
+		ss := make([]string, 0, n)
+		for i := 0; i < n && r.err == nil; i++ {
+			ss = append(ss, r.ConsumeNextString(EncodingISO88591))
+		}
+		if r.err != nil {
+			return
+		}
+		p.value.Set(reflect.ValueOf(ss))
+		return
+	}
+
+	enc, err := fieldEncoding(p, state)
+	if err != nil {
+		r.err = err
+		return
+	}
 	ss := r.ConsumeStrings(enc)
 	if r.err != nil {
 		return
 	}
 
-	if rf.version < Version2_4 && len(ss) > 1 {
+	if !rf.allowsStringList(state) && len(ss) > 1 {
 		ss = ss[:1]
 	}
 
 	p.value.Set(reflect.ValueOf(ss))
 }
 
+// allowsStringList returns true if the current frame may hold more than
+// one text string. Only v2.4 supports multiple strings in text frames, with
+// the exception of the v2.3 involved people list (IPLS), which stores a
+// sequence of role/name pairs.
+func (rf *reflector) allowsStringList(state *state) bool {
+	if rf.version >= Version2_4 {
+		return true
+	}
+	typ := rf.vdata.frameTypes.LookupFrameType(state.frameID)
+	return typ == FrameTypeTextInvolvedPeople
+}
+
 func (rf *reflector) scanStructSlice(r *reader, p property, state *state) {
 	if r.err != nil {
 		return
@@ -350,16 +405,21 @@ func (rf *reflector) scanString(r *reader, p property, state *state) {
 		return
 	}
 
-	var enc Encoding
-	switch p.typ.Name() {
-	case "WesternString":
-		enc = EncodingISO88591
-	default:
-		sf := state.structStack.first()
-		enc = Encoding(sf.FieldByName("Encoding").Uint())
+	enc, err := fieldEncoding(p, state)
+	if err != nil {
+		r.err = err
+		return
 	}
 
-	str := r.ConsumeNextString(enc)
+	// A v2.4 user-defined text frame may hold multiple null-separated
+	// values. Keep all of them.
+	var str string
+	if rf.version >= Version2_4 && p.name == "Text" &&
+		rf.vdata.frameTypes.LookupFrameType(state.frameID) == FrameTypeTextCustom {
+		str = strings.Join(r.ConsumeStrings(enc), "\x00")
+	} else {
+		str = r.ConsumeNextString(enc)
+	}
 
 	if r.err != nil {
 		return
@@ -384,11 +444,15 @@ func (rf *reflector) outputStruct(w *writer, p property, state *state) {
 		}
 
 		field := p.typ.Field(i)
+		if field.PkgPath != "" {
+			continue // skip unexported fields
+		}
 
 		fp := property{
 			typ:   field.Type,
 			value: p.value.Field(i),
 			name:  field.Name,
+			tag:   field.Tag,
 		}
 
 		switch field.Type.Kind() {
@@ -401,8 +465,8 @@ func (rf *reflector) outputStruct(w *writer, p property, state *state) {
 		case reflect.Uint32:
 			rf.outputUint32(w, fp, state)
 
-		case reflect.Uint64:
-			rf.outputUint64(w, fp, state)
+		case reflect.Int16:
+			rf.outputInt16(w, fp, state)
 
 		case reflect.Slice:
 			switch field.Type.Elem().Kind() {
@@ -414,6 +478,8 @@ func (rf *reflector) outputStruct(w *writer, p property, state *state) {
 				rf.outputStringSlice(w, fp, state)
 			case reflect.Struct:
 				rf.outputStructSlice(w, fp, state)
+			case reflect.Interface:
+				// Embedded subframes are encoded by the codec.
 			default:
 				panic(errUnknownFieldType)
 			}
@@ -477,40 +543,24 @@ func (rf *reflector) outputUint16(w *writer, p property, state *state) {
 	}
 }
 
-func (rf *reflector) outputUint32(w *writer, p property, state *state) {
+func (rf *reflector) outputInt16(w *writer, p property, state *state) {
 	if w.err != nil {
 		return
 	}
 
-	v := uint32(p.value.Uint())
-	b := []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
-
-	w.StoreBytes(b)
+	v := uint16(p.value.Int())
+	w.StoreBytes([]byte{byte(v >> 8), byte(v)})
 }
 
-func (rf *reflector) outputUint64(w *writer, p property, state *state) {
+func (rf *reflector) outputUint32(w *writer, p property, state *state) {
 	if w.err != nil {
 		return
 	}
 
-	v := p.value.Uint()
+	v := uint32(p.value.Uint())
+	b := []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
 
-	switch p.name {
-	case "Counter":
-		b := make([]byte, 0, 4)
-		for v != 0 {
-			b = append(b, byte(v&0xff))
-			v = v >> 8
-		}
-		for len(b) < 4 {
-			b = append(b, 0)
-		}
-		for i := len(b) - 1; i >= 0; i-- {
-			w.StoreByte(b[i])
-		}
-	default:
-		panic(errUnknownFieldType)
-	}
+	w.StoreBytes(b)
 }
 
 func (rf *reflector) outputUint32Slice(w *writer, p property, state *state) {
@@ -563,6 +613,21 @@ func (rf *reflector) outputByteSlice(w *writer, p property, state *state) {
 
 	var b []byte
 	reflect.ValueOf(&b).Elem().Set(p.value)
+
+	switch p.name {
+	case "CounterBytes":
+		// Counters must be at least 4 bytes long.
+		if len(b) < 4 {
+			w.StoreBytes(make([]byte, 4-len(b)))
+		}
+	case "Peak":
+		bits := int(state.structStack.top().FieldByName("PeakBits").Uint())
+		if len(b) != (bits+7)/8 {
+			w.err = ErrInvalidFrame
+			return
+		}
+	}
+
 	w.StoreBytes(b)
 }
 
@@ -571,13 +636,29 @@ func (rf *reflector) outputStringSlice(w *writer, p property, state *state) {
 		return
 	}
 
-	sf := state.structStack.first()
-	enc := Encoding(sf.FieldByName("Encoding").Uint())
+	if p.name == "ChildElementIDs" {
+		n := p.value.Len()
+		if n > 0xff {
+			w.err = ErrInvalidFrame
+			return
+		}
+		w.StoreByte(byte(n))
+		for i := 0; i < n; i++ {
+			w.StoreString(p.value.Index(i).String(), EncodingISO88591, true)
+		}
+		return
+	}
+
+	enc, err := fieldEncoding(p, state)
+	if err != nil {
+		w.err = err
+		return
+	}
 
 	var ss []string
 	reflect.ValueOf(&ss).Elem().Set(p.value)
 
-	if rf.version < Version2_4 && len(ss) > 1 {
+	if !rf.allowsStringList(state) && len(ss) > 1 {
 		ss = ss[:1]
 	}
 
@@ -624,13 +705,10 @@ func (rf *reflector) outputString(w *writer, p property, state *state) {
 		return
 	}
 
-	var enc Encoding
-	switch p.typ.Name() {
-	case "WesternString":
-		enc = EncodingISO88591
-	default:
-		sf := state.structStack.first()
-		enc = Encoding(sf.FieldByName("Encoding").Uint())
+	enc, err := fieldEncoding(p, state)
+	if err != nil {
+		w.err = err
+		return
 	}
 
 	// Always terminate strings unless they are the last struct field
@@ -638,3 +716,43 @@ func (rf *reflector) outputString(w *writer, p property, state *state) {
 	term := state.structStack.depth() > 1 || (state.fieldIndex != state.fieldCount-1)
 	w.StoreString(v, enc, term)
 }
+
+// fieldEncodings maps the values of the "encoding" struct tag option to the
+// text encodings they select. A string field of a frame struct may use the
+// option to select a fixed encoding, ignoring the frame's Encoding byte:
+//
+//	Name string `id3:"encoding=utf8always"`
+var fieldEncodings = map[string]Encoding{
+	"iso88591always": EncodingISO88591,
+	"utf16bomalways": EncodingUTF16BOM,
+	"utf16always":    EncodingUTF16,
+	"utf8always":     EncodingUTF8,
+}
+
+// fieldEncoding returns the text encoding of a string field. See the
+// documentation of Encoding and fieldEncodings for the rules. It returns
+// errUnknownFieldEncoding if the field's struct tag selects an unknown
+// encoding.
+func fieldEncoding(p property, state *state) (Encoding, error) {
+	for _, opt := range strings.Split(p.tag.Get("id3"), ",") {
+		if strings.HasPrefix(opt, "encoding=") {
+			enc, ok := fieldEncodings[strings.TrimPrefix(opt, "encoding=")]
+			if !ok {
+				return 0, errUnknownFieldEncoding
+			}
+			return enc, nil
+		}
+	}
+
+	typ := p.typ
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	if typ.Name() == "WesternString" {
+		return EncodingISO88591, nil
+	}
+	if f := state.structStack.first().FieldByName("Encoding"); f.IsValid() {
+		return Encoding(f.Uint()), nil
+	}
+	return EncodingISO88591, nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
 )
@@ -10,6 +11,8 @@ type conn struct {
 	input       *bufio.Scanner
 	output      *bufio.Writer
 	interactive bool
+	json        bool // emit JSON output
+	raw         bool // capture raw frame bytes when reading tags
 }
 
 func newConn(r io.Reader, w io.Writer) *conn {
@@ -38,6 +41,15 @@ func (c *conn) Println(args ...interface{}) {
 	c.Flush()
 }
 
+func (c *conn) PrintJSON(v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		c.Printf("{\"error\": %q}\n", err.Error())
+		return
+	}
+	c.Printf("%s\n", b)
+}
+
 func (c *conn) GetLine() (string, error) {
 	if c.input.Scan() {
 		return c.input.Text(), nil
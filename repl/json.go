@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+
+	"github.com/beevik/id3"
+)
+
+// JSON representations of the REPL's output.
+
+type tagSummaryJSON struct {
+	Version int                `json:"version"`
+	Size    int                `json:"size"`
+	CRC     *uint32            `json:"crc,omitempty"`
+	Padding int                `json:"padding"`
+	Frames  []frameSummaryJSON `json:"frames,omitempty"`
+}
+
+type frameSummaryJSON struct {
+	ID      string    `json:"id"`
+	Type    string    `json:"type"`
+	Size    int       `json:"size"`
+	Payload id3.Frame `json:"payload,omitempty"`
+}
+
+type fileStatusJSON struct {
+	Name      string `json:"name"`
+	BytesRead int    `json:"bytesRead"`
+}
+
+type statusJSON struct {
+	File  *fileStatusJSON   `json:"file"`
+	Tag   *tagSummaryJSON   `json:"tag"`
+	Frame *frameSummaryJSON `json:"frame"`
+}
+
+func tagSummary(t *id3.Tag) *tagSummaryJSON {
+	tj := &tagSummaryJSON{
+		Version: int(t.Version),
+		Size:    t.Size + 10,
+		Padding: t.Padding,
+		Frames:  []frameSummaryJSON{},
+	}
+	if (t.Flags & id3.TagFlagHasCRC) != 0 {
+		crc := t.CRC
+		tj.CRC = &crc
+	}
+	for _, f := range t.Frames {
+		fj := frameSummary(f)
+		fj.Payload = f
+		tj.Frames = append(tj.Frames, fj)
+	}
+	return tj
+}
+
+func frameSummary(f id3.Frame) frameSummaryJSON {
+	h := id3.HeaderOf(f)
+	return frameSummaryJSON{
+		ID:   h.FrameID,
+		Type: reflect.TypeOf(f).Elem().Name(),
+		Size: h.Size,
+	}
+}
+
+func statusSummary(s *state) statusJSON {
+	var st statusJSON
+	if s.activeFileReader != nil {
+		st.File = &fileStatusJSON{s.activeFilename, s.activeFileBytesRead}
+	}
+	if s.activeTag != nil {
+		tj := tagSummary(s.activeTag)
+		for i := range tj.Frames {
+			tj.Frames[i].Payload = nil
+		}
+		st.Tag = tj
+	}
+	if s.activeFrame != nil {
+		fj := frameSummary(s.activeFrame)
+		fj.Payload = s.activeFrame
+		st.Frame = &fj
+	}
+	return st
+}
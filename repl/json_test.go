@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/beevik/id3"
+)
+
+// decodedTag returns a tag holding a title, as decoded from its encoding.
+func decodedTag(t *testing.T, flags id3.TagFlags) *id3.Tag {
+	tag := id3.NewTag(id3.Version2_4, flags)
+	tag.SetTitle("Title")
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := tag.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+	tag2 := &id3.Tag{}
+	if _, err := tag2.ReadFrom(buf); err != nil {
+		t.Fatal(err)
+	}
+	return tag2
+}
+
+// printJSON returns the compacted JSON output printed for v.
+func printJSON(t *testing.T, v interface{}) string {
+	out := bytes.NewBuffer([]byte{})
+	c := newConn(strings.NewReader(""), out)
+	c.PrintJSON(v)
+
+	compact := bytes.NewBuffer([]byte{})
+	if err := json.Compact(compact, out.Bytes()); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out.String(), err)
+	}
+	return compact.String()
+}
+
+const titleFrameJSON = `"payload":{"Header":{"FrameType":1,"FrameID":"TIT2","Size":6,"Flags":0,` +
+	`"GroupID":0,"EncryptMethod":0,"DataLength":0},"Encoding":0,"Text":["Title"]}`
+
+func TestTagSummaryJSON(t *testing.T) {
+	tag := decodedTag(t, 0)
+	want := `{"version":4,"size":26,"padding":0,"frames":[` +
+		`{"id":"TIT2","type":"FrameText","size":6,` + titleFrameJSON + `}]}`
+	if got := printJSON(t, tagSummary(tag)); got != want {
+		t.Errorf("got %s, expected %s", got, want)
+	}
+
+	tag = decodedTag(t, id3.TagFlagHasCRC)
+	want = fmt.Sprintf(`"crc":%d,`, tag.CRC)
+	if got := printJSON(t, tagSummary(tag)); !strings.Contains(got, want) {
+		t.Errorf("got %s, expected it to contain %s", got, want)
+	}
+}
+
+func TestFrameListJSON(t *testing.T) {
+	tag := decodedTag(t, 0)
+	want := `{"id":"TIT2","type":"FrameText","size":6}`
+	if got := printJSON(t, frameSummary(tag.Frames[0])); got != want {
+		t.Errorf("got %s, expected %s", got, want)
+	}
+}
+
+func TestStatusJSON(t *testing.T) {
+	if got, want := printJSON(t, statusSummary(&state{})), `{"file":null,"tag":null,"frame":null}`; got != want {
+		t.Errorf("got %s, expected %s", got, want)
+	}
+
+	tag := decodedTag(t, 0)
+	s := &state{
+		activeFileReader:    bufio.NewReader(strings.NewReader("")),
+		activeFilename:      "file.mp3",
+		activeFileBytesRead: 26,
+		activeTag:           tag,
+		activeFrame:         tag.Frames[0],
+	}
+	want := `{"file":{"name":"file.mp3","bytesRead":26},"tag":{"version":4,"size":26,"padding":0,"frames":[` +
+		`{"id":"TIT2","type":"FrameText","size":6}]},` +
+		`"frame":{"id":"TIT2","type":"FrameText","size":6,` + titleFrameJSON + `}}`
+	if got := printJSON(t, statusSummary(s)); got != want {
+		t.Errorf("got %s, expected %s", got, want)
+	}
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
@@ -21,13 +21,18 @@ var cmds = newCommands([]command{
 	{name: "tag", description: "Run a tag command", commands: newCommands([]command{
 		{name: "read", description: "Read the next tag from the open file", handler: onTagRead},
 		{name: "print", description: "Display the active tag's contents", handler: onTagPrint},
-		{name: "dump", description: "Hex dump the active tag", handler: onTagDump},
+		{name: "dump", description: "Dump the raw bytes of the active tag's frames", handler: onTagDump},
 	})},
 	{name: "frame", description: "Find a frame with the given ID", commands: newCommands([]command{
 		{name: "activate", description: "Activate a frame with the given ID", handler: onFrameActivate},
 		{name: "list", description: "List all frames in the active tag", handler: onFrameList},
+		{name: "dump", description: "Dump the raw bytes of the active frame", handler: onFrameDump},
 		{name: "deactivate", description: "Deactivate the active frame", handler: onFrameDeactivate},
 	})},
+	{name: "set", description: "Change a setting", commands: newCommands([]command{
+		{name: "output", description: "Set the output format (text or json)", handler: onSetOutput},
+		{name: "raw", description: "Capture raw frame bytes for dumps (on or off)", handler: onSetRaw},
+	})},
 	{name: "status", description: "Display the current status", handler: onStatus},
 	{name: "exit", description: "", handler: onQuit},
 	{name: "quit", description: "Exit the application", handler: onQuit},
@@ -50,19 +55,21 @@ func (s *state) reset() {
 }
 
 func main() {
-	args := os.Args[1:]
+	jsonOutput := flag.Bool("json", false, "emit machine-readable JSON output")
+	flag.Parse()
+	args := flag.Args()
 
 	switch {
 	case len(args) == 0:
-		repl()
+		repl(*jsonOutput)
 	default:
 		for _, filename := range args {
-			exec(filename)
+			exec(filename, *jsonOutput)
 		}
 	}
 }
 
-func exec(filename string) error {
+func exec(filename string, jsonOutput bool) error {
 	file, err := os.Open(filename)
 	if err != nil {
 		fmt.Printf("Command file '%s' not found.\n", filename)
@@ -70,12 +77,14 @@ func exec(filename string) error {
 	}
 
 	c := newConn(file, os.Stdout)
+	c.json = jsonOutput
 	return runCommands(c)
 }
 
-func repl() error {
+func repl(jsonOutput bool) error {
 	c := newConn(os.Stdin, os.Stdout)
 	c.interactive = true
+	c.json = jsonOutput
 	return runCommands(c)
 }
 
@@ -93,7 +102,7 @@ func runCommands(c *conn) error {
 			break
 		}
 
-		if !c.interactive {
+		if !c.interactive && !c.json {
 			c.Printf("id3> %s\n", line)
 		}
 
@@ -119,7 +128,9 @@ func runCommands(c *conn) error {
 		if err != nil {
 			break
 		}
-		c.Printf("\n")
+		if !c.json {
+			c.Printf("\n")
+		}
 	}
 
 	return nil
@@ -187,7 +198,7 @@ func onTagRead(c *conn, s *state, args string) error {
 	}
 
 	t := &id3.Tag{}
-	n, err := t.ReadFrom(s.activeFileReader)
+	n, err := t.ReadFromWithOptions(s.activeFileReader, &id3.DecodeOptions{CaptureRaw: c.raw})
 	s.activeFileBytesRead += int(n)
 	if err == id3.ErrInvalidTag {
 		c.Println("ERROR: No valid tag discovered.")
@@ -210,6 +221,11 @@ func onTagPrint(c *conn, s *state, args string) error {
 		return nil
 	}
 
+	if c.json {
+		c.PrintJSON(tagSummary(s.activeTag))
+		return nil
+	}
+
 	outputTag(c, s.activeTag)
 
 	for _, f := range s.activeTag.Frames {
@@ -225,14 +241,11 @@ func onTagDump(c *conn, s *state, args string) error {
 		return nil
 	}
 
-	buf := bytes.NewBuffer([]byte{})
-	_, err := s.activeTag.WriteTo(buf)
-	if err != nil {
-		c.Printf("ERROR: %v\n", err)
-		return nil
+	for _, f := range s.activeTag.Frames {
+		if !dumpFrame(c, f) {
+			break
+		}
 	}
-
-	hexdump(c, buf.Bytes())
 	return nil
 }
 
@@ -249,15 +262,7 @@ func onFrameActivate(c *conn, s *state, args string) error {
 		return nil
 	}
 
-	var found id3.Frame
-	for _, f := range s.activeTag.Frames {
-		h := id3.HeaderOf(f)
-		if h.FrameID == frameID {
-			found = f
-			break
-		}
-	}
-
+	found := s.activeTag.FirstFrameByID(frameID)
 	if found == nil {
 		c.Printf("ERROR: Frame '%s' not found.\n", frameID)
 		return nil
@@ -275,6 +280,15 @@ func onFrameList(c *conn, s *state, args string) error {
 		return nil
 	}
 
+	if c.json {
+		list := []frameSummaryJSON{}
+		for _, f := range s.activeTag.Frames {
+			list = append(list, frameSummary(f))
+		}
+		c.PrintJSON(list)
+		return nil
+	}
+
 	for _, f := range s.activeTag.Frames {
 		h := id3.HeaderOf(f)
 		c.Printf("%s: %d bytes\n", h.FrameID, h.Size)
@@ -283,6 +297,32 @@ func onFrameList(c *conn, s *state, args string) error {
 	return nil
 }
 
+func onFrameDump(c *conn, s *state, args string) error {
+	if s.activeFrame == nil {
+		c.Println("ERROR: No active frame.")
+		return nil
+	}
+
+	dumpFrame(c, s.activeFrame)
+	return nil
+}
+
+// dumpFrame writes an annotated dump of a frame's raw bytes, returning
+// false if the bytes couldn't be dumped.
+func dumpFrame(c *conn, f id3.Frame) bool {
+	err := id3.DebugDump(f, c.output, 256)
+	c.Flush()
+	switch {
+	case err == id3.ErrNoRawData:
+		c.Println("ERROR: Raw frame bytes not captured. Use 'set raw on' and read the tag again.")
+		return false
+	case err != nil:
+		c.Printf("ERROR: %v\n", err)
+		return false
+	}
+	return true
+}
+
 func onFrameDeactivate(c *conn, s *state, args string) error {
 	if s.activeFrame == nil {
 		c.Println("ERROR: No active tag.")
@@ -298,6 +338,11 @@ func onFrameDeactivate(c *conn, s *state, args string) error {
 }
 
 func onStatus(c *conn, s *state, args string) error {
+	if c.json {
+		c.PrintJSON(statusSummary(s))
+		return nil
+	}
+
 	if s.activeFileReader == nil {
 		c.Println("No active file.")
 	} else {
@@ -335,6 +380,33 @@ func onStatus(c *conn, s *state, args string) error {
 	return nil
 }
 
+func onSetOutput(c *conn, s *state, args string) error {
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "json":
+		c.json = true
+	case "text":
+		c.json = false
+		c.Println("Output format set to text.")
+	default:
+		c.Println("ERROR: output format must be 'text' or 'json'.")
+	}
+	return nil
+}
+
+func onSetRaw(c *conn, s *state, args string) error {
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "on":
+		c.raw = true
+		c.Println("Raw frame capture enabled.")
+	case "off":
+		c.raw = false
+		c.Println("Raw frame capture disabled.")
+	default:
+		c.Println("ERROR: raw capture must be 'on' or 'off'.")
+	}
+	return nil
+}
+
 func onQuit(c *conn, s *state, args string) error {
 	return errors.New("quitting")
 }
@@ -384,31 +456,14 @@ func outputFrame(c *conn, ff id3.Frame) {
 			data = data[:32]
 		}
 		c.Printf(": %s %v (%d bytes)", f.Owner, data, len(f.Data))
+	case *id3.FrameMusicCDIdentifier:
+		if toc, err := f.ParseTOC(); err == nil {
+			c.Printf(": %d tracks, disc ID %s", len(toc.Tracks), toc.MusicBrainzDiscID())
+		}
 	case *id3.FramePlayCount:
-		c.Printf(": %d", f.Counter)
+		c.Printf(": %d", f.Counter())
 	case *id3.FramePopularimeter:
-		c.Printf(": %s (%d) %d", f.Email, f.Rating, f.Counter)
+		c.Printf(": %s (%d) %d", f.Email, f.Rating, f.Counter())
 	}
 	c.Printf("\n")
 }
-
-func hexdump(c *conn, b []byte) {
-	c.Printf("var b = []byte{\n")
-
-	for i := 0; i < len(b); i += 8 {
-		r := i + 8
-		if r > len(b) {
-			r = len(b)
-		}
-
-		c.Printf("\t")
-
-		var j int
-		for j = i; j < r-1; j++ {
-			c.Printf("0x%02x, ", b[j])
-		}
-		c.Printf("0x%02x,\n", b[j])
-	}
-
-	c.Printf("}\n")
-}
@@ -1,3 +1,4 @@
+set raw on
 file open file.mp3
 tag read
 tag print
@@ -0,0 +1,307 @@
+package id3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+// Restrictions interprets the ID3 v2.4 tag restrictions byte stored in
+// Tag.Restrictions, which advertises limits the tag's encoder promised to
+// respect. It is made of five independent restrictions, each selected by
+// one of the groups of constants below. When a v2.4 tag has the
+// TagFlagHasRestrictions flag, writing it fails with a RestrictionError,
+// and nothing is written, if the tag doesn't satisfy its restrictions.
+type Restrictions uint8
+
+// Tag size restrictions.
+const (
+	RestrictTagSize1MB   Restrictions = 0 << 6 // No more than 128 frames and 1 MB
+	RestrictTagSize128KB Restrictions = 1 << 6 // No more than 64 frames and 128 KB
+	RestrictTagSize40KB  Restrictions = 2 << 6 // No more than 32 frames and 40 KB
+	RestrictTagSize4KB   Restrictions = 3 << 6 // No more than 32 frames and 4 KB
+)
+
+// Text encoding restrictions.
+const (
+	RestrictTextEncoding Restrictions = 1 << 5 // Only ISO-8859-1 or UTF-8 text
+)
+
+// Text field size restrictions.
+const (
+	RestrictTextSize1024 Restrictions = 1 << 3 // No text longer than 1024 characters
+	RestrictTextSize128  Restrictions = 2 << 3 // No text longer than 128 characters
+	RestrictTextSize30   Restrictions = 3 << 3 // No text longer than 30 characters
+)
+
+// Image encoding restrictions.
+const (
+	RestrictImageEncoding Restrictions = 1 << 2 // Only PNG or JPEG images
+)
+
+// Image size restrictions.
+const (
+	RestrictImageSize256     Restrictions = 1 // No image larger than 256x256 pixels
+	RestrictImageSize64      Restrictions = 2 // No image larger than 64x64 pixels
+	RestrictImageSize64Exact Restrictions = 3 // All images exactly 64x64 pixels
+)
+
+// Masks selecting each of the restrictions.
+const (
+	restrictTagSizeMask   Restrictions = 3 << 6
+	restrictTextSizeMask  Restrictions = 3 << 3
+	restrictImageSizeMask Restrictions = 3
+)
+
+// tagSizeLimits holds the maximum number of frames and bytes allowed by each
+// tag size restriction.
+var tagSizeLimits = [4]struct{ frames, bytes int }{
+	{128, 1024 * 1024},
+	{64, 128 * 1024},
+	{32, 40 * 1024},
+	{32, 4 * 1024},
+}
+
+// textSizeLimits holds the maximum number of characters in a text frame
+// allowed by each text field size restriction. Zero means no limit.
+var textSizeLimits = [4]int{0, 1024, 128, 30}
+
+// imageSizeLimits holds the maximum width and height of images allowed by
+// each image size restriction. Zero means no limit.
+var imageSizeLimits = [4]int{0, 256, 64, 64}
+
+// TagSize returns the maximum number of frames and the maximum size in
+// bytes of the tag.
+func (r Restrictions) TagSize() (frames, bytes int) {
+	l := tagSizeLimits[(r&restrictTagSizeMask)>>6]
+	return l.frames, l.bytes
+}
+
+// TextEncoding returns true if text must be encoded as ISO-8859-1 or UTF-8.
+func (r Restrictions) TextEncoding() bool {
+	return (r & RestrictTextEncoding) != 0
+}
+
+// TextSize returns the maximum number of characters of a text frame, or 0
+// if the length of text isn't restricted. The characters of all the strings
+// of a frame are counted.
+func (r Restrictions) TextSize() int {
+	return textSizeLimits[(r&restrictTextSizeMask)>>3]
+}
+
+// ImageEncoding returns true if images must be PNG or JPEG images.
+func (r Restrictions) ImageEncoding() bool {
+	return (r & RestrictImageEncoding) != 0
+}
+
+// ImageSize returns the maximum width and height of images, or 0 if the size
+// of images isn't restricted. If exact is true, images must be exactly of
+// the maximum size.
+func (r Restrictions) ImageSize() (max int, exact bool) {
+	return imageSizeLimits[r&restrictImageSizeMask], (r & restrictImageSizeMask) == RestrictImageSize64Exact
+}
+
+// InferFrom sets the restrictions to the strictest restrictions the tag
+// already satisfies, so that they may be advertised in the tag:
+//
+//	var r id3.Restrictions
+//	r.InferFrom(tag)
+//	tag.Restrictions = uint8(r)
+//	tag.Flags |= TagFlagHasRestrictions
+//
+// A copy of the tag is measured as though it had the TagFlagHasRestrictions
+// flag and were written with the default options, so the frames and frame
+// encodings selected when it is written are taken into account; the tag
+// itself is left unchanged. Images that aren't PNG or JPEG images
+// are treated as exceeding every image size restriction. InferFrom returns
+// ErrExceedsRestrictions if the tag is too large for any tag size
+// restriction, and any error encountered while encoding the tag.
+func (r *Restrictions) InferFrom(t *Tag) error {
+	c, err := newCodec(t.Version)
+	if err != nil {
+		return err
+	}
+
+	cp := t.copyTag(false)
+	if cp.Version >= Version2_4 {
+		cp.Flags |= TagFlagHasRestrictions
+	}
+	opts := &EncodeOptions{unrestricted: true}
+	opts.applyDefaults(cp)
+	n, frames, err := cp.measure(c, opts)
+	if err != nil {
+		return err
+	}
+
+	var inferred Restrictions
+	for i := len(tagSizeLimits) - 1; ; i-- {
+		if i < 0 {
+			return ErrExceedsRestrictions
+		}
+		if l := tagSizeLimits[i]; frames <= l.frames && n <= l.bytes {
+			inferred = Restrictions(i << 6)
+			break
+		}
+	}
+
+	inferred |= RestrictTextEncoding | RestrictTextSize30 | RestrictImageEncoding | RestrictImageSize64Exact
+	for _, f := range cp.Frames {
+		inferred = inferred.relaxFor(f)
+	}
+	*r = inferred
+	return nil
+}
+
+// relaxFor returns the restrictions relaxed as little as needed for the
+// frame to satisfy them.
+func (r Restrictions) relaxFor(f Frame) Restrictions {
+	if sf := subframesOf(f); sf != nil {
+		for _, s := range *sf {
+			r = r.relaxFor(s)
+		}
+	}
+
+	var text []string
+	switch ff := f.(type) {
+	case *FrameText:
+		text = ff.Text
+	case *FrameTextCustom:
+		text = []string{ff.Description, ff.Text}
+	case *FrameAttachedPicture:
+		return r.relaxForImage(ff)
+	}
+
+	if enc := reflect.ValueOf(f).Elem().FieldByName("Encoding"); enc.IsValid() {
+		if e := Encoding(enc.Uint()); e != EncodingISO88591 && e != EncodingUTF8 {
+			r &^= RestrictTextEncoding
+		}
+	}
+
+	if text != nil {
+		n := 0
+		for _, s := range text {
+			n += utf8.RuneCountInString(s)
+		}
+		for max := r.TextSize(); max != 0 && n > max; max = r.TextSize() {
+			r -= 1 << 3
+		}
+	}
+	return r
+}
+
+// relaxForImage returns the restrictions relaxed as little as needed for an
+// attached picture to satisfy them.
+func (r Restrictions) relaxForImage(f *FrameAttachedPicture) Restrictions {
+	if f.Encoding != EncodingISO88591 && f.Encoding != EncodingUTF8 {
+		r &^= RestrictTextEncoding
+	}
+	if f.MimeType == LinkMimeType {
+		return r
+	}
+
+	m := normalizeMimeType(string(f.MimeType))
+	if m != "image/png" && m != "image/jpeg" {
+		r &^= RestrictImageEncoding
+	}
+
+	// Read the image's dimensions only if its size is restricted.
+	if (r & restrictImageSizeMask) == 0 {
+		return r
+	}
+	w, h, ok := imageSize(f.Data)
+	if !ok {
+		return r &^ restrictImageSizeMask
+	}
+	if (r&restrictImageSizeMask) == RestrictImageSize64Exact && (w != 64 || h != 64) {
+		r--
+	}
+	for max, _ := r.ImageSize(); max != 0 && (w > max || h > max); max, _ = r.ImageSize() {
+		r--
+	}
+	return r
+}
+
+// imageSize returns the width and height of a PNG or JPEG image, read from
+// the image's header. It returns false if the image isn't a PNG or JPEG
+// image or its header is invalid.
+func imageSize(b []byte) (w, h int, ok bool) {
+	if bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")) {
+		// The IHDR chunk, which holds the dimensions, comes first.
+		if len(b) < 24 || string(b[12:16]) != "IHDR" {
+			return 0, 0, false
+		}
+		return int(binary.BigEndian.Uint32(b[16:20])), int(binary.BigEndian.Uint32(b[20:24])), true
+	}
+
+	if !bytes.HasPrefix(b, []byte{0xff, 0xd8}) {
+		return 0, 0, false
+	}
+
+	// Walk the JPEG segments until a start of frame segment is found.
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xff {
+			return 0, 0, false
+		}
+		m := b[i+1]
+		switch {
+		case m == 0xff:
+			i++ // fill byte
+			continue
+		case m == 0x01 || (m >= 0xd0 && m <= 0xd8):
+			i += 2 // marker without a segment
+			continue
+		}
+		n := int(binary.BigEndian.Uint16(b[i+2 : i+4]))
+		if m >= 0xc0 && m <= 0xcf && m != 0xc4 && m != 0xc8 && m != 0xcc {
+			if n < 7 || i+9 > len(b) {
+				return 0, 0, false
+			}
+			return int(binary.BigEndian.Uint16(b[i+7 : i+9])), int(binary.BigEndian.Uint16(b[i+5 : i+7])), true
+		}
+		i += 2 + n
+	}
+	return 0, 0, false
+}
+
+// check returns the ways in which a tag whose encoding holds the requested
+// number of frames and bytes exceeds the restrictions, or nil if it
+// satisfies them.
+func (r Restrictions) check(t *Tag, frames, size int) error {
+	var e RestrictionError
+	if maxFrames, maxBytes := r.TagSize(); frames > maxFrames || size > maxBytes {
+		e = append(e, RestrictionViolation{-1, "", fmt.Sprintf(
+			"tag holds %d frames in %d bytes, exceeding the limit of %d frames in %d bytes",
+			frames, size, maxFrames, maxBytes)})
+	}
+
+	types := newCodec24().vdata.frameTypes
+	for i, f := range t.Frames {
+		relaxed := r.relaxFor(f)
+		add := func(format string, args ...interface{}) {
+			e = append(e, RestrictionViolation{i, frameIDOf(types, f), fmt.Sprintf(format, args...)})
+		}
+		if r.TextEncoding() && !relaxed.TextEncoding() {
+			add("text encoding is not ISO-8859-1 or UTF-8")
+		}
+		if max := r.TextSize(); max != relaxed.TextSize() {
+			add("text is longer than %d characters", max)
+		}
+		if r.ImageEncoding() && !relaxed.ImageEncoding() {
+			add("image is not a PNG or JPEG image")
+		}
+		if max, exact := r.ImageSize(); relaxed&restrictImageSizeMask < r&restrictImageSizeMask {
+			if exact {
+				add("image is not exactly %dx%d pixels", max, max)
+			} else {
+				add("image is larger than %dx%d pixels", max, max)
+			}
+		}
+	}
+
+	if len(e) > 0 {
+		return e
+	}
+	return nil
+}
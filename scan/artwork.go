@@ -0,0 +1,206 @@
+// Package scan contains helpers that process ID3 tags across many files at
+// once.
+package scan
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/beevik/id3"
+)
+
+// ErrQuotaExceeded is returned by ExtractArtwork when writing the next
+// image would exceed the configured byte quota.
+var ErrQuotaExceeded = errors.New("artwork quota exceeded")
+
+// ArtworkOptions control the behavior of ExtractArtwork.
+type ArtworkOptions struct {
+	// MaxBytes limits the total number of image bytes written to the
+	// destination directory. Zero means no limit.
+	MaxBytes int64
+
+	// SkipErrors causes files that cannot be opened or whose tags cannot be
+	// decoded to be skipped instead of aborting the extraction.
+	SkipErrors bool
+}
+
+// Artwork describes a cover image extracted from a file.
+type Artwork struct {
+	Source   string // path of the file containing the image
+	File     string // path of the extracted image in the destination directory
+	Hash     string // hex-encoded SHA-1 hash of the image data
+	MimeType string // image MIME type as stored in the tag
+	Size     int    // image size in bytes
+	Existing bool   // true if the image was already present in the cache
+}
+
+// ExtractArtwork walks the requested paths (files or directories), extracts
+// the front-cover attached picture from each file's ID3 tag, and writes it
+// to destDir. Images are deduplicated by hash and stored under stable names
+// derived from that hash, so repeated extractions reuse existing files.
+//
+// ExtractArtwork returns one Artwork entry per source file that had a
+// front cover. If the quota is reached, it returns the entries gathered so
+// far along with ErrQuotaExceeded.
+func ExtractArtwork(paths []string, destDir string, opts ArtworkOptions) ([]Artwork, error) {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, err
+	}
+
+	files, err := walkFiles(paths, opts.SkipErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	var written int64
+	var result []Artwork
+	for _, path := range files {
+		pic, err := readFrontCover(path)
+		if err != nil {
+			if opts.SkipErrors {
+				continue
+			}
+			return result, err
+		}
+		if pic == nil {
+			continue
+		}
+
+		sum := sha1.Sum(pic.Data)
+		a := Artwork{
+			Source:   path,
+			Hash:     hex.EncodeToString(sum[:]),
+			MimeType: string(pic.MimeType),
+			Size:     len(pic.Data),
+		}
+		a.File = filepath.Join(destDir, a.Hash+extensionOf(a.MimeType))
+
+		if _, err := os.Stat(a.File); err == nil {
+			a.Existing = true
+			result = append(result, a)
+			continue
+		}
+
+		if opts.MaxBytes > 0 && written+int64(a.Size) > opts.MaxBytes {
+			return result, ErrQuotaExceeded
+		}
+
+		if err := writeFileAtomic(a.File, pic.Data); err != nil {
+			return result, err
+		}
+		written += int64(a.Size)
+		result = append(result, a)
+	}
+
+	return result, nil
+}
+
+// walkFiles walks the requested paths (files or directories) and returns
+// the regular files found. If skipErrors is true, paths that can't be
+// walked are skipped.
+func walkFiles(paths []string, skipErrors bool) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		err := filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil && !skipErrors {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// readTag returns the file's first ID3 tag, or nil if the file has no tag.
+func readTag(path string) (*id3.Tag, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	r := bufio.NewReader(file)
+	p, err := r.Peek(10)
+	if err != nil {
+		return nil, nil
+	}
+	if _, _, err := id3.PeekTag(p); err != nil {
+		return nil, nil
+	}
+
+	t := &id3.Tag{}
+	if _, err := t.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// readFrontCover returns the front-cover picture frame of the file's first
+// ID3 tag, or nil if the file has no tag or no front cover.
+func readFrontCover(path string) (*id3.FrameAttachedPicture, error) {
+	t, err := readTag(path)
+	if t == nil {
+		return nil, err
+	}
+
+	for _, f := range t.FindFrames(id3.FrameTypeAttachedPicture) {
+		pic := f.(*id3.FrameAttachedPicture)
+		if pic.PictureType == id3.PictureTypeCoverFront && !pic.IsLink() {
+			return pic, nil
+		}
+	}
+	return nil, nil
+}
+
+// writeFileAtomic writes data to a uniquely named temporary file in the
+// target directory, syncs it to stable storage and renames it into place,
+// so a partially written image never appears under its final name.
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".artwork-*")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func extensionOf(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg", "image/jpg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/bmp":
+		return ".bmp"
+	default:
+		return ".bin"
+	}
+}
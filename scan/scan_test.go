@@ -0,0 +1,245 @@
+package scan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/beevik/id3"
+)
+
+// writeTagged writes a file holding a tag with the requested title, followed
+// by some audio data.
+func writeTagged(t *testing.T, path, title string) {
+	tag := id3.NewTag(id3.Version2_4, 0)
+	tag.SetTitle(title)
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := tag.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("audio data")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A testNotifier delivers the notifications sent by a test.
+type testNotifier struct {
+	events chan FileEvent
+	errs   chan error
+	added  []string
+	onAdd  func(path string)
+}
+
+func newTestNotifier() *testNotifier {
+	return &testNotifier{events: make(chan FileEvent), errs: make(chan error)}
+}
+
+func (n *testNotifier) Add(path string) error {
+	n.added = append(n.added, path)
+	if n.onAdd != nil {
+		n.onAdd(path)
+	}
+	return nil
+}
+
+func (n *testNotifier) Events() <-chan FileEvent { return n.events }
+func (n *testNotifier) Errors() <-chan error     { return n.errs }
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.mp3"), filepath.Join(dir, "b.mp3")
+	writeTagged(t, a, "A")
+
+	// A file created after watching starts but before the initial scan
+	// completes must be found.
+	n := newTestNotifier()
+	n.onAdd = func(string) { writeTagged(t, b, "B") }
+
+	changes := make(chan Change, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(context.Background(), n, []string{dir}, WatchOptions{InitialScan: true}, func(c Change) {
+			changes <- c
+		})
+	}()
+
+	next := func() Change {
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a change")
+			return Change{}
+		}
+	}
+
+	for _, path := range []string{a, b} {
+		if c := next(); c.Kind != TagAdded || c.Path != path || c.Tag == nil {
+			t.Errorf("got %+v, expected %s to be added", c, path)
+		}
+	}
+
+	writeTagged(t, a, "A2")
+	n.events <- FileEvent{Path: a}
+	c := next()
+	if c.Kind != TagModified || c.Path != a || c.Tag.Title() != "A2" || c.Previous.Title() != "A" {
+		t.Errorf("got %+v, expected %s to be modified", c, a)
+	}
+
+	n.errs <- os.ErrPermission
+	if c := next(); c.Kind != WatchError || c.Err != os.ErrPermission {
+		t.Errorf("got %+v, expected a WatchError", c)
+	}
+
+	// Events are still delivered once the error channel is closed.
+	close(n.errs)
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	n.events <- FileEvent{Path: b, Removed: true}
+	if c := next(); c.Kind != TagRemoved || c.Path != b || c.Previous.Title() != "B" {
+		t.Errorf("got %+v, expected %s to be removed", c, b)
+	}
+
+	// An unchanged tag isn't reported.
+	n.events <- FileEvent{Path: a}
+	close(n.events)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("got unexpected change %+v", <-changes)
+	}
+	if len(n.added) != 1 || n.added[0] != dir {
+		t.Errorf("got watched paths %q", n.added)
+	}
+}
+
+func TestWatchCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := newTestNotifier()
+	close(n.errs)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, n, []string{t.TempDir()}, WatchOptions{}, func(Change) {})
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("got %v, expected context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch didn't return when canceled")
+	}
+}
+
+// writeCover writes a file holding a tag with an attached picture.
+func writeCover(t *testing.T, path, mimeType string, pt id3.PictureType, data []byte) {
+	tag := id3.NewTag(id3.Version2_4, 0)
+	tag.Frames = append(tag.Frames, id3.NewFrameAttachedPicture(mimeType, "", pt, data))
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := tag.WriteTo(f); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArtwork(t *testing.T) {
+	src, dest := t.TempDir(), filepath.Join(t.TempDir(), "art")
+	png, jpeg := []byte("png image"), []byte("jpeg image")
+	writeCover(t, filepath.Join(src, "a.mp3"), "image/png", id3.PictureTypeCoverFront, png)
+	writeCover(t, filepath.Join(src, "b.mp3"), "image/png", id3.PictureTypeCoverFront, png)
+	writeCover(t, filepath.Join(src, "c.mp3"), "image/png", id3.PictureTypeCoverBack, png)
+	writeCover(t, filepath.Join(src, "d.mp3"), "image/jpeg", id3.PictureTypeCoverFront, jpeg)
+	if err := os.WriteFile(filepath.Join(src, "e.txt"), []byte("no tag"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	art, err := ExtractArtwork([]string{src}, dest, ArtworkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		source   string
+		ext      string
+		existing bool
+		data     []byte
+	}{
+		{"a.mp3", ".png", false, png},
+		{"b.mp3", ".png", true, png},
+		{"d.mp3", ".jpg", false, jpeg},
+	}
+	if len(art) != len(want) {
+		t.Fatalf("got %d images, expected %d", len(art), len(want))
+	}
+	for i, w := range want {
+		a := art[i]
+		if filepath.Base(a.Source) != w.source || filepath.Ext(a.File) != w.ext || a.Existing != w.existing || a.Size != len(w.data) {
+			t.Errorf("image %d: got %+v", i, a)
+		}
+		if b, err := os.ReadFile(a.File); err != nil || string(b) != string(w.data) {
+			t.Errorf("image %d: got %q, %v", i, b, err)
+		}
+	}
+	if art[0].File != art[1].File {
+		t.Errorf("identical images not deduplicated")
+	}
+
+	// Extracting again reuses the existing images.
+	art, err = ExtractArtwork([]string{src}, dest, ArtworkOptions{})
+	if err != nil || len(art) != 3 || !art[0].Existing || !art[2].Existing {
+		t.Errorf("got %+v, %v", art, err)
+	}
+}
+
+func TestExtractArtworkQuota(t *testing.T) {
+	src, dest := t.TempDir(), t.TempDir()
+	writeCover(t, filepath.Join(src, "a.mp3"), "image/png", id3.PictureTypeCoverFront, []byte("first"))
+	writeCover(t, filepath.Join(src, "b.mp3"), "image/png", id3.PictureTypeCoverFront, []byte("second"))
+
+	art, err := ExtractArtwork([]string{src}, dest, ArtworkOptions{MaxBytes: 8})
+	if err != ErrQuotaExceeded || len(art) != 1 {
+		t.Errorf("got %+v, %v", art, err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dest, "*")); len(files) != 1 {
+		t.Errorf("got files %q", files)
+	}
+}
+
+func TestExtractArtworkErrors(t *testing.T) {
+	src, dest := t.TempDir(), t.TempDir()
+	writeCover(t, filepath.Join(src, "a.mp3"), "image/png", id3.PictureTypeCoverFront, []byte("image"))
+
+	// A tag claiming more data than the file holds fails to decode.
+	bad := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 1, 0, 'T', 'I', 'T', '2'}
+	if err := os.WriteFile(filepath.Join(src, "b.mp3"), bad, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if art, err := ExtractArtwork([]string{src}, dest, ArtworkOptions{}); err == nil || len(art) != 1 {
+		t.Errorf("got %+v, %v, expected a decoding error", art, err)
+	}
+	missing := filepath.Join(src, "missing")
+	if _, err := ExtractArtwork([]string{missing}, dest, ArtworkOptions{}); !os.IsNotExist(err) {
+		t.Errorf("got %v, expected a missing file error", err)
+	}
+
+	art, err := ExtractArtwork([]string{src, missing}, dest, ArtworkOptions{SkipErrors: true})
+	if err != nil || len(art) != 1 || filepath.Base(art[0].Source) != "a.mp3" {
+		t.Errorf("got %+v, %v", art, err)
+	}
+}
@@ -0,0 +1,192 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"os"
+	"path/filepath"
+
+	"github.com/beevik/id3"
+)
+
+// A FileEvent reports that a file or directory was created, modified,
+// renamed or removed.
+type FileEvent struct {
+	Path    string // path of the affected file or directory
+	Removed bool   // true if the path was removed or renamed away
+}
+
+// A Notifier delivers file system change notifications to Watch. It is
+// typically a thin adapter over a platform notification library such as
+// fsnotify, whose watcher's events map directly onto FileEvent values.
+type Notifier interface {
+	// Add starts watching a file or directory.
+	Add(path string) error
+
+	// Events returns the channel delivering change notifications. Watch
+	// returns when the channel is closed.
+	Events() <-chan FileEvent
+
+	// Errors returns the channel delivering notification errors.
+	Errors() <-chan error
+}
+
+// ChangeKind describes how a file's tag changed.
+type ChangeKind uint8
+
+// All possible ChangeKind values.
+const (
+	TagAdded    ChangeKind = iota // a file with a tag appeared, or a tag was added
+	TagModified                   // a file's tag was modified
+	TagRemoved                    // a file or its tag was removed
+	WatchError                    // a file could not be read or watched
+)
+
+// A Change describes a change to the tag of a watched file.
+type Change struct {
+	Kind     ChangeKind
+	Path     string
+	Tag      *id3.Tag // the file's new tag; nil if it was removed
+	Previous *id3.Tag // the file's previous tag; nil if it was added
+	Err      error    // the error, for WatchError changes
+}
+
+// WatchOptions control the behavior of Watch.
+type WatchOptions struct {
+	// InitialScan causes a TagAdded change to be reported for every file
+	// with a tag found when watching starts.
+	InitialScan bool
+}
+
+// Watch maintains an index of the ID3 tags of the files found under the
+// requested paths (files or directories), and calls fn with a Change
+// whenever a notification causes a file's tag to be added, modified or
+// removed. Files are re-read when notified, and changes are reported only
+// if the tag's encoded contents changed. Directories created while watching
+// are added to the notifier and scanned.
+//
+// Watch runs until the context is canceled or the notifier's event channel
+// is closed. Changes are reported from the calling goroutine.
+func Watch(ctx context.Context, n Notifier, paths []string, opts WatchOptions, fn func(Change)) error {
+	w := &watcher{n: n, fn: fn, index: make(map[string]*indexEntry)}
+
+	// Start watching before the initial walk, so that changes made during
+	// the walk aren't missed.
+	for _, p := range paths {
+		if err := n.Add(p); err != nil {
+			return err
+		}
+	}
+	files, err := walkFiles(paths, true)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		w.update(path, opts.InitialScan)
+	}
+
+	events, errs := n.Events(), n.Errors()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			w.handle(ev)
+		case err, ok := <-errs:
+			if !ok {
+				// Stop selecting on the closed channel.
+				errs = nil
+				continue
+			}
+			fn(Change{Kind: WatchError, Err: err})
+		}
+	}
+}
+
+// A watcher holds the state of a running Watch.
+type watcher struct {
+	n     Notifier
+	fn    func(Change)
+	index map[string]*indexEntry // tags by file path
+}
+
+// An indexEntry holds the tag of a watched file.
+type indexEntry struct {
+	tag *id3.Tag
+	sum [sha1.Size]byte // hash of the encoded tag
+}
+
+// handle processes a single file system event.
+func (w *watcher) handle(ev FileEvent) {
+	path := filepath.Clean(ev.Path)
+	info, err := os.Stat(path)
+	if ev.Removed || err != nil {
+		w.remove(path)
+		return
+	}
+
+	if info.IsDir() {
+		if err := w.n.Add(path); err != nil {
+			w.fn(Change{Kind: WatchError, Path: path, Err: err})
+		}
+		files, _ := walkFiles([]string{path}, true)
+		for _, f := range files {
+			w.update(f, true)
+		}
+		return
+	}
+	if info.Mode().IsRegular() {
+		w.update(path, true)
+	}
+}
+
+// update re-reads a file's tag and reports any change to it, if requested.
+func (w *watcher) update(path string, report bool) {
+	path = filepath.Clean(path)
+	t, err := readTag(path)
+	if err != nil {
+		if report {
+			w.fn(Change{Kind: WatchError, Path: path, Err: err})
+		}
+		return
+	}
+	if t == nil {
+		w.remove(path)
+		return
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := t.WriteTo(buf); err != nil {
+		if report {
+			w.fn(Change{Kind: WatchError, Path: path, Err: err})
+		}
+		return
+	}
+	e := &indexEntry{tag: t, sum: sha1.Sum(buf.Bytes())}
+
+	prev, ok := w.index[path]
+	w.index[path] = e
+	switch {
+	case !report:
+	case !ok:
+		w.fn(Change{Kind: TagAdded, Path: path, Tag: t})
+	case prev.sum != e.sum:
+		w.fn(Change{Kind: TagModified, Path: path, Tag: t, Previous: prev.tag})
+	}
+}
+
+// remove removes a file, or all files within a directory, from the index,
+// reporting the removal of their tags.
+func (w *watcher) remove(path string) {
+	prefix := path + string(filepath.Separator)
+	for p, e := range w.index {
+		if p == path || (len(p) > len(prefix) && p[:len(prefix)] == prefix) {
+			delete(w.index, p)
+			w.fn(Change{Kind: TagRemoved, Path: p, Previous: e.tag})
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package id3
+
+// ScrubOptions control the removal of privacy-sensitive information from a
+// tag by Tag.Scrub.
+type ScrubOptions struct {
+	// Timestamps maps timestamp frame types, such as
+	// FrameTypeTextEncodingTime (TDEN), FrameTypeTextTaggingTime (TDTG) and
+	// FrameTypeTextRecordingTime (TDRC), to the policy applied to frames of
+	// that type. Frames of types not in the map are unchanged.
+	Timestamps map[FrameType]TimestampPolicy
+}
+
+// A TimestampPolicy describes how the timestamps of a frame type are
+// anonymized.
+type TimestampPolicy struct {
+	// Strip causes frames of the type to be removed from the tag.
+	Strip bool
+
+	// Precision is the highest precision kept when Strip is false.
+	// Timestamps with a higher precision are truncated, so that, for
+	// example, PrecisionYear keeps only the year. Values that aren't valid
+	// timestamps are removed, along with frames left with no values.
+	Precision TimePrecision
+}
+
+// defaultScrubOptions returns the options used by Tag.Scrub when none are
+// requested.
+func defaultScrubOptions() *ScrubOptions {
+	return &ScrubOptions{
+		Timestamps: map[FrameType]TimestampPolicy{
+			FrameTypeTextEncodingTime:  {Strip: true},
+			FrameTypeTextTaggingTime:   {Strip: true},
+			FrameTypeTextRecordingTime: {Precision: PrecisionYear},
+		},
+	}
+}
+
+// Scrub removes privacy-sensitive information from the tag according to
+// the options, marking the frames it modifies. It returns the number of
+// frames modified or removed. A nil opts selects the default options,
+// which remove the encoding time (TDEN) and tagging time (TDTG) frames and
+// keep only the year of the recording time (TDRC).
+func (t *Tag) Scrub(opts *ScrubOptions) int {
+	if opts == nil {
+		opts = defaultScrubOptions()
+	}
+
+	n := 0
+	frames := make([]Frame, 0, len(t.Frames))
+	for _, f := range t.Frames {
+		policy, ok := opts.Timestamps[HeaderOf(f).FrameType]
+		tf, isText := f.(*FrameText)
+		if !ok || !isText {
+			frames = append(frames, f)
+			continue
+		}
+		if policy.Strip {
+			n++
+			continue
+		}
+
+		text, changed := truncateTimestamps(tf.Text, policy.Precision)
+		if changed {
+			n++
+			if len(text) == 0 {
+				continue
+			}
+			tf.Text = text
+			t.MarkDirty(tf)
+		}
+		frames = append(frames, f)
+	}
+	t.Frames = frames
+	return n
+}
+
+// truncateTimestamps truncates timestamps to a maximum precision, removing
+// invalid values. It returns the resulting values and whether any value
+// changed.
+func truncateTimestamps(values []string, p TimePrecision) ([]string, bool) {
+	var out []string
+	changed := false
+	for _, v := range values {
+		ts, prec, ok := parseTimestamp(v)
+		switch {
+		case !ok:
+			changed = true
+		case prec > p:
+			out = append(out, ts.Format(timestampLayouts[p]))
+			changed = true
+		default:
+			out = append(out, v)
+		}
+	}
+	return out, changed
+}
@@ -0,0 +1,68 @@
+package id3
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io"
+)
+
+// Sealed tag envelope layout:
+//
+//	"ID3S"        4-byte magic
+//	version       1 byte (sealVersion)
+//	nonce         aead.NonceSize() bytes
+//	ciphertext    encrypted tag, including the AEAD tag
+//
+// The magic and version bytes are authenticated as additional data.
+const sealVersion = 1
+
+var sealMagic = []byte{'I', 'D', '3', 'S'}
+
+// SealTag encodes the tag and encrypts the result using the provided AEAD
+// cipher (e.g., AES-GCM). The returned envelope is suitable for storing
+// tag metadata at rest. Use OpenTag to recover the tag.
+func SealTag(t *Tag, aead cipher.AEAD) ([]byte, error) {
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := t.WriteTo(buf); err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	hdr := append(append([]byte{}, sealMagic...), sealVersion)
+	out := make([]byte, 0, len(hdr)+len(nonce)+buf.Len()+aead.Overhead())
+	out = append(out, hdr...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, buf.Bytes(), hdr), nil
+}
+
+// OpenTag decrypts an envelope produced by SealTag using the provided AEAD
+// cipher and decodes the tag it contains. It returns ErrInvalidEnvelope if
+// the envelope is malformed, was produced by an unsupported version, or
+// fails authentication.
+func OpenTag(b []byte, aead cipher.AEAD) (*Tag, error) {
+	hdrLen := len(sealMagic) + 1
+	if len(b) < hdrLen+aead.NonceSize() || !bytes.Equal(b[:len(sealMagic)], sealMagic) {
+		return nil, ErrInvalidEnvelope
+	}
+	if b[len(sealMagic)] != sealVersion {
+		return nil, ErrInvalidEnvelope
+	}
+
+	hdr := b[:hdrLen]
+	nonce := b[hdrLen : hdrLen+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, b[hdrLen+aead.NonceSize():], hdr)
+	if err != nil {
+		return nil, ErrInvalidEnvelope
+	}
+
+	t := &Tag{}
+	if _, err := t.ReadFrom(bytes.NewReader(plain)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
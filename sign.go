@@ -0,0 +1,156 @@
+package id3
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+)
+
+// SignatureOwner is the owner identifier of the private (PRIV) frame used
+// to hold a tag's signature.
+const SignatureOwner = "github.com/beevik/id3/signature"
+
+// SignTag computes a signature over the canonical serialization of the tag
+// and stores it in a private (PRIV) frame owned by SignatureOwner, replacing
+// any previous signature. Ed25519 signers sign the serialization directly;
+// all other signers sign its SHA-256 digest.
+//
+// The canonical serialization is the tag's encoding, without padding,
+// extended header data or signature frames. Any modification to the tag's
+// frames after signing invalidates the signature.
+func SignTag(t *Tag, signer crypto.Signer) error {
+	msg, err := canonicalTagBytes(t)
+	if err != nil {
+		return err
+	}
+
+	var sig []byte
+	switch signer.Public().(type) {
+	case ed25519.PublicKey:
+		sig, err = signer.Sign(rand.Reader, msg, crypto.Hash(0))
+	default:
+		digest := sha256.Sum256(msg)
+		sig, err = signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		return err
+	}
+
+	removeSignatures(t)
+	t.Frames = append(t.Frames, NewFramePrivate(SignatureOwner, sig))
+	return nil
+}
+
+// VerifyTag verifies the signature stored in the tag by SignTag using the
+// signer's public key. Ed25519, ECDSA and RSA (PKCS #1 v1.5) keys are
+// supported. VerifyTag returns ErrNoSignature if the tag is unsigned and
+// ErrInvalidSignature if verification fails.
+func VerifyTag(t *Tag, pub crypto.PublicKey) error {
+	var sig []byte
+	for _, f := range t.FindFrames(FrameTypePrivate) {
+		if p := f.(*FramePrivate); p.Owner == SignatureOwner {
+			sig = p.Data
+		}
+	}
+	if sig == nil {
+		return ErrNoSignature
+	}
+
+	msg, err := canonicalTagBytes(t)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(msg)
+
+	var ok bool
+	switch key := pub.(type) {
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, msg, sig)
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(key, digest[:], sig)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	default:
+		return ErrUnsupportedKey
+	}
+
+	if !ok {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// canonicalTagBytes returns the canonical serialization of the tag used
+// when computing its signature.
+func canonicalTagBytes(t *Tag) ([]byte, error) {
+	c := &Tag{Version: t.Version}
+	c.Frames = make([]Frame, 0, len(t.Frames))
+	for _, f := range t.Frames {
+		if p, ok := f.(*FramePrivate); ok && p.Owner == SignatureOwner {
+			continue
+		}
+		c.Frames = append(c.Frames, f)
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	if _, err := c.WriteTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// removeSignatures removes all signature frames from the tag.
+func removeSignatures(t *Tag) {
+	ff := t.Frames[:0]
+	for _, f := range t.Frames {
+		if p, ok := f.(*FramePrivate); ok && p.Owner == SignatureOwner {
+			continue
+		}
+		ff = append(ff, f)
+	}
+	t.Frames = ff
+}
+
+// A SignatureVerifier validates a signature (SIGN) frame against the frames
+// belonging to its signed group, which are all frames whose group
+// identifier matches the signature's group symbol. The group argument holds
+// the group identifier registration (GRID) frame for the group symbol, or
+// nil if the tag doesn't register the symbol.
+type SignatureVerifier func(sig *FrameSignature, group *FrameGroupID, frames []Frame) error
+
+// verifySignatures calls the options' signature verifier, if there is one,
+// for each signature frame in the tag.
+func (o *DecodeOptions) verifySignatures(t *Tag) error {
+	if o.VerifySignature == nil {
+		return nil
+	}
+
+	for _, f := range t.FindFrames(FrameTypeSignature) {
+		sig := f.(*FrameSignature)
+
+		var group *FrameGroupID
+		for _, g := range t.FindFrames(FrameTypeGroupID) {
+			if g := g.(*FrameGroupID); g.GroupID == sig.GroupSymbol {
+				group = g
+				break
+			}
+		}
+
+		var frames []Frame
+		for _, ff := range t.Frames {
+			h := HeaderOf(ff)
+			if (h.Flags&FrameFlagHasGroupID) != 0 && h.GroupID == sig.GroupSymbol && ff != f {
+				frames = append(frames, ff)
+			}
+		}
+
+		if err := o.VerifySignature(sig, group, frames); err != nil {
+			return err
+		}
+	}
+	return nil
+}
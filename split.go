@@ -0,0 +1,83 @@
+package id3
+
+// A RawFrameSlice describes a frame found by SplitFrames. Its byte slices
+// refer to the tag body passed to SplitFrames and are not copies.
+type RawFrameSlice struct {
+	ID      string     // frame ID, e.g. "APIC"
+	Flags   FrameFlags // frame header flags, always 0 in v2.2 tags
+	Offset  int        // offset of the frame header within the tag body
+	Frame   []byte     // the entire frame, including its header
+	Payload []byte     // the frame's data following its header
+}
+
+// SplitFrames splits the body of a tag into its frames without decoding
+// their payloads. The tag body is the data following the tag header and
+// extended header, with tag-level unsynchronization already removed, and
+// may end with padding. The frames' payloads are returned as stored, so
+// frames that are compressed, encrypted, or carry a group ID or data length
+// indicator include the corresponding data.
+//
+// SplitFrames returns ErrIncompleteFrame if a frame extends past the end of
+// the tag body and ErrInvalidFrameHeader if a frame header is malformed,
+// along with the frames preceding the failed frame.
+func SplitFrames(tagBody []byte, v Version) ([]RawFrameSlice, error) {
+	var idLen, hdrLen int
+	var flags flagMap
+	switch v {
+	case Version2_2:
+		idLen, hdrLen = 3, 6
+	case Version2_3:
+		idLen, hdrLen = 4, 10
+		flags = newCodec23().vdata.frameFlags
+	case Version2_4:
+		idLen, hdrLen = 4, 10
+		flags = newCodec24().vdata.frameFlags
+	default:
+		return nil, ErrInvalidVersion
+	}
+
+	frames := []RawFrameSlice{}
+	for off := 0; off < len(tagBody); {
+		// A zero byte where a frame ID should be starts the padding.
+		b := tagBody[off:]
+		if b[0] == 0 {
+			break
+		}
+		if len(b) < hdrLen {
+			return frames, ErrIncompleteFrame
+		}
+
+		var size uint32
+		switch v {
+		case Version2_2:
+			size = uint32(b[3])<<16 | uint32(b[4])<<8 | uint32(b[5])
+		case Version2_3:
+			size = decodeUint32(b[4:8])
+		case Version2_4:
+			var err error
+			if size, err = decodeSyncSafeUint32(b[4:8]); err != nil {
+				return frames, ErrInvalidFrameHeader
+			}
+		}
+		if size < 1 {
+			return frames, ErrInvalidFrameHeader
+		}
+		if uint64(hdrLen)+uint64(size) > uint64(len(b)) {
+			return frames, ErrIncompleteFrame
+		}
+
+		end := hdrLen + int(size)
+		f := RawFrameSlice{
+			ID:      string(b[:idLen]),
+			Offset:  off,
+			Frame:   b[:end:end],
+			Payload: b[hdrLen:end:end],
+		}
+		if flags != nil {
+			f.Flags = FrameFlags(flags.Decode(uint32(b[8])<<8 | uint32(b[9])))
+		}
+		frames = append(frames, f)
+		off += end
+	}
+	return frames, nil
+}
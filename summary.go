@@ -0,0 +1,70 @@
+package id3
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TrackInfo contains a flattened summary of the most commonly used
+// information stored within a tag.
+type TrackInfo struct {
+	Title       string
+	Artists     []string
+	Album       string
+	AlbumArtist string
+	Track       int
+	TrackTotal  int
+	Disc        int
+	DiscTotal   int
+	Year        int
+	Genre       string
+	DurationMs  int
+	HasArt      bool
+}
+
+// Summarize computes a TrackInfo summary of the tag. It hides differences
+// between tag versions, such as TDRC versus TYER, numeric versus named
+// genres, and "3/12" style track numbers. Artists are the text values of the
+// TPE1 frame; slash-separated v2.3 values aren't split, since slashes may be
+// part of an artist's name, as in "AC/DC". The genre is the first of the
+// tag's Genres.
+func Summarize(t *Tag) TrackInfo {
+	var info TrackInfo
+
+	info.Title = firstText(t, FrameTypeTextSongTitle)
+	info.Album = firstText(t, FrameTypeTextAlbumName)
+	info.AlbumArtist = firstText(t, FrameTypeTextAlbumArtist)
+
+	if f, ok := t.FindFrame(FrameTypeTextArtist).(*FrameText); ok {
+		for _, s := range f.Text {
+			if s = strings.TrimSpace(s); s != "" {
+				info.Artists = append(info.Artists, s)
+			}
+		}
+	}
+
+	track, disc := t.Track(), t.Disc()
+	info.Track, info.TrackTotal = track.Number, track.Total
+	info.Disc, info.DiscTotal = disc.Number, disc.Total
+
+	if y := firstText(t, FrameTypeTextRecordingTime); len(y) >= 4 {
+		info.Year, _ = strconv.Atoi(y[:4])
+	}
+
+	if g := t.Genres(); len(g) > 0 {
+		info.Genre = g[0]
+	}
+	info.DurationMs, _ = strconv.Atoi(strings.TrimSpace(firstText(t, FrameTypeTextLengthInMs)))
+	info.HasArt = t.FindFrame(FrameTypeAttachedPicture) != nil
+
+	return info
+}
+
+// firstText returns the first text string of the first text frame of the
+// requested type, or the empty string if there is none.
+func firstText(t *Tag, typ FrameType) string {
+	if f, ok := t.FindFrame(typ).(*FrameText); ok && len(f.Text) > 0 {
+		return strings.TrimSpace(f.Text[0])
+	}
+	return ""
+}
@@ -1,10 +1,16 @@
 package id3
 
 import (
+	"bytes"
 	"io"
+	"io/ioutil"
 )
 
 // A Tag represents an entire ID3 tag, including zero or more frames.
+//
+// A Tag must not be used by multiple goroutines simultaneously, since even
+// encoding a tag updates its frames' headers. Use Freeze to obtain a
+// read-only view of a tag that may be shared.
 type Tag struct {
 	Version      Version  // ID3 codec version (2.2, 2.3, or 2.4)
 	Flags        TagFlags // Flags
@@ -13,6 +19,15 @@ type Tag struct {
 	CRC          uint32   // Optional CRC code
 	Restrictions uint8    // ID3 restrictions (v2.4 only)
 	Frames       []Frame  // All ID3 frames included in the tag
+
+	// ExtendedUnknown holds any unrecognized bytes found at the end of the
+	// tag's extended header. They are written back only if the encode
+	// options request it.
+	ExtendedUnknown []byte
+
+	encryption map[byte]EncryptionCodec // codecs by encryption method
+	dirty      map[Frame]bool           // frames modified since decoding
+	defaulted  map[Frame]bool           // frames awaiting default language and encoding
 }
 
 // TagFlags describe flags that may appear within an ID3 tag. Not all
@@ -54,8 +69,8 @@ func NewTag(v Version, f TagFlags) *Tag {
 
 // PeekTag peeks at a buffer containing at least 10 bytes to determine if it
 // contains an ID3 tag. If it does, PeekTag returns the ID3 version number
-// and the total size of the tag in bytes. If it doesn't, PeekTag returns
-// ErrInvalidHeader.
+// and the total size of the tag in bytes, including its header and any
+// footer. If it doesn't, PeekTag returns ErrInvalidHeader.
 func PeekTag(b []byte) (version Version, size int, err error) {
 	switch {
 	case len(b) < 10:
@@ -74,13 +89,48 @@ func PeekTag(b []byte) (version Version, size int, err error) {
 		return 0, 0, ErrInvalidHeader
 	}
 
-	return Version(b[3]), int(sz + 10), nil
+	size = int(sz + 10)
+	if b[3] == 4 && (b[5]&0x10) != 0 {
+		size += 10 // footer
+	}
+
+	return Version(b[3]), size, nil
 }
 
 // ReadFrom reads from a stream into an ID3 tag. It returns the number of
 // bytes read and any error encountered during decoding.
 func (t *Tag) ReadFrom(r io.Reader) (int64, error) {
+	return t.ReadFromWithOptions(r, nil)
+}
+
+// ReadFromWithOptions reads from a stream into an ID3 tag, using the
+// requested decoding options. A nil opts selects the default options. It
+// returns the number of bytes read and any error encountered during
+// decoding.
+//
+// If a frame fails to decode, the tag retains the frames decoded before
+// it, and the options' report, if any, describes the failed frame.
+func (t *Tag) ReadFromWithOptions(r io.Reader, opts *DecodeOptions) (int64, error) {
+	if opts == nil {
+		opts = &DecodeOptions{}
+	}
+
+	// Lazy picture decoding requires random access to the source.
+	if opts.LazyPictureSize > 0 {
+		ra, isReaderAt := r.(io.ReaderAt)
+		s, isSeeker := r.(io.Seeker)
+		if isReaderAt && isSeeker {
+			if _, err := s.Seek(0, io.SeekCurrent); err == nil {
+				o := *opts
+				o.source, o.seeker = ra, s
+				opts = &o
+			}
+		}
+	}
+
+	t.dirty, t.defaulted = nil, nil
 	rr := newReader(r)
+	rr.ctx = opts.Context
 
 	// Read 3 bytes to check for the ID3 file id.
 	if rr.Load(3); rr.err != nil {
@@ -101,14 +151,147 @@ func (t *Tag) ReadFrom(r io.Reader) (int64, error) {
 		return int64(rr.n), err
 	}
 
-	// Decode the rest of the tag.
-	err = c.Decode(t, rr)
+	// Decode the rest of the tag, then reassemble any user-defined text
+	// frames split across continuation frames.
+	err = c.Decode(t, rr, opts)
+	joinUserText(t)
+	if opts.CombineDates {
+		combineDates(t)
+	}
 	return int64(rr.n), err
 }
 
+// maxSeekChain is the maximum number of SEEK frames followed by ReadTagChain.
+const maxSeekChain = 8
+
+// ReadTagChain reads the ID3 tag located at the current position of the
+// stream. If the tag contains a SEEK frame, ReadTagChain follows it to the
+// next tag in the stream, and so on, returning all tags discovered in the
+// chain in stream order. Up to 8 SEEK frames are followed. If an error
+// occurs, ReadTagChain returns the tags read before the error.
+func ReadTagChain(r io.ReadSeeker) ([]*Tag, error) {
+	t := &Tag{}
+	if _, err := t.ReadFrom(r); err != nil {
+		return nil, err
+	}
+	tags := []*Tag{t}
+
+	for i := 0; i < maxSeekChain; i++ {
+		seek, ok := t.FindFrame(FrameTypeSeek).(*FrameSeek)
+		if !ok {
+			break
+		}
+
+		if _, err := r.Seek(int64(seek.Offset), io.SeekCurrent); err != nil {
+			return tags, err
+		}
+
+		t = &Tag{}
+		if _, err := t.ReadFrom(r); err != nil {
+			return tags, err
+		}
+		tags = append(tags, t)
+	}
+
+	return tags, nil
+}
+
+// ReadAllTags reads the chain of ID3 tags located at the current position
+// of the stream, as ReadTagChain does, and merges the tags' frames into a
+// single tag. The SEEK frames of the chain are removed.
+//
+// When merging, text and URL frames of a later tag replace frames of the same
+// type found in earlier tags, while all other frames are appended. Later
+// tags flagged as updates are merged using ApplyUpdate instead.
+func ReadAllTags(r io.ReadSeeker) (*Tag, error) {
+	tags, err := ReadTagChain(r)
+	if len(tags) == 0 {
+		return nil, err
+	}
+
+	t := tags[0]
+	for _, next := range tags[1:] {
+		if (next.Flags & TagFlagIsUpdate) != 0 {
+			t.ApplyUpdate(next)
+		} else {
+			mergeTag(t, next)
+		}
+	}
+	t.RemoveFrames(FrameTypeSeek)
+	return t, err
+}
+
+// mergeTag merges the frames of tag src into tag dst. The frames of dst
+// replaced by text and URL frames of src are removed before any frames are
+// appended, so every frame of src is kept.
+func mergeTag(dst, src *Tag) {
+	replaced := make(map[FrameType]bool)
+	for _, f := range src.Frames {
+		switch f.(type) {
+		case *FrameText, *FrameURL:
+			replaced[HeaderOf(f).FrameType] = true
+		}
+	}
+
+	frames := dst.Frames[:0]
+	for _, f := range dst.Frames {
+		if !replaced[HeaderOf(f).FrameType] {
+			frames = append(frames, f)
+		}
+	}
+	dst.Frames = append(frames, src.Frames...)
+}
+
+// ApplyUpdate merges an update tag, which is a tag with the TagFlagIsUpdate
+// flag found later in the same file or stream, into the tag. Following the
+// ID3 v2.4 specification, frames of the update that may appear only once,
+// or only once per identifying fields as described by AddFrame, override the
+// corresponding frames of the tag in place, and all other frames of the
+// update are appended. Since the tag is altered, frames of unknown type
+// flagged FrameFlagDiscardOnTagAlteration are removed from it. Merged frames
+// are marked as modified. The tag's header fields are unchanged.
+//
+// ApplyUpdate returns ErrNotUpdate, leaving the tag unchanged, if the update
+// isn't flagged as an update tag.
+func (t *Tag) ApplyUpdate(update *Tag) error {
+	if (update.Flags & TagFlagIsUpdate) == 0 {
+		return ErrNotUpdate
+	}
+	if len(update.Frames) == 0 {
+		return nil
+	}
+
+	frames := t.Frames[:0]
+	for _, f := range t.Frames {
+		_, unknown := f.(*FrameUnknown)
+		if unknown && (HeaderOf(f).Flags&FrameFlagDiscardOnTagAlteration) != 0 {
+			continue
+		}
+		frames = append(frames, f)
+	}
+	t.Frames = frames
+
+	for _, f := range update.Frames {
+		t.ReplaceFrame(f)
+	}
+	return nil
+}
+
 // WriteTo writes an ID3 tag to an output stream. It returns the number of
 // bytes written and any error encountered during encoding.
 func (t *Tag) WriteTo(w io.Writer) (int64, error) {
+	return t.WriteToWithOptions(w, nil)
+}
+
+// WriteToWithOptions writes an ID3 tag to an output stream, using the
+// requested encoding options. A nil opts selects the default options. It
+// returns the number of bytes written and any error encountered during
+// encoding.
+func (t *Tag) WriteToWithOptions(w io.Writer, opts *EncodeOptions) (int64, error) {
+	if opts == nil {
+		opts = &EncodeOptions{}
+	}
+
 	ww := newWriter(w)
 
 	// Select a codec based on the ID3 version.
@@ -117,12 +300,324 @@ func (t *Tag) WriteTo(w io.Writer) (int64, error) {
 		return 0, err
 	}
 
-	err = c.Encode(t, ww)
+	if opts.CanonicalOrder {
+		t.SortFrames()
+	}
+	if opts.Stamp != nil {
+		// Encode stamped copies of the frames, restoring the tag's own
+		// frames afterward.
+		frames := t.Frames
+		t.Frames = opts.stamp(t)
+		defer func() { t.Frames = frames }()
+		if opts.CanonicalOrder {
+			t.SortFrames()
+		}
+	}
+	opts.applyDefaults(t)
+	if opts.Padding != nil || opts.Alignment > 0 {
+		if err := t.padTag(c, opts); err != nil {
+			return 0, err
+		}
+	}
+	if opts.Verify {
+		return t.writeVerified(w, c, opts)
+	}
+	err = c.Encode(t, ww, opts)
 	return int64(ww.n), err
 }
 
+// EncodedSize returns the exact number of bytes the tag would occupy if it
+// were written with the requested encoding options, including its header,
+// extended header, footer, padding, and any expansion caused by
+// unsynchronization. A nil opts selects the default options. The tag's
+// frames are encoded one at a time and discarded, so the tag is measured
+// without being held in memory in its encoded form. The tag is left
+// unchanged, so options such as Stamp, CanonicalOrder and Alignment affect
+// only the size reported. Frames that fail to encode are reported as by
+// Tag.WriteToWithOptions.
+func (t *Tag) EncodedSize(opts *EncodeOptions) (int, error) {
+	if opts == nil {
+		opts = &EncodeOptions{}
+	}
+
+	c, err := newCodec(t.Version)
+	if err != nil {
+		return 0, err
+	}
+
+	// Measure a copy of the tag sharing its byte slices, since encoding
+	// updates the tag and its frames.
+	cp := t.copyTag(false)
+	if opts.Stamp != nil {
+		cp.Frames = opts.stamp(cp)
+	}
+	opts.applyDefaults(cp)
+	if opts.Padding != nil || opts.Alignment > 0 {
+		if err := cp.padTag(c, opts); err != nil {
+			return 0, err
+		}
+	}
+	n, _, err := cp.measure(c, opts)
+	return n, err
+}
+
+// measure returns the number of bytes the codec encodes the tag into and
+// the number of frames encoded. The headers are encoded separately from the
+// frames, which are encoded one at a time and discarded, and the size of
+// the padding, footer and unsync codes is computed without encoding them.
+func (t *Tag) measure(c versionCodec, opts *EncodeOptions) (size, count int, err error) {
+	vdata, err := versionDataOf(t.Version)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Encode the tag and extended headers, without unsynchronization.
+	frames, padding, flags := t.Frames, t.Padding, t.Flags
+	t.Frames, t.Padding, t.Flags = nil, 0, t.Flags&^TagFlagUnsync
+	o := *opts
+	o.unrestricted = true
+	buf := bytes.NewBuffer([]byte{})
+	err = c.Encode(t, newWriter(buf), &o)
+	t.Frames, t.Padding, t.Flags = frames, padding, flags|(t.Flags&TagFlagExtended)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var footer int
+	if t.Version == Version2_4 && (t.Flags&TagFlagFooter) != 0 {
+		footer = 10
+	}
+	var u unsyncCounter
+	hdr := buf.Bytes()[:buf.Len()-footer]
+	u.add(hdr[10:])
+
+	// Encode the frames one at a time.
+	size = len(hdr)
+	w := newWriter(ioutil.Discard)
+	failed, err := opts.encodeFramesTo(t, w, vdata.frameTypes, c.(frameCodec).encodeFrame, func(b []byte) {
+		frames, _ := SplitFrames(b, t.Version)
+		count += len(frames)
+		size += len(b)
+		u.add(b)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Add the padding, which must be at least large enough to hold a frame
+	// ID of zeros. Tags with a footer may not contain padding.
+	if footer > 0 {
+		t.Padding = 0
+	}
+	if t.Padding > 0 {
+		if least := minPadding(t.Version); t.Padding < least {
+			t.Padding = least
+		}
+		size += t.Padding
+		u.add([]byte{0})
+	}
+	if (t.Flags & TagFlagUnsync) != 0 {
+		size += u.codes
+	}
+	t.Size = size - 10
+	size += footer
+
+	// Enforce the tag's restrictions on the tag as measured.
+	if t.Version == Version2_4 && (t.Flags&TagFlagHasRestrictions) != 0 && !opts.unrestricted {
+		if err := Restrictions(t.Restrictions).check(t, count, size); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if len(failed) > 0 {
+		return size, count, failed
+	}
+	return size, count, nil
+}
+
+// minPadding returns the minimum size of the padding of a tag, which must
+// be large enough to hold a frame ID of zeros.
+func minPadding(v Version) int {
+	if v == Version2_2 {
+		return 3
+	}
+	return 4
+}
+
+// padTag sets the tag's padding according to the options' padding policy
+// and alignment. Without a policy, the current padding is treated as the
+// minimum.
+func (t *Tag) padTag(c versionCodec, opts *EncodeOptions) error {
+	footer := t.Version == Version2_4 && (t.Flags&TagFlagFooter) != 0
+	if footer && opts.Alignment > 0 {
+		return ErrPaddingNotAllowed
+	}
+
+	// Measure the tag without padding.
+	reserve := t.Padding
+	t.Padding = 0
+	n, _, err := t.measure(c, opts)
+	if err != nil && !isFrameErrors(err) {
+		t.Padding = reserve
+		return err
+	}
+
+	if opts.Padding != nil {
+		reserve = opts.Padding.Padding(n)
+		if reserve < 0 {
+			reserve = 0
+		}
+	}
+	if footer && reserve > 0 {
+		return ErrPaddingNotAllowed
+	}
+
+	least := minPadding(t.Version)
+	padding := reserve
+	if align := opts.Alignment; align > 0 {
+		padding = (n+reserve+align-1)/align*align - n
+		for padding > 0 && padding < least {
+			padding += align
+		}
+	} else if padding > 0 && padding < least {
+		padding = least
+	}
+	t.Padding = padding
+	return nil
+}
+
+// writeVerified encodes the tag into a buffer and verifies that it decodes
+// to the tag before writing it to an output stream.
+func (t *Tag) writeVerified(w io.Writer, c versionCodec, opts *EncodeOptions) (int64, error) {
+	buf := bytes.NewBuffer([]byte{})
+	err := c.Encode(t, newWriter(buf), opts)
+	if err != nil && !isFrameErrors(err) {
+		return 0, err
+	}
+
+	// Frames skipped or omitted by the encoder are excluded from the
+	// comparison.
+	skip := make(map[int]bool)
+	failed, _ := err.(FrameErrors)
+	for _, f := range failed {
+		skip[f.Index] = true
+	}
+	if len(opts.OmitFrames) > 0 {
+		vdata, _ := versionDataOf(t.Version)
+		for i, f := range t.Frames {
+			if opts.omitsFrame(vdata.frameTypes, f) {
+				skip[i] = true
+			}
+		}
+	}
+
+	if verr := verifyEncoding(t, buf.Bytes(), skip); verr != nil {
+		return 0, verr
+	}
+
+	n, werr := w.Write(buf.Bytes())
+	if werr != nil {
+		return int64(n), werr
+	}
+	return int64(n), err
+}
+
+// Overwrite encodes the tag in place over the previous tag prev, which
+// occupies the start of w. The tag's padding is adjusted so that the encoded
+// tag fills exactly the space occupied by the previous tag, including its
+// padding. The padding region is written according to the options'
+// PaddingFill policy; bytes that held data in the previous tag are always
+// overwritten. A nil opts selects the default options. The options' padding
+// policy and alignment are ignored.
+//
+// If the tag doesn't fit, Overwrite returns ErrTagTooLarge without writing
+// anything. If the tag fits but the remaining space can't be filled with
+// padding, because the tag has a footer or because the space is smaller
+// than the minimum padding size of 4 bytes (3 in v2.2 tags), Overwrite
+// returns ErrTagSizeMismatch without writing anything.
+func (t *Tag) Overwrite(w io.WriterAt, prev *Tag, opts *EncodeOptions) error {
+	o := EncodeOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Padding, o.Alignment = nil, 0
+	opts = &o
+
+	size := 10 + prev.Size
+	if prev.Version == Version2_4 && (prev.Flags&TagFlagFooter) != 0 {
+		size += 10
+	}
+	used := 10 + prev.Size - prev.Padding
+
+	// Measure the tag without padding.
+	padding := t.Padding
+	t.Padding = 0
+	b, err := t.encode(opts)
+	if err != nil && !isFrameErrors(err) {
+		t.Padding = padding
+		return err
+	}
+
+	footer := t.Version == Version2_4 && (t.Flags&TagFlagFooter) != 0
+
+	switch gap := size - len(b); {
+	case gap < 0:
+		t.Padding = padding
+		return ErrTagTooLarge
+	case gap > 0 && (footer || gap < minPadding(t.Version)):
+		t.Padding = padding
+		return ErrTagSizeMismatch
+	case gap > 0:
+		// Encode the tag again with enough padding to fill the space.
+		// Unsynchronization may add a byte before the padding, which is
+		// taken from the padding.
+		for _, p := range []int{gap, gap - 1} {
+			t.Padding = p
+			if b, err = t.encode(opts); err != nil && !isFrameErrors(err) {
+				t.Padding = padding
+				return err
+			}
+			if len(b) <= size {
+				break
+			}
+		}
+		if len(b) != size {
+			t.Padding = padding
+			return ErrTagSizeMismatch
+		}
+	}
+
+	// Leave the previous tag's padding untouched if requested. The padding
+	// must be written when it is covered by a CRC.
+	if opts.PaddingFill == PaddingFillPreserve && (t.Flags&TagFlagHasCRC) == 0 {
+		n := len(b) - t.Padding
+		if n < used {
+			n = used
+		}
+		b = b[:n]
+	}
+
+	if _, werr := w.WriteAt(b, 0); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// encode encodes the tag into a byte slice.
+func (t *Tag) encode(opts *EncodeOptions) ([]byte, error) {
+	buf := bytes.NewBuffer([]byte{})
+	_, err := t.WriteToWithOptions(buf, opts)
+	return buf.Bytes(), err
+}
+
+func isFrameErrors(err error) bool {
+	_, ok := err.(FrameErrors)
+	return ok
+}
+
 // FindFrame searches the tag's frames for the first frame of the requested
-// type and returns it. If no frame is found, it returns nil.
+// type and returns it. If no frame is found, it returns nil. To find a
+// frame by its frame ID, use FirstFrameByID.
 func (t *Tag) FindFrame(typ FrameType) Frame {
 	for _, f := range t.Frames {
 		if HeaderOf(f).FrameType == typ {
@@ -133,7 +628,8 @@ func (t *Tag) FindFrame(typ FrameType) Frame {
 }
 
 // FindFrames searches the Tag's frames for all frames of the requested type
-// and returns them.
+// and returns them in tag order. To find frames by their frame ID, use
+// FramesByID.
 func (t *Tag) FindFrames(typ FrameType) []Frame {
 	ff := []Frame{}
 	for _, f := range t.Frames {
@@ -144,12 +640,104 @@ func (t *Tag) FindFrames(typ FrameType) []Frame {
 	return ff
 }
 
+// FramesByType returns all the tag's frames of the requested type, in tag
+// order. It is equivalent to FindFrames.
+func (t *Tag) FramesByType(typ FrameType) []Frame {
+	return t.FindFrames(typ)
+}
+
+// FirstFrameByType returns the tag's first frame of the requested type, or
+// nil if there is none. It is equivalent to FindFrame.
+func (t *Tag) FirstFrameByType(typ FrameType) Frame {
+	return t.FindFrame(typ)
+}
+
+// FramesByID returns all the tag's frames with the requested frame ID, such
+// as "TIT2", in tag order. Frame IDs are those of the tag's version, so a
+// v2.2 tag's title frame has the ID "TT2". Frames of unknown types are
+// matched using their FrameID fields.
+func (t *Tag) FramesByID(id string) []Frame {
+	ff := []Frame{}
+	vdata, err := versionDataOf(t.Version)
+	if err != nil {
+		return ff
+	}
+	for _, f := range t.Frames {
+		if frameIDOf(vdata.frameTypes, f) == id {
+			ff = append(ff, f)
+		}
+	}
+	return ff
+}
+
+// FirstFrameByID returns the tag's first frame with the requested frame ID,
+// or nil if there is none. See FramesByID for details.
+func (t *Tag) FirstFrameByID(id string) Frame {
+	if ff := t.FramesByID(id); len(ff) > 0 {
+		return ff[0]
+	}
+	return nil
+}
+
+// frameIDOf returns the frame ID under which a frame is encoded.
+func frameIDOf(types *frameTypeMap, f Frame) string {
+	if u, ok := f.(*FrameUnknown); ok {
+		return u.FrameID
+	}
+	return types.LookupFrameID(HeaderOf(f).FrameType)
+}
+
 // RemoveFrames removes all frames of the requested type from the tag.
 func (t *Tag) RemoveFrames(typ FrameType) {
 	for i := 0; i < len(t.Frames); i++ {
 		if HeaderOf(t.Frames[i]).FrameType == typ {
-			t.Frames = append(t.Frames[:i], t.Frames[i+1:])
+			t.Frames = append(t.Frames[:i], t.Frames[i+1:]...)
 			i--
 		}
 	}
 }
+
+// SetText sets the text of the tag's first text frame of the requested
+// type, adding the frame if necessary, and marks the frame as modified. It
+// returns the frame. Text that can't be represented in ISO-8859-1 is
+// encoded in UTF-8, or in UTF-16 with a BOM in tags older than v2.4; the
+// encoding of an existing ISO-8859-1 frame is upgraded if necessary.
+func (t *Tag) SetText(typ FrameType, text ...string) *FrameText {
+	f, ok := t.FindFrame(typ).(*FrameText)
+	if ok {
+		f.Text = text
+	} else {
+		f = &FrameText{Header: FrameHeader{FrameType: typ}, Text: text}
+		t.Frames = append(t.Frames, f)
+	}
+	fitEncoding(&f.Encoding, t.Version, text...)
+	t.MarkDirty(f)
+	return f
+}
+
+// MarkDirty marks a frame of the tag as modified. Setter methods such as
+// SetText mark the frames they modify automatically; call MarkDirty after
+// modifying a frame directly.
+func (t *Tag) MarkDirty(f Frame) {
+	if t.dirty == nil {
+		t.dirty = make(map[Frame]bool)
+	}
+	t.dirty[f] = true
+}
+
+// DirtyFrames returns the tag's frames that were marked as modified since
+// the tag was decoded or ClearDirty was last called, in frame order.
+func (t *Tag) DirtyFrames() []Frame {
+	ff := []Frame{}
+	for _, f := range t.Frames {
+		if t.dirty[f] {
+			ff = append(ff, f)
+		}
+	}
+	return ff
+}
+
+// ClearDirty clears the modification marks of all the tag's frames.
+func (t *Tag) ClearDirty() {
+	t.dirty = nil
+}
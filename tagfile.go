@@ -0,0 +1,218 @@
+package id3
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+)
+
+// A TagFile is an audio file opened for editing the ID3v2 tag at its start.
+// Create one with Open, modify the tag returned by Tag, and write it back to
+// the file with Save. The audio data following the tag is preserved.
+type TagFile struct {
+	// PreserveModTime causes Save to restore the file's modification time
+	// after writing the tag.
+	PreserveModTime bool
+
+	// Progress, if non-nil, is called repeatedly while Save rewrites the
+	// file, with the number of bytes written so far and the total size of
+	// the new file. It isn't called when the tag is saved in place.
+	Progress func(written, total int64)
+
+	path     string
+	file     *os.File
+	writable bool // file was opened for writing
+	tag      *Tag
+	prev     *Tag  // header of the tag found in the file, or nil
+	audio    int64 // offset of the audio data
+}
+
+// Open opens an audio file and decodes the ID3v2 tag at its start. If the
+// file doesn't start with a tag, an empty v2.4 tag is created, and is added
+// to the file by Save. The file is opened read-only if it can't be opened
+// for writing, and its tag can then be read but not saved. Only the first of several consecutive tags is decoded; any
+// further tags are treated as audio data.
+func Open(path string) (*TagFile, error) {
+	f, writable, err := openFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tf := &TagFile{path: path, file: f, writable: writable}
+	if tf.tag, err = tf.locate(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if tf.tag == nil {
+		tf.tag = NewTag(Version2_4, 0)
+	}
+	return tf, nil
+}
+
+// locate decodes the tag at the start of the file, recording its header
+// and the offset of the audio data. It returns nil if the file has no tag.
+func (tf *TagFile) locate() (*Tag, error) {
+	tf.prev, tf.audio = nil, 0
+
+	hdr := make([]byte, 10)
+	if _, err := tf.file.ReadAt(hdr, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	_, size, err := PeekTag(hdr)
+	if err != nil {
+		return nil, nil
+	}
+	tf.audio = int64(size)
+
+	t := &Tag{}
+	if _, err := t.ReadFrom(io.NewSectionReader(tf.file, 0, tf.audio)); err != nil {
+		return nil, err
+	}
+	tf.prev = &Tag{Version: t.Version, Flags: t.Flags, Size: t.Size, Padding: t.Padding}
+	return t, nil
+}
+
+// Tag returns the file's tag. Changes to the tag are written to the file by
+// Save.
+func (tf *TagFile) Tag() *Tag {
+	return tf.tag
+}
+
+// AudioOffset returns the offset within the file of the audio data
+// following the tag, which is the size of the tag in the file, or zero if
+// the file has no tag.
+func (tf *TagFile) AudioOffset() int64 {
+	return tf.audio
+}
+
+// Save writes the tag to the file, using the default encoding options.
+func (tf *TagFile) Save() error {
+	return tf.SaveWithOptions(nil)
+}
+
+// SaveWithOptions writes the tag to the file, using the requested encoding
+// options. A nil opts selects the default options. Frames that fail to
+// encode are reported as by Tag.WriteToWithOptions.
+//
+// If the file is writable and the tag can be padded to fill the space
+// occupied by the file's previous tag, including its padding, the tag is
+// overwritten in place using Tag.Overwrite, and the audio data is left
+// untouched. The tag's padding is adjusted to fill the space, and the
+// options' padding policy and alignment are ignored. Otherwise, the new tag
+// and the audio data are written to a temporary file in the same directory,
+// which is flushed to stable storage and atomically renamed over the
+// original, so that the original file is never left partially written. The
+// file's permissions are preserved. If the file was opened read-only,
+// SaveWithOptions returns os.ErrPermission and the file is left unchanged.
+func (tf *TagFile) SaveWithOptions(opts *EncodeOptions) error {
+	if !tf.writable {
+		return os.ErrPermission
+	}
+
+	if tf.prev != nil {
+		info, err := tf.file.Stat()
+		if err != nil {
+			return err
+		}
+		err = tf.tag.Overwrite(tf.file, tf.prev, opts)
+		if err != ErrTagTooLarge && err != ErrTagSizeMismatch {
+			if err == nil || isFrameErrors(err) {
+				tf.overwritten()
+				if terr := tf.restoreModTime(info); terr != nil {
+					return terr
+				}
+			}
+			return err
+		}
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	_, ferr := tf.tag.WriteToWithOptions(buf, opts)
+	if ferr != nil && !isFrameErrors(ferr) {
+		return ferr
+	}
+
+	info, err := tf.file.Stat()
+	if err != nil {
+		return err
+	}
+	var r io.Reader = io.MultiReader(buf, io.NewSectionReader(tf.file, tf.audio, info.Size()-tf.audio))
+	if tf.Progress != nil {
+		r = &progressReader{r: r, total: int64(buf.Len()) + info.Size() - tf.audio, fn: tf.Progress}
+	}
+	if err := rewriteFile(tf.path, r); err != nil {
+		return err
+	}
+	if err := tf.reopen(); err != nil {
+		return err
+	}
+	if err := tf.restoreModTime(info); err != nil {
+		return err
+	}
+	return ferr
+}
+
+// restoreModTime restores the modification time of the file, if requested.
+func (tf *TagFile) restoreModTime(info os.FileInfo) error {
+	if !tf.PreserveModTime {
+		return nil
+	}
+	return os.Chtimes(tf.path, time.Now(), info.ModTime())
+}
+
+// A progressReader reports the progress of a file rewrite as data is read.
+type progressReader struct {
+	r     io.Reader
+	n     int64
+	total int64
+	fn    func(written, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		p.fn(p.n, p.total)
+	}
+	return n, err
+}
+
+// overwritten records the header of a tag overwritten in place, which
+// occupies the space of the previous tag.
+func (tf *TagFile) overwritten() {
+	t := tf.tag
+	size := int(tf.audio) - 10
+	if t.Version == Version2_4 && (t.Flags&TagFlagFooter) != 0 {
+		size -= 10
+	}
+	tf.prev = &Tag{Version: t.Version, Flags: t.Flags, Size: size, Padding: t.Padding}
+}
+
+// reopen reopens the file after it was replaced, and locates its new tag.
+func (tf *TagFile) reopen() error {
+	tf.file.Close()
+	f, writable, err := openFile(tf.path)
+	if err != nil {
+		return err
+	}
+	tf.file, tf.writable = f, writable
+	_, err = tf.locate()
+	return err
+}
+
+// openFile opens a file for reading and, if permitted, writing. It
+// returns true if the file was opened for writing.
+func openFile(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if os.IsPermission(err) {
+		f, err = os.Open(path)
+		return f, false, err
+	}
+	return f, err == nil, err
+}
+
+// Close closes the file. Unsaved changes to the tag are discarded.
+func (tf *TagFile) Close() error {
+	return tf.file.Close()
+}
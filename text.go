@@ -1,6 +1,7 @@
 package id3
 
 import (
+	"bytes"
 	"unicode/utf16"
 	"unicode/utf8"
 )
@@ -8,6 +9,15 @@ import (
 // An Encoding value describes the type of text encoding used on a frame's
 // strings.  Options include UTF8, UTF16, UTF16 with a BOM, and ISO 8559-1
 // (Western).
+//
+// A frame stores its text encoding once, in the byte held by the Encoding
+// field of the frame's struct, and that encoding applies to every string
+// field of the frame that follows it, including the string fields of
+// nested structs and struct slices. The following fields are exceptions
+// and are always encoded in ISO-8859-1, as the ID3 specification requires:
+// fields of type WesternString or []WesternString, Language fields, and
+// the child element IDs of table of contents frames. A frame without an
+// Encoding field stores all its strings in ISO-8859-1.
 type Encoding uint8
 
 // Possible values used to indicate the text encoding scheme.
@@ -163,3 +173,114 @@ func encodeStrings(ss []string, enc Encoding) ([]byte, error) {
 	}
 	return buf, nil
 }
+
+// Decode the next null-terminated string in the byte slice without fully
+// trusting the declared encoding. A string starting with a UTF-16 byte order
+// mark is decoded as UTF-16 regardless of the declared encoding, and a
+// string declared as UTF-16 with BOM that has neither a BOM nor the zero
+// bytes typical of UTF-16 text is decoded as an 8-bit string. Return the
+// decoded string, whether it was found to be UTF-16, and the unprocessed
+// remainder of the byte slice.
+func sniffNextString(b []byte, enc Encoding) (s string, wide bool, remain []byte, err error) {
+	var str []byte
+	switch {
+	case len(b) >= 2 && b[0] == 0xff && b[1] == 0xfe:
+		// Little-endian UTF-16. Swap it to big-endian before decoding.
+		str, remain = splitUTF16(b)
+		be := make([]byte, len(str))
+		for i := 0; i+1 < len(str); i += 2 {
+			be[i], be[i+1] = str[i+1], str[i]
+		}
+		s, err = decodeString(be, EncodingUTF16BOM)
+		return s, true, remain, err
+
+	case enc == EncodingUTF16BOM && len(b) >= 3 && b[0] == 0 && hasBOM(b[1:]):
+		// An empty 8-bit string followed by a UTF-16 string.
+		return "", false, b[1:], nil
+
+	case hasBOM(b), enc == EncodingUTF16,
+		enc == EncodingUTF16BOM && (len(b) < 2 || b[0] == 0 || b[1] == 0):
+		str, remain = splitUTF16(b)
+		s, err = decodeString(str, EncodingUTF16BOM)
+		return s, true, remain, err
+
+	default:
+		str, remain = b, []byte{}
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			str, remain = b[:i], b[i+1:]
+		}
+		if enc != EncodingISO88591 && utf8.Valid(str) {
+			return string(str), false, remain, nil
+		}
+		s, err = decodeString(str, EncodingISO88591)
+		return s, false, remain, err
+	}
+}
+
+// Return true if the byte slice starts with a UTF-16 byte order mark.
+func hasBOM(b []byte) bool {
+	return len(b) >= 2 && ((b[0] == 0xfe && b[1] == 0xff) || (b[0] == 0xff && b[1] == 0xfe))
+}
+
+// Split a byte slice at the first aligned UTF-16 null terminator. Return the
+// string data preceding the terminator and the remainder following it.
+func splitUTF16(b []byte) (str, remain []byte) {
+	for i := 0; i+1 < len(b); i += 2 {
+		if b[i] == 0 && b[i+1] == 0 {
+			return b[:i], b[i+2:]
+		}
+	}
+	return b, []byte{}
+}
+
+// repairMixedEncodings detects frames whose description and text were
+// written in different encodings despite sharing a single encoding byte, a
+// common bug in TXXX frames written by some taggers. Such frames are
+// reported with a warning. In lenient mode, each string is re-decoded
+// independently and the payload is rewritten using UTF-16 with BOM.
+func repairMixedEncodings(h *FrameHeader, r *reader, opts *DecodeOptions) {
+	var skip int
+	switch h.FrameType {
+	case FrameTypeTextCustom:
+		skip = 0
+	case FrameTypeComment, FrameTypeLyricsUnsync:
+		skip = 3 // language code
+	default:
+		return
+	}
+
+	b := r.Bytes()
+	if (h.Flags&FrameFlagEncrypted) != 0 || len(b) < 1+skip {
+		return
+	}
+
+	enc := Encoding(b[0])
+	if enc > EncodingUTF8 {
+		return
+	}
+	desc, descWide, remain, err := sniffNextString(b[1+skip:], enc)
+	if err != nil {
+		return
+	}
+	text, textWide, _, err := sniffNextString(remain, enc)
+	if err != nil || descWide == textWide {
+		return
+	}
+
+	opts.warn(h.FrameID, ErrMixedEncodings)
+	if !opts.Lenient {
+		return
+	}
+
+	d, _ := encodeString(desc, EncodingUTF16BOM)
+	t, _ := encodeString(text, EncodingUTF16BOM)
+	p := make([]byte, 0, 1+skip+len(d)+2+len(t))
+	p = append(p, byte(EncodingUTF16BOM))
+	p = append(p, b[1:1+skip]...)
+	p = append(p, d...)
+	p = append(p, null[EncodingUTF16BOM]...)
+	p = append(p, t...)
+	r.ReplaceBuffer(p)
+
+	opts.repair(h.FrameID, "strings re-decoded independently")
+}
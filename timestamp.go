@@ -0,0 +1,63 @@
+package id3
+
+import "time"
+
+// A Timestamp holds the time stored in a timestamp frame, such as the
+// recording time (TDRC) or release time (TDRL), along with its precision.
+// Components of the time finer than the precision are zero. Timestamps are
+// in UTC, as the ID3 specification requires.
+type Timestamp struct {
+	Time      time.Time
+	Precision TimePrecision
+}
+
+// ParseTimestamp parses an ID3v2.4 timestamp of the form
+// yyyy[-MM[-dd[THH[:mm[:ss]]]]], a subset of ISO 8601. Surrounding spaces
+// are ignored. It returns ErrInvalidTimestamp if the value isn't of this
+// form.
+func ParseTimestamp(s string) (Timestamp, error) {
+	t, p, ok := parseTimestamp(s)
+	if !ok {
+		return Timestamp{}, ErrInvalidTimestamp
+	}
+	return Timestamp{t, p}, nil
+}
+
+// String formats the timestamp as it is stored in a timestamp frame,
+// omitting the components finer than its precision.
+func (ts Timestamp) String() string {
+	p := ts.Precision
+	if int(p) >= len(timestampLayouts) {
+		p = PrecisionSecond
+	}
+	return ts.Time.UTC().Format(timestampLayouts[p])
+}
+
+// Before returns true if the timestamp ts sorts before u. Timestamps are
+// ordered by time; a less precise timestamp sorts before a more precise
+// timestamp with the same time, so that "2003" sorts before "2003-01".
+func (ts Timestamp) Before(u Timestamp) bool {
+	if !ts.Time.Equal(u.Time) {
+		return ts.Time.Before(u.Time)
+	}
+	return ts.Precision < u.Precision
+}
+
+// Timestamp returns the timestamp stored in the tag's first text frame of
+// the requested type, such as FrameTypeTextRecordingTime. It returns false
+// if the tag has no such frame or its value can't be parsed.
+func (t *Tag) Timestamp(typ FrameType) (Timestamp, bool) {
+	ts, err := ParseTimestamp(firstText(t, typ))
+	return ts, err == nil
+}
+
+// SetTimestamp stores a timestamp in the tag's first text frame of the
+// requested type, adding the frame if necessary. Tags older than v2.4 store
+// only the year of timestamps other than the recording time, which is
+// split into the TYER, TDAT and TIME frames when the tag is written.
+func (t *Tag) SetTimestamp(typ FrameType, ts Timestamp) {
+	if t.Version < Version2_4 && typ != FrameTypeTextRecordingTime {
+		ts.Precision = PrecisionYear
+	}
+	t.setTextValues(typ, ts.String())
+}
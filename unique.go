@@ -0,0 +1,150 @@
+package id3
+
+import (
+	"reflect"
+	"strings"
+)
+
+// uniqueFields lists, for frame types that may appear more than once in a
+// tag, the fields whose combined values must be unique among the tag's
+// frames of the type. Frame types not listed here may appear at most once,
+// except for those listed in repeatableTypes.
+var uniqueFields = map[FrameType][]string{
+	FrameTypeTextCustom:                   {"Description"},
+	FrameTypeURLArtist:                    {"URL"},
+	FrameTypeURLCommercial:                {"URL"},
+	FrameTypeURLCustom:                    {"Description"},
+	FrameTypeAttachedPicture:              {"Description"},
+	FrameTypeAudioEncryption:              {"Owner"},
+	FrameTypeChapter:                      {"ElementID"},
+	FrameTypeComment:                      {"Language", "Description"},
+	FrameTypeEncryptionMethodRegistration: {"EncryptMethod"},
+	FrameTypeEqualization2:                {"Identification"},
+	FrameTypeGeneralObject:                {"Description"},
+	FrameTypeGroupID:                      {"GroupID"},
+	FrameTypeLyricsSync:                   {"Language", "Descriptor"},
+	FrameTypeLyricsUnsync:                 {"Language", "Descriptor"},
+	FrameTypePopularimeter:                {"Email"},
+	FrameTypePrivate:                      {"Owner", "Data"},
+	FrameTypeTableOfContents:              {"ElementID"},
+	FrameTypeTermsOfUse:                   {"Language"},
+	FrameTypeUniqueFileID:                 {"Owner"},
+	FrameTypeVolumeAdjustment2:            {"Identification"},
+}
+
+// repeatableTypes lists the frame types that may appear any number of
+// times in a tag.
+var repeatableTypes = map[FrameType]bool{
+	FrameTypeSignature: true,
+	FrameTypeUnknown:   true,
+}
+
+// uniqueContentIDs lists the IDs of frames decoded as unknown frames that
+// may appear more than once in a tag, but only with different contents:
+// commercial (COMR) and linked information (LINK) frames.
+var uniqueContentIDs = map[string]bool{
+	"COMR": true,
+	"LINK": true,
+	"LNK":  true,
+}
+
+// uniqueKey returns the key identifying a frame among the frames of its
+// type. Two frames of the same type with the same key may not both appear
+// in a tag. It returns false if the frame's type may be repeated freely.
+func uniqueKey(f Frame) (string, bool) {
+	if u, ok := f.(*FrameUnknown); ok && uniqueContentIDs[u.FrameID] {
+		return u.FrameID + "\x00" + string(u.Data), true
+	}
+
+	typ := HeaderOf(f).FrameType
+	if repeatableTypes[typ] {
+		return "", false
+	}
+
+	// Only one picture of each file icon type is allowed.
+	if p, ok := f.(*FrameAttachedPicture); ok {
+		if p.PictureType == PictureTypeIcon || p.PictureType == PictureTypeIconOther {
+			return string([]byte{0, byte(p.PictureType)}), true
+		}
+	}
+
+	v := reflect.ValueOf(f).Elem()
+	var values []string
+	for _, name := range uniqueFields[typ] {
+		fv := v.FieldByName(name)
+		switch fv.Kind() {
+		case reflect.String:
+			values = append(values, fv.String())
+		case reflect.Slice:
+			values = append(values, string(fv.Bytes()))
+		default:
+			values = append(values, string([]byte{byte(fv.Uint())}))
+		}
+	}
+	return strings.Join(values, "\x00"), true
+}
+
+// conflictingFrame returns the index of the tag's frame that may not appear
+// in the tag along with frame f, or -1 if there is none.
+func (t *Tag) conflictingFrame(f Frame) int {
+	key, ok := uniqueKey(f)
+	if !ok {
+		return -1
+	}
+	typ := HeaderOf(f).FrameType
+	for i, ff := range t.Frames {
+		if ff == f || HeaderOf(ff).FrameType != typ {
+			continue
+		}
+		if k, _ := uniqueKey(ff); k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddFrame adds a frame to the end of the tag and marks it as modified. It
+// enforces the uniqueness rules of the ID3 specification: a tag may contain
+// only one frame of most types, such as a single title (TIT2) frame, while
+// frames of other types must differ in identifying fields, such as the
+// language and description of comment (COMM) frames or the owner of unique
+// file identifier (UFID) frames. If the frame would duplicate one of the
+// tag's frames, AddFrame returns ErrDuplicateFrame and leaves the tag
+// unchanged.
+func (t *Tag) AddFrame(f Frame) error {
+	if t.conflictingFrame(f) >= 0 {
+		return ErrDuplicateFrame
+	}
+	t.Frames = append(t.Frames, f)
+	t.MarkDirty(f)
+	return nil
+}
+
+// ReplaceFrame adds a frame to the tag, replacing the frame it would
+// duplicate under the uniqueness rules described by AddFrame, and marks it
+// as modified. The replaced frame's position in the tag is kept. It returns
+// the replaced frame, or nil if the frame was added to the end of the tag.
+func (t *Tag) ReplaceFrame(f Frame) Frame {
+	i := t.conflictingFrame(f)
+	if i < 0 {
+		t.Frames = append(t.Frames, f)
+		t.MarkDirty(f)
+		return nil
+	}
+	old := t.Frames[i]
+	t.Frames[i] = f
+	t.MarkDirty(f)
+	return old
+}
+
+// RemoveFrame removes a frame from the tag. It returns false if the frame
+// isn't one of the tag's frames.
+func (t *Tag) RemoveFrame(f Frame) bool {
+	for i, ff := range t.Frames {
+		if ff == f {
+			t.Frames = append(t.Frames[:i], t.Frames[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
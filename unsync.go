@@ -43,3 +43,21 @@ func removeUnsyncCodes(buf []byte) []byte {
 	}
 	return out.Bytes()
 }
+
+// An unsyncCounter counts the unsync codes addUnsyncCodes would insert into
+// a buffer whose contents are presented in pieces.
+type unsyncCounter struct {
+	prev  byte
+	codes int
+}
+
+// add counts the unsync codes inserted into the next piece of the buffer.
+func (u *unsyncCounter) add(buf []byte) {
+	for _, b := range buf {
+		if u.prev == 0xff && (b == 0 || (b&0xe0) == 0xe0) {
+			u.codes++
+			b = 0
+		}
+		u.prev = b
+	}
+}
@@ -0,0 +1,229 @@
+package id3
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CanonicalUserTextKey returns the canonical form of a user-defined text
+// (TXXX) frame description, following the conventions of common taggers
+// for namespaced keys. "replaygain_" keys are lowercase, "MusicBrainz "
+// keys use title case (e.g., "MusicBrainz Album Id"), and "ACOUSTID_" keys
+// are uppercase. Keys outside these namespaces are returned unchanged.
+func CanonicalUserTextKey(desc string) string {
+	lower := strings.ToLower(desc)
+	switch {
+	case strings.HasPrefix(lower, "replaygain_"):
+		return lower
+	case strings.HasPrefix(lower, "musicbrainz "):
+		words := strings.Fields(lower)
+		for i, w := range words {
+			words[i] = strings.ToUpper(w[:1]) + w[1:]
+		}
+		words[0] = "MusicBrainz"
+		return strings.Join(words, " ")
+	case strings.HasPrefix(lower, "acoustid_"):
+		return strings.ToUpper(desc)
+	default:
+		return desc
+	}
+}
+
+// UserTextMap returns the values of all user-defined text (TXXX) frames in
+// the tag, keyed by their canonical descriptions. Multiple values stored in
+// a single v2.4 frame, which are null-separated, are returned separately,
+// as are the values of frames sharing a description. Values of frames in
+// earlier versions are returned as stored, since a slash may be part of a
+// value such as a URL.
+func (t *Tag) UserTextMap() map[string][]string {
+	m := make(map[string][]string)
+	for _, f := range t.FindFrames(FrameTypeTextCustom) {
+		ff := f.(*FrameTextCustom)
+		key := CanonicalUserTextKey(ff.Description)
+		m[key] = append(m[key], strings.Split(ff.Text, "\x00")...)
+	}
+	return m
+}
+
+// userTextSeparator returns the separator of multiple values stored in a
+// single user-defined text frame of a tag of version v.
+func userTextSeparator(v Version) string {
+	if v >= Version2_4 {
+		return "\x00"
+	}
+	return "/"
+}
+
+// SetUserTextMap stores the values in the map as user-defined text (TXXX)
+// frames, replacing any frames whose canonical descriptions match a key in
+// the map. Keys are stored in canonical form. A key with no values removes
+// the key's frames. Multiple values for a key are stored null-separated in
+// v2.4 tags and slash-separated in earlier versions, in which UserTextMap
+// returns them as a single value.
+func (t *Tag) SetUserTextMap(m map[string][]string) {
+	values := make(map[string][]string, len(m))
+	for k, v := range m {
+		values[CanonicalUserTextKey(k)] = v
+	}
+
+	sep := userTextSeparator(t.Version)
+
+	// Replace the first frame of each key in place and remove the rest.
+	done := make(map[string]bool)
+	ff := t.Frames[:0]
+	for _, f := range t.Frames {
+		if tf, ok := f.(*FrameTextCustom); ok {
+			key := CanonicalUserTextKey(tf.Description)
+			if v, ok := values[key]; ok {
+				if done[key] || len(v) == 0 {
+					continue
+				}
+				tf.Description = key
+				tf.Text = strings.Join(v, sep)
+				done[key] = true
+			}
+		}
+		ff = append(ff, f)
+	}
+	t.Frames = ff
+
+	// Append frames for the remaining keys in sorted order.
+	keys := make([]string, 0, len(values))
+	for k, v := range values {
+		if !done[k] && len(v) > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f := NewFrameTextCustom(k, strings.Join(values[k], sep))
+		if t.Version < Version2_4 {
+			f.Encoding = EncodingUTF16BOM
+		}
+		t.Frames = append(t.Frames, f)
+	}
+}
+
+// SplitUserText splits a user-defined text (TXXX) frame whose encoded value
+// is longer than maxSize bytes into multiple continuation frames, since
+// some players truncate oversized frames. Each continuation frame holds a
+// part of the value, and its description is the original description
+// followed by " [part k/n]", where k is the part number and n is the
+// number of parts. Complete sequences of continuation frames are
+// reassembled into a single frame when a tag is read. Values are split
+// between characters, never within one.
+//
+// If the frame doesn't require splitting, SplitUserText returns a slice
+// containing only the frame.
+func SplitUserText(f *FrameTextCustom, maxSize int) []*FrameTextCustom {
+	enc := f.Encoding
+	if enc > EncodingUTF8 {
+		return []*FrameTextCustom{f}
+	}
+	if b, _ := encodeString(f.Text, enc); maxSize <= 0 || len(b) <= maxSize {
+		return []*FrameTextCustom{f}
+	}
+
+	// Accumulate characters into parts until the budget is exhausted.
+	var parts []string
+	var part []rune
+	size := 0
+	for _, c := range f.Text {
+		b, _ := encodeString(string(c), enc)
+		n := len(b)
+		if enc == EncodingUTF16BOM && len(part) > 0 {
+			n -= 2 // byte order mark
+		}
+		if size+n > maxSize && len(part) > 0 {
+			parts = append(parts, string(part))
+			part, size = nil, 0
+			b, _ = encodeString(string(c), enc)
+			n = len(b)
+		}
+		part = append(part, c)
+		size += n
+	}
+	parts = append(parts, string(part))
+
+	ff := make([]*FrameTextCustom, len(parts))
+	for i, p := range parts {
+		h := f.Header
+		h.raw = nil
+		ff[i] = &FrameTextCustom{
+			Header:      h,
+			Encoding:    enc,
+			Description: fmt.Sprintf("%s [part %d/%d]", f.Description, i+1, len(parts)),
+			Text:        p,
+		}
+	}
+	return ff
+}
+
+// splitUserText splits a frame into continuation frames if it is a
+// user-defined text frame whose encoded value is longer than maxSize bytes.
+func splitUserText(f Frame, maxSize int) []Frame {
+	tf, ok := f.(*FrameTextCustom)
+	if !ok || maxSize <= 0 {
+		return []Frame{f}
+	}
+	parts := SplitUserText(tf, maxSize)
+	ff := make([]Frame, len(parts))
+	for i := range parts {
+		ff[i] = parts[i]
+	}
+	return ff
+}
+
+// parseContinuation parses the description of a continuation frame,
+// returning the original description, the part number and the number of
+// parts.
+func parseContinuation(desc string) (base string, k, n int, ok bool) {
+	i := strings.LastIndex(desc, " [part ")
+	if i < 0 || !strings.HasSuffix(desc, "]") {
+		return "", 0, 0, false
+	}
+	_, err := fmt.Sscanf(desc[i:], " [part %d/%d]", &k, &n)
+	if err != nil || k < 1 || k > n || desc[i:] != fmt.Sprintf(" [part %d/%d]", k, n) {
+		return "", 0, 0, false
+	}
+	return desc[:i], k, n, true
+}
+
+// joinUserText reassembles complete sequences of continuation frames
+// created by SplitUserText into single user-defined text frames. The
+// reassembled frame takes the position of the sequence's first part.
+// Incomplete or out-of-order sequences are left untouched.
+func joinUserText(t *Tag) {
+	for i := 0; i < len(t.Frames); i++ {
+		first, ok := t.Frames[i].(*FrameTextCustom)
+		if !ok {
+			continue
+		}
+		base, k, n, ok := parseContinuation(first.Description)
+		if !ok || k != 1 {
+			continue
+		}
+
+		// Collect the remaining parts, which must follow in order.
+		text := first.Text
+		j := i + 1
+		for part := 2; part <= n; part, j = part+1, j+1 {
+			if j >= len(t.Frames) {
+				break
+			}
+			f, ok := t.Frames[j].(*FrameTextCustom)
+			if !ok || f.Description != fmt.Sprintf("%s [part %d/%d]", base, part, n) {
+				break
+			}
+			text += f.Text
+		}
+		if j-i != n {
+			continue
+		}
+
+		first.Description = base
+		first.Text = text
+		t.Frames = append(t.Frames[:i+1], t.Frames[j:]...)
+	}
+}
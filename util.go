@@ -101,6 +101,10 @@ func (v *valueStack) first() reflect.Value {
 	return v.stack[0]
 }
 
+func (v *valueStack) top() reflect.Value {
+	return v.stack[len(v.stack)-1]
+}
+
 func (v *valueStack) depth() int {
 	return len(v.stack)
 }
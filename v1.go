@@ -0,0 +1,463 @@
+package id3
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// V1TextOptions control how text from ID3v2 frames is fitted into the
+// fixed-width, ISO 8859-1 encoded fields of an ID3v1 tag.
+type V1TextOptions struct {
+	// Transliterate, if non-nil, is applied to the text before it is
+	// fitted. It is typically used to replace characters that cannot be
+	// represented in ISO 8859-1 (e.g., "Dvořák" -> "Dvorak").
+	Transliterate func(s string) string
+
+	// WordBoundary causes text that must be truncated to be cut at the last
+	// word boundary that fits, rather than in the middle of a word.
+	WordBoundary bool
+
+	// Extended causes V1FromV2 to store the text of titles, artists and
+	// albums longer than 30 characters in an extended ID3v1 block, split
+	// with SplitV1Text, instead of truncating it to fit the ID3v1 tag.
+	// WordBoundary doesn't apply to text split this way.
+	Extended bool
+}
+
+// FitV1Text fits a string into an ID3v1 field that is n bytes wide. Text is
+// truncated on character boundaries, so multi-byte UTF-8 sequences are never
+// split. Characters outside ISO 8859-1 that survive transliteration are
+// stored as '.'. FitV1Text returns the text that fits along with the portion
+// that was lost to truncation, if any.
+func FitV1Text(s string, n int, opts V1TextOptions) (fit, lost string) {
+	if opts.Transliterate != nil {
+		s = opts.Transliterate(s)
+	}
+
+	// Each rune occupies a single byte once encoded as ISO 8859-1.
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s, ""
+	}
+
+	cut := n
+	if opts.WordBoundary {
+		for i := n; i > 0; i-- {
+			if unicode.IsSpace(runes[i]) {
+				cut = i
+				break
+			}
+		}
+	}
+
+	fit = strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace)
+	lost = strings.TrimLeftFunc(string(runes[cut:]), unicode.IsSpace)
+	return fit, lost
+}
+
+// V1Size is the size in bytes of an ID3v1 tag.
+const V1Size = 128
+
+// V1GenreNone is the genre index of an ID3v1 tag with no genre.
+const V1GenreNone = 255
+
+// A V1Tag holds the contents of an ID3v1 or ID3v1.1 tag, stored in the last
+// 128 bytes of a file. All text is stored as ISO 8859-1, in fields of 30
+// bytes (4 for the year).
+type V1Tag struct {
+	Title   string
+	Artist  string
+	Album   string
+	Year    string
+	Comment string // up to 28 characters if Track is non-zero
+	Track   uint8  // ID3v1.1 track number, or 0 if there is none
+	Genre   uint8  // index into V1Genres, or V1GenreNone
+
+	// Extended holds the extended ID3v1 ("TAG+") block preceding the tag,
+	// or nil if there is none.
+	Extended *V1Extended
+}
+
+// ReadV1 reads the ID3v1 tag at the end of r, along with the extended
+// ID3v1 block preceding it, if any. The size of r must be known, so r must
+// provide a Size or Stat method (as *bytes.Reader, *io.SectionReader and
+// *os.File do). ReadV1 returns ErrNoV1 if r doesn't end with an ID3v1 tag.
+func ReadV1(r io.ReaderAt) (*V1Tag, error) {
+	size, ok := readerSize(r)
+	if !ok || size < V1Size {
+		return nil, ErrNoV1
+	}
+
+	b := make([]byte, V1Size)
+	if _, err := r.ReadAt(b, size-V1Size); err != nil {
+		return nil, err
+	}
+	t, err := ParseV1(b)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e, err := ReadV1Extended(r); err {
+	case nil:
+		t.Extended = e
+	case ErrNoV1Extended:
+	default:
+		return nil, err
+	}
+	return t, nil
+}
+
+// ParseV1 decodes a 128-byte ID3v1 tag. A tag whose comment field ends with
+// a zero byte followed by a non-zero byte is an ID3v1.1 tag, and the last
+// byte holds its track number. ParseV1 returns ErrNoV1 if b isn't an ID3v1
+// tag.
+func ParseV1(b []byte) (*V1Tag, error) {
+	if len(b) != V1Size || string(b[:3]) != "TAG" {
+		return nil, ErrNoV1
+	}
+
+	t := &V1Tag{
+		Title:  decodeV1String(b[3:33]),
+		Artist: decodeV1String(b[33:63]),
+		Album:  decodeV1String(b[63:93]),
+		Year:   decodeV1String(b[93:97]),
+		Genre:  b[127],
+	}
+	if b[125] == 0 && b[126] != 0 {
+		t.Comment = decodeV1String(b[97:125])
+		t.Track = b[126]
+	} else {
+		t.Comment = decodeV1String(b[97:127])
+	}
+	return t, nil
+}
+
+// GenreName returns the name of the tag's genre in the ID3v1 genre table,
+// or the empty string if the genre isn't in the table. If the tag has an
+// extended block with a free-text genre, that genre is returned instead.
+func (t *V1Tag) GenreName() string {
+	if t.Extended != nil && t.Extended.Genre != "" {
+		return t.Extended.Genre
+	}
+	if int(t.Genre) < len(V1Genres) {
+		return V1Genres[t.Genre]
+	}
+	return ""
+}
+
+// Bytes returns the encoded 128-byte ID3v1 tag, which is an ID3v1.1 tag if
+// the track number is non-zero. The extended block, if any, isn't included.
+// Text that doesn't fit in a field is truncated, and characters outside ISO
+// 8859-1 are stored as '.'.
+func (t *V1Tag) Bytes() []byte {
+	b := make([]byte, V1Size)
+	copy(b, "TAG")
+	putV1String(b[3:33], t.Title)
+	putV1String(b[33:63], t.Artist)
+	putV1String(b[63:93], t.Album)
+	putV1String(b[93:97], t.Year)
+	if t.Track != 0 {
+		putV1String(b[97:125], t.Comment)
+		b[126] = t.Track
+	} else {
+		putV1String(b[97:127], t.Comment)
+	}
+	b[127] = t.Genre
+	return b
+}
+
+// putV1String stores a string in a fixed-size ID3v1 field as ISO 8859-1,
+// truncating it if necessary.
+func putV1String(field []byte, s string) {
+	enc, _ := encodeString(s, EncodingISO88591)
+	copy(field, enc)
+}
+
+// V1FromV2 builds an ID3v1.1 tag from the frames of an ID3v2 tag. The
+// title, album, year and track number are taken from the tag's TIT2, TALB,
+// TDRC (or TYER) and TRCK frames, the artist from the lead artists of its
+// TPE1 frame, joined by slashes, and the comment from its first comment frame,
+// preferring one with an empty description. The genre is the first of the
+// tag's genres found in the ID3v1 genre table, or V1GenreNone. Text is
+// fitted into the tag's fields with FitV1Text using the options; track
+// numbers above 255 are dropped. If the Extended option is set and the
+// title, artist or album is too long for the tag, the tag gets an extended
+// block holding the rest of the text.
+func V1FromV2(t *Tag, opts V1TextOptions) *V1Tag {
+	fit := func(s string, n int) string {
+		f, _ := FitV1Text(s, n, opts)
+		return f
+	}
+
+	v1 := &V1Tag{
+		Title:  fit(t.Title(), 30),
+		Artist: fit(strings.Join(t.Artists(), "/"), 30),
+		Album:  fit(t.Album(), 30),
+		Genre:  V1GenreNone,
+	}
+	if opts.Extended {
+		v1.splitExtended(t, opts)
+	}
+	if y := t.Year(); y > 0 && y <= 9999 {
+		v1.Year = fmt.Sprintf("%04d", y)
+	}
+	if n := t.Track().Number; n > 0 && n <= 255 {
+		v1.Track = uint8(n)
+	}
+
+	var comment *FrameComment
+	for _, f := range t.FindFrames(FrameTypeComment) {
+		if c, ok := f.(*FrameComment); ok && (comment == nil || (c.Description == "" && comment.Description != "")) {
+			comment = c
+		}
+	}
+	if comment != nil {
+		n := 30
+		if v1.Track != 0 {
+			n = 28
+		}
+		v1.Comment = fit(comment.Text, n)
+	}
+
+	for _, g := range t.Genres() {
+		if i := V1GenreNumber(g); i >= 0 && i < V1GenreNone {
+			v1.Genre = uint8(i)
+			break
+		}
+	}
+	return v1
+}
+
+// splitExtended splits the title, artist and album of an ID3v2 tag between
+// the ID3v1 tag and an extended block, if any of them is too long for the
+// ID3v1 tag.
+func (v1 *V1Tag) splitExtended(t *Tag, opts V1TextOptions) {
+	text := []string{t.Title(), strings.Join(t.Artists(), "/"), t.Album()}
+	if opts.Transliterate != nil {
+		for i := range text {
+			text[i] = opts.Transliterate(text[i])
+		}
+	}
+
+	e := &V1Extended{}
+	v1.Title, e.Title = SplitV1Text(text[0])
+	v1.Artist, e.Artist = SplitV1Text(text[1])
+	v1.Album, e.Album = SplitV1Text(text[2])
+	if e.Title != "" || e.Artist != "" || e.Album != "" {
+		v1.Extended = e
+	}
+}
+
+// SyncV1FromV2 stores an ID3v1 tag built from the frames of an ID3v2 tag,
+// as described by V1FromV2, at the end of a file. An existing ID3v1 tag is
+// overwritten; otherwise the tag is appended to the file. If the new tag has
+// an extended block, the block is stored before it, replacing any existing
+// block. An existing block is otherwise removed, since its text would no
+// longer match the new tag.
+func SyncV1FromV2(path string, t *Tag, opts V1TextOptions) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	end := info.Size()
+	if _, err := ReadV1(f); err == nil {
+		end -= V1Size
+		if _, err := ReadV1Extended(f); err == nil {
+			end -= V1ExtendedSize
+		}
+	} else if err != ErrNoV1 {
+		return err
+	}
+
+	v1 := V1FromV2(t, opts)
+	b := v1.Bytes()
+	if v1.Extended != nil {
+		b = append(v1.Extended.Bytes(), b...)
+	}
+	if _, err := f.WriteAt(b, end); err != nil {
+		return err
+	}
+	if end+int64(len(b)) < info.Size() {
+		return f.Truncate(end + int64(len(b)))
+	}
+	return nil
+}
+
+// NewTagFromV1 creates an ID3v2 tag of version v holding the contents of an
+// ID3v1 tag. The title, artist, album, year and track number are stored in
+// the TIT2, TPE1, TALB, TDRC (or TYER) and TRCK frames, and the comment in
+// a comment frame with an empty description. The genre index is mapped to
+// its name through the ID3v1 genre table and stored in the TCON frame;
+// unknown genres are dropped. Text continued in an extended block is
+// reassembled, and the block's free-text genre, if any, replaces the
+// genre index. Empty fields and years that aren't numeric are omitted.
+// NewTagFromV1 returns ErrInvalidVersion if the version is invalid.
+func NewTagFromV1(v1 *V1Tag, v Version) (*Tag, error) {
+	if _, err := versionDataOf(v); err != nil {
+		return nil, err
+	}
+
+	title, artist, album := v1.Title, v1.Artist, v1.Album
+	if e := v1.Extended; e != nil {
+		title = JoinV1Text(title, e.Title)
+		artist = JoinV1Text(artist, e.Artist)
+		album = JoinV1Text(album, e.Album)
+	}
+
+	t := NewTag(v, 0)
+	if title != "" {
+		t.SetTitle(title)
+	}
+	if artist != "" {
+		t.SetArtist(artist)
+	}
+	if album != "" {
+		t.SetAlbum(album)
+	}
+	if y, err := strconv.Atoi(strings.TrimSpace(v1.Year)); err == nil && y > 0 {
+		t.SetYear(y)
+	}
+	if v1.Track != 0 {
+		t.SetTrack(TrackNumber{Number: int(v1.Track)})
+	}
+	if g := v1.GenreName(); g != "" {
+		t.SetGenres(g)
+	}
+	if v1.Comment != "" {
+		t.SetComment("", v1.Comment)
+	}
+	return t, nil
+}
+
+// V1ExtendedSize is the size in bytes of an extended ID3v1 ("TAG+") block.
+const V1ExtendedSize = 227
+
+// A V1Extended holds the extended ID3v1 ("TAG+") block written by some
+// legacy rippers immediately before the ID3v1 tag at the end of a file. Its
+// title, artist and album fields hold the text that didn't fit into the
+// 30-byte fields of the ID3v1 tag; use JoinV1Text to reassemble the full
+// text. All text is stored as ISO 8859-1.
+type V1Extended struct {
+	Title     string  // up to 60 characters following the ID3v1 title
+	Artist    string  // up to 60 characters following the ID3v1 artist
+	Album     string  // up to 60 characters following the ID3v1 album
+	Speed     V1Speed // speed of the music
+	Genre     string  // free-text genre, up to 30 characters
+	StartTime string  // start of the music, as "mmm:ss"
+	EndTime   string  // end of the music, as "mmm:ss"
+}
+
+// V1Speed describes the speed of the music in an extended ID3v1 block.
+type V1Speed uint8
+
+// All possible V1Speed values.
+const (
+	V1SpeedUnset V1Speed = iota
+	V1SpeedSlow
+	V1SpeedMedium
+	V1SpeedFast
+	V1SpeedHardcore
+)
+
+// v1ExtendedFields lists the offsets and sizes of the text fields of an
+// extended ID3v1 block.
+var v1ExtendedFields = []struct {
+	offset, size int
+	field        func(e *V1Extended) *string
+}{
+	{4, 60, func(e *V1Extended) *string { return &e.Title }},
+	{64, 60, func(e *V1Extended) *string { return &e.Artist }},
+	{124, 60, func(e *V1Extended) *string { return &e.Album }},
+	{185, 30, func(e *V1Extended) *string { return &e.Genre }},
+	{215, 6, func(e *V1Extended) *string { return &e.StartTime }},
+	{221, 6, func(e *V1Extended) *string { return &e.EndTime }},
+}
+
+// ReadV1Extended reads the extended ID3v1 block stored before the ID3v1
+// tag at the end of r. The size of r must be known, so r must provide a
+// Size or Stat method (as *bytes.Reader, *io.SectionReader and *os.File
+// do). ReadV1Extended returns ErrNoV1Extended if r doesn't end with an
+// extended block followed by an ID3v1 tag.
+func ReadV1Extended(r io.ReaderAt) (*V1Extended, error) {
+	size, ok := readerSize(r)
+	if !ok || size < V1ExtendedSize+128 {
+		return nil, ErrNoV1Extended
+	}
+
+	b := make([]byte, V1ExtendedSize+3)
+	if _, err := r.ReadAt(b, size-V1ExtendedSize-128); err != nil {
+		return nil, err
+	}
+	if string(b[:4]) != "TAG+" || string(b[V1ExtendedSize:]) != "TAG" {
+		return nil, ErrNoV1Extended
+	}
+
+	e := &V1Extended{Speed: V1Speed(b[184])}
+	for _, f := range v1ExtendedFields {
+		*f.field(e) = decodeV1String(b[f.offset : f.offset+f.size])
+	}
+	return e, nil
+}
+
+// Bytes returns the encoded extended ID3v1 block. Text that doesn't fit in
+// a field is truncated, and characters outside ISO 8859-1 are stored as
+// '.'.
+func (e *V1Extended) Bytes() []byte {
+	b := make([]byte, V1ExtendedSize)
+	copy(b, "TAG+")
+	for _, f := range v1ExtendedFields {
+		enc, _ := encodeString(*f.field(e), EncodingISO88591)
+		copy(b[f.offset:f.offset+f.size], enc)
+	}
+	b[184] = byte(e.Speed)
+	return b
+}
+
+// decodeV1String decodes an ISO 8859-1 string stored in a fixed-size ID3v1
+// field, removing the zeros and spaces padding it.
+func decodeV1String(b []byte) string {
+	if i := strings.IndexByte(string(b), 0); i >= 0 {
+		b = b[:i]
+	}
+	s, _ := decodeString(b, EncodingISO88591)
+	return strings.TrimRight(s, " ")
+}
+
+// SplitV1Text splits text into the part stored by a 30-byte ID3v1 field and
+// the part stored by the matching 60-byte field of an extended ID3v1 block.
+// Text beyond 90 characters is dropped.
+func SplitV1Text(s string) (v1, extended string) {
+	runes := []rune(s)
+	switch {
+	case len(runes) <= 30:
+		return s, ""
+	case len(runes) <= 90:
+		return string(runes[:30]), string(runes[30:])
+	default:
+		return string(runes[:30]), string(runes[30:90])
+	}
+}
+
+// JoinV1Text reassembles text split by SplitV1Text between an ID3v1 field
+// and an extended ID3v1 field. Spaces at the end of the ID3v1 field, which
+// are removed when the field is read, are restored.
+func JoinV1Text(v1, extended string) string {
+	if extended == "" {
+		return v1
+	}
+	if n := len([]rune(v1)); n < 30 {
+		v1 += strings.Repeat(" ", 30-n)
+	}
+	return v1 + extended
+}
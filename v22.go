@@ -1,16 +1,297 @@
 package id3
 
+import (
+	"bytes"
+	"sync"
+)
+
+var (
+	v22Data     *versionData
+	v22DataInit sync.Once
+)
+
 type codec22 struct {
+	vdata *versionData
 }
 
 func newCodec22() *codec22 {
-	return &codec22{}
+	v22DataInit.Do(func() {
+		v22Data = &versionData{
+			headerFlags: flagMap{
+				{1 << 7, uint32(TagFlagUnsync)},
+			},
+			bounds: boundsMap{
+				"Encoding":         {0, 1, ErrInvalidEncoding},
+				"LyricContentType": {0, 6, ErrInvalidLyricContentType},
+				"PictureType":      {0, 20, ErrInvalidPictureType},
+				"TimeStampFormat":  {1, 2, ErrInvalidTimeStampFormat},
+			},
+			frameTypes: newFrameTypeMap(Version2_2),
+		}
+	})
+
+	return &codec22{vdata: v22Data}
 }
 
-func (c *codec22) Decode(t *Tag, r *reader) error {
-	return errUnimplemented
+func (c *codec22) Decode(t *Tag, r *reader, opts *DecodeOptions) error {
+	// Load the remaining six bytes of the tag header.
+	if r.Load(6); r.err != nil {
+		return r.err
+	}
+
+	// Decode the header.
+	hdr := r.ConsumeBytes(10)
+	if hdr[4] != 0 {
+		return ErrInvalidTag
+	}
+
+	// Process tag header flags. No compression scheme was ever defined for
+	// v2.2, so tags with the compression flag set can't be decoded.
+	flags := uint32(hdr[5])
+	if (flags & (1 << 6)) != 0 {
+		return ErrInvalidHeaderFlags
+	}
+	t.Flags = TagFlags(c.vdata.headerFlags.Decode(flags))
+
+	// Process tag size.
+	size, err := decodeSyncSafeUint32(hdr[6:10])
+	if err != nil {
+		return err
+	}
+	t.Size = int(size)
+
+	// Load the rest of the tag into the reader's buffer.
+	truncated, err := opts.loadTag(r, t)
+	if err != nil {
+		return err
+	}
+
+	// Remove unsync codes.
+	if (t.Flags & TagFlagUnsync) != 0 {
+		newBuf := removeUnsyncCodes(r.ConsumeAll())
+		r.ReplaceBuffer(newBuf)
+	}
+
+	// Decode the tag's frames until tag data is exhausted or padding is
+	// encountered.
+	for r.Len() > 0 {
+		var f Frame
+		offset, b := 10+t.Size-r.Len(), r.Bytes()
+		err = c.decodeFrame(t, &f, r, opts)
+
+		if err == errPaddingEncountered {
+			// In lenient mode, recover frames that buggy writers store
+			// after a run of padding.
+			if i := c.vdata.frameTypes.FrameAfterPadding(r.Bytes(), 3); opts.Lenient && i >= 0 {
+				opts.repair("", "recovered frames stored after padding")
+				r.ConsumeBytes(i)
+				continue
+			}
+
+			t.Padding = r.Len() + 3
+			r.ConsumeAll()
+			break
+		}
+
+		// In lenient mode, drop a frame cut short by the end of a
+		// truncated tag.
+		if err != nil && truncated {
+			opts.repair("", "dropped frame cut short by the end of the tag")
+			r.ConsumeAll()
+			break
+		}
+
+		if err != nil {
+			opts.fail(offset, b, 3, err)
+			return err
+		}
+
+		opts.capture(f, Version2_2, b[:len(b)-r.Len()])
+		opts.deferPicture(f, r.Len())
+		t.Frames = append(t.Frames, f)
+	}
+
+	return nil
 }
 
-func (c *codec22) Encode(t *Tag, w *writer) error {
-	return errUnimplemented
+func (c *codec22) decodeFrame(t *Tag, f *Frame, r *reader, opts *DecodeOptions) error {
+	// Read the first three bytes of the frame header data to see if it's
+	// padding.
+	id := r.ConsumeBytes(3)
+	if r.err != nil {
+		return r.err
+	}
+	if id[0] == 0 && id[1] == 0 && id[2] == 0 {
+		return errPaddingEncountered
+	}
+
+	// Read the remaining 3 bytes of the header data, which hold the frame's
+	// payload size. v2.2 frame headers have no flags.
+	hd := r.ConsumeBytes(3)
+	if r.err != nil {
+		return r.err
+	}
+	size := uint32(hd[0])<<16 | uint32(hd[1])<<8 | uint32(hd[2])
+	if size < 1 {
+		return ErrInvalidFrameHeader
+	}
+
+	// Repair malformed frame IDs in lenient mode.
+	frameID := string(id)
+	if opts.Lenient {
+		if _, ok := c.vdata.frameTypes.FrameIDToFrameType[frameID]; !ok {
+			if fixed, ok := c.vdata.frameTypes.RepairFrameID(frameID); ok {
+				opts.repair(frameID, "frame ID repaired to "+fixed)
+				frameID = fixed
+			}
+		}
+	}
+
+	// Start bulding the frame header.
+	h := FrameHeader{
+		FrameID: frameID,
+		Size:    int(size),
+	}
+
+	// Consume the rest of the frame into a new reader.
+	fr := r.ConsumeIntoNewReader(h.Size)
+	if r.err != nil {
+		return r.err
+	}
+	r = fr
+
+	// A PIC frame stores a fixed-length 3-character image format where
+	// later versions store a null-terminated MIME type. Replace the format
+	// with the equivalent MIME type so the payload can be scanned like an
+	// APIC frame.
+	if h.FrameID == "PIC" {
+		b := r.ConsumeAll()
+		if len(b) < 4 {
+			return ErrInvalidFrame
+		}
+		mimeType := PictureFormatMimeType(string(b[1:4]))
+		p := make([]byte, 0, len(b)+len(mimeType)+1)
+		p = append(p, b[0])
+		p = append(p, mimeType...)
+		p = append(p, 0)
+		p = append(p, b[4:]...)
+		r.ReplaceBuffer(p)
+	}
+
+	// Look up the frame type.
+	h.FrameType = c.vdata.frameTypes.LookupFrameType(h.FrameID)
+	if h.FrameType == FrameTypeUnknown {
+		opts.unknown(h.FrameID)
+	}
+
+	// Detect and repair strings written with inconsistent encodings.
+	repairMixedEncodings(&h, r, opts)
+
+	// Use a reflector to scan the frame's fields.
+	rf := newReflector(Version2_2, c.vdata)
+	var err error
+	*f, err = rf.ScanFrame(r, h.FrameID)
+	if err != nil {
+		return err
+	}
+
+	// Copy the header into the frame.
+	rf.SetFrameHeader(*f, &h)
+	return nil
+}
+
+func (c *codec22) Encode(t *Tag, w *writer, opts *EncodeOptions) error {
+	// Encode the header, leaving a placeholder for the size.
+	flags := uint8(c.vdata.headerFlags.Encode(uint32(t.Flags)))
+	hdr := []byte{'I', 'D', '3', 2, 0, flags, 0, 0, 0, 0}
+	w.StoreBytes(hdr)
+	sizeOffset := 6
+
+	// Encode the frames.
+	failed, err := opts.encodeFrames(t, w, c.vdata.frameTypes, c.encodeFrame)
+	if err != nil {
+		return err
+	}
+
+	// Add padding.
+	if t.Padding > 0 {
+		if t.Padding < 3 {
+			t.Padding = 3 // must be at least 3 bytes.
+		}
+		w.StoreBytes(make([]byte, t.Padding))
+	}
+
+	// Unsynchronize.
+	if (t.Flags & TagFlagUnsync) != 0 {
+		b := addUnsyncCodes(w.ConsumeBytesFromOffset(10))
+		w.StoreBytes(b)
+	}
+
+	// Update the tag header's size.
+	t.Size = w.Len() - len(hdr)
+	sizeBuf := w.SliceBuffer(sizeOffset, 4)
+	encodeSyncSafeUint32(sizeBuf, uint32(t.Size))
+
+	// Save writer's buffer to the output stream, then report any frames
+	// that were skipped.
+	if _, err = w.Save(); err == nil && len(failed) > 0 {
+		err = failed
+	}
+	return err
+}
+
+func (c *codec22) encodeFrame(t *Tag, f Frame, w *writer) error {
+	// Retrieve the frame's header. v2.2 frames have no flags, so frames
+	// requiring compression, encryption or grouping can't be represented.
+	h := HeaderOf(f)
+	if h.Flags != 0 {
+		return ErrInvalidFrameFlags
+	}
+	if _, ok := c.vdata.frameTypes.FrameTypeToFrameID[h.FrameType]; !ok {
+		return ErrUnsupportedFrameType
+	}
+
+	// Store placeholders for the frame ID and size.
+	idOffset := w.Len()
+	w.StoreBytes([]byte{0, 0, 0, 0, 0, 0})
+
+	// Use a reflector to output the frame's fields.
+	payloadOffset := w.Len()
+	rf := newReflector(Version2_2, c.vdata)
+	frameID, err := rf.OutputFrame(w, f)
+	if err != nil {
+		return err
+	}
+
+	// A PIC frame stores a fixed-length 3-character image format in place
+	// of the null-terminated MIME type output for APIC frames. Replace the
+	// MIME type with the equivalent format.
+	if frameID == "PIC" {
+		p := w.ConsumeBytesFromOffset(payloadOffset)
+		n := bytes.IndexByte(p[1:], 0)
+		if n < 0 {
+			return ErrInvalidFrame
+		}
+		format, ok := MimeTypePictureFormat(string(p[1 : 1+n]))
+		if !ok {
+			return ErrInvalidFrame
+		}
+		w.StoreBytes(p[:1])
+		w.StoreBytes([]byte(format))
+		w.StoreBytes(p[2+n:])
+	}
+
+	// Update the header frame ID.
+	h.FrameID = frameID
+	copy(w.SliceBuffer(idOffset, 3), []byte(h.FrameID))
+
+	// Update the header frame size.
+	h.Size = w.Len() - payloadOffset
+	if h.Size > 0xffffff {
+		return ErrInvalidFrame
+	}
+	b := w.SliceBuffer(idOffset+3, 3)
+	b[0], b[1], b[2] = byte(h.Size>>16), byte(h.Size>>8), byte(h.Size)
+
+	return w.err
 }
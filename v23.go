@@ -41,80 +41,14 @@ func newCodec23() *codec23 {
 				"PictureType":      {0, 20, ErrInvalidPictureType},
 				"TimeStampFormat":  {1, 2, ErrInvalidTimeStampFormat},
 			},
-			frameTypes: newFrameTypeMap(map[FrameType]string{
-				FrameTypeAttachedPicture:              "APIC",
-				FrameTypeAudioEncryption:              "AENC",
-				FrameTypeComment:                      "COMM",
-				FrameTypeEncryptionMethodRegistration: "ENCR",
-				FrameTypeGroupID:                      "GRID",
-				FrameTypePlayCount:                    "PCNT",
-				FrameTypePopularimeter:                "POPM",
-				FrameTypePrivate:                      "PRIV",
-				FrameTypeLyricsSync:                   "SYLT",
-				FrameTypeSyncTempoCodes:               "SYTC",
-				FrameTypeTextAlbumName:                "TALB",
-				FrameTypeTextBPM:                      "TBPM",
-				FrameTypeTextCompilationItunes:        "TCMP",
-				FrameTypeTextComposer:                 "TCOM",
-				FrameTypeTextGenre:                    "TCON",
-				FrameTypeTextCopyright:                "TCOP",
-				FrameTypeTextDate:                     "TDAT",
-				FrameTypeTextPlaylistDelay:            "TDLY",
-				FrameTypeTextEncodedBy:                "TENC",
-				FrameTypeTextLyricist:                 "TEXT",
-				FrameTypeTextFileType:                 "TFLT",
-				FrameTypeTextTime:                     "TIME",
-				FrameTypeTextGroupDescription:         "TIT1",
-				FrameTypeTextSongTitle:                "TIT2",
-				FrameTypeTextSongSubtitle:             "TIT3",
-				FrameTypeTextMusicalKey:               "TKEY",
-				FrameTypeTextLanguage:                 "TLAN",
-				FrameTypeTextLengthInMs:               "TLEN",
-				FrameTypeTextMediaType:                "TMED",
-				FrameTypeTextOriginalAlbum:            "TOAL",
-				FrameTypeTextOriginalFileName:         "TOFN",
-				FrameTypeTextOriginalLyricist:         "TOLY",
-				FrameTypeTextOriginalPerformer:        "TOPE",
-				FrameTypeTextOriginalReleaseTime:      "TORY",
-				FrameTypeTextOwner:                    "TOWN",
-				FrameTypeTextArtist:                   "TPE1",
-				FrameTypeTextAlbumArtist:              "TPE2",
-				FrameTypeTextConductor:                "TPE3",
-				FrameTypeTextRemixer:                  "TPE4",
-				FrameTypeTextPartOfSet:                "TPOS",
-				FrameTypeTextPublisher:                "TPUB",
-				FrameTypeTextTrackNumber:              "TRCK",
-				FrameTypeTextRecordingDates:           "TRDA",
-				FrameTypeTextRadioStation:             "TRSN",
-				FrameTypeTextRadioStationOwner:        "TRSO",
-				FrameTypeTextSize:                     "TSIZ",
-				FrameTypeTextAlbumSortOrderItunes:     "TSO2",
-				FrameTypeTextComposerSortOrderItunes:  "TSOC",
-				FrameTypeTextISRC:                     "TSRC",
-				FrameTypeTextEncodingSoftware:         "TSSE",
-				FrameTypeTextRecordingTime:            "TYER",
-				FrameTypeTextCustom:                   "TXXX",
-				FrameTypeUniqueFileID:                 "UFID",
-				FrameTypeTermsOfUse:                   "USER",
-				FrameTypeLyricsUnsync:                 "USLT",
-				FrameTypeURLCommercial:                "WCOM",
-				FrameTypeURLCopyright:                 "WCOP",
-				FrameTypeURLAudioFile:                 "WOAF",
-				FrameTypeURLArtist:                    "WOAR",
-				FrameTypeURLAudioSource:               "WOAS",
-				FrameTypeURLRadioStation:              "WORS",
-				FrameTypeURLPayment:                   "WPAY",
-				FrameTypeURLPublisher:                 "WPUB",
-				FrameTypeURLCustom:                    "WXXX",
-				FrameTypeUnknown:                      "ZZZZ",
-			}),
+			frameTypes: newFrameTypeMap(Version2_3),
 		}
 	})
 
 	return &codec23{vdata: v23Data}
 }
 
-func (c *codec23) Decode(t *Tag, r *reader) error {
+func (c *codec23) Decode(t *Tag, r *reader, opts *DecodeOptions) error {
 	// Load the remaining six bytes of the tag header.
 	if r.Load(6); r.err != nil {
 		return r.err
@@ -138,8 +72,9 @@ func (c *codec23) Decode(t *Tag, r *reader) error {
 	t.Size = int(size)
 
 	// Load the rest of the tag into the reader's buffer.
-	if r.Load(t.Size); r.err != nil {
-		return r.err
+	truncated, err := opts.loadTag(r, t)
+	if err != nil {
+		return err
 	}
 
 	// Remove unsync codes.
@@ -148,37 +83,56 @@ func (c *codec23) Decode(t *Tag, r *reader) error {
 		r.ReplaceBuffer(newBuf)
 	}
 
-	// Decode the extended header.
+	// Decode the extended header. Its size excludes the size field itself
+	// and is either 6 or 10 bytes, depending on whether a CRC is present.
+	paddingSize := 0
+	var exHdr []byte
+	t.ExtendedUnknown = nil
 	if (t.Flags & TagFlagExtended) != 0 {
-		exSize := decodeUint32(r.ConsumeBytes(4))
+		exStart := r.Bytes()
+		exSize := int(decodeUint32(r.ConsumeBytes(4)))
+		if r.err == nil && exSize < 6 {
+			return ErrInvalidHeader
+		}
+		ex := r.ConsumeIntoNewReader(exSize)
+		if r.err != nil {
+			return r.err
+		}
 
 		// Decode the extended header flags.
-		exFlags := r.ConsumeBytes(2)
+		exFlags := ex.ConsumeBytes(2)
 		t.Flags = TagFlags(uint32(t.Flags) | c.vdata.headerExFlags.Decode(uint32(exFlags[0])<<8))
 
-		// Consume the rest of the extended header data.
-		exBytesConsumed := 6
+		// Decode the size of the padding, which the CRC excludes.
+		paddingSize = int(decodeUint32(ex.ConsumeBytes(4)))
 
 		if (t.Flags & TagFlagHasCRC) != 0 {
-			t.CRC = decodeUint32(r.ConsumeBytes(4))
-			exBytesConsumed += 4
+			t.CRC = decodeUint32(ex.ConsumeBytes(4))
 		}
 
-		// Consume and ignore any remaining bytes in the extended header.
-		if exBytesConsumed < int(exSize) {
-			r.ConsumeBytes(int(exSize) - exBytesConsumed)
+		// Retain any remaining bytes in the extended header.
+		if ex.err != nil {
+			return ex.err
+		}
+		if ex.Len() > 0 {
+			t.ExtendedUnknown = append([]byte{}, ex.ConsumeAll()...)
 		}
 
-		if r.err != nil {
-			return r.err
+		// Keep a copy of the extended header, without its CRC, for CRC
+		// compatibility checks.
+		exHdr = append([]byte{}, exStart[:len(exStart)-r.Len()]...)
+		if (t.Flags & TagFlagHasCRC) != 0 {
+			copy(exHdr[10:14], make([]byte, 4))
 		}
 	}
 
-	// Validate the CRC.
-	if (t.Flags & TagFlagHasCRC) != 0 {
-		crc := crc32.ChecksumIEEE(r.Bytes())
-		if crc != t.CRC {
-			return ErrFailedCRC
+	// Validate the CRC, which covers only the frames.
+	if (t.Flags&TagFlagHasCRC) != 0 && !truncated {
+		if paddingSize > r.Len() {
+			return ErrInvalidHeader
+		}
+		if err := opts.checkCRC(t.CRC, CRCFrames, exHdr, r.Bytes(), paddingSize); err != nil {
+			return err
 		}
 	}
 
@@ -186,25 +140,45 @@ func (c *codec23) Decode(t *Tag, r *reader) error {
 	// encountered.
 	for r.Len() > 0 {
 		var f Frame
-		err = c.decodeFrame(t, &f, r)
+		offset, b := 10+t.Size-r.Len(), r.Bytes()
+		err = c.decodeFrame(t, &f, r, opts)
 
 		if err == errPaddingEncountered {
+			// In lenient mode, recover frames that buggy writers store
+			// after a run of padding.
+			if i := c.vdata.frameTypes.FrameAfterPadding(r.Bytes(), 4); opts.Lenient && i >= 0 {
+				opts.repair("", "recovered frames stored after padding")
+				r.ConsumeBytes(i)
+				continue
+			}
+
 			t.Padding = r.Len() + 4
 			r.ConsumeAll()
 			break
 		}
 
+		// In lenient mode, drop a frame cut short by the end of a
+		// truncated tag.
+		if err != nil && truncated {
+			opts.repair("", "dropped frame cut short by the end of the tag")
+			r.ConsumeAll()
+			break
+		}
+
 		if err != nil {
+			opts.fail(offset, b, 4, err)
 			return err
 		}
 
+		opts.capture(f, Version2_3, b[:len(b)-r.Len()])
+		opts.deferPicture(f, r.Len())
 		t.Frames = append(t.Frames, f)
 	}
 
 	return nil
 }
 
-func (c *codec23) decodeFrame(t *Tag, f *Frame, r *reader) error {
+func (c *codec23) decodeFrame(t *Tag, f *Frame, r *reader, opts *DecodeOptions) error {
 	// Read the first four bytes of the frame header data to see if it's
 	// padding.
 	id := r.ConsumeBytes(4)
@@ -230,9 +204,20 @@ func (c *codec23) decodeFrame(t *Tag, f *Frame, r *reader) error {
 	// Decode the frame flags.
 	flags := c.vdata.frameFlags.Decode(uint32(hd[4])<<8 | uint32(hd[5]))
 
+	// Repair malformed frame IDs in lenient mode.
+	frameID := string(id)
+	if opts.Lenient {
+		if _, ok := c.vdata.frameTypes.FrameIDToFrameType[frameID]; !ok {
+			if fixed, ok := c.vdata.frameTypes.RepairFrameID(frameID); ok {
+				opts.repair(frameID, "frame ID repaired to "+fixed)
+				frameID = fixed
+			}
+		}
+	}
+
 	// Start bulding the frame header.
 	h := FrameHeader{
-		FrameID: string(id),
+		FrameID: frameID,
 		Size:    int(size),
 		Flags:   FrameFlags(flags),
 	}
@@ -267,6 +252,24 @@ func (c *codec23) decodeFrame(t *Tag, f *Frame, r *reader) error {
 		}
 	}
 
+	// Decrypt and decompress the payload and validate its data length.
+	// Frames that can't be decrypted are decoded as opaque unknown frames.
+	if err := unpackFrameData(t, &h, r, opts); err == errUndecryptable {
+		*f = opaqueFrame(&h, r)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Look up the frame type.
+	h.FrameType = c.vdata.frameTypes.LookupFrameType(h.FrameID)
+	if h.FrameType == FrameTypeUnknown {
+		opts.unknown(h.FrameID)
+	}
+
+	// Detect and repair strings written with inconsistent encodings.
+	repairMixedEncodings(&h, r, opts)
+
 	// Use a reflector to scan the frame's fields.
 	rf := newReflector(Version2_3, c.vdata)
 	var err error
@@ -277,11 +280,27 @@ func (c *codec23) decodeFrame(t *Tag, f *Frame, r *reader) error {
 
 	// Copy the header into the frame.
 	rf.SetFrameHeader(*f, &h)
+
+	// Decode the subframes embedded in the remainder of the payload.
+	if sf := subframesOf(*f); sf != nil {
+		for r.Len() > 0 {
+			var s Frame
+			err = c.decodeFrame(t, &s, r, opts)
+			if err == errPaddingEncountered {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			*sf = append(*sf, s)
+		}
+	}
 	return nil
 }
 
-func (c *codec23) Encode(t *Tag, w *writer) error {
-	if (t.Flags & TagFlagHasCRC) != 0 {
+func (c *codec23) Encode(t *Tag, w *writer, opts *EncodeOptions) error {
+	exUnknown := opts.extendedUnknown(t)
+	if (t.Flags&TagFlagHasCRC) != 0 || len(exUnknown) > 0 {
 		t.Flags |= TagFlagExtended
 	}
 
@@ -291,32 +310,37 @@ func (c *codec23) Encode(t *Tag, w *writer) error {
 	w.StoreBytes(hdr)
 	sizeOffset := 6
 
-	// Store the extended tag header.
-	crcOffset := -1
+	// Store the extended tag header, with placeholders for the padding size
+	// and CRC. The extended header's size excludes the size field itself.
+	paddingOffset, crcOffset := -1, -1
 	if (t.Flags & TagFlagExtended) != 0 {
 		exFlags := uint16(c.vdata.headerExFlags.Encode(uint32(t.Flags)))
 
-		// Store the first 6 bytes of the extended tag header, with a
-		// placeholder for the extended header's size.
-		exHdrOffset := w.Len()
-		w.StoreBytes([]byte{0, 0, 0, 0, byte(exFlags >> 8), 0})
+		exSize := 6 + len(exUnknown)
+		if (t.Flags & TagFlagHasCRC) != 0 {
+			exSize += 4
+		}
+		sizeBuf := make([]byte, 4)
+		encodeUint32(sizeBuf, uint32(exSize))
+		w.StoreBytes(sizeBuf)
+		w.StoreBytes([]byte{byte(exFlags >> 8), 0})
+
+		paddingOffset = w.Len()
+		w.StoreBytes([]byte{0, 0, 0, 0})
 
 		if (t.Flags & TagFlagHasCRC) != 0 {
 			crcOffset = w.Len()
 			w.StoreBytes([]byte{0, 0, 0, 0})
 		}
 
-		// Update the extended header size.
-		exSize := w.Len() - exHdrOffset
-		encodeUint32(w.SliceBuffer(exHdrOffset, 4), uint32(exSize))
+		w.StoreBytes(exUnknown)
 	}
 
 	// Encode the frames.
 	framesOffset := w.Len()
-	for _, f := range t.Frames {
-		if err := c.encodeFrame(t, f, w); err != nil {
-			return err
-		}
+	failed, err := opts.encodeFrames(t, w, c.vdata.frameTypes, c.encodeFrame)
+	if err != nil {
+		return err
 	}
 
 	// Add padding.
@@ -327,10 +351,15 @@ func (c *codec23) Encode(t *Tag, w *writer) error {
 		w.StoreBytes(make([]byte, t.Padding))
 	}
 
-	// Calculate a CRC covering only the frames and padding, and store it into
-	// the extended header.
+	// Update the extended header's padding size.
+	if paddingOffset > -1 {
+		encodeUint32(w.SliceBuffer(paddingOffset, 4), uint32(t.Padding))
+	}
+
+	// Calculate a CRC covering only the frames, and store it into the
+	// extended header.
 	if crcOffset > -1 {
-		framesBuf := w.SliceBuffer(framesOffset, w.Len()-framesOffset)
+		framesBuf := w.SliceBuffer(framesOffset, w.Len()-framesOffset-t.Padding)
 		t.CRC = uint32(crc32.ChecksumIEEE(framesBuf))
 		crcBuf := w.SliceBuffer(crcOffset, 4)
 		encodeUint32(crcBuf, t.CRC)
@@ -347,8 +376,11 @@ func (c *codec23) Encode(t *Tag, w *writer) error {
 	sizeBuf := w.SliceBuffer(sizeOffset, 4)
 	encodeSyncSafeUint32(sizeBuf, uint32(t.Size))
 
-	// Save writer's buffer to the output stream.
-	_, err := w.Save()
+	// Save writer's buffer to the output stream, then report any frames
+	// that were skipped.
+	if _, err = w.Save(); err == nil && len(failed) > 0 {
+		err = failed
+	}
 	return err
 }
 
@@ -406,9 +438,21 @@ func (c *codec23) encodeFrame(t *Tag, f Frame, w *writer) error {
 		return err
 	}
 
-	// Update data length.
+	// Encode the embedded subframes following the frame's fields.
+	if sf := subframesOf(f); sf != nil {
+		for _, s := range *sf {
+			if err := c.encodeFrame(t, s, w); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Compress the payload and update the data length.
+	dl, err := packFrameData(t, h, w, payloadOffset)
+	if err != nil {
+		return err
+	}
 	if dataLengthOffset > -1 {
-		dl := w.Len() - payloadOffset
 		encodeUint32(w.SliceBuffer(dataLengthOffset, 4), uint32(dl))
 	}
 
@@ -418,7 +462,7 @@ func (c *codec23) encodeFrame(t *Tag, f Frame, w *writer) error {
 
 	// Update the header frame size.
 	h.Size = w.Len() - startOffset
-	encodeSyncSafeUint32(w.SliceBuffer(sizeOffset, 4), uint32(h.Size))
+	encodeUint32(w.SliceBuffer(sizeOffset, 4), uint32(h.Size))
 
 	return w.err
 }
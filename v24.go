@@ -1,6 +1,7 @@
 package id3
 
 import (
+	"bytes"
 	"hash/crc32"
 	"sync"
 )
@@ -46,81 +47,7 @@ func newCodec24() *codec24 {
 				"PictureType":      {0, 20, ErrInvalidPictureType},
 				"TimeStampFormat":  {1, 2, ErrInvalidTimeStampFormat},
 			},
-			frameTypes: newFrameTypeMap(map[FrameType]string{
-				FrameTypeAttachedPicture:              "APIC",
-				FrameTypeAudioEncryption:              "AENC",
-				FrameTypeAudioSeekPointIndex:          "ASPI",
-				FrameTypeComment:                      "COMM",
-				FrameTypeEncryptionMethodRegistration: "ENCR",
-				FrameTypeGroupID:                      "GRID",
-				FrameTypePlayCount:                    "PCNT",
-				FrameTypePopularimeter:                "POPM",
-				FrameTypePrivate:                      "PRIV",
-				FrameTypeLyricsSync:                   "SYLT",
-				FrameTypeSyncTempoCodes:               "SYTC",
-				FrameTypeTextAlbumName:                "TALB",
-				FrameTypeTextBPM:                      "TBPM",
-				FrameTypeTextCompilationItunes:        "TCMP",
-				FrameTypeTextComposer:                 "TCOM",
-				FrameTypeTextGenre:                    "TCON",
-				FrameTypeTextCopyright:                "TCOP",
-				FrameTypeTextEncodingTime:             "TDEN",
-				FrameTypeTextPlaylistDelay:            "TDLY",
-				FrameTypeTextOriginalReleaseTime:      "TDOR",
-				FrameTypeTextRecordingTime:            "TDRC",
-				FrameTypeTextReleaseTime:              "TDRL",
-				FrameTypeTextTaggingTime:              "TDTG",
-				FrameTypeTextEncodedBy:                "TENC",
-				FrameTypeTextLyricist:                 "TEXT",
-				FrameTypeTextFileType:                 "TFLT",
-				FrameTypeTextInvolvedPeople:           "TIPL",
-				FrameTypeTextGroupDescription:         "TIT1",
-				FrameTypeTextSongTitle:                "TIT2",
-				FrameTypeTextSongSubtitle:             "TIT3",
-				FrameTypeTextMusicalKey:               "TKEY",
-				FrameTypeTextLanguage:                 "TLAN",
-				FrameTypeTextLengthInMs:               "TLEN",
-				FrameTypeTextMusicians:                "TMCL",
-				FrameTypeTextMediaType:                "TMED",
-				FrameTypeTextMood:                     "TMOO",
-				FrameTypeTextOriginalAlbum:            "TOAL",
-				FrameTypeTextOriginalFileName:         "TOFN",
-				FrameTypeTextOriginalLyricist:         "TOLY",
-				FrameTypeTextOriginalPerformer:        "TOPE",
-				FrameTypeTextOwner:                    "TOWN",
-				FrameTypeTextArtist:                   "TPE1",
-				FrameTypeTextAlbumArtist:              "TPE2",
-				FrameTypeTextConductor:                "TPE3",
-				FrameTypeTextRemixer:                  "TPE4",
-				FrameTypeTextPartOfSet:                "TPOS",
-				FrameTypeTextProducedNotice:           "TPRO",
-				FrameTypeTextPublisher:                "TPUB",
-				FrameTypeTextTrackNumber:              "TRCK",
-				FrameTypeTextRadioStation:             "TRSN",
-				FrameTypeTextRadioStationOwner:        "TRSO",
-				FrameTypeTextAlbumSortOrderItunes:     "TSO2",
-				FrameTypeTextAlbumSortOrder:           "TSOA",
-				FrameTypeTextComposerSortOrderItunes:  "TSOC",
-				FrameTypeTextPerformerSortOrder:       "TSOP",
-				FrameTypeTextTitleSortOrder:           "TSOT",
-				FrameTypeTextISRC:                     "TSRC",
-				FrameTypeTextEncodingSoftware:         "TSSE",
-				FrameTypeTextSetSubtitle:              "TSST",
-				FrameTypeTextCustom:                   "TXXX",
-				FrameTypeUniqueFileID:                 "UFID",
-				FrameTypeTermsOfUse:                   "USER",
-				FrameTypeLyricsUnsync:                 "USLT",
-				FrameTypeURLCommercial:                "WCOM",
-				FrameTypeURLCopyright:                 "WCOP",
-				FrameTypeURLAudioFile:                 "WOAF",
-				FrameTypeURLArtist:                    "WOAR",
-				FrameTypeURLAudioSource:               "WOAS",
-				FrameTypeURLRadioStation:              "WORS",
-				FrameTypeURLPayment:                   "WPAY",
-				FrameTypeURLPublisher:                 "WPUB",
-				FrameTypeURLCustom:                    "WXXX",
-				FrameTypeUnknown:                      "ZZZZ",
-			}),
+			frameTypes: newFrameTypeMap(Version2_4),
 		}
 	})
 
@@ -128,14 +55,14 @@ func newCodec24() *codec24 {
 }
 
 // Decode decodes an ID3 v2.4 tag.
-func (c *codec24) Decode(t *Tag, r *reader) error {
+func (c *codec24) Decode(t *Tag, r *reader, opts *DecodeOptions) error {
 	// Load the remaining six bytes of the tag header.
 	if r.Load(6); r.err != nil {
 		return r.err
 	}
 
 	// Decode the header.
-	hdr := r.ConsumeBytes(10)
+	hdr := append([]byte{}, r.ConsumeBytes(10)...)
 	if hdr[4] != 0 {
 		return ErrInvalidTag
 	}
@@ -152,8 +79,9 @@ func (c *codec24) Decode(t *Tag, r *reader) error {
 	t.Size = int(size)
 
 	// Load the rest of the tag into the reader's buffer.
-	if r.Load(t.Size); r.err != nil {
-		return r.err
+	truncated, err := opts.loadTag(r, t)
+	if err != nil {
+		return err
 	}
 
 	// Remove unsync codes.
@@ -163,7 +91,10 @@ func (c *codec24) Decode(t *Tag, r *reader) error {
 	}
 
 	// Decode the extended header.
+	var exHdr []byte
+	t.ExtendedUnknown = nil
 	if (t.Flags & TagFlagExtended) != 0 {
+		exStart := r.Bytes()
 		exSize, err := decodeSyncSafeUint32(r.ConsumeBytes(4))
 		if err != nil {
 			return err
@@ -185,7 +116,9 @@ func (c *codec24) Decode(t *Tag, r *reader) error {
 			exBytesConsumed++
 		}
 
+		crcAt := -1
 		if (t.Flags & TagFlagHasCRC) != 0 {
+			crcAt = len(exStart) - r.Len() + 1
 			data := r.ConsumeBytes(6)
 			if data[0] != 5 {
 				return ErrInvalidHeader
@@ -205,21 +138,28 @@ func (c *codec24) Decode(t *Tag, r *reader) error {
 			exBytesConsumed += 2
 		}
 
-		// Consume and ignore any remaining bytes in the extended header.
+		// Retain any remaining bytes in the extended header.
 		if exBytesConsumed < int(exSize) {
-			r.ConsumeBytes(int(exSize) - exBytesConsumed)
+			b := r.ConsumeBytes(int(exSize) - exBytesConsumed)
+			t.ExtendedUnknown = append([]byte{}, b...)
 		}
 
 		if r.err != nil {
 			return r.err
 		}
+
+		// Keep a copy of the extended header, without its CRC, for CRC
+		// compatibility checks.
+		exHdr = append([]byte{}, exStart[:len(exStart)-r.Len()]...)
+		if crcAt > -1 {
+			copy(exHdr[crcAt:crcAt+5], make([]byte, 5))
+		}
 	}
 
 	// Validate the CRC.
-	if (t.Flags & TagFlagHasCRC) != 0 {
-		crc := crc32.ChecksumIEEE(r.Bytes())
-		if crc != t.CRC {
-			return ErrFailedCRC
+	if (t.Flags&TagFlagHasCRC) != 0 && !truncated {
+		if err := opts.checkCRC(t.CRC, CRCFramesPadding, exHdr, r.Bytes(), -1); err != nil {
+			return err
 		}
 	}
 
@@ -227,25 +167,57 @@ func (c *codec24) Decode(t *Tag, r *reader) error {
 	// encountered.
 	for r.Len() > 0 {
 		var f Frame
-		err = c.decodeFrame(t, &f, r)
+		offset, b := 10+t.Size-r.Len(), r.Bytes()
+		err = c.decodeFrame(t, &f, r, opts)
 
 		if err == errPaddingEncountered {
+			// In lenient mode, recover frames that buggy writers store
+			// after a run of padding.
+			if i := c.vdata.frameTypes.FrameAfterPadding(r.Bytes(), 4); opts.Lenient && i >= 0 {
+				opts.repair("", "recovered frames stored after padding")
+				r.ConsumeBytes(i)
+				continue
+			}
+
 			t.Padding = r.Len() + 4
 			r.ConsumeAll()
 			break
 		}
 
+		// In lenient mode, drop a frame cut short by the end of a
+		// truncated tag.
+		if err != nil && truncated {
+			opts.repair("", "dropped frame cut short by the end of the tag")
+			r.ConsumeAll()
+			break
+		}
+
 		if err != nil {
+			opts.fail(offset, b, 4, err)
 			return err
 		}
 
+		opts.capture(f, Version2_4, b[:len(b)-r.Len()])
+		opts.deferPicture(f, r.Len())
 		t.Frames = append(t.Frames, f)
 	}
 
-	return nil
+	// Validate the footer, which must duplicate the header apart from its
+	// identifier.
+	if (t.Flags&TagFlagFooter) != 0 && !truncated {
+		if r.Load(10); r.err != nil {
+			return r.err
+		}
+		ftr := r.ConsumeBytes(10)
+		if ftr[0] != '3' || ftr[1] != 'D' || ftr[2] != 'I' || !bytes.Equal(ftr[3:], hdr[3:]) {
+			return ErrInvalidFooter
+		}
+	}
+
+	return opts.verifySignatures(t)
 }
 
-func (c *codec24) decodeFrame(t *Tag, f *Frame, r *reader) error {
+func (c *codec24) decodeFrame(t *Tag, f *Frame, r *reader, opts *DecodeOptions) error {
 	// Read the first four bytes of the frame header data to see if it's
 	// padding.
 	id := r.ConsumeBytes(4)
@@ -274,9 +246,20 @@ func (c *codec24) decodeFrame(t *Tag, f *Frame, r *reader) error {
 	// Decode the frame flags.
 	flags := c.vdata.frameFlags.Decode(uint32(hd[4])<<8 | uint32(hd[5]))
 
+	// Repair malformed frame IDs in lenient mode.
+	frameID := string(id)
+	if opts.Lenient {
+		if _, ok := c.vdata.frameTypes.FrameIDToFrameType[frameID]; !ok {
+			if fixed, ok := c.vdata.frameTypes.RepairFrameID(frameID); ok {
+				opts.repair(frameID, "frame ID repaired to "+fixed)
+				frameID = fixed
+			}
+		}
+	}
+
 	// Start bulding the frame header.
 	h := FrameHeader{
-		FrameID: string(id),
+		FrameID: frameID,
 		Size:    int(size),
 		Flags:   FrameFlags(flags),
 	}
@@ -332,6 +315,24 @@ func (c *codec24) decodeFrame(t *Tag, f *Frame, r *reader) error {
 		}
 	}
 
+	// Decrypt and decompress the payload and validate its data length.
+	// Frames that can't be decrypted are decoded as opaque unknown frames.
+	if err := unpackFrameData(t, &h, r, opts); err == errUndecryptable {
+		*f = opaqueFrame(&h, r)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Look up the frame type.
+	h.FrameType = c.vdata.frameTypes.LookupFrameType(h.FrameID)
+	if h.FrameType == FrameTypeUnknown {
+		opts.unknown(h.FrameID)
+	}
+
+	// Detect and repair strings written with inconsistent encodings.
+	repairMixedEncodings(&h, r, opts)
+
 	// Use a reflector to scan the frame's fields.
 	rf := newReflector(Version2_4, c.vdata)
 	*f, err = rf.ScanFrame(r, h.FrameID)
@@ -339,16 +340,29 @@ func (c *codec24) decodeFrame(t *Tag, f *Frame, r *reader) error {
 		return err
 	}
 
-	// Update the frame type.
-	h.FrameType = rf.vdata.frameTypes.LookupFrameType(h.FrameID)
-
 	// Copy the header into the frame.
 	rf.SetFrameHeader(*f, &h)
+
+	// Decode the subframes embedded in the remainder of the payload.
+	if sf := subframesOf(*f); sf != nil {
+		for r.Len() > 0 {
+			var s Frame
+			err = c.decodeFrame(t, &s, r, opts)
+			if err == errPaddingEncountered {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			*sf = append(*sf, s)
+		}
+	}
 	return nil
 }
 
-func (c *codec24) Encode(t *Tag, w *writer) error {
-	if (t.Flags & (TagFlagHasCRC | TagFlagHasRestrictions | TagFlagIsUpdate)) != 0 {
+func (c *codec24) Encode(t *Tag, w *writer, opts *EncodeOptions) error {
+	exUnknown := opts.extendedUnknown(t)
+	if (t.Flags&(TagFlagHasCRC|TagFlagHasRestrictions|TagFlagIsUpdate)) != 0 || len(exUnknown) > 0 {
 		t.Flags |= TagFlagExtended
 	}
 
@@ -378,9 +392,11 @@ func (c *codec24) Encode(t *Tag, w *writer) error {
 		}
 
 		if (t.Flags & TagFlagHasRestrictions) != 0 {
-			w.StoreBytes([]byte{1, t.Restrictions})
+			w.StoreBytes([]byte{1, byte(t.Restrictions)})
 		}
 
+		w.StoreBytes(exUnknown)
+
 		// Update the extended header size.
 		exSize := w.Len() - exHdrOffset
 		encodeSyncSafeUint32(w.SliceBuffer(exHdrOffset, 4), uint32(exSize))
@@ -388,13 +404,16 @@ func (c *codec24) Encode(t *Tag, w *writer) error {
 
 	// Encode the frames.
 	framesOffset := w.Len()
-	for _, f := range t.Frames {
-		if err := c.encodeFrame(t, f, w); err != nil {
-			return err
-		}
+	failed, err := opts.encodeFrames(t, w, c.vdata.frameTypes, c.encodeFrame)
+	if err != nil {
+		return err
 	}
+	framesEnd := w.Len()
 
-	// Add padding.
+	// Add padding. Tags with a footer may not contain padding.
+	if (t.Flags & TagFlagFooter) != 0 {
+		t.Padding = 0
+	}
 	if t.Padding > 0 {
 		if t.Padding < 4 {
 			t.Padding = 4 // must be at least 4 bytes.
@@ -422,8 +441,26 @@ func (c *codec24) Encode(t *Tag, w *writer) error {
 	sizeBuf := w.SliceBuffer(sizeOffset, 4)
 	encodeSyncSafeUint32(sizeBuf, uint32(t.Size))
 
-	// Save writer's buffer to the output stream.
-	_, err := w.Save()
+	// Store the footer, which duplicates the header apart from its
+	// identifier.
+	if (t.Flags & TagFlagFooter) != 0 {
+		ftr := append([]byte{'3', 'D', 'I'}, w.SliceBuffer(3, 7)...)
+		w.StoreBytes(ftr)
+	}
+
+	// Enforce the tag's restrictions on the tag as encoded.
+	if (t.Flags&TagFlagHasRestrictions) != 0 && !opts.unrestricted {
+		frames, _ := SplitFrames(w.SliceBuffer(framesOffset, framesEnd-framesOffset), Version2_4)
+		if err := Restrictions(t.Restrictions).check(t, len(frames), w.Len()); err != nil {
+			return err
+		}
+	}
+
+	// Save writer's buffer to the output stream, then report any frames
+	// that were skipped.
+	if _, err = w.Save(); err == nil && len(failed) > 0 {
+		err = failed
+	}
 	return err
 }
 
@@ -484,9 +521,21 @@ func (c *codec24) encodeFrame(t *Tag, f Frame, w *writer) error {
 		return err
 	}
 
-	// Update data length.
+	// Encode the embedded subframes following the frame's fields.
+	if sf := subframesOf(f); sf != nil {
+		for _, s := range *sf {
+			if err := c.encodeFrame(t, s, w); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Compress the payload and update the data length.
+	dl, err := packFrameData(t, h, w, payloadOffset)
+	if err != nil {
+		return err
+	}
 	if dataLengthOffset > -1 {
-		dl := w.Len() - payloadOffset
 		encodeSyncSafeUint32(w.SliceBuffer(dataLengthOffset, 4), uint32(dl))
 	}
 
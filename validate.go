@@ -0,0 +1,288 @@
+package id3
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+)
+
+// Severity describes how serious a validation issue is.
+type Severity int
+
+// All possible Severity values.
+const (
+	SeverityWarning Severity = iota // the tag is valid but may be misread
+	SeverityError                   // the tag violates the ID3 specification
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// A ValidationIssue describes a problem found by Tag.Validate.
+type ValidationIssue struct {
+	Severity Severity // seriousness of the problem
+	Index    int      // index of the frame within the tag, or -1 for the tag
+	Offset   int      // offset of the frame within the encoded tag, or -1
+	FrameID  string   // ID of the frame, or empty for the tag
+	Message  string   // description of the problem
+}
+
+func (i ValidationIssue) String() string {
+	if i.Index < 0 {
+		return fmt.Sprintf("%s: tag: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: frame %d (%s) at offset %d: %s", i.Severity, i.Index, i.FrameID, i.Offset, i.Message)
+}
+
+// timestampFrameTypes lists the frame types holding v2.4 timestamps.
+var timestampFrameTypes = map[FrameType]bool{
+	FrameTypeTextEncodingTime:        true,
+	FrameTypeTextOriginalReleaseTime: true,
+	FrameTypeTextRecordingTime:       true,
+	FrameTypeTextReleaseTime:         true,
+	FrameTypeTextTaggingTime:         true,
+}
+
+// Validate checks the tag against the ID3 specification of its version and
+// returns the problems found, in frame order. It checks:
+//
+//   - that tag flags, frame types and frame IDs are defined by the version;
+//   - that text encodings are defined by the version;
+//   - that language codes consist of three ISO-639-2 letters;
+//   - the syntax of timestamps, years, dates and times;
+//   - the consistency of frame flags, such as v2.4 compressed frames lacking
+//     a data length indicator or grouped frames with no group registration;
+//   - that the tag and its frames can be encoded within the limits of the
+//     size fields of the version.
+//
+// Frame offsets are measured from the start of the tag, as encoded with the
+// default options before any unsynchronization. Frames added by the tag's
+// high-level setters, such as SetComment, are checked with the language and
+// text encoding selected for them by the default options. The tag is not
+// modified.
+func (t *Tag) Validate() []ValidationIssue {
+	vdata, err := versionDataOf(t.Version)
+	if err != nil {
+		return []ValidationIssue{{SeverityError, -1, -1, "", err.Error()}}
+	}
+
+	var issues []ValidationIssue
+	tagIssue := func(sev Severity, format string, args ...interface{}) {
+		issues = append(issues, ValidationIssue{sev, -1, -1, "", fmt.Sprintf(format, args...)})
+	}
+
+	supported := vdata.headerFlags.Decode(vdata.headerFlags.Encode(uint32(t.Flags))) |
+		vdata.headerExFlags.Decode(vdata.headerExFlags.Encode(uint32(t.Flags)))
+	if unsupported := t.Flags &^ TagFlags(supported); unsupported != 0 {
+		tagIssue(SeverityError, "tag flags %#x not defined by ID3v2.%d", uint32(unsupported), t.Version)
+	}
+	if (t.Flags&TagFlagFooter) != 0 && t.Padding > 0 {
+		tagIssue(SeverityError, "%s", ErrPaddingNotAllowed)
+	}
+	if (t.Flags&TagFlagHasRestrictions) == 0 && t.Restrictions != 0 {
+		tagIssue(SeverityWarning, "restrictions are set but not flagged")
+	}
+
+	// Encode a copy of the tag one frame at a time to measure the frames'
+	// offsets and find frames that fail to encode. The languages and
+	// encodings of the copy's frames are checked, since their defaults have
+	// been applied.
+	c := t.Clone()
+	offsets, errs, tagErr := c.encodeEach()
+	if tagErr != nil {
+		tagIssue(SeverityError, "%v", tagErr)
+	}
+
+	groups := make(map[uint8]bool)
+	for _, f := range t.Frames {
+		if g, ok := f.(*FrameGroupID); ok {
+			groups[g.GroupID] = true
+		}
+	}
+
+	for i, f := range t.Frames {
+		id := frameIDOf(vdata.frameTypes, f)
+		add := func(sev Severity, format string, args ...interface{}) {
+			issues = append(issues, ValidationIssue{sev, i, offsets[i], id, fmt.Sprintf(format, args...)})
+		}
+
+		h := HeaderOf(f)
+		if _, ok := f.(*FrameUnknown); ok {
+			if !validFrameID(id, t.Version) {
+				add(SeverityError, "invalid frame ID %q", id)
+			}
+		} else if _, ok := vdata.frameTypes.FrameTypeToFrameID[h.FrameType]; !ok {
+			add(SeverityError, "frame type not defined by ID3v2.%d", t.Version)
+		}
+
+		switch {
+		case t.Version >= Version2_4 && (h.Flags&FrameFlagCompressed) != 0 && (h.Flags&FrameFlagHasDataLength) == 0:
+			add(SeverityError, "compressed frame lacks a data length indicator")
+		case (h.Flags&FrameFlagHasDataLength) == 0 && h.DataLength != 0:
+			add(SeverityWarning, "data length is set but not flagged")
+		}
+		if (h.Flags&FrameFlagHasGroupID) != 0 && !groups[h.GroupID] {
+			add(SeverityWarning, "group %#x has no group registration (GRID) frame", h.GroupID)
+		}
+		if (h.Flags&FrameFlagEncrypted) != 0 && t.encryption[h.EncryptMethod] == nil {
+			add(SeverityWarning, "no codec registered for encryption method %#x", h.EncryptMethod)
+		}
+
+		v := reflect.ValueOf(c.Frames[i]).Elem()
+		if enc := v.FieldByName("Encoding"); enc.IsValid() {
+			switch e := Encoding(enc.Uint()); {
+			case e > EncodingUTF8:
+				add(SeverityError, "invalid text encoding %d", e)
+			case e > EncodingUTF16BOM && t.Version < Version2_4:
+				add(SeverityWarning, "text encoding %d not defined by ID3v2.%d", e, t.Version)
+			}
+		}
+		if lang := v.FieldByName("Language"); lang.IsValid() {
+			if sev, msg := checkLanguage(lang.String()); msg != "" {
+				add(sev, "%s", msg)
+			}
+		}
+		if tf, ok := f.(*FrameText); ok {
+			for _, s := range tf.Text {
+				if msg := checkTime(h.FrameType, s, t.Version); msg != "" {
+					add(SeverityError, "%s", msg)
+				}
+			}
+		}
+
+		// Report encoding failures not explained by the errors above.
+		if errs[i] != nil && !hasError(issues, i) {
+			add(SeverityError, "%v", errs[i])
+		}
+	}
+	return issues
+}
+
+// hasError returns true if the issues include an error affecting the i-th
+// frame.
+func hasError(issues []ValidationIssue, i int) bool {
+	for _, is := range issues {
+		if is.Index == i && is.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeEach encodes the tag's frames one at a time, returning the offset
+// of each frame within the encoded tag and the error encountered encoding
+// it, if any. It also returns any error affecting the whole tag.
+func (t *Tag) encodeEach() (offsets []int, errs []error, tagErr error) {
+	c, err := newCodec(t.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	fc := c.(frameCodec)
+
+	// Measure the headers preceding the frames using an empty tag.
+	frames := t.Frames
+	t.Frames = nil
+	opts := &EncodeOptions{unrestricted: true}
+	buf := bytes.NewBuffer([]byte{})
+	if err := c.Encode(t, newWriter(buf), opts); err != nil {
+		tagErr = err
+	}
+	t.Frames = frames
+	off := 10
+	if b := buf.Bytes(); len(b) >= 10 {
+		off += extendedHeaderLen(b, t.Version)
+	}
+
+	opts.applyDefaults(t)
+	offsets = make([]int, len(t.Frames))
+	errs = make([]error, len(t.Frames))
+	for i, f := range t.Frames {
+		offsets[i] = off
+		w := newWriter(ioutil.Discard)
+		for _, p := range opts.expandFrame(t, f) {
+			if errs[i] = fc.encodeFrame(t, p, w); errs[i] != nil {
+				break
+			}
+		}
+		if errs[i] == nil {
+			off += w.Len()
+		}
+	}
+
+	if tagErr == nil && off > 0x0fffffff {
+		tagErr = fmt.Errorf("tag size %d exceeds the maximum of %d bytes", off, 0x0fffffff)
+	}
+	return offsets, errs, tagErr
+}
+
+// validFrameID returns true if a frame ID has the length required by the
+// version and consists of uppercase letters and digits.
+func validFrameID(id string, v Version) bool {
+	n := 4
+	if v == Version2_2 {
+		n = 3
+	}
+	if len(id) != n {
+		return false
+	}
+	for _, c := range id {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// checkLanguage returns the severity and description of the problem with
+// an ISO-639-2 language code, or an empty description if it is valid.
+func checkLanguage(lang string) (Severity, string) {
+	if len(lang) != 3 {
+		return SeverityError, fmt.Sprintf("language code %q is not three letters long", lang)
+	}
+	for _, c := range lang {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return SeverityWarning, fmt.Sprintf("language code %q contains characters other than letters", lang)
+		}
+	}
+	if strings.ToLower(lang) != lang && lang != LanguageUnknown {
+		return SeverityWarning, fmt.Sprintf("language code %q is not lowercase", lang)
+	}
+	return SeverityWarning, ""
+}
+
+// checkTime returns a description of the problem with a timestamp, year,
+// date or time stored in a text frame, or an empty string if it is valid or
+// the frame doesn't hold one.
+func checkTime(typ FrameType, s string, v Version) string {
+	switch {
+	case v >= Version2_4 && timestampFrameTypes[typ]:
+		if _, _, ok := parseTimestamp(s); !ok {
+			return fmt.Sprintf("invalid timestamp %q", s)
+		}
+	case v < Version2_4 && typ == FrameTypeTextRecordingTime:
+		// Recording timestamps are split into year, date and time frames
+		// when encoded.
+		if _, _, ok := parseTimestamp(s); !ok {
+			return fmt.Sprintf("invalid year %q", s)
+		}
+	case v < Version2_4 && typ == FrameTypeTextOriginalReleaseTime:
+		if _, ok := digits([]string{s}, 4); !ok {
+			return fmt.Sprintf("invalid year %q", s)
+		}
+	case typ == FrameTypeTextDate:
+		if d, ok := digits([]string{s}, 4); !ok || d[:2] < "01" || d[:2] > "31" || d[2:] < "01" || d[2:] > "12" {
+			return fmt.Sprintf("invalid date %q, must be of the form DDMM", s)
+		}
+	case typ == FrameTypeTextTime:
+		if d, ok := digits([]string{s}, 4); !ok || d[:2] > "23" || d[2:] > "59" {
+			return fmt.Sprintf("invalid time %q, must be of the form HHMM", s)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,121 @@
+package id3
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+)
+
+// verifyEncoding decodes an encoded tag and compares it with the tag it was
+// encoded from, returning a VerifyError if they differ. Frames whose indices
+// are in skip were left out by the encoder and are excluded from the
+// comparison.
+func verifyEncoding(t *Tag, b []byte, skip map[int]bool) error {
+	// Timestamps split into several frames by the encoder must be combined
+	// again.
+	opts := &DecodeOptions{}
+	if f := t.FindFrame(FrameTypeTextRecordingTime); f != nil && splitDate(t, f) != nil {
+		opts.CombineDates = true
+	}
+
+	t2 := &Tag{encryption: t.encryption}
+	if _, err := t2.ReadFromWithOptions(bytes.NewReader(b), opts); err != nil {
+		return VerifyError{{Index: -1, Field: "Tag", Want: "a valid tag", Got: err.Error()}}
+	}
+
+	var m VerifyError
+	if t2.Version != t.Version {
+		want, got := fmt.Sprintf("v2.%d", t.Version), fmt.Sprintf("v2.%d", t2.Version)
+		m = append(m, VerifyMismatch{-1, "", "Version", want, got})
+	}
+
+	var idx []int
+	for i := range t.Frames {
+		if !skip[i] {
+			idx = append(idx, i)
+		}
+	}
+	if len(idx) != len(t2.Frames) {
+		want, got := fmt.Sprintf("%d frames", len(idx)), fmt.Sprintf("%d frames", len(t2.Frames))
+		m = append(m, VerifyMismatch{-1, "", "Frames", want, got})
+	}
+
+	for j, i := range idx {
+		if j >= len(t2.Frames) {
+			break
+		}
+		f := t.Frames[i]
+		id := HeaderOf(t2.Frames[j]).FrameID
+		for _, d := range compareFrames("", f, t2.Frames[j]) {
+			d.Index, d.FrameID = i, id
+			m = append(m, d)
+		}
+	}
+
+	if len(m) > 0 {
+		return m
+	}
+	return nil
+}
+
+// compareFrames returns the differences between the exported fields of two
+// frames, other than their headers. Subframes are compared recursively.
+func compareFrames(prefix string, want, got Frame) []VerifyMismatch {
+	if reflect.TypeOf(want) != reflect.TypeOf(got) {
+		return []VerifyMismatch{{Field: prefix + "Type", Want: typeName(want), Got: typeName(got)}}
+	}
+
+	var m []VerifyMismatch
+	wv, gv := reflect.ValueOf(want).Elem(), reflect.ValueOf(got).Elem()
+	for i := 0; i < wv.NumField(); i++ {
+		sf := wv.Type().Field(i)
+		if sf.PkgPath != "" || sf.Name == "Header" {
+			continue
+		}
+		name := prefix + sf.Name
+		w, g := wv.Field(i), gv.Field(i)
+
+		// Compare lazily decoded picture data with the data in the source.
+		if p, ok := want.(*FrameAttachedPicture); ok && sf.Name == "Data" && p.source != nil {
+			rc := p.Open()
+			b, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			w = reflect.ValueOf(b)
+		}
+
+		switch {
+		case w.Kind() == reflect.Slice && w.Type().Elem().Kind() == reflect.Interface:
+			if w.Len() != g.Len() {
+				m = append(m, VerifyMismatch{Field: name, Want: lenString(w), Got: lenString(g)})
+				continue
+			}
+			for j := 0; j < w.Len(); j++ {
+				sw, _ := w.Index(j).Interface().(Frame)
+				sg, _ := g.Index(j).Interface().(Frame)
+				m = append(m, compareFrames(fmt.Sprintf("%s[%d].", name, j), sw, sg)...)
+			}
+		case w.Kind() == reflect.Slice && w.Len() == 0 && g.Len() == 0:
+		case !reflect.DeepEqual(w.Interface(), g.Interface()):
+			m = append(m, VerifyMismatch{Field: name, Want: valueString(w), Got: valueString(g)})
+		}
+	}
+	return m
+}
+
+func typeName(f Frame) string {
+	return reflect.TypeOf(f).String()
+}
+
+func lenString(v reflect.Value) string {
+	return fmt.Sprintf("%d items", v.Len())
+}
+
+// valueString formats a field value for a mismatch description, eliding
+// the contents of long byte slices.
+func valueString(v reflect.Value) string {
+	if b, ok := v.Interface().([]byte); ok && len(b) > 16 {
+		return fmt.Sprintf("%x... (%d bytes)", b[:16], len(b))
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(v.Interface()))
+}
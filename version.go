@@ -1,5 +1,7 @@
 package id3
 
+import "runtime/debug"
+
 // Version defines the ID3 codec version (2.2, 2.3, or 2.4).
 type Version uint8
 
@@ -10,9 +12,37 @@ const (
 	Version2_4                    // v2.4
 )
 
+// modulePath is the import path of the module containing this package.
+const modulePath = "github.com/beevik/id3"
+
+// LibraryVersion returns the version of this package's module recorded in
+// the running binary's build information, such as "v1.2.0", or the empty
+// string if the version is unknown, as it is when the package isn't built
+// as a versioned module dependency.
+func LibraryVersion() string {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	mods := append([]*debug.Module{&bi.Main}, bi.Deps...)
+	for _, m := range mods {
+		if m.Path != modulePath {
+			continue
+		}
+		if m.Replace != nil {
+			m = m.Replace
+		}
+		if m.Version == "(devel)" {
+			return ""
+		}
+		return m.Version
+	}
+	return ""
+}
+
 type versionCodec interface {
-	Decode(t *Tag, r *reader) error
-	Encode(t *Tag, w *writer) error
+	Decode(t *Tag, r *reader, opts *DecodeOptions) error
+	Encode(t *Tag, w *writer, opts *EncodeOptions) error
 }
 
 type versionData struct {
@@ -22,3 +52,18 @@ type versionData struct {
 	bounds        boundsMap
 	frameTypes    *frameTypeMap
 }
+
+// versionDataOf returns the version-specific data used by the codec for an
+// ID3 version.
+func versionDataOf(v Version) (*versionData, error) {
+	switch v {
+	case Version2_2:
+		return newCodec22().vdata, nil
+	case Version2_3:
+		return newCodec23().vdata, nil
+	case Version2_4:
+		return newCodec24().vdata, nil
+	default:
+		return nil, ErrInvalidVersion
+	}
+}